@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// AdminNameCacheTTL is how long a name resolved for /admins is reused before
+// Telegram is asked again, so renamed admins show up eventually.
+const AdminNameCacheTTL = 24 * time.Hour
+
+// adminNameCache keeps the names of admins resolved via getChat. A nil
+// *adminNameCache keeps nothing.
+type adminNameCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[int64]adminNameEntry
+}
+
+type adminNameEntry struct {
+	name     string
+	resolved time.Time
+}
+
+func newAdminNameCache(ttl time.Duration) *adminNameCache {
+	return &adminNameCache{ttl: ttl, entries: make(map[int64]adminNameEntry)}
+}
+
+// Get returns the cached name of userID unless it is older than the TTL.
+func (c *adminNameCache) Get(userID int64, now time.Time) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[userID]
+	if !ok || now.Sub(e.resolved) > c.ttl {
+		return "", false
+	}
+	return e.name, true
+}
+
+func (c *adminNameCache) Put(userID int64, name string, now time.Time) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[userID] = adminNameEntry{name: name, resolved: now}
+}
+
+// adminDisplayName is how /admins shows a user: @username, or the name for
+// users without one.
+func adminDisplayName(chat tgbotapi.Chat) string {
+	if chat.UserName != "" {
+		return "@" + chat.UserName
+	}
+	if name := strings.TrimSpace(chat.FirstName + " " + chat.LastName); name != "" {
+		return name + " (no username)"
+	}
+	return "an admin without a username"
+}
+
+// adminName resolves the display name of userID via getChat, from the cache
+// when possible. Failed lookups are not cached.
+func (b *Bot) adminName(userID int64) (string, error) {
+	if name, ok := b.adminNames.Get(userID, time.Now()); ok {
+		return name, nil
+	}
+
+	resp, err := b.request(tgbotapi.ChatInfoConfig{ChatConfig: tgbotapi.ChatConfig{ChatID: userID}})
+	if err != nil {
+		return "", err
+	}
+	var chat tgbotapi.Chat
+	if err := json.Unmarshal(resp.Result, &chat); err != nil {
+		return "", fmt.Errorf("failed to decode chat %d: %w", userID, err)
+	}
+
+	name := adminDisplayName(chat)
+	b.adminNames.Put(userID, name, time.Now())
+	return name, nil
+}
+
+// handleAdminsCommand lists who to contact for help. With HIDE_ADMINS only
+// admins get the list.
+func (b *Bot) handleAdminsCommand(chatID, userID int64) {
+	if b.hideAdmins && !b.isUserAdmin(userID) {
+		b.sendTextMessage(chatID, "🙈 The admins of this bot are not listed.")
+		return
+	}
+
+	ids := b.adminIDs()
+	if len(ids) == 0 {
+		b.sendTextMessage(chatID, "👤 This bot has no admins.")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("👤 Admins of this bot:\n\n")
+	for _, id := range ids {
+		name, err := b.adminName(id)
+		if err != nil {
+			log.Printf("Failed to resolve admin %d: %v", id, err)
+			name = "an admin the bot cannot look up"
+		}
+		sb.WriteString("• " + name + "\n")
+	}
+	b.sendLongMessage(chatID, strings.TrimRight(sb.String(), "\n"))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// chatInfoAPI answers getChat from chats and counts the lookups.
+type chatInfoAPI struct {
+	*mockTelegramAPI
+	chats   map[int64]tgbotapi.Chat
+	lookups int
+}
+
+func (a *chatInfoAPI) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	info, ok := c.(tgbotapi.ChatInfoConfig)
+	if !ok {
+		return a.mockTelegramAPI.Request(c)
+	}
+	a.lookups++
+	chat, ok := a.chats[info.ChatID]
+	if !ok {
+		return nil, errors.New("Bad Request: chat not found")
+	}
+	data, err := json.Marshal(chat)
+	if err != nil {
+		return nil, err
+	}
+	return &tgbotapi.APIResponse{Ok: true, Result: data}, nil
+}
+
+func TestAdminsCommand(t *testing.T) {
+	b := newTestBot(t)
+	api := &chatInfoAPI{mockTelegramAPI: &mockTelegramAPI{}, chats: map[int64]tgbotapi.Chat{
+		1: {ID: 1, UserName: "alice"},
+		2: {ID: 2, FirstName: "Bob", LastName: "Builder"},
+		3: {ID: 3},
+	}}
+	b.client = api
+	b.adminNames = newAdminNameCache(AdminNameCacheTTL)
+	for _, id := range []int64{1, 2, 3, 4} {
+		b.adminUsers[id] = true
+	}
+	send := func(userID int64, text string) string {
+		t.Helper()
+		b.handleMessage(&tgbotapi.Message{From: &tgbotapi.User{ID: userID}, Chat: &tgbotapi.Chat{ID: userID}, Text: text})
+		texts := api.texts()
+		return texts[len(texts)-1]
+	}
+
+	want := "👤 Admins of this bot:\n\n• @alice\n• Bob Builder (no username)\n• an admin without a username\n• an admin the bot cannot look up"
+	if reply := send(42, "/admins"); reply != want {
+		t.Errorf("unexpected list %q", reply)
+	}
+	if api.lookups != 4 {
+		t.Errorf("expected 4 lookups, got %d", api.lookups)
+	}
+
+	// Resolved names are cached, failed lookups retried
+	if reply := send(42, "/whoisadmin"); reply != want {
+		t.Errorf("unexpected cached list %q", reply)
+	}
+	if api.lookups != 5 {
+		t.Errorf("expected only the failed lookup to be repeated, got %d lookups", api.lookups)
+	}
+
+	b.hideAdmins = true
+	if reply := send(42, "/admins"); reply != "🙈 The admins of this bot are not listed." {
+		t.Errorf("expected the admins to be hidden, got %q", reply)
+	}
+	if reply := send(1, "/admins"); reply != want {
+		t.Errorf("expected admins to still see the list, got %q", reply)
+	}
+}
+
+func TestAdminNameCacheExpires(t *testing.T) {
+	c := newAdminNameCache(time.Hour)
+	now := time.Now()
+	c.Put(1, "@alice", now)
+
+	if name, ok := c.Get(1, now.Add(59*time.Minute)); !ok || name != "@alice" {
+		t.Errorf("expected a cached name, got %q, %v", name, ok)
+	}
+	if _, ok := c.Get(1, now.Add(61*time.Minute)); ok {
+		t.Error("expected the name to expire")
+	}
+
+	var none *adminNameCache
+	none.Put(1, "@alice", now)
+	if _, ok := none.Get(1, now); ok {
+		t.Error("expected a nil cache to keep nothing")
+	}
+}
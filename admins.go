@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+)
+
+// applyAdminChanges applies the persisted promotions and demotions to admins.
+func applyAdminChanges(admins map[int64]bool, state BotState) {
+	for _, id := range state.PromotedAdmins {
+		admins[id] = true
+	}
+	for _, id := range state.DemotedAdmins {
+		delete(admins, id)
+	}
+}
+
+// errLastAdmin reports an attempt to demote the only admin.
+var errLastAdmin = errors.New("cannot demote the last admin")
+
+// setAdmin grants or revokes admin privileges and persists the change.
+// The admin set is replaced rather than mutated, since the status service
+// reads it from its own goroutine.
+func (b *Bot) setAdmin(userID int64, admin bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.usersMu.Lock()
+	defer b.usersMu.Unlock()
+
+	if !admin && b.adminUsers[userID] && len(b.adminUsers) == 1 {
+		return errLastAdmin
+	}
+
+	state := b.state
+	state.PromotedAdmins = removeID(state.PromotedAdmins, userID)
+	state.DemotedAdmins = removeID(state.DemotedAdmins, userID)
+	if admin {
+		state.PromotedAdmins = append(state.PromotedAdmins, userID)
+	} else {
+		state.DemotedAdmins = append(state.DemotedAdmins, userID)
+	}
+	if err := saveState(b.statePath(), state); err != nil {
+		return err
+	}
+	b.state = state
+
+	admins := make(map[int64]bool, len(b.adminUsers)+1)
+	for id := range b.adminUsers {
+		admins[id] = true
+	}
+	if admin {
+		admins[userID] = true
+	} else {
+		delete(admins, userID)
+	}
+	b.adminUsers = admins
+	if b.statusService != nil {
+		b.statusService.SetAdmins(admins)
+	}
+	return nil
+}
+
+// adminIDs returns the current admins in ascending order.
+func (b *Bot) adminIDs() []int64 {
+	b.usersMu.RLock()
+	defer b.usersMu.RUnlock()
+	return sortedIDs(b.adminUsers)
+}
+
+func sortedIDs(set map[int64]bool) []int64 {
+	ids := make([]int64, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func removeID(ids []int64, id int64) []int64 {
+	var kept []int64
+	for _, candidate := range ids {
+		if candidate != id {
+			kept = append(kept, candidate)
+		}
+	}
+	return kept
+}
+
+// handleAdminPromote grants (promote) or revokes (demote) admin privileges.
+// The last admin cannot be demoted, so the bot always keeps one.
+func (b *Bot) handleAdminPromote(chatID, adminID int64, args []string, promote bool) {
+	command := "demote"
+	if promote {
+		command = "promote"
+	}
+	if len(args) != 1 {
+		b.sendTextMessage(chatID, fmt.Sprintf("Usage: /admin %s <user_id>", command))
+		return
+	}
+
+	userID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		b.sendTextMessage(chatID, "❌ Invalid user ID format")
+		return
+	}
+
+	switch {
+	case promote && b.isUserAdmin(userID):
+		b.sendTextMessage(chatID, fmt.Sprintf("ℹ️ User %d is already an admin", userID))
+		return
+	case !promote && !b.isUserAdmin(userID):
+		b.sendTextMessage(chatID, fmt.Sprintf("ℹ️ User %d is not an admin", userID))
+		return
+	}
+
+	err = b.setAdmin(userID, promote)
+	if errors.Is(err, errLastAdmin) {
+		b.sendTextMessage(chatID, "🚫 Cannot demote the last admin. Promote another admin first.")
+		return
+	}
+	if err != nil {
+		log.Printf("Error saving admin change for user %d: %v", userID, err)
+		b.sendTextMessage(chatID, "❌ Failed to save the admin change.")
+		return
+	}
+	b.setAdminMenu(userID, promote)
+
+	if !promote {
+		b.sendTextMessage(chatID, fmt.Sprintf("✅ User %d is no longer an admin", userID))
+		log.Printf("Admin %d demoted user %d", adminID, userID)
+		return
+	}
+
+	reply := fmt.Sprintf("✅ User %d is now an admin", userID)
+	if !b.isUserAllowed(userID) {
+		reply += fmt.Sprintf("\n⚠️ They are not in the allowed list yet; add them with /admin add %d", userID)
+	}
+	b.sendTextMessage(chatID, reply)
+	log.Printf("Admin %d promoted user %d", adminID, userID)
+}
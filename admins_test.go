@@ -0,0 +1,132 @@
+package main
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestAdminPromoteAndDemote(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+	b.adminUsers = map[int64]bool{1: true}
+
+	b.handleAdminPromote(1, 1, []string{"42"}, true)
+	if !b.isUserAdmin(42) {
+		t.Fatal("expected user 42 to be promoted")
+	}
+
+	b.handleAdminPromote(1, 1, []string{"1"}, false)
+	if b.isUserAdmin(1) || !b.isUserAdmin(42) {
+		t.Fatalf("expected user 1 to be demoted, admins: %v", b.adminIDs())
+	}
+
+	// Changes survive a restart on top of the configured admins
+	state, err := loadState(filepath.Join(b.storagePath, stateFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := map[int64]bool{1: true, 7: true}
+	applyAdminChanges(restored, state)
+	if len(restored) != 2 || !restored[7] || !restored[42] || restored[1] {
+		t.Errorf("unexpected admins after restart: %v", restored)
+	}
+}
+
+func TestAdminDemoteLastAdminRefused(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+	b.adminUsers = map[int64]bool{1: true}
+
+	b.handleAdminPromote(1, 1, []string{"1"}, false)
+	if !b.isUserAdmin(1) {
+		t.Fatal("expected the last admin to remain an admin")
+	}
+	if texts := api.texts(); len(texts) != 1 || !strings.Contains(texts[0], "Cannot demote the last admin") {
+		t.Errorf("unexpected reply %q", texts)
+	}
+}
+
+// TestUserAccessConcurrent is meant for -race: admin commands change the user
+// sets while other handlers check them.
+func TestUserAccessConcurrent(t *testing.T) {
+	b := newTestBot(t)
+	b.client = &mockTelegramAPI{}
+	b.adminUsers = map[int64]bool{1: true}
+
+	var wg sync.WaitGroup
+	for i := int64(0); i < 4; i++ {
+		wg.Add(2)
+		go func(id int64) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				b.addUser(100 + id)
+				b.removeUser(100 + id)
+				b.handleAdminAddUser(1, strconv.FormatInt(200+id, 10))
+			}
+		}(i)
+		go func(id int64) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				b.isUserAllowed(100 + id)
+				b.isUserAdmin(1)
+				b.allowedUserIDs()
+				b.adminIDs()
+			}
+		}(i)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		b.handleAdminPromote(1, 1, []string{"2"}, true)
+		b.handleAdminPromote(1, 1, []string{"2"}, false)
+	}()
+	wg.Wait()
+
+	if ids := b.allowedUserIDs(); len(ids) != 4 || ids[0] != 200 {
+		t.Errorf("unexpected allowed users %v", ids)
+	}
+}
+
+func TestAdminAddGreetsUser(t *testing.T) {
+	b := newTestBot(t)
+	blocked := map[int64]bool{43: true}
+	api := &mockTelegramAPI{sendErr: func(_ int, c tgbotapi.Chattable) error {
+		if msg, ok := c.(tgbotapi.MessageConfig); ok && blocked[msg.ChatID] {
+			return &tgbotapi.Error{Code: 403, Message: "Forbidden: bot can't initiate conversation with a user"}
+		}
+		return nil
+	}}
+	b.client = api
+	b.adminUsers = map[int64]bool{1: true}
+	b.allowedUsers = map[int64]bool{1: true}
+	b.welcomeMessage = "Welcome aboard!"
+
+	b.handleAdminAddUser(1, "42")
+	if len(api.sent) != 2 {
+		t.Fatalf("expected a greeting and a reply, got %d messages", len(api.sent))
+	}
+	greeting := api.sent[0].(tgbotapi.MessageConfig)
+	if greeting.ChatID != 42 || !strings.HasPrefix(greeting.Text, "Welcome aboard!\n\n📖 Available Commands:") || strings.Contains(greeting.Text, "/admin -") {
+		t.Errorf("unexpected greeting to %d: %q", greeting.ChatID, greeting.Text)
+	}
+	if reply := api.texts()[1]; reply != "✅ User 42 added to allowed list and told so" {
+		t.Errorf("unexpected reply %q", reply)
+	}
+
+	// A user who never started the bot cannot be messaged
+	b.handleAdminAddUser(1, "43")
+	if !b.isUserAllowed(43) {
+		t.Fatal("expected user 43 to be added regardless")
+	}
+	texts := api.texts()
+	if reply := texts[len(texts)-1]; !strings.Contains(reply, "added to allowed list, but they could not be told") || !strings.Contains(reply, "/start") {
+		t.Errorf("unexpected reply for an unreachable user %q", reply)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// isTextMIME reports whether a MIME type describes text.
+func isTextMIME(mimeType string) bool {
+	return strings.HasPrefix(strings.ToLower(mimeType), "text/")
+}
+
+// appendsDuplicates reports whether a document of mimeType may be appended to
+// an existing file of the same name under the append collision strategy
+// (APPEND_ON_DUPLICATE or /setcollision append). Compressed and encrypted
+// files cannot be appended to in place.
+func (b *Bot) appendsDuplicates(strategy, mediaType, mimeType string, compress bool) bool {
+	if strategy != CollisionAppend || mediaType != "documents" || !isTextMIME(mimeType) || compress || b.cipher != nil {
+		return false
+	}
+	_, ok := b.storage.(*LocalStorage)
+	return ok
+}
+
+// hardlinkCount returns how many directory entries share the file of info.
+func hardlinkCount(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Nlink)
+	}
+	return 1
+}
+
+// copyOnWrite gives the file at path content of its own when it is
+// hardlinked elsewhere, so changing it in place changes no other stored file.
+// It reports whether it made a copy.
+func copyOnWrite(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	if hardlinkCount(info) <= 1 {
+		return false, nil
+	}
+
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".cow")
+	os.Remove(tmp)
+	if err := copyFile(path, tmp); err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+	return true, nil
+}
+
+// appendTooLarge is the error for an append that would take a stored file
+// past the size limit of its media type.
+func appendTooLarge(rel string, limit int64) *BotError {
+	return &BotError{
+		Category: CategoryTooLarge,
+		Message:  fmt.Sprintf("❌ Appending this would make '%s' larger than %s, so it was not saved.", filepath.Base(rel), formatLimit(limit)),
+		Err:      fmt.Errorf("appending to %s would exceed the %d byte limit", rel, limit),
+	}
+}
+
+// appendToExisting copies the content of r onto the end of owner's stored
+// text file rel. When there is no such file or either content is not text it
+// appends nothing and returns false, along with a reader yielding all of r
+// again. The grown file must stay within the size limit of mediaType and be
+// accepted by accept, when not nil, like a newly stored one; otherwise it is
+// truncated back and the error returned.
+func (b *Bot) appendToExisting(r io.Reader, rel, mediaType string, owner int64, accept func(m *FileMeta) error) (FileMeta, io.Reader, bool, error) {
+	existing, err := readMeta(b.storagePath, rel)
+	if err != nil || existing.Owner != owner || existing.Compressed || !isTextMIME(existing.MIME) {
+		return FileMeta{}, r, false, nil
+	}
+	path := b.localPath(rel)
+	if !exists(path) {
+		return FileMeta{}, r, false, nil
+	}
+
+	// Trust the content over the declared type before mixing it in
+	br := bufio.NewReaderSize(r, sniffLen)
+	head, _ := br.Peek(sniffLen)
+	if len(head) == 0 {
+		return FileMeta{}, br, false, nil
+	}
+	if !strings.HasPrefix(http.DetectContentType(head), "text/") {
+		log.Printf("Not appending to %s: the new content is not text", rel)
+		return FileMeta{}, br, false, nil
+	}
+
+	b.appendMu.Lock()
+	defer b.appendMu.Unlock()
+
+	// A hardlinked file gets its own copy first
+	if err := b.detachContent(rel); err != nil {
+		return FileMeta{}, nil, true, fmt.Errorf("failed to copy %s before appending: %w", rel, err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return FileMeta{}, nil, true, fmt.Errorf("failed to open %s for appending: %w", rel, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return FileMeta{}, nil, true, fmt.Errorf("failed to stat %s: %w", rel, err)
+	}
+	appended, err := io.Copy(f, br)
+	if err != nil {
+		// Leave the file as it was rather than with half an append
+		f.Truncate(info.Size())
+		f.Close()
+		return FileMeta{}, nil, true, fmt.Errorf("failed to append to %s: %w", rel, err)
+	}
+	if err := f.Close(); err != nil {
+		return FileMeta{}, nil, true, fmt.Errorf("failed to append to %s: %w", rel, err)
+	}
+
+	m := existing
+	if m.SHA256, m.Size, err = hashFile(path, b.openPath); err != nil {
+		os.Truncate(path, info.Size())
+		return FileMeta{}, nil, true, fmt.Errorf("failed to hash %s: %w", rel, err)
+	}
+	m.Appended = appended
+	if limit, _ := b.sizeLimits.limit(mediaType); m.Size > limit {
+		err = appendTooLarge(rel, limit)
+	} else if accept != nil {
+		err = accept(&m)
+	}
+	if err != nil {
+		if terr := os.Truncate(path, info.Size()); terr != nil {
+			log.Printf("Failed to undo the append to %s: %v", rel, terr)
+		}
+		log.Printf("Not appending to %s from user %d: %v", rel, owner, err)
+		return FileMeta{}, nil, true, err
+	}
+	// The Telegram file no longer matches the stored content
+	m.FileID, m.MediaType = "", ""
+	log.Printf("Appended %d bytes to %s (now %d bytes)", appended, rel, m.Size)
+
+	b.recordFile(m)
+	b.audit(AuditEntry{User: owner, Action: "append", Path: rel, Size: m.Size, SHA256: m.SHA256, Detail: fmt.Sprintf("%d bytes appended", appended)})
+	b.runPostSaveHook(m)
+	return m, nil, true, nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSaveFileAppendsTextDuplicates(t *testing.T) {
+	b := newTestBot(t)
+	b.appendDuplicates = true
+
+	for _, chunk := range []string{"line 1\n", "line 2\n", "line 3\n"} {
+		if _, err := b.saveFile(strings.NewReader(chunk), "documents", "app.log", "text/plain", 42); err != nil {
+			t.Fatalf("saveFile failed: %v", err)
+		}
+	}
+	saved, err := b.saveFile(strings.NewReader("line 4\n"), "documents", "app.log", "text/plain", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "line 1\nline 2\nline 3\nline 4\n"
+	if saved.Path != "app.log" || saved.Appended != 7 || saved.Size != int64(len(want)) {
+		t.Errorf("expected 7 bytes appended to app.log, got %+v", saved)
+	}
+	if data, _ := os.ReadFile(b.localPath("app.log")); string(data) != want {
+		t.Errorf("expected the chunks in order, got %q", data)
+	}
+	if exists(b.localPath("app (1).log")) {
+		t.Error("expected no suffixed copy")
+	}
+	if m, err := readMeta(b.storagePath, "app.log"); err != nil || m.Size != int64(len(want)) || m.SHA256 != sha256Hex(want) {
+		t.Errorf("expected the metadata to describe the whole file, got %+v (%v)", m, err)
+	}
+}
+
+func TestSaveFileAppendLeavesSharedCopiesAlone(t *testing.T) {
+	b := newTestBot(t)
+	b.perUserDirs = true
+	b.appendDuplicates = true
+	if _, err := b.saveFile(strings.NewReader("line 1\n"), "documents", "app.log", "text/plain", 42); err != nil {
+		t.Fatal(err)
+	}
+	shared, err := b.shareFile("42/app.log", 42, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, _ := os.Stat(b.localPath(shared)); hardlinkCount(a) != 2 {
+		t.Fatalf("expected /share to hardlink the file, got %d links", hardlinkCount(a))
+	}
+
+	// The recipient sends a text document under the name of the shared file
+	saved, err := b.saveFile(strings.NewReader("line 2\n"), "documents", "shared/app.log", "text/plain", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Path != shared || saved.Appended == 0 {
+		t.Fatalf("expected an append to %s, got %+v", shared, saved)
+	}
+	if data, _ := os.ReadFile(b.localPath(shared)); string(data) != "line 1\nline 2\n" {
+		t.Errorf("expected the append in the recipient's copy, got %q", data)
+	}
+	if data, _ := os.ReadFile(b.localPath("42/app.log")); string(data) != "line 1\n" {
+		t.Errorf("expected the sender's copy unchanged, got %q", data)
+	}
+}
+
+func TestSaveFileAppendRespectsLimits(t *testing.T) {
+	b := newTestBot(t)
+	b.appendDuplicates = true
+	b.sizeLimits = sizeLimits{perType: map[string]int64{"documents": 10}}
+	if _, err := b.saveFile(strings.NewReader("line 1\n"), "documents", "app.log", "text/plain", 42); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file would grow past MAX_DOC_SIZE
+	_, err := b.saveFile(strings.NewReader("line 2\n"), "documents", "app.log", "text/plain", 42)
+	var be *BotError
+	if !errors.As(err, &be) || be.Category != CategoryTooLarge {
+		t.Errorf("expected the append to be refused as too large, got %v", err)
+	}
+
+	// The caller's check refuses it
+	b.sizeLimits = sizeLimits{}
+	refused := errors.New("refused")
+	_, err = b.saveFileChecked(strings.NewReader("line 2\n"), "documents", "app.log", "text/plain", 42, 42, func(m *FileMeta) error {
+		if m.Size != 14 {
+			t.Errorf("expected the check to see the grown size, got %d", m.Size)
+		}
+		return refused
+	})
+	if !errors.Is(err, refused) {
+		t.Errorf("expected the check's error, got %v", err)
+	}
+
+	if data, _ := os.ReadFile(b.localPath("app.log")); string(data) != "line 1\n" {
+		t.Errorf("expected the file truncated back, got %q", data)
+	}
+	if m, err := readMeta(b.storagePath, "app.log"); err != nil || m.Size != 7 || m.SHA256 != sha256Hex("line 1\n") {
+		t.Errorf("expected the metadata kept, got %+v (%v)", m, err)
+	}
+}
+
+func TestSaveFileSuffixesWhenNotAppending(t *testing.T) {
+	cases := []struct {
+		name     string
+		enabled  bool
+		mime     string
+		content  string
+		existing string // MIME of the stored file
+		owner    int64
+	}{
+		{"disabled", false, "text/plain", "more\n", "text/plain", 42},
+		{"binary MIME", true, "application/octet-stream", "more\n", "text/plain", 42},
+		{"binary content", true, "text/plain", "\x00\x01\x02PK\x03\x04", "text/plain", 42},
+		{"binary existing", true, "text/plain", "more\n", "application/pdf", 42},
+		{"other owner", true, "text/plain", "more\n", "text/plain", 7},
+	}
+	for _, tc := range cases {
+		b := newTestBot(t)
+		b.appendDuplicates = tc.enabled
+		if _, err := b.saveFile(strings.NewReader("first\n"), "documents", "app.log", tc.existing, 42); err != nil {
+			t.Fatal(err)
+		}
+
+		saved, err := b.saveFile(strings.NewReader(tc.content), "documents", "app.log", tc.mime, tc.owner)
+		if err != nil || saved.Path != "app (1).log" || saved.Appended != 0 {
+			t.Errorf("%s: expected a suffixed copy, got %+v (%v)", tc.name, saved, err)
+		}
+		if data, _ := os.ReadFile(b.localPath("app.log")); string(data) != "first\n" {
+			t.Errorf("%s: expected the original to be untouched, got %q", tc.name, data)
+		}
+		if data, _ := os.ReadFile(b.localPath("app (1).log")); string(data) != tc.content {
+			t.Errorf("%s: expected the whole new content in the copy, got %q", tc.name, data)
+		}
+	}
+}
+
+func TestReplySavedReportsAppend(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+
+	b.replySaved(42, 42, FileMeta{Path: "app.log", Size: 3072, Appended: 1024})
+	if texts := api.texts(); len(texts) != 1 || texts[0] != "📎 Appended 1.0 KB to 'app.log', now 3.0 KB" {
+		t.Errorf("unexpected reply %q", texts)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAuditLogChainContinuity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	a, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Append(AuditEntry{User: 1, Action: "save", Path: "a.txt", Size: 3})
+	a.Append(AuditEntry{User: 1, Action: "save", Path: "b.txt", Size: 4})
+	a.Close()
+
+	// Entries appended after reopening continue the same chain
+	a, err = OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Append(AuditEntry{User: 2, Action: "rename", Path: "c.txt"})
+	a.Close()
+
+	count, brk, err := VerifyAuditLog(path)
+	if err != nil || brk != nil {
+		t.Fatalf("expected an intact chain, got break %+v (%v)", brk, err)
+	}
+	if count != 3 {
+		t.Errorf("expected 3 entries, got %d", count)
+	}
+}
+
+func TestAuditLogDetectsTampering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	a, _ := OpenAuditLog(path)
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+		a.Append(AuditEntry{User: 1, Action: "save", Path: name, Size: 10})
+	}
+	a.Close()
+	original, _ := os.ReadFile(path)
+
+	// Modify the second entry
+	os.WriteFile(path, bytes.Replace(original, []byte(`"b.txt","size":10`), []byte(`"b.txt","size":99`), 1), 0600)
+	count, brk, _ := VerifyAuditLog(path)
+	if brk == nil || brk.Line != 3 || count != 2 {
+		t.Errorf("expected a break at line 3 after editing line 2, got %+v (count %d)", brk, count)
+	}
+
+	// Remove the second entry
+	lines := strings.SplitAfter(string(original), "\n")
+	os.WriteFile(path, []byte(lines[0]+lines[2]+lines[3]), 0600)
+	if _, brk, _ := VerifyAuditLog(path); brk == nil || brk.Line != 2 {
+		t.Errorf("expected a break at line 2 after removing an entry, got %+v", brk)
+	}
+
+	// Garbage line
+	os.WriteFile(path, []byte(lines[0]+"not json\n"), 0600)
+	if _, brk, _ := VerifyAuditLog(path); brk == nil || brk.Line != 2 {
+		t.Errorf("expected a break at the corrupt line, got %+v", brk)
+	}
+}
+
+func TestSaveFileWritesAuditEntry(t *testing.T) {
+	b := newTestBot(t)
+	b.auditPath = filepath.Join(t.TempDir(), "audit.log")
+	var err error
+	if b.auditLog, err = OpenAuditLog(b.auditPath); err != nil {
+		t.Fatal(err)
+	}
+	defer b.auditLog.Close()
+
+	saved, err := b.saveFile(strings.NewReader("content"), "documents", "a.txt", "text/plain", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(b.auditPath)
+	for _, want := range []string{`"user":42`, `"action":"save"`, `"path":"a.txt"`, `"size":7`, saved.SHA256} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected audit line to contain %s, got %s", want, data)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// botConfig is what one bot of the process is created from.
+type botConfig struct {
+	Name         string // for logs, e.g. "bot2"
+	Token        string
+	StoragePath  string
+	AllowedUsers []int64
+	AdminUsers   []int64
+	Env          envLookup
+}
+
+// perBotVars are the settings a bot of BOT_TOKENS never inherits from the
+// shared environment: files several bots must not write to at once.
+var perBotVars = map[string]bool{
+	"STORAGE_PATH":     true,
+	"AUDIT_LOG":        true,
+	"INDEX_DB":         true,
+	"CHAT_STORAGE_MAP": true,
+	"WEBDAV_URL":       true,
+	"HTTP_ADDR":        true,
+	"PUBLIC_URL":       true,
+}
+
+// isPerBotVar reports whether name is one of perBotVars or a per-media-type
+// storage root (STORAGE_PHOTOS, ...).
+func isPerBotVar(name string) bool {
+	if perBotVars[name] {
+		return true
+	}
+	for _, mediaType := range mediaTypes {
+		if name == "STORAGE_"+strings.ToUpper(mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// prefixedEnv reads name as prefix+name, falling back to the shared name for
+// everything but the per-bot settings.
+func prefixedEnv(getenv envLookup, prefix string) envLookup {
+	return func(name string) string {
+		if value := getenv(prefix + name); value != "" {
+			return value
+		}
+		if isPerBotVar(name) {
+			return ""
+		}
+		return getenv(name)
+	}
+}
+
+// loadBotConfigs reads the bots to run. Without BOT_TOKENS that is the single
+// bot of TELEGRAM_BOT_TOKEN. BOT_TOKENS lists one token per bot; bot N reads
+// BOTN_<NAME> before the shared <NAME> and stores its files in
+// BOTN_STORAGE_PATH, by default the folder botN inside STORAGE_PATH.
+func loadBotConfigs(getenv envLookup) ([]botConfig, error) {
+	storagePath := getenv("STORAGE_PATH")
+	if storagePath == "" {
+		storagePath = DefaultStoragePath
+	}
+
+	var tokens []string
+	for _, token := range strings.Split(getenv("BOT_TOKENS"), ",") {
+		if token = strings.TrimSpace(token); token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	if len(tokens) == 0 {
+		token := getenv("TELEGRAM_BOT_TOKEN")
+		if token == "" {
+			return nil, fmt.Errorf("TELEGRAM_BOT_TOKEN environment variable is required")
+		}
+		return []botConfig{{
+			Name:         "bot",
+			Token:        token,
+			StoragePath:  storagePath,
+			AllowedUsers: parseAllowedUsers(getenv("ALLOWED_USERS")),
+			AdminUsers:   parseAllowedUsers(getenv("ADMIN_USERS")),
+			Env:          getenv,
+		}}, nil
+	}
+
+	configs := make([]botConfig, len(tokens))
+	byPath := make(map[string]string)
+	for i, token := range tokens {
+		name := fmt.Sprintf("bot%d", i+1)
+		env := prefixedEnv(getenv, fmt.Sprintf("BOT%d_", i+1))
+		path := env("STORAGE_PATH")
+		if path == "" {
+			path = filepath.Join(storagePath, name)
+		}
+		if other, taken := byPath[filepath.Clean(path)]; taken {
+			return nil, fmt.Errorf("%s and %s would share the storage path %s", other, name, path)
+		}
+		byPath[filepath.Clean(path)] = name
+
+		configs[i] = botConfig{
+			Name:         name,
+			Token:        token,
+			StoragePath:  path,
+			AllowedUsers: parseAllowedUsers(env("ALLOWED_USERS")),
+			AdminUsers:   parseAllowedUsers(env("ADMIN_USERS")),
+			Env:          env,
+		}
+	}
+	return configs, nil
+}
+
+// Close releases the resources of a bot once Start has returned.
+func (b *Bot) Close() {
+	if b.statusService != nil {
+		b.statusService.Stop()
+	}
+	if b.index != nil {
+		b.index.Close()
+	}
+	if b.auditLog != nil {
+		b.auditLog.Close()
+	}
+	// Let running post-save hooks finish
+	b.postSaveHook.Wait()
+	// Deliver summaries still waiting for quiet-mode users
+	b.quiet.FlushAll()
+}
+
+// runBots runs every bot's update loop in its own goroutine until ctx is
+// cancelled and all of them returned.
+func runBots(ctx context.Context, bots []*Bot) {
+	var wg sync.WaitGroup
+	for _, b := range bots {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.Start(ctx)
+		}()
+	}
+	wg.Wait()
+}
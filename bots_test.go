@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// mapEnv is an envLookup over a fixed set of variables.
+func mapEnv(vars map[string]string) envLookup {
+	return func(name string) string { return vars[name] }
+}
+
+func TestLoadBotConfigsSingleToken(t *testing.T) {
+	configs, err := loadBotConfigs(mapEnv(map[string]string{
+		"TELEGRAM_BOT_TOKEN": "t0",
+		"STORAGE_PATH":       "/data",
+		"ALLOWED_USERS":      "1,2",
+	}))
+	if err != nil || len(configs) != 1 {
+		t.Fatalf("expected one bot, got %+v (%v)", configs, err)
+	}
+	if c := configs[0]; c.Token != "t0" || c.StoragePath != "/data" || !reflect.DeepEqual(c.AllowedUsers, []int64{1, 2}) {
+		t.Errorf("unexpected config %+v", c)
+	}
+
+	if _, err := loadBotConfigs(mapEnv(nil)); err == nil || !strings.Contains(err.Error(), "TELEGRAM_BOT_TOKEN") {
+		t.Errorf("expected a missing token to be reported, got %v", err)
+	}
+}
+
+func TestLoadBotConfigsPerBotSettings(t *testing.T) {
+	configs, err := loadBotConfigs(mapEnv(map[string]string{
+		"BOT_TOKENS":         "t1, t2",
+		"TELEGRAM_BOT_TOKEN": "ignored",
+		"STORAGE_PATH":       "/data",
+		"ALLOWED_USERS":      "1",
+		"ORGANIZE":           "date",
+		"INDEX_DB":           "/data/index.db",
+		"STORAGE_PHOTOS":     "/photos",
+		"BOT2_ALLOWED_USERS": "7",
+		"BOT2_STORAGE_PATH":  "/tenant2",
+		"BOT2_ORGANIZE":      "type",
+	}))
+	if err != nil || len(configs) != 2 {
+		t.Fatalf("expected two bots, got %+v (%v)", configs, err)
+	}
+	one, two := configs[0], configs[1]
+	if one.Token != "t1" || one.StoragePath != filepath.Join("/data", "bot1") || !reflect.DeepEqual(one.AllowedUsers, []int64{1}) {
+		t.Errorf("unexpected first bot %+v", one)
+	}
+	if two.Token != "t2" || two.StoragePath != "/tenant2" || !reflect.DeepEqual(two.AllowedUsers, []int64{7}) {
+		t.Errorf("unexpected second bot %+v", two)
+	}
+	if one.Env("ORGANIZE") != "date" || two.Env("ORGANIZE") != "type" {
+		t.Errorf("expected shared settings with per-bot overrides, got %q and %q", one.Env("ORGANIZE"), two.Env("ORGANIZE"))
+	}
+	// Files only one bot may write to are never shared
+	if one.Env("INDEX_DB") != "" || one.Env("STORAGE_PHOTOS") != "" {
+		t.Errorf("expected per-bot files not to be inherited, got %q and %q", one.Env("INDEX_DB"), one.Env("STORAGE_PHOTOS"))
+	}
+
+	_, err = loadBotConfigs(mapEnv(map[string]string{"BOT_TOKENS": "t1,t2", "BOT1_STORAGE_PATH": "/same", "BOT2_STORAGE_PATH": "/same/"}))
+	if err == nil || !strings.Contains(err.Error(), "share the storage path") {
+		t.Errorf("expected a shared storage path to be rejected, got %v", err)
+	}
+}
+
+func TestTwoBotsInitializeIndependently(t *testing.T) {
+	// A Bot API server knowing both tokens
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/bot"), "/")
+		fmt.Fprintf(w, `{"ok":true,"result":{"id":1,"is_bot":true,"username":"%s_bot"}}`, token)
+	}))
+	defer srv.Close()
+
+	root := t.TempDir()
+	configs, err := loadBotConfigs(mapEnv(map[string]string{
+		"BOT_TOKENS":         "alpha,beta",
+		"TELEGRAM_API_URL":   srv.URL,
+		"STORAGE_PATH":       root,
+		"SYNOLOGY_USERNAME":  "user",
+		"SYNOLOGY_PASSWORD":  "secret",
+		"ALLOWED_USERS":      "1",
+		"ADMIN_USERS":        "9",
+		"BOT2_ADMIN_USERS":   "8",
+		"BOT2_PER_USER_DIRS": "true",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var bots []*Bot
+	for _, cfg := range configs {
+		b, err := NewBot(cfg)
+		if err != nil {
+			t.Fatalf("NewBot(%s) failed: %v", cfg.Name, err)
+		}
+		defer b.Close()
+		bots = append(bots, b)
+	}
+	alpha, beta := bots[0], bots[1]
+
+	if alpha.api.Self.UserName != "alpha_bot" || beta.api.Self.UserName != "beta_bot" {
+		t.Errorf("expected each bot on its own account, got %q and %q", alpha.api.Self.UserName, beta.api.Self.UserName)
+	}
+	if alpha.storagePath == beta.storagePath || alpha.perUserDirs || !beta.perUserDirs {
+		t.Errorf("expected separate storage and settings, got %s (%v) and %s (%v)", alpha.storagePath, alpha.perUserDirs, beta.storagePath, beta.perUserDirs)
+	}
+	if !alpha.isUserAdmin(9) || alpha.isUserAdmin(8) || !beta.isUserAdmin(8) || beta.isUserAdmin(9) {
+		t.Error("expected each bot to have its own admins")
+	}
+
+	// Changing the users of one bot leaves the other alone
+	alpha.usersMu.Lock()
+	alpha.allowedUsers[5] = true
+	alpha.usersMu.Unlock()
+	if !alpha.isUserAllowed(5) || beta.isUserAllowed(5) {
+		t.Error("expected the user maps to be separate")
+	}
+
+	if _, err := alpha.saveFile(strings.NewReader("hello"), "documents", "a.txt", "text/plain", 42); err != nil {
+		t.Fatal(err)
+	}
+	if exists(filepath.Join(beta.storagePath, "a.txt")) || exists(filepath.Join(beta.storagePath, "42", "a.txt")) {
+		t.Error("expected the file to be stored by the first bot only")
+	}
+}
@@ -3,5 +3,9 @@ docker manifest inspect ag0n1k/tg-fsync:v$VERSION > /dev/null 2>&1 && VERSION_NE
 
 echo -n $VERSION_NEW > version
 
-docker build -t ag0n1k/tg-fsync:v$VERSION_NEW .
+docker build -t ag0n1k/tg-fsync:v$VERSION_NEW \
+  --build-arg VERSION=$VERSION_NEW \
+  --build-arg COMMIT=$(git rev-parse --short HEAD) \
+  --build-arg BUILD_DATE=$(date -u +%Y-%m-%dT%H:%M:%SZ) \
+  .
 docker push ag0n1k/tg-fsync:v$VERSION_NEW
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// channelDir is the storage-relative folder of a channel's posts.
+func channelDir(chatID int64) string {
+	return fmt.Sprintf("channel_%d", chatID)
+}
+
+// isChannel reports whether chatID is a channel whose posts are stored.
+func (b *Bot) isChannel(chatID int64) bool {
+	_, ok := b.channels.Load(chatID)
+	return ok
+}
+
+// handleChannelPost stores the media of a post in a channel the bot is an
+// admin of, with STORE_CHANNEL_POSTS. Channels have no sender to authorize
+// (ALLOWED_CHATS still applies) and take no replies, so refusals and failures
+// are only logged.
+func (b *Bot) handleChannelPost(post *tgbotapi.Message) {
+	chatID := post.Chat.ID
+	if !b.storeChannelPosts {
+		return
+	}
+	if !b.isChatAllowed(chatID) {
+		b.logDisallowedChat(chatID, 0)
+		return
+	}
+
+	media := messageMedia(post)
+	if media == nil {
+		return
+	}
+	if b.isPaused() {
+		log.Printf("Skipping %s from channel %d: uploads are paused", media.Label, chatID)
+		return
+	}
+	if media.Protected && b.rejectProtected {
+		log.Printf("Skipping protected %s from channel %d", media.Label, chatID)
+		return
+	}
+	if limit, _ := b.sizeLimits.limit(media.MediaType); media.Size > limit || (media.Size > 0 && media.Size < b.minFileSize) ||
+		(media.Size > PublicAPIDownloadLimit && !b.localBotAPI) {
+		log.Printf("Skipping %s from channel %d: declared size %d", media.Label, chatID, media.Size)
+		return
+	}
+
+	b.channels.Store(chatID, true)
+	saved, err := b.downloadAndSave(*media, b.organizedName(media.MediaType, media.fileName(time.Now()), time.Now()), chatID, chatID)
+	var tooSmall *tooSmallError
+	if errors.As(err, &tooSmall) {
+		log.Printf("Discarded %s from channel %d: %v", media.Label, chatID, err)
+		return
+	}
+	if err != nil {
+		log.Printf("Error handling %s from channel %d (%s): %v", media.Label, chatID, errorCategory(err), err)
+		return
+	}
+	log.Printf("Stored %s from channel %d as %s", media.Label, chatID, saved.Path)
+}
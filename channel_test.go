@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestChannelPostStored(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("channel content"))
+	}))
+	defer srv.Close()
+
+	b := newDownloadTestBot(t, 5*time.Second)
+	api := &mockTelegramAPI{fileURL: srv.URL}
+	b.client = api
+	post := func(chatID int64, name string) *tgbotapi.Message {
+		return &tgbotapi.Message{
+			Chat:       &tgbotapi.Chat{ID: chatID, Type: "channel"},
+			SenderChat: &tgbotapi.Chat{ID: chatID, Type: "channel"},
+			Document:   &tgbotapi.Document{FileID: name, FileName: name, MimeType: "application/pdf"},
+		}
+	}
+
+	// Off by default
+	b.handleChannelPost(post(-1001, "off.pdf"))
+	if _, err := os.Stat(b.localPath("channel_-1001/off.pdf")); err == nil {
+		t.Fatal("expected channel posts to be ignored without STORE_CHANNEL_POSTS")
+	}
+
+	b.storeChannelPosts = true
+	b.handleChannelPost(post(-1001, "report.pdf"))
+	data, err := os.ReadFile(b.localPath("channel_-1001/report.pdf"))
+	if err != nil || string(data) != "channel content" {
+		t.Fatalf("expected the post stored in the channel folder, got %q (%v)", data, err)
+	}
+	if m, err := readMeta(b.storagePath, "channel_-1001/report.pdf"); err != nil || m.Owner != -1001 {
+		t.Errorf("unexpected metadata %+v (%v)", m, err)
+	}
+
+	// Text posts and channels outside ALLOWED_CHATS are skipped
+	b.handleChannelPost(&tgbotapi.Message{Chat: &tgbotapi.Chat{ID: -1001, Type: "channel"}, Text: "hello"})
+	b.allowedChats = map[int64]bool{-1001: true}
+	b.handleChannelPost(post(-1002, "other.pdf"))
+	if _, err := os.Stat(b.localPath("channel_-1002/other.pdf")); err == nil {
+		t.Error("expected a channel outside ALLOWED_CHATS to be skipped")
+	}
+
+	if len(api.sent) != 0 {
+		t.Errorf("expected no replies in channels, got %d messages", len(api.sent))
+	}
+}
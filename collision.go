@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// Collision strategies: what happens when a file arrives under a name the
+// user already stores a file as (/setcollision).
+const (
+	CollisionSuffix    = "suffix"    // store it as "name (1).ext"
+	CollisionOverwrite = "overwrite" // replace the stored file
+	CollisionReject    = "reject"    // refuse the new file
+	CollisionAppend    = "append"    // append text documents, else suffix
+)
+
+// collisionStrategies are the values /setcollision accepts.
+var collisionStrategies = []string{CollisionSuffix, CollisionOverwrite, CollisionReject, CollisionAppend}
+
+// collisionDescriptions explain each strategy in /setcollision replies.
+var collisionDescriptions = map[string]string{
+	CollisionSuffix:    "a number is added to the new file's name",
+	CollisionOverwrite: "the new file replaces the stored one",
+	CollisionReject:    "the new file is refused",
+	CollisionAppend:    "text documents are appended to the stored file, other files get a numbered name",
+}
+
+var errNameTaken = errors.New("a file with this name is already stored")
+
+// parseCollisionStrategy validates a /setcollision value.
+func parseCollisionStrategy(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, strategy := range collisionStrategies {
+		if s == strategy {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("unknown strategy %q: use %s", s, strings.Join(collisionStrategies, ", "))
+}
+
+// collisionStrategy returns the strategy of owner: their /setcollision
+// setting, else append with APPEND_ON_DUPLICATE, else suffix.
+func (b *Bot) collisionStrategy(owner int64) string {
+	if b.users != nil {
+		if strategy := b.users.Get(owner).Collision; strategy != "" {
+			return strategy
+		}
+	}
+	if b.appendDuplicates {
+		return CollisionAppend
+	}
+	return CollisionSuffix
+}
+
+// nameTakenError is the reply to a file refused under the reject strategy.
+func nameTakenError(name string) error {
+	be := newBotError(CategoryInternal, fmt.Errorf("%s: %w", name, errNameTaken))
+	be.Message = fmt.Sprintf("❌ A file named '%s' is already stored, so this one was not saved. Rename or delete the stored file, or change this with /setcollision.", name)
+	return be
+}
+
+// replaceable returns why the file stored as target must not be replaced by a
+// file of owner, or nil. Without PER_USER_DIRS the name may be another
+// user's, so only files recorded as owner's that are neither pinned nor
+// protected are overwritten.
+func (b *Bot) replaceable(target string, owner int64) error {
+	m, err := readMeta(b.storagePath, target)
+	switch {
+	case err != nil:
+		return fmt.Errorf("cannot tell who owns %s: %w", target, err)
+	case m.Owner != owner:
+		return fmt.Errorf("%s belongs to user %d", target, m.Owner)
+	case m.Pinned:
+		return fmt.Errorf("%s is pinned", target)
+	case m.Protected:
+		return fmt.Errorf("%s is protected", target)
+	}
+	return nil
+}
+
+// replaceStored moves the just stored file rel over target, replacing the file
+// stored there. Only local storage can replace a file in place.
+func (b *Bot) replaceStored(rel, target string) error {
+	old := b.localPath(target)
+	dst := b.localPathBeside(rel, target)
+	if err := os.Rename(b.localPath(rel), dst); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", target, err)
+	}
+	// The replaced file may live in another storage root
+	if old != dst {
+		if err := os.Remove(old); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Failed to remove replaced file %s: %v", old, err)
+		}
+	}
+	return nil
+}
+
+// handleSetCollisionCommand shows or changes what happens to files arriving
+// under a name the user already stores.
+func (b *Bot) handleSetCollisionCommand(chatID, userID int64, args string) {
+	usage := "Usage: /setcollision " + strings.Join(collisionStrategies, "|")
+	if strings.TrimSpace(args) == "" {
+		strategy := b.collisionStrategy(userID)
+		b.sendTextMessage(chatID, fmt.Sprintf("📛 On a name collision: %s (%s).\n%s", strategy, collisionDescriptions[strategy], usage))
+		return
+	}
+
+	strategy, err := parseCollisionStrategy(args)
+	if err != nil {
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ %v\n%s", err, usage))
+		return
+	}
+	if strategy == CollisionOverwrite {
+		if _, ok := b.storage.(*LocalStorage); !ok {
+			b.sendTextMessage(chatID, "❌ Overwriting is only supported with local storage.")
+			return
+		}
+	}
+
+	if err := b.users.Update(userID, func(s *UserSettings) { s.Collision = strategy }); err != nil {
+		log.Printf("Failed to save collision strategy of user %d: %v", userID, err)
+		b.sendTextMessage(chatID, "❌ Failed to save the setting.")
+		return
+	}
+	log.Printf("User %d set their collision strategy to %s", userID, strategy)
+	b.sendTextMessage(chatID, fmt.Sprintf("✅ On a name collision from now on: %s (%s).", strategy, collisionDescriptions[strategy]))
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollisionStrategies(t *testing.T) {
+	save := func(t *testing.T, b *Bot, content, mime string) (FileMeta, error) {
+		t.Helper()
+		return b.saveFile(strings.NewReader(content), "documents", "notes.txt", mime, 42)
+	}
+	newBot := func(t *testing.T, strategy string) *Bot {
+		t.Helper()
+		b := newTestBot(t)
+		if err := b.users.Update(42, func(s *UserSettings) { s.Collision = strategy }); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := save(t, b, "first\n", "text/plain"); err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	content := func(b *Bot, rel string) string {
+		data, _ := os.ReadFile(b.localPath(rel))
+		return string(data)
+	}
+
+	t.Run("suffix", func(t *testing.T) {
+		b := newBot(t, CollisionSuffix)
+		saved, err := save(t, b, "second\n", "text/plain")
+		if err != nil || saved.Path != "notes (1).txt" || content(b, "notes.txt") != "first\n" {
+			t.Errorf("expected a numbered copy, got %+v (%v)", saved, err)
+		}
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		b := newBot(t, CollisionOverwrite)
+		saved, err := save(t, b, "second\n", "text/plain")
+		if err != nil || saved.Path != "notes.txt" || content(b, "notes.txt") != "second\n" {
+			t.Fatalf("expected the stored file replaced, got %+v (%v)", saved, err)
+		}
+		if exists(b.localPath("notes (1).txt")) {
+			t.Error("expected no numbered copy left behind")
+		}
+		if m, err := readMeta(b.storagePath, "notes.txt"); err != nil || m.SHA256 != sha256Hex("second\n") {
+			t.Errorf("expected the metadata of the new file, got %+v (%v)", m, err)
+		}
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		b := newBot(t, CollisionReject)
+		_, err := save(t, b, "second\n", "text/plain")
+		if !errors.Is(err, errNameTaken) || !strings.Contains(errorReply(err, ""), "'notes.txt' is already stored") {
+			t.Fatalf("expected the file to be refused, got %v", err)
+		}
+		if content(b, "notes.txt") != "first\n" || exists(b.localPath("notes (1).txt")) {
+			t.Error("expected the stored file untouched and no copy")
+		}
+	})
+
+	t.Run("append", func(t *testing.T) {
+		b := newBot(t, CollisionAppend)
+		saved, err := save(t, b, "second\n", "text/plain")
+		if err != nil || saved.Path != "notes.txt" || content(b, "notes.txt") != "first\nsecond\n" {
+			t.Fatalf("expected the text appended, got %+v (%v)", saved, err)
+		}
+		// What cannot be appended gets a numbered name
+		saved, err = save(t, b, "\x89PNG\r\n\x1a\n", "image/png")
+		if err != nil || saved.Path != "notes (1).txt" {
+			t.Errorf("expected a numbered copy of binary content, got %+v (%v)", saved, err)
+		}
+	})
+}
+
+func TestOverwriteKeepsStoredFileWhenRefused(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tiny":
+			w.Write([]byte("abc"))
+		case "/big.txt":
+			w.(http.Flusher).Flush()
+			w.Write([]byte(strings.Repeat("x", 2048)))
+		}
+	}))
+	defer srv.Close()
+
+	b := newDownloadTestBot(t, 5*time.Second)
+	b.client = &mockTelegramAPI{fileURL: srv.URL}
+	b.fetchClient = newFetchClient(5*time.Second, true)
+	if err := b.users.Update(42, func(s *UserSettings) { s.Collision = CollisionOverwrite }); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"small.txt", "big.txt"} {
+		if _, err := b.saveFile(strings.NewReader("stored before"), "documents", name, "text/plain", 42); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// An upload below MIN_FILE_SIZE does not replace the stored file
+	b.minFileSize = 10
+	var tooSmall *tooSmallError
+	if _, err := b.downloadAndSave(mediaFile{MediaType: "documents", FileID: "tiny", MIME: "text/plain"}, "small.txt", 42, 42); !errors.As(err, &tooSmall) {
+		t.Fatalf("expected the upload to be refused as too small, got %v", err)
+	}
+
+	// Neither does a link turning out larger than the document limit
+	b.sizeLimits = sizeLimits{perType: map[string]int64{"documents": 1024}}
+	u, _ := url.Parse(srv.URL + "/big.txt")
+	if _, err := b.fetchURL(u, 42, 42); err == nil {
+		t.Fatal("expected the oversized link to be refused")
+	}
+
+	for _, name := range []string{"small.txt", "big.txt"} {
+		if data, _ := os.ReadFile(b.localPath(name)); string(data) != "stored before" {
+			t.Errorf("expected %s to keep its content, got %q", name, data)
+		}
+		if m, err := readMeta(b.storagePath, name); err != nil || m.SHA256 != sha256Hex("stored before") {
+			t.Errorf("expected the metadata of %s to be kept, got %+v (%v)", name, m, err)
+		}
+	}
+	for _, name := range []string{"small (1).txt", "big (1).txt"} {
+		if exists(b.localPath(name)) {
+			t.Errorf("expected the refused %s to be removed", name)
+		}
+	}
+}
+
+func TestOverwriteOnlyReplacesOwnFiles(t *testing.T) {
+	b := newTestBot(t)
+	if err := b.users.Update(42, func(s *UserSettings) { s.Collision = CollisionOverwrite }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.saveFile(strings.NewReader("user 7's"), "documents", "shared.txt", "text/plain", 7); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"pinned.txt", "protected.txt"} {
+		m, err := b.saveFile(strings.NewReader("kept"), "documents", name, "text/plain", 42)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.Pinned, m.Protected = name == "pinned.txt", name == "protected.txt"
+		b.recordFile(m)
+	}
+
+	for _, c := range []struct{ name, copy string }{
+		{"shared.txt", "shared (1).txt"},
+		{"pinned.txt", "pinned (1).txt"},
+		{"protected.txt", "protected (1).txt"},
+	} {
+		saved, err := b.saveFile(strings.NewReader("new"), "documents", c.name, "text/plain", 42)
+		if err != nil || saved.Path != c.copy {
+			t.Errorf("expected %s to be kept and the upload stored as %s, got %+v (%v)", c.name, c.copy, saved, err)
+		}
+		if data, _ := os.ReadFile(b.localPath(c.name)); string(data) == "new" {
+			t.Errorf("expected %s not to be replaced", c.name)
+		}
+	}
+	if m, err := readMeta(b.storagePath, "shared.txt"); err != nil || m.Owner != 7 {
+		t.Errorf("expected shared.txt to stay user 7's, got %+v (%v)", m, err)
+	}
+}
+
+func TestSetCollisionCommand(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+	last := func() string {
+		texts := api.texts()
+		return texts[len(texts)-1]
+	}
+
+	b.handleSetCollisionCommand(42, 42, "")
+	if reply := last(); !strings.HasPrefix(reply, "📛 On a name collision: suffix (") {
+		t.Errorf("expected suffix by default, got %q", reply)
+	}
+	b.appendDuplicates = true
+	b.handleSetCollisionCommand(42, 42, "")
+	if reply := last(); !strings.HasPrefix(reply, "📛 On a name collision: append (") {
+		t.Errorf("expected APPEND_ON_DUPLICATE to set the default, got %q", reply)
+	}
+
+	b.handleSetCollisionCommand(42, 42, " Reject")
+	if reply := last(); !strings.HasPrefix(reply, "✅ On a name collision from now on: reject") {
+		t.Errorf("unexpected reply %q", reply)
+	}
+	if got := b.collisionStrategy(42); got != CollisionReject || b.users.Get(42).Collision != CollisionReject {
+		t.Errorf("expected reject to be saved, got %q", got)
+	}
+	if got := b.collisionStrategy(43); got != CollisionAppend {
+		t.Errorf("expected other users to keep the default, got %q", got)
+	}
+
+	// The setting is persisted
+	reopened, err := OpenUserDB(b.users.path)
+	if err != nil || reopened.Get(42).Collision != CollisionReject {
+		t.Errorf("expected the strategy to survive a restart, got %+v (%v)", reopened.Get(42), err)
+	}
+
+	b.handleSetCollisionCommand(42, 42, " rename")
+	if reply := last(); !strings.HasPrefix(reply, `❌ unknown strategy "rename"`) {
+		t.Errorf("unexpected reply to an invalid value %q", reply)
+	}
+	if b.collisionStrategy(42) != CollisionReject {
+		t.Error("expected an invalid value to change nothing")
+	}
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"unicode"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+var (
+	errUnknownAdminCommand = errors.New("unknown admin command")
+	errAdminArgCount       = errors.New("wrong number of arguments")
+)
+
+// adminCall is one invocation of an /admin subcommand.
+type adminCall struct {
+	Message *tgbotapi.Message
+	ChatID  int64
+	UserID  int64
+	Args    []string // the words after the subcommand's name
+}
+
+// adminCommand is an /admin subcommand. handleAdminCommand checks the
+// argument count against MinArgs and MaxArgs before calling Run, so Run only
+// validates what the arguments say.
+type adminCommand struct {
+	Name    string
+	Args    string // synopsis, e.g. "<user_id> [types|all]"
+	Summary string // one line for the command list
+	Details string // optional, shown by /help <command>
+	MinArgs int
+	MaxArgs int // -1 for no limit
+	Run     func(b *Bot, c adminCall)
+}
+
+// usage returns how the command is called, e.g. "/admin add <user_id>".
+func (c adminCommand) usage() string {
+	if c.Args == "" {
+		return "/admin " + c.Name
+	}
+	return "/admin " + c.Name + " " + c.Args
+}
+
+// usageHelp is the reply to a call with the wrong number of arguments.
+func (c adminCommand) usageHelp() string {
+	return fmt.Sprintf("Usage: %s\n%s", c.usage(), c.Summary)
+}
+
+// adminCommands are the /admin subcommands, in the order help lists them.
+// Set up in init, as the help command lists them.
+var adminCommands []adminCommand
+
+func init() {
+	adminCommands = []adminCommand{
+		{Name: "list", Summary: "List all allowed users",
+			Run: func(b *Bot, c adminCall) { b.handleAdminListUsers(c.ChatID) }},
+		{Name: "add", Args: "<user_id>", Summary: "Add user to allowed list", MinArgs: 1, MaxArgs: 1,
+			Run: func(b *Bot, c adminCall) { b.handleAdminAddUser(c.ChatID, c.Args[0]) }},
+		{Name: "remove", Args: "<user_id>", Summary: "Remove user from allowed list", MinArgs: 1, MaxArgs: 1,
+			Run: func(b *Bot, c adminCall) { b.handleAdminRemoveUser(c.ChatID, c.Args[0]) }},
+		{Name: "invite", Args: "[validity]", Summary: "Create a single-use invite link (valid 24h by default)", MaxArgs: 1,
+			Details: "Validity is a duration such as 48h. Whoever opens the link is added to the allowed list.",
+			Run:     func(b *Bot, c adminCall) { b.handleAdminInvite(c.ChatID, c.UserID, c.Args) }},
+		{Name: "status", Summary: "Show bot statistics",
+			Run: func(b *Bot, c adminCall) { b.handleAdminStatus(c.ChatID) }},
+		{Name: "reindex", Summary: "Rebuild the file index from disk",
+			Run: func(b *Bot, c adminCall) { b.handleAdminReindex(c.ChatID) }},
+		{Name: "fsck", Args: "[--fix]", Summary: "Check the file index against disk, optionally repairing it", MaxArgs: 1,
+			Details: "Reports files missing from the index, entries whose file is gone and size or checksum mismatches. --fix brings the index in line with the disk.",
+			Run:     func(b *Bot, c adminCall) { b.handleAdminFsck(c.ChatID, c.Args) }},
+		{Name: "allow", Args: "<user_id> [types|all]", Summary: "Show or restrict a user's media types", MinArgs: 1, MaxArgs: -1,
+			Details: "Types: " + strings.Join(mediaTypes, ", ") + ". Separate several with commas.",
+			Run:     func(b *Bot, c adminCall) { b.handleAdminAllow(c.ChatID, c.Args) }},
+		{Name: "as", Args: "<user_id> <command> [args]", Summary: "Run a read-only command as a user sees it", MinArgs: 2, MaxArgs: -1,
+			Details: "Commands: list, search, info, limits. They run against the user's storage and settings, and the reply is labelled as an impersonated view. Commands that change files cannot be run this way.",
+			Run:     func(b *Bot, c adminCall) { b.handleAdminAs(c.ChatID, c.UserID, c.Args) }},
+		{Name: "recent", Args: "[N]", Summary: "Show the last N uploads across all users", MaxArgs: 1,
+			Run: func(b *Bot, c adminCall) { b.handleAdminRecent(c.ChatID, c.Args) }},
+		{Name: "usage", Args: "[page]", Summary: "Show disk usage per user directory, largest first", MaxArgs: 1,
+			Run: func(b *Bot, c adminCall) { b.handleAdminUsage(c.ChatID, c.Args) }},
+		{Name: "trend", Summary: "Show storage growth over the last 7 and 30 days",
+			Run: func(b *Bot, c adminCall) { b.handleAdminTrend(c.ChatID) }},
+		{Name: "duplicates", Args: "[page|link]", Summary: "Find files with identical content, or hardlink the copies", MaxArgs: 1,
+			Details: "link replaces the copies of each group with hardlinks to one file, freeing their space.",
+			Run:     func(b *Bot, c adminCall) { b.handleAdminDuplicates(c.ChatID, c.Args) }},
+		{Name: "export", Summary: "Send the allowed and admin users as a JSON file",
+			Run: func(b *Bot, c adminCall) { b.handleAdminExport(c.ChatID) }},
+		{Name: "import", Summary: "Reply to such a file to merge its users in",
+			Run: func(b *Bot, c adminCall) { b.handleAdminImport(c.Message, c.ChatID, c.UserID) }},
+		{Name: "verify-audit", Summary: "Check the audit log hash chain",
+			Run: func(b *Bot, c adminCall) { b.handleAdminVerifyAudit(c.ChatID) }},
+		{Name: "errors", Summary: "Show the most recent errors reported to users",
+			Run: func(b *Bot, c adminCall) { b.handleAdminErrors(c.ChatID) }},
+		{Name: "logs", Args: "[N]", Summary: "Show the last N lines of the bot's log", MaxArgs: 1,
+			Details: fmt.Sprintf("Reads LOG_FILE; %d lines by default, at most %d. Bot tokens are redacted.", DefaultLogLines, MaxLogLines),
+			Run:     func(b *Bot, c adminCall) { b.handleAdminLogs(c.ChatID, c.Args) }},
+		{Name: "promote", Args: "<user_id>", Summary: "Grant admin privileges", MinArgs: 1, MaxArgs: 1,
+			Run: func(b *Bot, c adminCall) { b.handleAdminPromote(c.ChatID, c.UserID, c.Args, true) }},
+		{Name: "demote", Args: "<user_id>", Summary: "Revoke admin privileges", MinArgs: 1, MaxArgs: 1,
+			Run: func(b *Bot, c adminCall) { b.handleAdminPromote(c.ChatID, c.UserID, c.Args, false) }},
+		{Name: "pause", Summary: "Stop accepting uploads (persists across restarts)",
+			Run: func(b *Bot, c adminCall) { b.handleAdminPause(c.ChatID, true) }},
+		{Name: "resume", Summary: "Accept uploads again",
+			Run: func(b *Bot, c adminCall) { b.handleAdminPause(c.ChatID, false) }},
+		{Name: "help", Args: "[command]", Summary: "Show this list, or details of one command", MaxArgs: 1,
+			Run: func(b *Bot, c adminCall) {
+				if len(c.Args) == 0 {
+					b.sendAdminHelp(c.ChatID)
+					return
+				}
+				b.sendAdminCommandHelp(c.ChatID, c.Args[0])
+			}},
+	}
+}
+
+// commandCall is one invocation of a top-level command.
+type commandCall struct {
+	Message *tgbotapi.Message
+	ChatID  int64
+	UserID  int64
+	Args    string // the text after the command's name, as sent
+}
+
+// userCommand is a top-level command, listed by /help and in Telegram's
+// command menu. handleMessage dispatches it through Run, which parses Args.
+type userCommand struct {
+	Name    string
+	Args    string // synopsis, e.g. "<file> <user_id>"
+	Summary string
+	Aliases []string // other names it answers to, not listed
+	NoArgs  bool     // "/<name> <text>" is not a call but text
+	Run     func(b *Bot, c commandCall)
+}
+
+// usage returns how the command is called, e.g. "/share <file> <user_id>".
+func (c userCommand) usage() string {
+	if c.Args == "" {
+		return "/" + c.Name
+	}
+	return "/" + c.Name + " " + c.Args
+}
+
+// userCommands are the commands of every allowed user, in the order help and
+// the command menu list them, and adminUserCommands those listed for admins
+// after them. Set up in init, as the help command lists them.
+var userCommands, adminUserCommands []userCommand
+
+func init() {
+	help := func(b *Bot, c commandCall) { b.handleHelpCommand(c.ChatID, c.UserID, c.Args) }
+	userCommands = []userCommand{
+		{Name: "start", Summary: "Show welcome message",
+			Run: func(b *Bot, c commandCall) {
+				b.handleStartCommand(c.ChatID, c.UserID, c.Message.From.UserName, strings.TrimSpace(c.Args))
+			}},
+		{Name: "help", Summary: "Show this help message", Run: help},
+		{Name: "id", Summary: "Show your Telegram user ID", NoArgs: true,
+			Run: func(b *Bot, c commandCall) { b.sendUserIDMessage(c.ChatID, c.UserID, c.Message.From) }},
+		{Name: "admins", Summary: "Show who to contact for help", Aliases: []string{"whoisadmin"}, NoArgs: true,
+			Run: func(b *Bot, c commandCall) { b.handleAdminsCommand(c.ChatID, c.UserID) }},
+		{Name: "version", Summary: "Show the bot version and build info", NoArgs: true,
+			Run: func(b *Bot, c commandCall) { b.handleVersionCommand(c.ChatID) }},
+		{Name: "status", Summary: "Show download tasks", NoArgs: true,
+			Run: func(b *Bot, c commandCall) { b.handleStatusCommand(c.ChatID) }},
+		{Name: "downloads", Summary: "Show download tasks (admins: pause, resume, delete <id>, fetch <id>)",
+			Run: func(b *Bot, c commandCall) { b.handleDownloadsCommand(c.ChatID, c.UserID, strings.Fields(c.Args)) }},
+		{Name: "list", Summary: "Show recently stored files", NoArgs: true,
+			Run: func(b *Bot, c commandCall) { b.handleListCommand(c.ChatID, c.UserID) }},
+		{Name: "search", Args: "<text>", Summary: "Find stored files by name",
+			Run: func(b *Bot, c commandCall) { b.handleSearchCommand(c.ChatID, c.UserID, strings.TrimSpace(c.Args)) }},
+		{Name: "rename", Args: "<old> <new>", Summary: "Rename a stored file",
+			Run: func(b *Bot, c commandCall) { b.handleRenameCommand(c.ChatID, c.UserID, c.Args) }},
+		{Name: "share", Args: "<file> <user_id>", Summary: "Share a copy of a file with another user",
+			Run: func(b *Bot, c commandCall) { b.handleShareCommand(c.ChatID, c.UserID, c.Args) }},
+		{Name: "resend", Args: "<file>", Summary: "Send a stored file back to you",
+			Run: func(b *Bot, c commandCall) { b.handleResendCommand(c.ChatID, c.UserID, c.Args) }},
+		{Name: "get", Args: "<pattern>", Summary: "Send back every file matching a pattern, e.g. *.pdf",
+			Run: func(b *Bot, c commandCall) { b.handleGetCommand(c.ChatID, c.UserID, c.Args) }},
+		{Name: "preview", Args: "<file>", Summary: "Show the start of a text file or an image",
+			Run: func(b *Bot, c commandCall) { b.handlePreviewCommand(c.ChatID, c.UserID, c.Args) }},
+		{Name: "setcollision", Args: "overwrite|suffix|reject|append", Summary: "Choose what happens to a file sent under a name already stored",
+			Run: func(b *Bot, c commandCall) { b.handleSetCollisionCommand(c.ChatID, c.UserID, c.Args) }},
+		{Name: "quiet", Args: "on|off", Summary: "Summarize saved files instead of confirming each one",
+			Run: func(b *Bot, c commandCall) { b.handleQuietCommand(c.ChatID, c.UserID, c.Args) }},
+		{Name: "limits", Summary: "Show your size limits, media types and folder", NoArgs: true,
+			Run: func(b *Bot, c commandCall) { b.handleLimitsCommand(c.ChatID, c.UserID) }},
+		{Name: "gallery", Summary: "Get a temporary link to a web page of your images", NoArgs: true,
+			Run: func(b *Bot, c commandCall) { b.handleGalleryCommand(c.ChatID, c.UserID) }},
+		{Name: "info", Args: "<file>", Summary: "Show details of a stored file",
+			Run: func(b *Bot, c commandCall) { b.handleInfoCommand(c.ChatID, c.UserID, c.Args) }},
+		{Name: "diff", Args: "<file1> <file2>", Summary: "Check whether two stored files are identical, with a diff of text files",
+			Run: func(b *Bot, c commandCall) { b.handleDiffCommand(c.ChatID, c.UserID, c.Args) }},
+		{Name: "manifest", Args: "[folder]", Summary: "Store and send a SHA256SUMS file of a folder",
+			Run: func(b *Bot, c commandCall) { b.handleManifestCommand(c.ChatID, c.UserID, c.Args) }},
+		{Name: "protect", Args: "<file> <password>", Summary: "Get a password-encrypted copy of a file to share",
+			Run: func(b *Bot, c commandCall) { b.handleProtectCommand(c.Message, c.ChatID, c.UserID, c.Args) }},
+		{Name: "peek", Summary: "Reply to a media message to see its Telegram details without storing it", NoArgs: true,
+			Run: func(b *Bot, c commandCall) { b.handlePeekCommand(c.Message, c.ChatID) }},
+		{Name: "convert", Args: "<file> mp3", Summary: "Convert a stored audio file (e.g. a voice message) to MP3",
+			Run: func(b *Bot, c commandCall) { b.handleConvertCommand(c.ChatID, c.UserID, c.Args) }},
+		{Name: "qr", Args: "<text>", Summary: "Generate and store a QR code",
+			Run: func(b *Bot, c commandCall) { b.handleQRCommand(c.ChatID, c.UserID, strings.TrimSpace(c.Args)) }},
+		{Name: "note", Args: "<title> <text>", Summary: "Add a line to a text note (/note alone lists notes)",
+			Run: func(b *Bot, c commandCall) { b.handleNoteCommand(c.ChatID, c.UserID, c.Args) }},
+		{Name: "pin", Args: "<file>", Summary: "Protect a file from automatic deletion",
+			Run: func(b *Bot, c commandCall) { b.handlePinCommand(c.ChatID, c.UserID, c.Args, true) }},
+		{Name: "unpin", Args: "<file>", Summary: "Remove that protection",
+			Run: func(b *Bot, c commandCall) { b.handlePinCommand(c.ChatID, c.UserID, c.Args, false) }},
+		{Name: "expire", Args: "<file> <delay>", Summary: "Delete a file after e.g. 2h or 7d (off cancels)",
+			Run: func(b *Bot, c commandCall) { b.handleExpireCommand(c.ChatID, c.UserID, c.Args) }},
+		{Name: "tag", Args: "<file> <tags...>", Summary: "Tag a file (-tag removes); find it with /search tag:<tag>",
+			Run: func(b *Bot, c commandCall) { b.handleTagCommand(c.ChatID, c.UserID, c.Args) }},
+	}
+	adminUserCommands = []userCommand{
+		{Name: "admin", Summary: "Admin commands (list, add, remove users, reindex)",
+			Run: func(b *Bot, c commandCall) { b.handleAdminCommand(c.Message, c.ChatID, c.UserID) }},
+		{Name: "help", Args: "<command>", Summary: "Show the usage and details of an admin command", Run: help},
+	}
+}
+
+// parseUserCommand returns the command text calls and the text after its
+// name. Text that calls no command, or passes text to a command without
+// arguments, is not a call.
+func parseUserCommand(text string) (userCommand, string, bool) {
+	name, ok := strings.CutPrefix(text, "/")
+	if !ok {
+		return userCommand{}, "", false
+	}
+	var args string
+	if i := strings.IndexFunc(name, unicode.IsSpace); i >= 0 {
+		name, args = name[:i], name[i:]
+	}
+	for _, c := range slices.Concat(userCommands, adminUserCommands) {
+		if c.Name != name && !slices.Contains(c.Aliases, name) {
+			continue
+		}
+		if c.NoArgs && args != "" {
+			return userCommand{}, "", false
+		}
+		return c, args, true
+	}
+	return userCommand{}, "", false
+}
+
+// commandMenu returns Telegram's command menu for users, or for admins. A
+// command listed twice (/help) appears once.
+func commandMenu(admin bool) []tgbotapi.BotCommand {
+	commands := userCommands
+	if admin {
+		commands = append(slices.Clone(commands), adminUserCommands...)
+	}
+	var menu []tgbotapi.BotCommand
+	seen := make(map[string]bool)
+	for _, c := range commands {
+		if seen[c.Name] {
+			continue
+		}
+		seen[c.Name] = true
+		menu = append(menu, tgbotapi.BotCommand{Command: c.Name, Description: c.Summary})
+	}
+	return menu
+}
+
+// registerCommands sets the command menu everyone sees and, in each admin's
+// private chat, the one including admin commands. Failures are only logged:
+// the bot works without a menu.
+func (b *Bot) registerCommands() {
+	if _, err := b.request(tgbotapi.NewSetMyCommands(commandMenu(false)...)); err != nil {
+		log.Printf("Failed to register the command menu: %v", err)
+	}
+	for _, id := range b.adminIDs() {
+		b.setAdminMenu(id, true)
+	}
+}
+
+// setAdminMenu gives userID's private chat the admin command menu, or removes
+// it so the default menu applies again.
+func (b *Bot) setAdminMenu(userID int64, admin bool) {
+	scope := tgbotapi.NewBotCommandScopeChat(userID)
+	var err error
+	if admin {
+		_, err = b.request(tgbotapi.NewSetMyCommandsWithScope(scope, commandMenu(true)...))
+	} else {
+		_, err = b.request(tgbotapi.NewDeleteMyCommandsWithScope(scope))
+	}
+	if err != nil {
+		log.Printf("Failed to update the command menu of admin %d: %v", userID, err)
+	}
+}
+
+// lookupAdminCommand returns the subcommand called name.
+func lookupAdminCommand(name string) (adminCommand, bool) {
+	for _, c := range adminCommands {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return adminCommand{}, false
+}
+
+// parseAdminCommand splits "/admin <name> <args...>" into the subcommand and
+// its arguments. A missing or unknown subcommand is errUnknownAdminCommand, a
+// wrong number of arguments errAdminArgCount.
+func parseAdminCommand(text string) (adminCommand, []string, error) {
+	parts := strings.Fields(text)
+	if len(parts) < 2 {
+		return adminCommand{}, nil, errUnknownAdminCommand
+	}
+	cmd, ok := lookupAdminCommand(parts[1])
+	if !ok {
+		return adminCommand{}, nil, errUnknownAdminCommand
+	}
+	args := parts[2:]
+	if len(args) < cmd.MinArgs || (cmd.MaxArgs >= 0 && len(args) > cmd.MaxArgs) {
+		return cmd, args, fmt.Errorf("%s: %w", cmd.usage(), errAdminArgCount)
+	}
+	return cmd, args, nil
+}
+
+// formatAdminHelp lists every subcommand with its summary.
+func formatAdminHelp() string {
+	var sb strings.Builder
+	sb.WriteString("🔧 Admin Commands:\n\n")
+	for _, c := range adminCommands {
+		sb.WriteString(fmt.Sprintf("%s - %s\n", c.usage(), c.Summary))
+	}
+	sb.WriteString("\nExample: /admin add 123456789\nDetails of one command: /help <command>")
+	return sb.String()
+}
+
+// formatAdminCommandHelp describes one subcommand for /help <command>.
+func formatAdminCommandHelp(c adminCommand) string {
+	msg := fmt.Sprintf("🔧 %s\n\n%s", c.usage(), c.Summary)
+	if c.Details != "" {
+		msg += "\n\n" + c.Details
+	}
+	return msg
+}
+
+func (b *Bot) sendAdminHelp(chatID int64) {
+	b.sendLongMessage(chatID, formatAdminHelp())
+}
+
+// sendAdminCommandHelp describes the subcommand called name, given with or
+// without "/admin" in front.
+func (b *Bot) sendAdminCommandHelp(chatID int64, name string) {
+	name = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(name), "/"), "admin ")
+	cmd, ok := lookupAdminCommand(strings.TrimSpace(name))
+	if !ok {
+		b.sendTextMessage(chatID, fmt.Sprintf("❓ No admin command '%s'. Send /admin help for the list.", name))
+		return
+	}
+	b.sendTextMessage(chatID, formatAdminCommandHelp(cmd))
+}
+
+// handleHelpCommand answers /help and /help <command>. Only admin commands
+// are described one by one; everyone else gets the general help.
+func (b *Bot) handleHelpCommand(chatID, userID int64, name string) {
+	if strings.TrimSpace(name) == "" || !b.isUserAdmin(userID) {
+		b.sendHelpMessage(chatID, userID)
+		return
+	}
+	b.sendAdminCommandHelp(chatID, name)
+}
+
+func (b *Bot) handleAdminCommand(message *tgbotapi.Message, chatID int64, userID int64) {
+	if !b.isUserAdmin(userID) {
+		b.sendTextMessage(chatID, "🚫 Access denied. Admin privileges required.")
+		return
+	}
+
+	cmd, args, err := parseAdminCommand(message.Text)
+	switch {
+	case errors.Is(err, errUnknownAdminCommand):
+		b.sendAdminHelp(chatID)
+	case errors.Is(err, errAdminArgCount):
+		b.sendTextMessage(chatID, cmd.usageHelp())
+	default:
+		cmd.Run(b, adminCall{Message: message, ChatID: chatID, UserID: userID, Args: args})
+	}
+}
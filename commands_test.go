@@ -0,0 +1,222 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestAdminCommandRegistry(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, c := range adminCommands {
+		if seen[c.Name] {
+			t.Errorf("duplicate admin command %q", c.Name)
+		}
+		seen[c.Name] = true
+		if c.Summary == "" || c.Run == nil {
+			t.Errorf("admin command %q lacks a summary or handler", c.Name)
+		}
+		if c.MaxArgs >= 0 && c.MaxArgs < c.MinArgs {
+			t.Errorf("admin command %q accepts at most %d of at least %d arguments", c.Name, c.MaxArgs, c.MinArgs)
+		}
+	}
+}
+
+func TestParseAdminCommand(t *testing.T) {
+	for _, text := range []string{"/admin", "/admin   ", "/admin frobnicate 1"} {
+		if _, _, err := parseAdminCommand(text); !errors.Is(err, errUnknownAdminCommand) {
+			t.Errorf("%q: expected an unknown command, got %v", text, err)
+		}
+	}
+
+	tests := []struct {
+		text  string
+		name  string
+		args  []string
+		usage string // expected in the usage reply, empty if valid
+	}{
+		{"/admin list", "list", nil, ""},
+		{"/admin add  42", "add", []string{"42"}, ""},
+		{"/admin add", "add", nil, "Usage: /admin add <user_id>\nAdd user to allowed list"},
+		{"/admin add 1 2", "add", []string{"1", "2"}, "Usage: /admin add <user_id>"},
+		{"/admin status now", "status", []string{"now"}, "Usage: /admin status\n"},
+		{"/admin fsck --fix", "fsck", []string{"--fix"}, ""},
+		{"/admin allow 42 photos videos documents", "allow", []string{"42", "photos", "videos", "documents"}, ""},
+		{"/admin allow", "allow", nil, "Usage: /admin allow <user_id> [types|all]"},
+	}
+	for _, tt := range tests {
+		cmd, args, err := parseAdminCommand(tt.text)
+		if cmd.Name != tt.name || strings.Join(args, " ") != strings.Join(tt.args, " ") {
+			t.Errorf("%q: got %q %q", tt.text, cmd.Name, args)
+		}
+		switch {
+		case tt.usage == "" && err != nil:
+			t.Errorf("%q: unexpected error %v", tt.text, err)
+		case tt.usage != "" && (!errors.Is(err, errAdminArgCount) || !strings.Contains(cmd.usageHelp(), tt.usage)):
+			t.Errorf("%q: expected %q, got %v (%q)", tt.text, tt.usage, err, cmd.usageHelp())
+		}
+	}
+}
+
+func TestAdminCommandDispatch(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+	b.adminUsers[9] = true
+	send := func(userID int64, text string) string {
+		t.Helper()
+		b.handleAdminCommand(&tgbotapi.Message{From: &tgbotapi.User{ID: userID}, Chat: &tgbotapi.Chat{ID: userID}, Text: text}, userID, userID)
+		texts := api.texts()
+		return texts[len(texts)-1]
+	}
+
+	if reply := send(9, "/admin add 42"); !strings.Contains(reply, "User 42 added") || !b.allowedUsers[42] {
+		t.Errorf("expected user 42 to be added, got %q", reply)
+	}
+	if reply := send(9, "/admin remove"); !strings.HasPrefix(reply, "Usage: /admin remove <user_id>") || !b.allowedUsers[42] {
+		t.Errorf("expected the usage without a user ID, got %q", reply)
+	}
+	if reply := send(9, "/admin nope"); !strings.HasPrefix(reply, "🔧 Admin Commands:") {
+		t.Errorf("expected the command list for an unknown command, got %q", reply)
+	}
+	if reply := send(42, "/admin remove 42"); !strings.Contains(reply, "Access denied") || !b.allowedUsers[42] {
+		t.Errorf("expected non-admins to be refused, got %q", reply)
+	}
+}
+
+func TestAdminHelp(t *testing.T) {
+	help := formatAdminHelp()
+	for _, c := range adminCommands {
+		if !strings.Contains(help, c.usage()+" - "+c.Summary+"\n") {
+			t.Errorf("expected %q to be listed", c.Name)
+		}
+	}
+
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+	b.adminUsers[9] = true
+	for _, text := range []string{"/help fsck", "/help /admin fsck", "/help admin fsck"} {
+		b.handleMessage(&tgbotapi.Message{From: &tgbotapi.User{ID: 9}, Chat: &tgbotapi.Chat{ID: 9}, Text: text})
+	}
+	b.handleMessage(&tgbotapi.Message{From: &tgbotapi.User{ID: 9}, Chat: &tgbotapi.Chat{ID: 9}, Text: "/help frobnicate"})
+	b.handleMessage(&tgbotapi.Message{From: &tgbotapi.User{ID: 42}, Chat: &tgbotapi.Chat{ID: 42}, Text: "/help fsck"})
+
+	texts := api.texts()
+	if len(texts) != 5 {
+		t.Fatalf("expected 5 replies, got %q", texts)
+	}
+	for _, text := range texts[:3] {
+		if !strings.HasPrefix(text, "🔧 /admin fsck [--fix]\n\n") || !strings.Contains(text, "--fix brings the index") {
+			t.Errorf("unexpected command help %q", text)
+		}
+	}
+	if !strings.Contains(texts[3], "No admin command 'frobnicate'") {
+		t.Errorf("unexpected reply for an unknown command %q", texts[3])
+	}
+	if !strings.HasPrefix(texts[4], "📖 Available Commands:") {
+		t.Errorf("expected the general help for non-admins, got %q", texts[4])
+	}
+}
+
+func TestUserCommandRegistry(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, c := range userCommands {
+		for _, name := range append([]string{c.Name}, c.Aliases...) {
+			if seen[name] {
+				t.Errorf("duplicate command %q", name)
+			}
+			seen[name] = true
+		}
+	}
+	for _, c := range slices.Concat(userCommands, adminUserCommands) {
+		if c.Summary == "" || c.Run == nil {
+			t.Errorf("command %q lacks a summary or handler", c.Name)
+		}
+	}
+}
+
+func TestParseUserCommand(t *testing.T) {
+	tests := []struct {
+		text string
+		name string // empty if not a call
+		args string
+	}{
+		{"/list", "list", ""},
+		{"/list please", "", ""},
+		{"/search  report ", "search", "  report "},
+		{"/note\nshopping milk", "note", "\nshopping milk"},
+		{"/whoisadmin", "admins", ""},
+		{"/admin add 42", "admin", " add 42"},
+		{"/help fsck", "help", " fsck"},
+		{"/lists", "", ""},
+		{"list", "", ""},
+		{"/", "", ""},
+	}
+	for _, tt := range tests {
+		cmd, args, ok := parseUserCommand(tt.text)
+		if ok != (tt.name != "") || cmd.Name != tt.name || args != tt.args {
+			t.Errorf("%q: got %q %q (%v)", tt.text, cmd.Name, args, ok)
+		}
+	}
+}
+
+func TestCommandMenu(t *testing.T) {
+	valid := regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+	check := func(menu []tgbotapi.BotCommand) map[string]bool {
+		t.Helper()
+		names := make(map[string]bool)
+		for _, c := range menu {
+			if names[c.Command] {
+				t.Errorf("duplicate menu command %q", c.Command)
+			}
+			names[c.Command] = true
+			if !valid.MatchString(c.Command) || c.Description == "" || len(c.Description) > 256 {
+				t.Errorf("menu command %q (%q) is not accepted by Telegram", c.Command, c.Description)
+			}
+		}
+		return names
+	}
+
+	users := check(commandMenu(false))
+	if len(users) != len(userCommands) || !users["search"] || !users["tag"] || users["admin"] {
+		t.Errorf("unexpected user menu %v", users)
+	}
+	admins := check(commandMenu(true))
+	if len(admins) != len(users)+1 || !admins["admin"] || !admins["help"] {
+		t.Errorf("unexpected admin menu %v", admins)
+	}
+}
+
+func TestRegisterCommands(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{sendErr: func(call int, c tgbotapi.Chattable) error {
+		if call == 1 {
+			return errors.New("Too Many Requests")
+		}
+		return nil
+	}}
+	b.client = api
+	b.adminUsers = map[int64]bool{1: true, 2: true}
+
+	// A failed call is logged and the others still made
+	b.registerCommands()
+	if len(api.requests) != 2 {
+		t.Fatalf("expected a menu per admin, got %d requests", len(api.requests))
+	}
+	for i, r := range api.requests {
+		set, ok := r.(tgbotapi.SetMyCommandsConfig)
+		if !ok || set.Scope == nil || set.Scope.Type != "chat" || set.Scope.ChatID != int64(i+1) || len(set.Commands) != len(commandMenu(true)) {
+			t.Errorf("unexpected admin menu request %+v", r)
+		}
+	}
+
+	b.handleAdminPromote(1, 1, []string{"2"}, false)
+	if del, ok := api.requests[len(api.requests)-1].(tgbotapi.DeleteMyCommandsConfig); !ok || del.Scope == nil || del.Scope.ChatID != 2 {
+		t.Errorf("expected the demoted admin's menu to be removed, got %+v", api.requests[len(api.requests)-1])
+	}
+}
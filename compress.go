@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// compressedSuffix is appended to the names of files stored gzip-compressed.
+const compressedSuffix = ".gz"
+
+// parseCompressExtensions parses COMPRESS_EXTENSIONS, a comma-separated list
+// of extensions such as ".txt,.log,csv", into a lowercase set with dots.
+func parseCompressExtensions(s string) map[string]bool {
+	exts := make(map[string]bool)
+	for _, ext := range strings.Split(s, ",") {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" || ext == "." {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		exts[ext] = true
+	}
+	return exts
+}
+
+// compresses reports whether a new file of mediaType named name is stored
+// compressed. Only documents are.
+func (b *Bot) compresses(mediaType, name string) bool {
+	return mediaType == "documents" && b.compressExts[strings.ToLower(path.Ext(name))]
+}
+
+// isCompressed reports whether the stored file rel was saved compressed.
+func (b *Bot) isCompressed(rel string) bool {
+	m, err := readMeta(b.storagePath, rel)
+	return err == nil && m.Compressed
+}
+
+// userFile resolves a name given by a user to the storage-relative path of
+// their file. A compressed file is also found by its name without ".gz", and
+// under LOWERCASE_NAMES one stored before it was enabled by its exact name.
+func (b *Bot) userFile(userID int64, name string) string {
+	rel := b.userPath(userID, b.storedRelPath(name))
+	if b.lowercaseNames && !b.storedAs(rel) {
+		if asSent := b.userPath(userID, b.storedRelPathCase(name, false)); b.storedAs(asSent) {
+			rel = asSent
+		}
+	}
+	if !exists(b.localPath(rel)) && b.isCompressed(rel+compressedSuffix) {
+		return rel + compressedSuffix
+	}
+	return rel
+}
+
+// storedAs reports whether a file is stored as rel, compressed or not.
+func (b *Bot) storedAs(rel string) bool {
+	return exists(b.localPath(rel)) || b.isCompressed(rel+compressedSuffix)
+}
+
+// uncompressedName returns rel without the ".gz" added when it was stored
+// compressed.
+func uncompressedName(rel string, m FileMeta) string {
+	if m.Compressed {
+		return strings.TrimSuffix(rel, compressedSuffix)
+	}
+	return rel
+}
+
+// gzipReader returns a reader yielding r gzip-compressed. Closing it stops the
+// compression goroutine.
+func gzipReader(r io.Reader) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		gw := gzip.NewWriter(pw)
+		// Flushing writes the header first, so nothing is read from r before
+		// the consumer starts reading (saveFile relies on that to retry names)
+		err := gw.Flush()
+		if err == nil {
+			_, err = io.Copy(gw, r)
+		}
+		if closeErr := gw.Close(); err == nil {
+			err = closeErr
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// gzipBytes returns data gzip-compressed.
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := gw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// gunzipReadCloser wraps rc, the stored content of a compressed file, in a
+// reader yielding the original content. Closing it closes rc.
+func gunzipReadCloser(rc io.ReadCloser) (io.ReadCloser, error) {
+	gz, err := gzip.NewReader(rc)
+	if err != nil {
+		rc.Close()
+		return nil, fmt.Errorf("failed to decompress: %w", err)
+	}
+	return readCloser{gz, rc}, nil
+}
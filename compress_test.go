@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestParseCompressExtensions(t *testing.T) {
+	got := parseCompressExtensions(" .TXT, log ,,., .csv")
+	if len(got) != 3 || !got[".txt"] || !got[".log"] || !got[".csv"] {
+		t.Errorf("unexpected extensions %v", got)
+	}
+}
+
+func TestSaveFileCompressesRoundTrip(t *testing.T) {
+	for _, encrypted := range []bool{false, true} {
+		b := newTestBot(t)
+		b.compressExts = parseCompressExtensions(".log")
+		if encrypted {
+			b.cipher = testCipher(t, 11)
+		}
+		content := strings.Repeat("2024-05-01 INFO request handled\n", 200)
+
+		saved, err := b.saveFile(strings.NewReader(content), "documents", "server.log", "text/plain", 42)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if saved.Path != "server.log.gz" || !saved.Compressed || saved.Size != int64(len(content)) || saved.SHA256 != sha256Hex(content) {
+			t.Fatalf("encrypted=%v: unexpected metadata %+v", encrypted, saved)
+		}
+
+		raw, err := os.ReadFile(b.localPath(saved.Path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(raw) >= len(content) {
+			t.Errorf("encrypted=%v: expected the stored file to be smaller, got %d bytes", encrypted, len(raw))
+		}
+		if !encrypted && !bytes.HasPrefix(raw, []byte{0x1f, 0x8b}) {
+			t.Errorf("expected gzip content on disk")
+		}
+
+		r, err := b.openFile(saved.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(r)
+		r.Close()
+		if string(data) != content {
+			t.Errorf("encrypted=%v: openFile returned %d bytes, want the original %d", encrypted, len(data), len(content))
+		}
+
+		// A taken name is suffixed before the .gz
+		again, err := b.saveFile(strings.NewReader("second"), "documents", "server.log", "text/plain", 42)
+		if err != nil || again.Path != "server (1).log.gz" {
+			t.Errorf("encrypted=%v: expected server (1).log.gz, got %q (%v)", encrypted, again.Path, err)
+		}
+	}
+}
+
+func TestSaveFileCompressesOnlyMatchingDocuments(t *testing.T) {
+	b := newTestBot(t)
+	b.compressExts = parseCompressExtensions(".txt")
+
+	for _, c := range []struct{ mediaType, name string }{{"documents", "report.pdf"}, {"audio", "lyrics.txt"}} {
+		saved, err := b.saveFile(strings.NewReader("x"), c.mediaType, c.name, "", 42)
+		if err != nil || saved.Compressed || saved.Path != c.name {
+			t.Errorf("expected %s (%s) to be stored as is, got %+v (%v)", c.name, c.mediaType, saved, err)
+		}
+	}
+}
+
+func TestCompressedFileUsedByOriginalName(t *testing.T) {
+	b := newTestBot(t)
+	b.compressExts = parseCompressExtensions(".csv")
+	api := &mockTelegramAPI{}
+	b.client = api
+
+	if _, err := b.saveFile(strings.NewReader("a,b\n1,2\n"), "documents", "data.csv", "text/csv", 42); err != nil {
+		t.Fatal(err)
+	}
+
+	if files, _ := b.findFiles(42, "", ListLimit); len(files) != 1 || files[0].Name != "data.csv" {
+		t.Errorf("expected the listing to show data.csv, got %+v", files)
+	}
+
+	b.handleResendCommand(42, 42, " data.csv")
+	if len(api.sent) != 1 {
+		t.Fatalf("expected one upload, got %d messages", len(api.sent))
+	}
+	doc, ok := api.sent[0].(tgbotapi.DocumentConfig)
+	if !ok {
+		t.Fatalf("expected a document, got %T", api.sent[0])
+	}
+	if file := doc.File.(tgbotapi.FileBytes); file.Name != "data.csv" || string(file.Bytes) != "a,b\n1,2\n" {
+		t.Errorf("expected the decompressed data.csv, got %s: %q", file.Name, file.Bytes)
+	}
+
+	b.handleInfoCommand(42, 42, " data.csv")
+	if texts := api.texts(); len(texts) != 1 || !strings.Contains(texts[0], "Size: 8 B") {
+		t.Errorf("expected /info of the original size, got %q", texts)
+	}
+
+	from, to, err := b.resolveRename(42, "data.csv", "renamed.csv")
+	if err != nil || from != "data.csv.gz" || to != "renamed.csv.gz" {
+		t.Errorf("expected the rename to keep .gz, got %s -> %s (%v)", from, to, err)
+	}
+}
+
+func TestAppendNoteToCompressedNote(t *testing.T) {
+	b := newTestBot(t)
+	b.compressExts = parseCompressExtensions(".txt")
+
+	for _, line := range []string{"first", "second"} {
+		if _, err := b.appendNote(42, "todo.txt", line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := b.openFile("todo.txt.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "first\nsecond\n" {
+		t.Errorf("unexpected note content %q", data)
+	}
+	if _, err := os.Stat(b.localPath("todo.txt")); err == nil {
+		t.Error("expected no uncompressed copy of the note")
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// contentDirName is the hidden folder under STORAGE_PATH holding the content
+// store of DEDUP_HARDLINK: one blob per distinct content, named by its
+// SHA-256, which the stored files with that content are hardlinks of.
+const contentDirName = ".content"
+
+// contentPath returns the location of the content store blob sum.
+func (b *Bot) contentPath(sum string) string {
+	return filepath.Join(b.storagePath, contentDirName, sum[:2], sum)
+}
+
+// linkContent makes the stored file m a hardlink of the blob of its content,
+// creating the blob from it when there is none yet, and counts the link in
+// the index. Where hardlinks fail (another filesystem, or one without them)
+// the file stays a plain copy. Compressed files are left alone, as their
+// checksum is not that of the bytes on disk.
+func (b *Bot) linkContent(m FileMeta) {
+	if !b.dedupHardlink || b.index == nil || m.Compressed || len(m.SHA256) < 2 {
+		return
+	}
+	b.contentMu.Lock()
+	defer b.contentMu.Unlock()
+
+	if err := b.shareContent(m); err != nil {
+		log.Printf("Keeping %s as a plain copy: %v", m.Path, err)
+		// It no longer has the content it may have linked to before
+		b.releaseContent(m.Path)
+		return
+	}
+	old, refs, err := b.index.LinkContent(m.Path, m.SHA256)
+	if err != nil {
+		log.Printf("Failed to record the content link of %s: %v", m.Path, err)
+		return
+	}
+	if old != "" && refs == 0 {
+		b.removeBlob(old)
+	}
+}
+
+// shareContent replaces the stored file m with a hardlink of its blob, or
+// turns it into the blob when the content is new.
+func (b *Bot) shareContent(m FileMeta) error {
+	src := b.localPath(m.Path)
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	blob := b.contentPath(m.SHA256)
+	blobInfo, err := os.Stat(blob)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if err := os.MkdirAll(filepath.Dir(blob), 0755); err != nil {
+			return err
+		}
+		return os.Link(src, blob)
+	case err != nil:
+		return err
+	case os.SameFile(info, blobInfo):
+		return nil
+	case blobInfo.Size() != info.Size():
+		return fmt.Errorf("the stored blob of %s has another size", m.SHA256)
+	}
+
+	if err := replaceWithLink(blob, src); err != nil {
+		return err
+	}
+	// All links share one mtime: keep the newest, so retention never comes early
+	if info.ModTime().After(blobInfo.ModTime()) {
+		if err := os.Chtimes(blob, info.ModTime(), info.ModTime()); err != nil {
+			log.Printf("Failed to update the mtime of %s: %v", blob, err)
+		}
+	}
+	return nil
+}
+
+// unlinkContent forgets the content link of the deleted file rel, removing
+// its blob once no stored file links to it any more. It runs without
+// DEDUP_HARDLINK too, so turning it off still frees the blobs.
+func (b *Bot) unlinkContent(rel string) {
+	if b.index == nil {
+		return
+	}
+	b.contentMu.Lock()
+	defer b.contentMu.Unlock()
+	b.releaseContent(rel)
+}
+
+// releaseContent is unlinkContent with contentMu held.
+func (b *Bot) releaseContent(rel string) {
+	sum, refs, err := b.index.UnlinkContent(rel)
+	if err != nil {
+		log.Printf("Failed to remove the content link of %s: %v", rel, err)
+		return
+	}
+	if sum != "" && refs == 0 {
+		b.removeBlob(sum)
+	}
+}
+
+// removeBlob deletes the content store blob sum, and its folder when empty.
+func (b *Bot) removeBlob(sum string) {
+	blob := b.contentPath(sum)
+	if err := os.Remove(blob); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Failed to remove unused blob %s: %v", sum, err)
+		return
+	}
+	os.Remove(filepath.Dir(blob))
+}
+
+// detachContent gives the stored file rel a content of its own before it is
+// changed in place, so the change does not reach the other files hardlinked
+// to it: its content store blob and the files of other users sharing it, or
+// copies made by /share and /admin duplicates link.
+func (b *Bot) detachContent(rel string) error {
+	b.contentMu.Lock()
+	defer b.contentMu.Unlock()
+
+	if _, err := copyOnWrite(b.localPath(rel)); err != nil {
+		return err
+	}
+	if b.index != nil {
+		b.releaseContent(rel)
+	}
+	return nil
+}
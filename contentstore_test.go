@@ -0,0 +1,179 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func newContentStoreBot(t *testing.T) *Bot {
+	t.Helper()
+	b := newTestBot(t)
+	b.index = newTestIndex(t)
+	b.perUserDirs = true
+	b.dedupHardlink = true
+	return b
+}
+
+// assertRefs checks the link count the index keeps for the content sum.
+func assertRefs(t *testing.T, b *Bot, sum string, want int) {
+	t.Helper()
+	refs, err := b.index.ContentRefs(sum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refs != want {
+		t.Errorf("expected %d links to %s…, got %d", want, sum[:8], refs)
+	}
+}
+
+func TestContentStoreLinksAndUnlinks(t *testing.T) {
+	b := newContentStoreBot(t)
+	sum := sha256Hex("shared content")
+
+	var paths []string
+	for _, owner := range []int64{1, 2, 3} {
+		saved, err := b.saveFile(strings.NewReader("shared content"), "documents", "report.pdf", "application/pdf", owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, saved.Path)
+	}
+	assertRefs(t, b, sum, 3)
+
+	blob, err := os.Stat(b.contentPath(sum))
+	if err != nil {
+		t.Fatalf("expected a blob in the content store: %v", err)
+	}
+	for _, rel := range paths {
+		if info, err := os.Stat(b.localPath(rel)); err != nil || !os.SameFile(info, blob) {
+			t.Errorf("expected %s to be a hardlink of the blob (%v)", rel, err)
+		}
+	}
+
+	// Recording a file again does not count it twice
+	m, err := readMeta(b.storagePath, paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.recordFile(m)
+	assertRefs(t, b, sum, 3)
+
+	// The blob goes with the last link only
+	for i, rel := range paths {
+		if err := os.Remove(b.localPath(rel)); err != nil {
+			t.Fatal(err)
+		}
+		b.forgetFile(rel)
+		assertRefs(t, b, sum, len(paths)-i-1)
+		if last := i == len(paths)-1; exists(b.contentPath(sum)) == last {
+			t.Errorf("after deleting %d of %d files: expected the blob to exist: %v", i+1, len(paths), !last)
+		}
+	}
+}
+
+func TestContentStoreReleasesReplacedContent(t *testing.T) {
+	b := newContentStoreBot(t)
+	old, updated := sha256Hex("version 1"), sha256Hex("version 2")
+
+	if _, err := b.saveFile(strings.NewReader("version 1"), "documents", "notes.txt", "text/plain", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.users.Update(1, func(s *UserSettings) { s.Collision = CollisionOverwrite }); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := b.saveFile(strings.NewReader("version 2"), "documents", "notes.txt", "text/plain", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Path != "1/notes.txt" {
+		t.Fatalf("expected the stored file to be replaced, got %s", saved.Path)
+	}
+	assertRefs(t, b, old, 0)
+	assertRefs(t, b, updated, 1)
+	if exists(b.contentPath(old)) || !exists(b.contentPath(updated)) {
+		t.Error("expected only the blob of the new content to be kept")
+	}
+}
+
+func TestContentStoreReleasesRenamedOverContent(t *testing.T) {
+	b := newContentStoreBot(t)
+	kept, replaced := sha256Hex("shared"), sha256Hex("replaced")
+
+	for _, owner := range []int64{1, 2} {
+		if _, err := b.saveFile(strings.NewReader("shared"), "documents", "a.txt", "text/plain", owner); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := b.saveFile(strings.NewReader("replaced"), "documents", "b.txt", "text/plain", 1); err != nil {
+		t.Fatal(err)
+	}
+	// The destination is gone from disk but still linked in the index
+	if err := os.Remove(b.localPath("1/b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.renameFile("1/a.txt", "1/b.txt", false); err != nil {
+		t.Fatal(err)
+	}
+
+	assertRefs(t, b, kept, 2)
+	assertRefs(t, b, replaced, 0)
+	if exists(b.contentPath(replaced)) || !exists(b.contentPath(kept)) {
+		t.Error("expected the blob of the renamed-over content to be removed")
+	}
+	if sum, _ := b.index.LinkedContent("1/b.txt"); sum != kept {
+		t.Errorf("expected 1/b.txt to be linked to the renamed content, got %q", sum)
+	}
+}
+
+func TestContentStoreAppendDetaches(t *testing.T) {
+	b := newContentStoreBot(t)
+	b.appendDuplicates = true
+	sum := sha256Hex("line 1\n")
+
+	for _, owner := range []int64{1, 2} {
+		if _, err := b.saveFile(strings.NewReader("line 1\n"), "documents", "app.log", "text/plain", owner); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := b.saveFile(strings.NewReader("line 2\n"), "documents", "app.log", "text/plain", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := os.ReadFile(b.localPath("1/app.log")); string(data) != "line 1\nline 2\n" {
+		t.Errorf("expected the append in user 1's file, got %q", data)
+	}
+	if data, _ := os.ReadFile(b.localPath("2/app.log")); string(data) != "line 1\n" {
+		t.Errorf("expected user 2's file unchanged, got %q", data)
+	}
+	assertRefs(t, b, sum, 1)
+	assertRefs(t, b, sha256Hex("line 1\nline 2\n"), 1)
+}
+
+func TestContentStoreKeepsCopyWhenLinkFails(t *testing.T) {
+	b := newContentStoreBot(t)
+	sum := sha256Hex("content")
+
+	// A blob that cannot stand for the file leaves it a plain copy
+	writeTestFile(t, b, ".content/"+sum[:2]+"/"+sum, "something else")
+	saved, err := b.saveFile(strings.NewReader("content"), "documents", "a.txt", "text/plain", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertRefs(t, b, sum, 0)
+	if data, _ := os.ReadFile(b.localPath(saved.Path)); string(data) != "content" {
+		t.Errorf("expected the file to keep its content, got %q", data)
+	}
+}
+
+func TestContentStoreDisabled(t *testing.T) {
+	b := newContentStoreBot(t)
+	b.dedupHardlink = false
+
+	if _, err := b.saveFile(strings.NewReader("content"), "documents", "a.txt", "text/plain", 1); err != nil {
+		t.Fatal(err)
+	}
+	if exists(b.contentPath(sha256Hex("content"))) {
+		t.Error("expected no content store without DEDUP_HARDLINK")
+	}
+}
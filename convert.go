@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ConvertTimeout bounds a single ffmpeg run.
+const ConvertTimeout = 5 * time.Minute
+
+var errNoFFmpeg = errors.New("ffmpeg is not installed")
+
+// convertFormat describes an output format of /convert.
+type convertFormat struct {
+	ext  string
+	mime string
+	args []string // ffmpeg output options
+}
+
+// convertFormats are the formats /convert can produce, by name.
+var convertFormats = map[string]convertFormat{
+	"mp3": {ext: ".mp3", mime: "audio/mpeg", args: []string{"-vn", "-codec:a", "libmp3lame", "-q:a", "4", "-f", "mp3"}},
+}
+
+// transcodeMode selects what TRANSCODE_VOICE does with saved voice messages.
+type transcodeMode int
+
+const (
+	transcodeOff     transcodeMode = iota
+	transcodeBeside                // keep the .ogg and add an .mp3
+	transcodeReplace               // keep only the .mp3
+)
+
+// parseTranscodeMode parses TRANSCODE_VOICE: empty or false disables it, true
+// adds an mp3 beside the original and "replace" stores the mp3 instead.
+func parseTranscodeMode(s string) (transcodeMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "0", "false", "no", "off":
+		return transcodeOff, nil
+	case "1", "true", "yes", "on":
+		return transcodeBeside, nil
+	case "replace":
+		return transcodeReplace, nil
+	}
+	return transcodeOff, fmt.Errorf("invalid TRANSCODE_VOICE %q (want true, false or replace)", s)
+}
+
+// transcoder runs ffmpeg. A transcoder without a binary fails every conversion
+// with errNoFFmpeg and logs a warning the first time.
+type transcoder struct {
+	ffmpeg string
+
+	warnOnce sync.Once
+}
+
+// newTranscoder looks ffmpeg up in PATH.
+func newTranscoder() *transcoder {
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		ffmpeg = ""
+	}
+	return &transcoder{ffmpeg: ffmpeg}
+}
+
+func (t *transcoder) available() bool {
+	if t.ffmpeg == "" {
+		t.warnOnce.Do(func() {
+			log.Printf("Warning: ffmpeg not found in PATH, audio conversion is disabled")
+		})
+		return false
+	}
+	return true
+}
+
+// convert transcodes r to format. The output is buffered so a failing run
+// never leaves a partial file in storage.
+func (t *transcoder) convert(r io.Reader, format convertFormat) ([]byte, error) {
+	if !t.available() {
+		return nil, errNoFFmpeg
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ConvertTimeout)
+	defer cancel()
+
+	args := append([]string{"-hide_banner", "-loglevel", "error", "-i", "pipe:0"}, format.args...)
+	cmd := exec.CommandContext(ctx, t.ffmpeg, append(args, "pipe:1")...)
+
+	var out, stderr bytes.Buffer
+	cmd.Stdin = r
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if out.Len() == 0 {
+		return nil, fmt.Errorf("ffmpeg produced no output")
+	}
+	return out.Bytes(), nil
+}
+
+// convertStored transcodes the stored file rel to format and saves the result
+// beside it, named after it with the format's extension.
+func (b *Bot) convertStored(rel, mediaType string, format convertFormat, owner int64) (FileMeta, error) {
+	src, err := b.openFile(rel)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return FileMeta{}, errSourceMissing
+		}
+		return FileMeta{}, err
+	}
+	defer src.Close()
+
+	data, err := b.transcoder.convert(src, format)
+	if err != nil {
+		return FileMeta{}, err
+	}
+
+	name := strings.TrimPrefix(strings.TrimSuffix(rel, path.Ext(rel))+format.ext, b.userDir(owner)+"/")
+	return b.saveFile(bytes.NewReader(data), mediaType, name, format.mime, owner)
+}
+
+// convertVoice applies TRANSCODE_VOICE to a freshly saved voice message and
+// returns the files that now hold it. Conversion failures keep the original.
+func (b *Bot) convertVoice(saved FileMeta) []FileMeta {
+	if b.transcodeVoice == transcodeOff {
+		return []FileMeta{saved}
+	}
+	if _, ok := b.storage.(*LocalStorage); !ok {
+		return []FileMeta{saved}
+	}
+
+	mp3, err := b.convertStored(saved.Path, "voice", convertFormats["mp3"], saved.Owner)
+	if err != nil {
+		if !errors.Is(err, errNoFFmpeg) {
+			log.Printf("Error converting %s to mp3: %v", saved.Path, err)
+		}
+		return []FileMeta{saved}
+	}
+
+	if b.transcodeVoice == transcodeReplace {
+		if err := b.storage.Delete(saved.Path); err != nil {
+			log.Printf("Failed to remove %s after conversion: %v", saved.Path, err)
+			return []FileMeta{saved, mp3}
+		}
+		b.forgetFile(saved.Path)
+		b.audit(AuditEntry{User: saved.Owner, Action: "delete", Path: saved.Path, Detail: "replaced by " + mp3.Path})
+		return []FileMeta{mp3}
+	}
+	return []FileMeta{saved, mp3}
+}
+
+// parseConvertArgs parses "/convert <file> <format>" arguments.
+func parseConvertArgs(args string) (string, convertFormat, error) {
+	parts := splitArgs(args)
+	if len(parts) != 2 {
+		return "", convertFormat{}, errors.New("usage")
+	}
+
+	name, err := cleanRelPath(parts[0])
+	if err != nil {
+		return "", convertFormat{}, err
+	}
+	format, ok := convertFormats[strings.ToLower(parts[1])]
+	if !ok {
+		return "", convertFormat{}, fmt.Errorf("unsupported format %q", parts[1])
+	}
+	return name, format, nil
+}
+
+func (b *Bot) handleConvertCommand(chatID, userID int64, args string) {
+	if !b.requireLocalStorage(chatID) {
+		return
+	}
+
+	name, format, err := parseConvertArgs(args)
+	switch {
+	case errors.Is(err, errInvalidName):
+		b.sendTextMessage(chatID, "❌ Invalid file name")
+		return
+	case err != nil:
+		b.sendTextMessage(chatID, "Usage: /convert <file> mp3\nQuote names containing spaces, e.g. /convert \"my voice.ogg\" mp3")
+		return
+	}
+
+	if b.isPaused() {
+		b.sendTextMessage(chatID, "⏸ Uploads are temporarily unavailable due to maintenance. Please try again later.")
+		return
+	}
+
+	from := b.userFile(userID, name)
+	saved, err := b.convertStored(from, "audio", format, userID)
+	switch {
+	case errors.Is(err, errSourceMissing):
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ File '%s' not found", name))
+	case errors.Is(err, errNoFFmpeg):
+		b.sendTextMessage(chatID, "⚠️ Conversion is unavailable: ffmpeg is not installed on the server.")
+	case err != nil:
+		log.Printf("Error converting %s: %v", from, err)
+		b.sendTextMessage(chatID, b.failureReply(userID, err, "Failed to convert the file."))
+	default:
+		log.Printf("User %d converted %s to %s", userID, from, saved.Path)
+		b.sendTextMessage(chatID, fmt.Sprintf("✅ Converted '%s' to '%s'", name, b.displayName(userID, saved.Path))+b.checksumNote(saved.SHA256))
+	}
+}
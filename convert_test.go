@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeFFmpeg installs a stand-in for ffmpeg that upper-cases its input.
+func fakeFFmpeg(t *testing.T) *transcoder {
+	t.Helper()
+	script := filepath.Join(t.TempDir(), "ffmpeg")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\ntr a-z A-Z\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return &transcoder{ffmpeg: script}
+}
+
+func TestParseConvertArgs(t *testing.T) {
+	name, format, err := parseConvertArgs(` "my voice.ogg" MP3`)
+	if err != nil || name != "my voice.ogg" || format.ext != ".mp3" {
+		t.Errorf("unexpected result %q, %+v, %v", name, format, err)
+	}
+
+	for _, args := range []string{"", " voice.ogg", " voice.ogg mp3 extra", " voice.ogg flac"} {
+		if _, _, err := parseConvertArgs(args); err == nil {
+			t.Errorf("expected %q to be rejected", args)
+		}
+	}
+	if _, _, err := parseConvertArgs(" ../secret.ogg mp3"); !errors.Is(err, errInvalidName) {
+		t.Errorf("expected errInvalidName for a relative path, got %v", err)
+	}
+}
+
+func TestParseTranscodeMode(t *testing.T) {
+	cases := map[string]transcodeMode{"": transcodeOff, "false": transcodeOff, "true": transcodeBeside, "Replace": transcodeReplace}
+	for in, want := range cases {
+		if got, err := parseTranscodeMode(in); err != nil || got != want {
+			t.Errorf("parseTranscodeMode(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := parseTranscodeMode("sometimes"); err == nil {
+		t.Error("expected an invalid mode to be rejected")
+	}
+}
+
+func TestConvertVoiceWithoutFFmpegKeepsOriginal(t *testing.T) {
+	b := newTestBot(t)
+	b.transcodeVoice = transcodeReplace
+	saved, err := b.saveFile(strings.NewReader("ogg data"), "voice", "voice.ogg", "audio/ogg", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if got := b.convertVoice(saved); len(got) != 1 || got[0].Path != "voice.ogg" {
+			t.Fatalf("expected only the original to be kept, got %+v", got)
+		}
+	}
+	if _, err := os.Stat(b.localPath("voice.ogg")); err != nil {
+		t.Errorf("expected the original to remain: %v", err)
+	}
+}
+
+func TestConvertVoice(t *testing.T) {
+	for _, mode := range []transcodeMode{transcodeBeside, transcodeReplace} {
+		b := newTestBot(t)
+		b.transcoder = fakeFFmpeg(t)
+		b.transcodeVoice = mode
+		saved, err := b.saveFile(strings.NewReader("ogg data"), "voice", "voice.ogg", "audio/ogg", 42)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, m := range b.convertVoice(saved) {
+			got = append(got, m.Path)
+		}
+		want := []string{"voice.ogg", "voice.mp3"}
+		if mode == transcodeReplace {
+			want = want[1:]
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("mode %v: expected %q, got %q", mode, want, got)
+		}
+
+		data, _ := os.ReadFile(b.localPath("voice.mp3"))
+		if string(data) != "OGG DATA" {
+			t.Errorf("mode %v: unexpected converted content %q", mode, data)
+		}
+		meta, err := readMeta(b.storagePath, "voice.mp3")
+		if err != nil || meta.MIME != "audio/mpeg" || meta.Owner != 42 {
+			t.Errorf("mode %v: unexpected metadata %+v (%v)", mode, meta, err)
+		}
+
+		_, err = os.Stat(b.localPath("voice.ogg"))
+		_, metaErr := readMeta(b.storagePath, "voice.ogg")
+		if mode == transcodeReplace && (!os.IsNotExist(err) || metaErr == nil) {
+			t.Error("expected the original and its metadata to be removed")
+		}
+	}
+}
+
+func TestConvertCommand(t *testing.T) {
+	b := newTestBot(t)
+	b.perUserDirs = true
+	api := &mockTelegramAPI{}
+	b.client = api
+	writeTestFile(t, b, "42/shared/note.ogg", "ogg data")
+
+	b.handleConvertCommand(42, 42, " shared/note.ogg mp3")
+	if texts := api.texts(); !strings.Contains(texts[len(texts)-1], "ffmpeg is not installed") {
+		t.Errorf("expected ffmpeg-absent reply, got %q", texts[len(texts)-1])
+	}
+
+	b.transcoder = fakeFFmpeg(t)
+	b.handleConvertCommand(42, 42, " shared/note.ogg mp3")
+	if texts := api.texts(); texts[len(texts)-1] != "✅ Converted 'shared/note.ogg' to 'shared/note.mp3'" {
+		t.Errorf("unexpected reply %q", texts[len(texts)-1])
+	}
+	if _, err := os.Stat(b.localPath("42/shared/note.mp3")); err != nil {
+		t.Errorf("expected the mp3 beside the original: %v", err)
+	}
+
+	b.handleConvertCommand(42, 42, " missing.ogg mp3")
+	if texts := api.texts(); texts[len(texts)-1] != "❌ File 'missing.ogg' not found" {
+		t.Errorf("unexpected reply %q", texts[len(texts)-1])
+	}
+}
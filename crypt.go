@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Encrypted file layout:
+//
+//	magic (8 bytes) | base nonce (12 bytes) | chunk 0 | chunk 1 | ... | final chunk
+//
+// Each chunk is up to encChunkSize bytes of plaintext sealed with AES-256-GCM.
+// The nonce of chunk i is the base nonce XOR i, and the additional data binds
+// the chunk index and a final-chunk flag so reordering and truncation are detected.
+const (
+	encMagic     = "TGFSENC1"
+	encChunkSize = 64 * 1024
+	encNonceSize = 12
+)
+
+var (
+	ErrDecrypt      = errors.New("failed to decrypt file (wrong key or corrupted data)")
+	ErrNoDecryptKey = errors.New("file is encrypted but no ENCRYPTION_KEY is configured")
+)
+
+// fileCipher encrypts and decrypts stored files.
+type fileCipher struct {
+	aead cipher.AEAD
+}
+
+// parseEncryptionKey decodes a base64-encoded 32-byte key.
+func parseEncryptionKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("ENCRYPTION_KEY is not valid base64: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("ENCRYPTION_KEY must decode to 32 bytes, got %d", len(key))
+	}
+	return key, nil
+}
+
+func newFileCipher(key []byte) (*fileCipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return &fileCipher{aead: aead}, nil
+}
+
+// encryptWriter returns a writer that encrypts everything written to it into w.
+// Close must be called to seal the final chunk; it does not close w.
+func (c *fileCipher) encryptWriter(w io.Writer) (io.WriteCloser, error) {
+	nonce := make([]byte, encNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	if _, err := io.WriteString(w, encMagic); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(nonce); err != nil {
+		return nil, err
+	}
+
+	return &encWriter{c: c, w: w, nonce: nonce, buf: make([]byte, 0, encChunkSize)}, nil
+}
+
+// encryptReader returns a reader yielding the encrypted form of r.
+// Closing it stops the encryption goroutine.
+func (c *fileCipher) encryptReader(r io.Reader) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		ew, err := c.encryptWriter(pw)
+		if err == nil {
+			_, err = io.Copy(ew, r)
+			if closeErr := ew.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// decryptReader returns a reader yielding the plaintext of an encrypted stream.
+// The magic header must already have been consumed.
+func (c *fileCipher) decryptReader(r io.Reader) (io.Reader, error) {
+	nonce := make([]byte, encNonceSize)
+	if _, err := io.ReadFull(r, nonce); err != nil {
+		return nil, fmt.Errorf("%w: truncated header", ErrDecrypt)
+	}
+	return &decReader{c: c, r: bufio.NewReaderSize(r, encChunkSize+c.aead.Overhead()+1), nonce: nonce}, nil
+}
+
+func (c *fileCipher) chunkNonce(base []byte, index uint64) []byte {
+	nonce := make([]byte, len(base))
+	copy(nonce, base)
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], index)
+	for i := range counter {
+		nonce[len(nonce)-8+i] ^= counter[i]
+	}
+	return nonce
+}
+
+func chunkAAD(index uint64, last bool) []byte {
+	aad := make([]byte, 9)
+	binary.BigEndian.PutUint64(aad, index)
+	if last {
+		aad[8] = 1
+	}
+	return aad
+}
+
+type encWriter struct {
+	c     *fileCipher
+	w     io.Writer
+	nonce []byte
+	buf   []byte
+	index uint64
+}
+
+func (e *encWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// A full buffer is only sealed once more data arrives, so the final
+		// chunk can always be flagged as such in Close.
+		if len(e.buf) == encChunkSize {
+			if err := e.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(e.buf[len(e.buf):encChunkSize], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (e *encWriter) Close() error {
+	return e.seal(true)
+}
+
+func (e *encWriter) seal(last bool) error {
+	sealed := e.c.aead.Seal(nil, e.c.chunkNonce(e.nonce, e.index), e.buf, chunkAAD(e.index, last))
+	e.index++
+	e.buf = e.buf[:0]
+	_, err := e.w.Write(sealed)
+	return err
+}
+
+type decReader struct {
+	c     *fileCipher
+	r     *bufio.Reader
+	nonce []byte
+	index uint64
+	plain []byte
+	done  bool
+}
+
+func (d *decReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+func (d *decReader) next() error {
+	sealed := make([]byte, encChunkSize+d.c.aead.Overhead())
+	n, err := io.ReadFull(d.r, sealed)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: truncated data", ErrDecrypt)
+	}
+
+	last := err == io.ErrUnexpectedEOF
+	if !last {
+		if _, peekErr := d.r.Peek(1); peekErr == io.EOF {
+			last = true
+		}
+	}
+
+	plain, openErr := d.c.aead.Open(nil, d.c.chunkNonce(d.nonce, d.index), sealed[:n], chunkAAD(d.index, last))
+	if openErr != nil {
+		return ErrDecrypt
+	}
+
+	d.index++
+	d.plain = plain
+	d.done = last
+	return nil
+}
+
+// openStored opens a stored file and returns its plaintext. Files written
+// without encryption are detected by the missing magic header and returned as-is.
+func openStored(path string, c *fileCipher) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, len(encMagic))
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		f.Close()
+		return nil, err
+	}
+	header = header[:n]
+
+	if !bytes.Equal(header, []byte(encMagic)) {
+		return readCloser{io.MultiReader(bytes.NewReader(header), f), f}, nil
+	}
+
+	if c == nil {
+		f.Close()
+		return nil, ErrNoDecryptKey
+	}
+
+	plain, err := c.decryptReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return readCloser{plain, f}, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// openFile opens a storage-relative file for reading, decrypting and
+// decompressing it if needed. All code reading stored content back must go
+// through it.
+func (b *Bot) openFile(rel string) (io.ReadCloser, error) {
+	f, err := b.openPath(b.localPath(rel))
+	if err != nil || !b.isCompressed(rel) {
+		return f, err
+	}
+	return gunzipReadCloser(f)
+}
+
+// openPath is openFile for an absolute filesystem path.
+func (b *Bot) openPath(path string) (io.ReadCloser, error) {
+	return openStored(path, b.cipher)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testCipher(t *testing.T, seed byte) *fileCipher {
+	t.Helper()
+	c, err := newFileCipher(bytes.Repeat([]byte{seed}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func encryptBytes(t *testing.T, c *fileCipher, plain []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := c.encryptWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func writeEncrypted(t *testing.T, c *fileCipher, plain []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "file.bin")
+	if err := os.WriteFile(path, encryptBytes(t, c, plain), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func readStored(path string, c *fileCipher) ([]byte, error) {
+	r, err := openStored(path, c)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func TestEncryptionRoundTrip(t *testing.T) {
+	c := testCipher(t, 1)
+
+	sizes := map[string]int{
+		"empty":          0,
+		"small":          100,
+		"chunk boundary": encChunkSize,
+		"multi chunk":    3*encChunkSize + 17,
+	}
+	for name, size := range sizes {
+		t.Run(name, func(t *testing.T) {
+			plain := bytes.Repeat([]byte("x"), size)
+			path := writeEncrypted(t, c, plain)
+
+			raw, _ := os.ReadFile(path)
+			if !bytes.HasPrefix(raw, []byte(encMagic)) {
+				t.Fatal("expected encrypted file to start with the magic header")
+			}
+
+			got, err := readStored(path, c)
+			if err != nil {
+				t.Fatalf("decrypt failed: %v", err)
+			}
+			if !bytes.Equal(got, plain) {
+				t.Errorf("round trip mismatch: got %d bytes, want %d", len(got), len(plain))
+			}
+		})
+	}
+}
+
+func TestDecryptWithWrongKeyFails(t *testing.T) {
+	path := writeEncrypted(t, testCipher(t, 1), []byte("secret"))
+
+	if _, err := readStored(path, testCipher(t, 2)); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("expected ErrDecrypt with the wrong key, got %v", err)
+	}
+}
+
+func TestDecryptDetectsTruncation(t *testing.T) {
+	c := testCipher(t, 1)
+	data := encryptBytes(t, c, bytes.Repeat([]byte("y"), 2*encChunkSize+5))
+
+	// Drop the final chunk, leaving a stream that ends on a chunk boundary
+	truncated := data[:len(encMagic)+encNonceSize+2*(encChunkSize+c.aead.Overhead())]
+	path := filepath.Join(t.TempDir(), "truncated.bin")
+	os.WriteFile(path, truncated, 0644)
+
+	if _, err := readStored(path, c); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("expected truncation to be detected, got %v", err)
+	}
+}
+
+func TestOpenStoredPlaintext(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"legacy.txt": "plain old file", "tiny.txt": "ab"} {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0644)
+
+		got, err := readStored(path, testCipher(t, 1))
+		if err != nil || string(got) != content {
+			t.Errorf("expected %s to be read as-is, got %q (%v)", name, got, err)
+		}
+	}
+}
+
+func TestOpenStoredEncryptedWithoutKey(t *testing.T) {
+	path := writeEncrypted(t, testCipher(t, 1), []byte("secret"))
+
+	if _, err := readStored(path, nil); !errors.Is(err, ErrNoDecryptKey) {
+		t.Errorf("expected ErrNoDecryptKey, got %v", err)
+	}
+}
+
+func TestParseEncryptionKey(t *testing.T) {
+	if _, err := parseEncryptionKey(base64.StdEncoding.EncodeToString(make([]byte, 32))); err != nil {
+		t.Errorf("expected a 32-byte key to be accepted, got %v", err)
+	}
+	for _, bad := range []string{"not base64!", base64.StdEncoding.EncodeToString(make([]byte, 16))} {
+		if _, err := parseEncryptionKey(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestSaveFileEncryptsAtRest(t *testing.T) {
+	b := newTestBot(t)
+	b.cipher = testCipher(t, 3)
+	content := bytes.Repeat([]byte("confidential "), 10000)
+
+	saved, err := b.saveFile(bytes.NewReader(content), "documents", "secret.bin", "application/octet-stream", 42)
+	if err != nil {
+		t.Fatalf("saveFile failed: %v", err)
+	}
+	checksum := saved.SHA256
+
+	raw, _ := os.ReadFile(b.localPath("secret.bin"))
+	if !bytes.HasPrefix(raw, []byte(encMagic)) || bytes.Contains(raw, []byte("confidential")) {
+		t.Fatal("expected stored file to be encrypted")
+	}
+
+	r, err := b.openFile("secret.bin")
+	if err != nil {
+		t.Fatalf("openFile failed: %v", err)
+	}
+	defer r.Close()
+	got, _ := io.ReadAll(r)
+	if !bytes.Equal(got, content) {
+		t.Error("expected openFile to return the plaintext")
+	}
+
+	sum := sha256.Sum256(content)
+	meta, _ := readMeta(b.storagePath, "secret.bin")
+	if checksum != hex.EncodeToString(sum[:]) || meta.Size != int64(len(content)) {
+		t.Errorf("expected checksum and size of the plaintext, got %s / %d", checksum, meta.Size)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// DefaultDuplicateWindow is how long a sent file counts as a duplicate when re-sent.
+const DefaultDuplicateWindow = 30 * time.Second
+
+// recentUploads remembers which files each user sent recently, to catch
+// accidental double sends of the same Telegram file. A nil *recentUploads
+// disables the check.
+type recentUploads struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu   sync.Mutex
+	seen map[string]time.Time // userID/FileUniqueID -> last sent
+}
+
+// newRecentUploads returns a cache remembering uploads for window.
+func newRecentUploads(window time.Duration) *recentUploads {
+	return &recentUploads{window: window, now: time.Now, seen: make(map[string]time.Time)}
+}
+
+// Seen reports whether the same user had the same file stored within the
+// window. Checking does not extend the window.
+func (r *recentUploads) Seen(userID int64, fileUniqueID string) bool {
+	if r == nil || fileUniqueID == "" {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	at, ok := r.seen[uploadKey(userID, fileUniqueID)]
+	return ok && r.now().Sub(at) <= r.window
+}
+
+// Mark records that userID's file was stored, once the save succeeded, so
+// failed uploads can be retried at once.
+func (r *recentUploads) Mark(userID int64, fileUniqueID string) {
+	if r == nil || fileUniqueID == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	for key, at := range r.seen {
+		if now.Sub(at) > r.window {
+			delete(r.seen, key)
+		}
+	}
+	r.seen[uploadKey(userID, fileUniqueID)] = now
+}
+
+func uploadKey(userID int64, fileUniqueID string) string {
+	return fmt.Sprintf("%d/%s", userID, fileUniqueID)
+}
+
+// messageFileUniqueID returns the FileUniqueID of a message's media, or ""
+// for non-media messages. For photos it is the largest size.
+func messageFileUniqueID(message *tgbotapi.Message) string {
+	if media := messageMedia(message); media != nil {
+		return media.FileUniqueID
+	}
+	return ""
+}
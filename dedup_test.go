@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestRecentUploadsWindow(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	r := newRecentUploads(30 * time.Second)
+	r.now = func() time.Time { return now }
+
+	if r.Seen(42, "AQAD") {
+		t.Fatal("an unmarked upload must not be a duplicate")
+	}
+	r.Mark(42, "AQAD")
+	now = now.Add(10 * time.Second)
+	if !r.Seen(42, "AQAD") {
+		t.Error("expected a re-send within the window to be a duplicate")
+	}
+	if r.Seen(7, "AQAD") || r.Seen(42, "BQAD") {
+		t.Error("other users and other files must not be duplicates")
+	}
+
+	// Hits do not slide the window forward
+	now = now.Add(15 * time.Second)
+	if !r.Seen(42, "AQAD") {
+		t.Error("expected a re-send within the window to be a duplicate")
+	}
+	now = now.Add(6 * time.Second)
+	if r.Seen(42, "AQAD") {
+		t.Error("expected a re-send after the window to be accepted")
+	}
+
+	var disabled *recentUploads
+	disabled.Mark(42, "AQAD")
+	if disabled.Seen(42, "AQAD") {
+		t.Error("a disabled cache must never report duplicates")
+	}
+}
+
+func TestDuplicateUploadIsNotSavedTwice(t *testing.T) {
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("report-bytes"))
+	}))
+	defer files.Close()
+
+	b := newDownloadTestBot(t, time.Second)
+	api := &mockTelegramAPI{fileURL: files.URL}
+	b.client = api
+	b.recentUploads = newRecentUploads(time.Minute)
+
+	upload := &tgbotapi.Message{
+		From:     &tgbotapi.User{ID: 42},
+		Chat:     &tgbotapi.Chat{ID: 42},
+		Document: &tgbotapi.Document{FileID: "file", FileUniqueID: "AQAD", FileName: "report.pdf"},
+	}
+	b.handleMessage(upload)
+	b.handleMessage(upload)
+
+	texts := api.texts()
+	if len(texts) != 2 || texts[1] != "♻️ Looks like a duplicate, already saved." {
+		t.Errorf("expected the second send to be reported as a duplicate, got %q", texts)
+	}
+	if exists(b.localPath("report (1).pdf")) {
+		t.Error("expected the duplicate not to be stored")
+	}
+}
+
+func TestFailedUploadCanBeRetried(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("report-bytes"))
+	}))
+	defer files.Close()
+
+	b := newDownloadTestBot(t, time.Second)
+	api := &mockTelegramAPI{fileURL: files.URL}
+	b.client = api
+	b.recentUploads = newRecentUploads(time.Minute)
+
+	upload := &tgbotapi.Message{
+		From:     &tgbotapi.User{ID: 42},
+		Chat:     &tgbotapi.Chat{ID: 42},
+		Document: &tgbotapi.Document{FileID: "file", FileUniqueID: "AQAD", FileName: "report.pdf"},
+	}
+	b.handleMessage(upload)
+	if exists(b.localPath("report.pdf")) {
+		t.Fatal("expected the failed download not to be stored")
+	}
+
+	fail.Store(false)
+	b.handleMessage(upload)
+	if data, err := os.ReadFile(b.localPath("report.pdf")); err != nil || string(data) != "report-bytes" {
+		t.Fatalf("expected the retry to be stored, got %q (%v)", data, err)
+	}
+	if texts := api.texts(); strings.Contains(strings.Join(texts, "\n"), "duplicate") {
+		t.Errorf("expected the retry not to count as a duplicate, got %q", texts)
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// MaxDiffBytes is how much of each text file /diff compares line by line.
+	MaxDiffBytes = 64 * 1024
+	// MaxDiffLines is how many lines of each text file /diff compares.
+	MaxDiffLines = 1000
+	// MaxDiffOutputLines is how much of the unified diff /diff shows, and
+	// MaxDiffLineChars how much of each of its lines.
+	MaxDiffOutputLines = 40
+	MaxDiffLineChars   = 100
+	// diffContext is the number of unchanged lines around each change.
+	diffContext = 3
+)
+
+// fileDigest returns the size and SHA-256 of the content of the stored file
+// rel: from the index when it has them, else from the sidecar or by hashing.
+func (b *Bot) fileDigest(rel string) (int64, string, error) {
+	src := b.localPath(rel)
+	info, err := os.Stat(src)
+	if err != nil || !info.Mode().IsRegular() {
+		return 0, "", errSourceMissing
+	}
+
+	if b.index != nil {
+		m, ok, err := b.index.Get(rel)
+		if err != nil {
+			log.Printf("Failed to look up %s in the index: %v", rel, err)
+		}
+		if ok && m.SHA256 != "" {
+			return m.Size, m.SHA256, nil
+		}
+	}
+	m := b.fileMeta(rel, src, info)
+	if m.SHA256 == "" {
+		return 0, "", fmt.Errorf("failed to hash %s", rel)
+	}
+	return m.Size, m.SHA256, nil
+}
+
+// readTextLines returns the lines of the first MaxDiffBytes (at most
+// MaxDiffLines lines) of the stored file rel and whether that was all of it.
+// It fails with errNotPreviewable for content that is not UTF-8 text.
+func (b *Bot) readTextLines(rel string) ([]string, bool, error) {
+	f, err := b.openFile(rel)
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+
+	head, err := io.ReadAll(io.LimitReader(f, MaxDiffBytes+1))
+	if err != nil {
+		return nil, false, err
+	}
+	complete := len(head) <= MaxDiffBytes
+	if !complete {
+		head = head[:MaxDiffBytes]
+		// The read may have stopped inside a character
+		for i := 0; i < utf8.UTFMax-1 && len(head) > 0 && !utf8.Valid(head); i++ {
+			head = head[:len(head)-1]
+		}
+	}
+	if !utf8.Valid(head) || bytes.IndexByte(head, 0) >= 0 {
+		return nil, false, errNotPreviewable
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(head), "\n"), "\n")
+	if len(lines) > MaxDiffLines {
+		lines, complete = lines[:MaxDiffLines], false
+	}
+	return lines, complete, nil
+}
+
+// diffOp is one line of an edit script: ' ' kept, '-' only in a, '+' only
+// in b. A and B are the positions in a and b where it applies.
+type diffOp struct {
+	Kind byte
+	A, B int
+}
+
+// diffLines returns the shortest edit script turning a into b, from their
+// longest common subsequence.
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i] == b[j]:
+			ops = append(ops, diffOp{' ', i, j})
+			i++
+			j++
+		case i < n && (j == m || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{'-', i, j})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', i, j})
+			j++
+		}
+	}
+	return ops
+}
+
+// unifiedDiff formats the differences between a and b as unified diff hunks,
+// keeping at most maxLines lines, and reports whether it cut any off.
+func unifiedDiff(a, b []string, nameA, nameB string, maxLines int) (string, bool) {
+	ops := diffLines(a, b)
+	out := []string{"--- " + nameA, "+++ " + nameB}
+
+	prevEnd := 0
+	for k := 0; k < len(ops); {
+		for k < len(ops) && ops[k].Kind == ' ' {
+			k++
+		}
+		if k == len(ops) {
+			break
+		}
+
+		// A hunk runs until the changes are more than two contexts apart
+		last := k
+		for e := k; e < len(ops) && e-last <= 2*diffContext; e++ {
+			if ops[e].Kind != ' ' {
+				last = e
+			}
+		}
+		start, end := max(k-diffContext, prevEnd), min(last+diffContext+1, len(ops))
+
+		var lenA, lenB int
+		var body []string
+		for _, op := range ops[start:end] {
+			switch op.Kind {
+			case ' ':
+				body = append(body, " "+a[op.A])
+				lenA++
+				lenB++
+			case '-':
+				body = append(body, "-"+a[op.A])
+				lenA++
+			case '+':
+				body = append(body, "+"+b[op.B])
+				lenB++
+			}
+		}
+		startA, startB := ops[start].A, ops[start].B
+		if lenA > 0 {
+			startA++
+		}
+		if lenB > 0 {
+			startB++
+		}
+		out = append(out, fmt.Sprintf("@@ -%d,%d +%d,%d @@", startA, lenA, startB, lenB))
+		out = append(out, body...)
+		prevEnd, k = end, end
+	}
+
+	truncated := len(out) > maxLines
+	if truncated {
+		out = out[:maxLines]
+	}
+	for i, line := range out {
+		if utf8.RuneCountInString(line) > MaxDiffLineChars {
+			out[i] = string([]rune(line)[:MaxDiffLineChars]) + "…"
+		}
+	}
+	return strings.Join(out, "\n"), truncated
+}
+
+// compareFiles describes whether the stored files relA and relB, called nameA
+// and nameB, are identical, with a diff of their start for text files.
+func (b *Bot) compareFiles(relA, relB, nameA, nameB string) (string, error) {
+	sizeA, sumA, err := b.fileDigest(relA)
+	if err != nil {
+		return "", err
+	}
+	sizeB, sumB, err := b.fileDigest(relB)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case sizeA == sizeB && sumA == sumB:
+		return html.EscapeString(fmt.Sprintf("✅ '%s' and '%s' are identical (%s, SHA-256 %s)", nameA, nameB, formatSize(sizeA), sumA)), nil
+	case sizeA != sizeB:
+		msg := fmt.Sprintf("❌ '%s' and '%s' differ: %s vs %s", nameA, nameB, formatSize(sizeA), formatSize(sizeB))
+		return b.appendTextDiff(html.EscapeString(msg), relA, relB, nameA, nameB)
+	default:
+		msg := fmt.Sprintf("❌ '%s' and '%s' differ: same size (%s), different content", nameA, nameB, formatSize(sizeA))
+		return b.appendTextDiff(html.EscapeString(msg), relA, relB, nameA, nameB)
+	}
+}
+
+// appendTextDiff adds to msg the first differences of relA and relB when both
+// are text files. Binary files are only reported as different.
+func (b *Bot) appendTextDiff(msg, relA, relB, nameA, nameB string) (string, error) {
+	linesA, completeA, err := b.readTextLines(relA)
+	if errors.Is(err, errNotPreviewable) {
+		return msg, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	linesB, completeB, err := b.readTextLines(relB)
+	if errors.Is(err, errNotPreviewable) {
+		return msg, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// Show as much of the diff as fits in one message
+	for maxLines := MaxDiffOutputLines; maxLines > 2; maxLines -= 5 {
+		diff, truncated := unifiedDiff(linesA, linesB, nameA, nameB, maxLines)
+		if !strings.Contains(diff, "\n@@") {
+			if !completeA || !completeB {
+				msg += fmt.Sprintf("\n\nTheir first %s (or %d lines) are identical.", formatSize(MaxDiffBytes), MaxDiffLines)
+			}
+			return msg, nil
+		}
+		text := msg + "\n<pre>" + html.EscapeString(diff) + "</pre>"
+		if truncated {
+			text += fmt.Sprintf("\n(first %d lines of the diff)", maxLines)
+		}
+		if len(text) <= MaxMessageLength {
+			return text, nil
+		}
+	}
+	return msg, nil
+}
+
+// handleDiffCommand compares two of the user's files.
+func (b *Bot) handleDiffCommand(chatID, userID int64, args string) {
+	if !b.requireLocalStorage(chatID) {
+		return
+	}
+
+	parts := splitArgs(args)
+	if len(parts) != 2 {
+		b.sendTextMessage(chatID, "Usage: /diff <file1> <file2>\nQuote names containing spaces, e.g. /diff \"my notes.txt\" notes.txt")
+		return
+	}
+	var names, rels [2]string
+	for i, part := range parts {
+		name, err := cleanRelPath(part)
+		if err != nil {
+			b.sendTextMessage(chatID, "❌ Invalid file name")
+			return
+		}
+		names[i], rels[i] = name, b.userFile(userID, name)
+		if _, err := os.Stat(b.localPath(rels[i])); err != nil {
+			b.sendTextMessage(chatID, fmt.Sprintf("❌ File '%s' not found", name))
+			return
+		}
+	}
+
+	text, err := b.compareFiles(rels[0], rels[1], names[0], names[1])
+	if err != nil {
+		log.Printf("Error comparing %s and %s: %v", rels[0], rels[1], err)
+		b.sendTextMessage(chatID, "❌ Failed to compare the files.")
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	if _, err := b.send(msg); err != nil {
+		log.Printf("Failed to send message: %v", err)
+	}
+}
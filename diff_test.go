@@ -0,0 +1,103 @@
+package main
+
+import (
+	"html"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	a := []string{"one", "two", "three", "four", "five", "six", "seven", "eight", "nine", "ten", "eleven", "twelve"}
+	b := []string{"one", "2", "three", "four", "five", "six", "seven", "eight", "nine", "ten", "eleven", "twelve", "thirteen"}
+
+	got, truncated := unifiedDiff(a, b, "a.txt", "b.txt", 100)
+	want := `--- a.txt
++++ b.txt
+@@ -1,5 +1,5 @@
+ one
+-two
++2
+ three
+ four
+ five
+@@ -10,3 +10,4 @@
+ ten
+ eleven
+ twelve
++thirteen`
+	if got != want || truncated {
+		t.Errorf("unifiedDiff =\n%s\nwant\n%s", got, want)
+	}
+
+	if got, truncated := unifiedDiff(a, b, "a.txt", "b.txt", 4); !truncated || strings.Count(got, "\n") != 3 {
+		t.Errorf("expected the diff cut to 4 lines, got %q (%v)", got, truncated)
+	}
+	if got, _ := unifiedDiff(a, a, "a.txt", "b.txt", 100); got != "--- a.txt\n+++ b.txt" {
+		t.Errorf("expected no hunks for equal input, got %q", got)
+	}
+}
+
+func TestDiffCommand(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+	last := func() string {
+		texts := api.texts()
+		return html.UnescapeString(texts[len(texts)-1])
+	}
+	writeTestFile(t, b, "a.txt", "alpha\nbeta\ngamma\n")
+	writeTestFile(t, b, "copy.txt", "alpha\nbeta\ngamma\n")
+	writeTestFile(t, b, "longer.txt", "alpha\nbeta <b>\ngamma\ndelta\n")
+	writeTestFile(t, b, "same-size.txt", "alpha\nBETA\ngamma\n")
+	writeTestFile(t, b, "a.bin", "\x00\x01\x02")
+	writeTestFile(t, b, "b.bin", "\x00\x01\x02\x03")
+
+	b.handleDiffCommand(42, 42, " a.txt copy.txt")
+	if reply := last(); !strings.HasPrefix(reply, "✅ 'a.txt' and 'copy.txt' are identical (17 B, SHA-256 ") {
+		t.Errorf("unexpected reply for identical files %q", reply)
+	}
+
+	b.handleDiffCommand(42, 42, " a.txt longer.txt")
+	want := "❌ 'a.txt' and 'longer.txt' differ: 17 B vs 27 B\n<pre>--- a.txt\n+++ longer.txt\n@@ -1,3 +1,4 @@\n alpha\n-beta\n+beta <b>\n gamma\n+delta</pre>"
+	if reply := last(); reply != want {
+		t.Errorf("unexpected reply for different sizes %q", reply)
+	}
+
+	b.handleDiffCommand(42, 42, " a.txt same-size.txt")
+	if reply := last(); !strings.HasPrefix(reply, "❌ 'a.txt' and 'same-size.txt' differ: same size (17 B), different content\n<pre>") ||
+		!strings.Contains(reply, "\n-beta\n+BETA\n") {
+		t.Errorf("unexpected reply for same-size files %q", reply)
+	}
+
+	// Binary files are only reported as different
+	b.handleDiffCommand(42, 42, " a.bin b.bin")
+	if reply := last(); reply != "❌ 'a.bin' and 'b.bin' differ: 3 B vs 4 B" {
+		t.Errorf("unexpected reply for binary files %q", reply)
+	}
+
+	b.handleDiffCommand(42, 42, " a.txt missing.txt")
+	if reply := last(); reply != "❌ File 'missing.txt' not found" {
+		t.Errorf("unexpected reply for a missing file %q", reply)
+	}
+}
+
+func TestDiffUsesIndexHashes(t *testing.T) {
+	b := newTestBot(t)
+	b.index = newTestIndex(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+	writeTestFile(t, b, "a.txt", "one")
+	writeTestFile(t, b, "b.txt", "two")
+
+	// The indexed hashes are trusted as they are
+	for _, rel := range []string{"a.txt", "b.txt"} {
+		if err := b.index.Put(FileMeta{Path: rel, Size: 3, SHA256: "same", ReceivedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.handleDiffCommand(42, 42, " a.txt b.txt")
+	if texts := api.texts(); !strings.HasPrefix(html.UnescapeString(texts[len(texts)-1]), "✅ 'a.txt' and 'b.txt' are identical") {
+		t.Errorf("expected the index hashes to be used, got %q", texts[len(texts)-1])
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// DefaultDiskWarnPercent is the disk usage at which admins are warned that
+// storage is filling up.
+const DefaultDiskWarnPercent = 90
+
+// DiskCheckSaveSize is the size from which a save checks the disk usage right
+// away instead of waiting for the janitor.
+const DiskCheckSaveSize = 100 << 20
+
+// parseDiskWarnPercent parses DISK_WARN_PERCENT; empty means the default and
+// 0 disables the warning.
+func parseDiskWarnPercent(s string) (int, error) {
+	s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "%"))
+	if s == "" {
+		return DefaultDiskWarnPercent, nil
+	}
+	percent, err := strconv.Atoi(s)
+	if err != nil || percent < 0 || percent > 100 {
+		return 0, fmt.Errorf("invalid DISK_WARN_PERCENT %q: expected a percentage from 0 to 100", s)
+	}
+	return percent, nil
+}
+
+// diskStats is the size and free space of the filesystem holding a path.
+type diskStats struct {
+	Total uint64
+	Free  uint64 // available to the bot, without the root reserve
+}
+
+// usedPercent counts the root reserve as used, since the bot cannot write to it.
+func (s diskStats) usedPercent() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return 100 * float64(s.Total-s.Free) / float64(s.Total)
+}
+
+// statDisk returns the stats of the filesystem holding path.
+func statDisk(path string) (diskStats, error) {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(path, &fs); err != nil {
+		return diskStats{}, err
+	}
+	return diskStats{Total: fs.Blocks * uint64(fs.Bsize), Free: fs.Bavail * uint64(fs.Bsize)}, nil
+}
+
+// checkDiskSpace warns the admins when the fullest data root crosses
+// DISK_WARN_PERCENT, once until its usage drops below the threshold again.
+// Only local storage is checked.
+func (b *Bot) checkDiskSpace() {
+	if b.diskWarnPercent == 0 || b.statfs == nil {
+		return
+	}
+	if _, ok := b.storage.(*LocalStorage); !ok {
+		return
+	}
+
+	var fullest diskStats
+	var fullestRoot string
+	for _, root := range b.dataRoots() {
+		stats, err := b.statfs(root)
+		if err != nil {
+			log.Printf("Failed to check free space of %s: %v", root, err)
+			continue
+		}
+		if fullestRoot == "" || stats.usedPercent() > fullest.usedPercent() {
+			fullest, fullestRoot = stats, root
+		}
+	}
+	if fullestRoot == "" {
+		return
+	}
+
+	if fullest.usedPercent() < float64(b.diskWarnPercent) {
+		if b.diskWarned.CompareAndSwap(true, false) {
+			log.Printf("Disk usage of %s is back below %d%%", fullestRoot, b.diskWarnPercent)
+		}
+		return
+	}
+	if !b.diskWarned.CompareAndSwap(false, true) {
+		return
+	}
+
+	msg := fmt.Sprintf("⚠️ Storage is %.1f%% full (warning at %d%%): %s used, %s free of %s in %s.",
+		fullest.usedPercent(), b.diskWarnPercent,
+		formatSize(int64(fullest.Total-fullest.Free)), formatSize(int64(fullest.Free)), formatSize(int64(fullest.Total)), fullestRoot)
+	log.Print(msg)
+	for _, adminID := range b.adminIDs() {
+		b.sendTextMessage(adminID, msg)
+	}
+}
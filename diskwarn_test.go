@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseDiskWarnPercent(t *testing.T) {
+	for input, want := range map[string]int{"": DefaultDiskWarnPercent, "80": 80, " 95% ": 95, "0": 0, "100": 100} {
+		if got, err := parseDiskWarnPercent(input); err != nil || got != want {
+			t.Errorf("parseDiskWarnPercent(%q) = %d, %v; want %d", input, got, err, want)
+		}
+	}
+	for _, bad := range []string{"-1", "101", "most"} {
+		if _, err := parseDiskWarnPercent(bad); err == nil {
+			t.Errorf("expected DISK_WARN_PERCENT=%q to be rejected", bad)
+		}
+	}
+}
+
+func TestCheckDiskSpaceWarnsOncePerCrossing(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+	b.adminUsers[9] = true
+	b.diskWarnPercent = 90
+
+	const gb = 1 << 30
+	free := uint64(50 * gb)
+	var statErr error
+	b.statfs = func(string) (diskStats, error) { return diskStats{Total: 100 * gb, Free: free}, statErr }
+
+	check := func(freeGB uint64) []string {
+		t.Helper()
+		free = freeGB * gb
+		before := len(api.texts())
+		b.checkDiskSpace()
+		return api.texts()[before:]
+	}
+
+	if texts := check(50); len(texts) != 0 {
+		t.Errorf("expected no warning at 50%%, got %q", texts)
+	}
+	texts := check(8)
+	if len(texts) != 1 || !strings.HasPrefix(texts[0], "⚠️ Storage is 92.0% full (warning at 90%): 92.0 GB used, 8.0 GB free of 100.0 GB") {
+		t.Fatalf("expected a warning at 92%%, got %q", texts)
+	}
+	if texts := check(5); len(texts) != 0 {
+		t.Errorf("expected a single warning while above the threshold, got %q", texts)
+	}
+
+	// Dropping below the threshold re-arms the warning
+	if texts := check(20); len(texts) != 0 {
+		t.Errorf("expected no warning at 80%%, got %q", texts)
+	}
+	if texts := check(10); len(texts) != 1 || !strings.Contains(texts[0], "90.0% full") {
+		t.Errorf("expected a warning on crossing again, got %q", texts)
+	}
+
+	statErr = errors.New("no such device")
+	if texts := check(1); len(texts) != 0 || !b.diskWarned.Load() {
+		t.Errorf("expected failed checks to change nothing, got %q", texts)
+	}
+
+	b.diskWarnPercent = 0
+	b.diskWarned.Store(false)
+	statErr = nil
+	if texts := check(1); len(texts) != 0 {
+		t.Errorf("expected no warning with DISK_WARN_PERCENT=0, got %q", texts)
+	}
+}
+
+func TestStatDisk(t *testing.T) {
+	stats, err := statDisk(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Total == 0 || stats.Free > stats.Total {
+		t.Errorf("implausible stats %+v", stats)
+	}
+}
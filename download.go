@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultHTTPTimeout bounds connecting, waiting for response headers and each
+// pause between body reads of a download.
+const DefaultHTTPTimeout = 30 * time.Second
+
+// newHTTPClient builds the client used for all downloads. proxy overrides the
+// proxy taken from the environment (HTTPS_PROXY etc.) when non-empty.
+func newHTTPClient(timeout time.Duration, proxy string) (*http.Client, error) {
+	proxyFunc := http.ProxyFromEnvironment
+	if proxy != "" {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid DOWNLOAD_PROXY %q", proxy)
+		}
+		proxyFunc = http.ProxyURL(proxyURL)
+	}
+
+	transport := &http.Transport{
+		Proxy:                 proxyFunc,
+		DialContext:           (&net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}).DialContext,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          10,
+	}
+
+	// No overall Timeout: large files may legitimately take long as long as data keeps flowing
+	return &http.Client{Transport: transport}, nil
+}
+
+// MaxDownloadResumes is how often a download that broke off is resumed with
+// a Range request; MaxDownloadRestarts how often downloadAndSave starts over
+// when the server does not support ranges.
+const (
+	MaxDownloadResumes  = 3
+	MaxDownloadRestarts = 1
+)
+
+// download fetches url with the shared HTTP client. The returned body fails if
+// no data arrives for longer than the configured timeout. If the connection
+// drops and the server accepts byte ranges, it resumes where it stopped.
+func (b *Bot) download(url string) (io.ReadCloser, error) {
+	resp, cancel, err := b.get(url, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &resumableBody{
+		bot:    b,
+		url:    url,
+		body:   newIdleTimeoutReader(resp.Body, b.httpTimeout, cancel),
+		total:  resp.ContentLength,
+		ranges: resp.Header.Get("Accept-Ranges") == "bytes",
+	}, nil
+}
+
+// get requests url from byte offset on: the whole file for 0, else the rest
+// as a partial response.
+func (b *Bot) get(url string, offset int64) (*http.Response, context.CancelFunc, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	want := http.StatusOK
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		want = http.StatusPartialContent
+	}
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		cancel()
+		return nil, nil, fmt.Errorf("failed to download file: %w", err)
+	}
+	if resp.StatusCode != want {
+		resp.Body.Close()
+		cancel()
+		return nil, nil, fmt.Errorf("failed to download file: unexpected status %s", resp.Status)
+	}
+	return resp, cancel, nil
+}
+
+// downloadError is a download that broke off after Offset bytes. Ranges tells
+// whether the server accepts byte ranges, so resuming was tried already.
+type downloadError struct {
+	Offset int64
+	Ranges bool
+	Err    error
+}
+
+func (e *downloadError) Error() string {
+	return fmt.Sprintf("download broke off after %d bytes: %v", e.Offset, e.Err)
+}
+
+func (e *downloadError) Unwrap() error { return e.Err }
+
+// resumableBody reads a download, requesting the rest from the bytes read so
+// far when the transfer breaks off and the server accepts ranges. A body
+// shorter than its Content-Length is an error, not the end.
+type resumableBody struct {
+	bot     *Bot
+	url     string
+	body    io.ReadCloser
+	read    int64
+	total   int64 // Content-Length of the whole file, -1 if unknown
+	ranges  bool
+	resumes int
+}
+
+func (r *resumableBody) Read(p []byte) (int, error) {
+	for {
+		n, err := r.body.Read(p)
+		r.read += int64(n)
+		if err == io.EOF && r.total >= 0 && r.read != r.total {
+			err = fmt.Errorf("got %d of %d bytes: %w", r.read, r.total, io.ErrUnexpectedEOF)
+		}
+		if err == nil || err == io.EOF {
+			return n, err
+		}
+
+		if !r.ranges || r.resumes == MaxDownloadResumes || (r.total >= 0 && r.read > r.total) {
+			return n, &downloadError{Offset: r.read, Ranges: r.ranges, Err: err}
+		}
+		r.resumes++
+		log.Printf("Download broke off after %d bytes (%v), resuming (%d/%d)", r.read, err, r.resumes, MaxDownloadResumes)
+		if rerr := r.resume(); rerr != nil {
+			log.Printf("Failed to resume download: %v", rerr)
+			return n, &downloadError{Offset: r.read, Ranges: r.ranges, Err: err}
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// resume replaces the body with the rest of the file from r.read on, checking
+// the server sends exactly that part of the same file.
+func (r *resumableBody) resume() error {
+	r.body.Close()
+	r.body = io.NopCloser(strings.NewReader(""))
+
+	resp, cancel, err := r.bot.get(r.url, r.read)
+	if err != nil {
+		return err
+	}
+	var first, last, total int64
+	if _, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-%d/%d", &first, &last, &total); err != nil ||
+		first != r.read || (r.total >= 0 && total != r.total) {
+		resp.Body.Close()
+		cancel()
+		return fmt.Errorf("unexpected Content-Range %q resuming at %d", resp.Header.Get("Content-Range"), r.read)
+	}
+	r.total = total
+	r.body = newIdleTimeoutReader(resp.Body, r.bot.httpTimeout, cancel)
+	return nil
+}
+
+func (r *resumableBody) Close() error {
+	return r.body.Close()
+}
+
+// idleTimeoutReader cancels a request when its body stalls for longer than timeout.
+type idleTimeoutReader struct {
+	body    io.ReadCloser
+	timeout time.Duration
+	cancel  context.CancelFunc
+
+	mu    sync.Mutex
+	timer *time.Timer
+}
+
+func newIdleTimeoutReader(body io.ReadCloser, timeout time.Duration, cancel context.CancelFunc) *idleTimeoutReader {
+	return &idleTimeoutReader{body: body, timeout: timeout, cancel: cancel, timer: time.AfterFunc(timeout, cancel)}
+}
+
+func (r *idleTimeoutReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	if n > 0 {
+		r.mu.Lock()
+		r.timer.Reset(r.timeout)
+		r.mu.Unlock()
+	}
+	return n, err
+}
+
+func (r *idleTimeoutReader) Close() error {
+	r.timer.Stop()
+	err := r.body.Close()
+	r.cancel()
+	return err
+}
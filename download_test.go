@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newDownloadTestBot(t *testing.T, timeout time.Duration) *Bot {
+	t.Helper()
+	b := newTestBot(t)
+	client, err := newHTTPClient(timeout, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.httpClient, b.httpTimeout = client, timeout
+	return b
+}
+
+func TestDownloadTimesOutOnUnresponsiveServer(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	b := newDownloadTestBot(t, 200*time.Millisecond)
+
+	start := time.Now()
+	_, err := b.download(srv.URL)
+	if err == nil {
+		t.Fatal("expected download to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected failure within the timeout, took %v", elapsed)
+	}
+}
+
+func TestDownloadTimesOutOnStalledBody(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	b := newDownloadTestBot(t, 200*time.Millisecond)
+
+	body, err := b.download(srv.URL)
+	if err != nil {
+		t.Fatalf("expected headers to arrive, got %v", err)
+	}
+	defer body.Close()
+
+	start := time.Now()
+	data, err := io.ReadAll(body)
+	if err == nil {
+		t.Fatal("expected stalled body to fail")
+	}
+	if string(data) != "partial" {
+		t.Errorf("expected the data sent before the stall, got %q", data)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected failure within the timeout, took %v", elapsed)
+	}
+}
+
+func TestDownloadRejectsErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	if _, err := newDownloadTestBot(t, time.Second).download(srv.URL); err == nil {
+		t.Error("expected a 404 to fail the download")
+	}
+}
+
+func TestNewHTTPClientProxyOverride(t *testing.T) {
+	client, err := newHTTPClient(time.Second, "http://proxy.local:3128")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "https://api.telegram.org/file/x", nil)
+	proxy, err := client.Transport.(*http.Transport).Proxy(req)
+	if err != nil || proxy == nil || proxy.Host != "proxy.local:3128" {
+		t.Errorf("expected DOWNLOAD_PROXY to be used, got %v (%v)", proxy, err)
+	}
+
+	if _, err := newHTTPClient(time.Second, "not a url"); err == nil {
+		t.Error("expected an invalid proxy to be rejected")
+	}
+}
+
+// flakyFileServer serves content, dropping the connection halfway through
+// the first drops full (non-range) requests. With ranges it advertises and
+// honours Range requests.
+type flakyFileServer struct {
+	content []byte
+	ranges  bool
+	drops   int
+
+	mu       sync.Mutex
+	requests []string // the Range header of each request
+}
+
+func (s *flakyFileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.Header.Get("Range"))
+	drop := r.Header.Get("Range") == "" && s.drops > 0
+	if drop {
+		s.drops--
+	}
+	s.mu.Unlock()
+
+	if !s.ranges {
+		r.Header.Del("Range")
+	}
+	if !drop {
+		if s.ranges {
+			http.ServeContent(w, r, "big.bin", time.Time{}, bytes.NewReader(s.content))
+			return
+		}
+		w.Write(s.content)
+		return
+	}
+
+	if s.ranges {
+		w.Header().Set("Accept-Ranges", "bytes")
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(s.content)))
+	w.Write(s.content[:len(s.content)/2])
+	w.(http.Flusher).Flush()
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err == nil {
+		conn.Close()
+	}
+}
+
+func (s *flakyFileServer) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.requests)
+}
+
+func TestDownloadResumesWithRanges(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 10000)
+	fs := &flakyFileServer{content: content, ranges: true, drops: 1}
+	srv := httptest.NewServer(fs)
+	defer srv.Close()
+
+	b := newDownloadTestBot(t, 5*time.Second)
+	b.client = &mockTelegramAPI{fileURL: srv.URL}
+
+	saved, err := b.downloadAndSave(mediaFile{MediaType: "documents", FileID: "big", MIME: "application/octet-stream"}, "big.bin", 42, 42)
+	if err != nil {
+		t.Fatalf("expected the download to be resumed, got %v", err)
+	}
+	if data, err := os.ReadFile(b.localPath(saved.Path)); err != nil || !bytes.Equal(data, content) {
+		t.Fatalf("expected the whole file, got %d bytes (%v)", len(data), err)
+	}
+	requests := fs.Requests()
+	if len(requests) != 2 || requests[0] != "" || requests[1] != fmt.Sprintf("bytes=%d-", len(content)/2) {
+		t.Errorf("expected one full and one range request, got %q", requests)
+	}
+}
+
+func TestDownloadRestartsWithoutRanges(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 10000)
+	fs := &flakyFileServer{content: content, drops: 1}
+	srv := httptest.NewServer(fs)
+	defer srv.Close()
+
+	b := newDownloadTestBot(t, 5*time.Second)
+	b.client = &mockTelegramAPI{fileURL: srv.URL}
+
+	saved, err := b.downloadAndSave(mediaFile{MediaType: "documents", FileID: "big", MIME: "application/octet-stream"}, "big.bin", 42, 42)
+	if err != nil {
+		t.Fatalf("expected the download to be started over, got %v", err)
+	}
+	if saved.Path != "big.bin" || saved.Size != int64(len(content)) {
+		t.Errorf("unexpected stored file %+v", saved)
+	}
+	if requests := fs.Requests(); len(requests) != 2 || requests[1] != "" {
+		t.Errorf("expected two full requests, got %q", requests)
+	}
+
+	// A server that keeps breaking off fails the download, leaving nothing behind
+	fs.mu.Lock()
+	fs.drops = MaxDownloadRestarts + 1
+	fs.mu.Unlock()
+	_, err = b.downloadAndSave(mediaFile{MediaType: "documents", FileID: "big", MIME: "application/octet-stream"}, "other.bin", 42, 42)
+	var be *BotError
+	if !errors.As(err, &be) || be.Category != CategoryNetwork {
+		t.Fatalf("expected a network error, got %v", err)
+	}
+	if b.storage.(*LocalStorage).Exists("other.bin") {
+		t.Error("expected no partial file")
+	}
+}
+
+func TestResumedDownloadMustMatchSize(t *testing.T) {
+	content := bytes.Repeat([]byte("x"), 1000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Accept-Ranges", "bytes")
+		if r.Header.Get("Range") != "" {
+			// The rest of some other, longer file
+			w.Header().Set("Content-Range", "bytes 500-1999/2000")
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(content)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.Write(content[:500])
+		w.(http.Flusher).Flush()
+		if conn, _, err := w.(http.Hijacker).Hijack(); err == nil {
+			conn.Close()
+		}
+	}))
+	defer srv.Close()
+
+	b := newDownloadTestBot(t, 5*time.Second)
+	body, err := b.download(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	var broken *downloadError
+	if !errors.As(err, &broken) || broken.Offset != 500 || len(data) != 500 {
+		t.Errorf("expected the mismatching resume to be refused after 500 bytes, got %d bytes (%v)", len(data), err)
+	}
+}
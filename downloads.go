@@ -0,0 +1,284 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// MaxTaskBatch bounds how many task IDs one /downloads action accepts.
+const MaxTaskBatch = 50
+
+// MaxFetchSize bounds the total size of a task copied by /downloads fetch.
+const MaxFetchSize = 4 * 1024 * 1024 * 1024 // 4GB
+
+// errNoMount reports that SYNOLOGY_MOUNT is not configured.
+var errNoMount = errors.New("SYNOLOGY_MOUNT is not set")
+
+// taskIDPattern matches DownloadStation task IDs (e.g. dbid_123).
+var taskIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// parseTaskIDs parses space- or comma-separated task IDs.
+func parseTaskIDs(args []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, arg := range args {
+		for _, id := range strings.Split(arg, ",") {
+			id = strings.TrimSpace(id)
+			if id == "" || seen[id] {
+				continue
+			}
+			if !taskIDPattern.MatchString(id) {
+				return nil, fmt.Errorf("invalid task ID %q", id)
+			}
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	switch {
+	case len(ids) == 0:
+		return nil, fmt.Errorf("no task IDs given")
+	case len(ids) > MaxTaskBatch:
+		return nil, fmt.Errorf("too many task IDs (max %d)", MaxTaskBatch)
+	}
+	return ids, nil
+}
+
+// formatTaskResults summarizes a batch response, one line per requested task.
+func formatTaskResults(action string, ids []string, results []TaskResult) string {
+	byID := make(map[string]int, len(results))
+	for _, r := range results {
+		byID[r.ID] = r.Error
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "📋 %s results:\n", action)
+	for _, id := range ids {
+		code, ok := byID[id]
+		switch {
+		case !ok:
+			fmt.Fprintf(&sb, "\n❔ %s: no result reported", id)
+		case code == 0:
+			fmt.Fprintf(&sb, "\n✅ %s", id)
+		default:
+			fmt.Fprintf(&sb, "\n❌ %s: %s", id, errorMessage(synologyTaskAPI, code))
+		}
+	}
+	return sb.String()
+}
+
+// taskFile is a file of a finished task.
+type taskFile struct {
+	Source string // as reported by DownloadStation, relative to the task destination
+	Name   string // user-relative path it is stored under
+	Size   int64
+}
+
+// taskFiles returns the files of a task from its additional=file data. Files of
+// multi-file tasks are stored in a folder named after the task; a task without
+// a file list is a single file named after its title.
+func taskFiles(task Task) ([]taskFile, error) {
+	if len(task.Additional.File) == 0 {
+		name, err := cleanRelPath(task.Title)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file name %q", task.Title)
+		}
+		return []taskFile{{Source: task.Title, Name: name, Size: task.Size}}, nil
+	}
+
+	folder := sanitizeFileName(task.Title)
+	files := make([]taskFile, 0, len(task.Additional.File))
+	for _, f := range task.Additional.File {
+		name, err := cleanRelPath(f.Name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file name %q", f.Name)
+		}
+		if len(task.Additional.File) > 1 && folder != "" && !strings.HasPrefix(name, folder+"/") {
+			name = folder + "/" + name
+		}
+		files = append(files, taskFile{Source: f.Name, Name: name, Size: f.Size})
+	}
+	return files, nil
+}
+
+// taskSourcePath locates a task file below the mounted destination folder.
+// DownloadStation places the files of multi-file tasks either directly in the
+// destination or in a folder named after the task.
+func taskSourcePath(mount, destination, title, source string) (string, error) {
+	dir := filepath.Join(mount, filepath.FromSlash(destination))
+	candidates := []string{filepath.Join(dir, filepath.FromSlash(source))}
+	if clean := sanitizeFileName(title); clean == title && title != "" {
+		candidates = append(candidates, filepath.Join(dir, title, filepath.FromSlash(source)))
+	}
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && info.Mode().IsRegular() {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("%s not found under %s", source, dir)
+}
+
+// fetchTask copies the files of a finished task from the mounted Synology
+// shared folder into owner's storage. It returns the files copied before any
+// failure, so partial copies can be reported.
+func (b *Bot) fetchTask(task Task, owner int64) ([]FileMeta, error) {
+	if b.synologyMount == "" {
+		return nil, errNoMount
+	}
+	if task.Status != "finished" {
+		return nil, fmt.Errorf("task is %s, not finished", task.Status)
+	}
+
+	files, err := taskFiles(task)
+	if err != nil {
+		return nil, err
+	}
+	var total int64
+	for _, f := range files {
+		total += f.Size
+	}
+	if total > MaxFetchSize {
+		return nil, fmt.Errorf("task is %s, over the %s limit", formatSize(total), formatSize(MaxFetchSize))
+	}
+
+	destination, err := cleanRelPath(task.Additional.Detail.Destination)
+	if err != nil {
+		return nil, fmt.Errorf("invalid task destination %q", task.Additional.Detail.Destination)
+	}
+
+	var copied []FileMeta
+	for _, f := range files {
+		src, err := taskSourcePath(b.synologyMount, destination, task.Title, f.Source)
+		if err != nil {
+			return copied, err
+		}
+		saved, err := b.copyTaskFile(src, f.Name, owner)
+		if err != nil {
+			return copied, fmt.Errorf("failed to copy %s: %w", f.Source, err)
+		}
+		copied = append(copied, saved)
+	}
+	return copied, nil
+}
+
+func (b *Bot) copyTaskFile(src, name string, owner int64) (FileMeta, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return FileMeta{}, err
+	}
+	defer in.Close()
+	return b.saveFile(in, "documents", b.organizedName("documents", name, time.Now()), mime.TypeByExtension(path.Ext(name)), owner)
+}
+
+// handleDownloadsFetch copies a finished task into the requesting user's storage.
+func (b *Bot) handleDownloadsFetch(chatID, userID int64, args []string) {
+	if len(args) != 1 || !taskIDPattern.MatchString(args[0]) {
+		b.sendTextMessage(chatID, "Usage: /downloads fetch <task_id>")
+		return
+	}
+	id := args[0]
+
+	tasks, err := b.synology.FetchTasks()
+	if err != nil {
+		log.Printf("Error fetching tasks: %v", err)
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ Failed to fetch tasks: %v", err))
+		return
+	}
+	var task *Task
+	for i := range tasks {
+		if tasks[i].ID == id {
+			task = &tasks[i]
+			break
+		}
+	}
+	if task == nil {
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ Task %s not found", id))
+		return
+	}
+
+	copied, err := b.fetchTask(*task, userID)
+	var sb strings.Builder
+	if len(copied) > 0 {
+		var size int64
+		for _, m := range copied {
+			size += m.Size
+		}
+		fmt.Fprintf(&sb, "📥 Copied %d file(s) from '%s' (%s):\n", len(copied), task.Title, formatSize(size))
+		for _, m := range copied {
+			fmt.Fprintf(&sb, "\n• %s", b.displayName(userID, m.Path))
+		}
+	}
+	if err != nil {
+		log.Printf("Error fetching task %s for user %d: %v", id, userID, err)
+		if sb.Len() > 0 {
+			sb.WriteString("\n\n")
+		}
+		sb.WriteString(b.failureReply(userID, err, fmt.Sprintf("❌ Failed to fetch '%s': %v", task.Title, err)))
+	} else {
+		log.Printf("User %d fetched task %s (%d files)", userID, id, len(copied))
+	}
+	b.sendLongMessage(chatID, sb.String())
+}
+
+// handleDownloadsCommand lists DownloadStation tasks, or pauses, resumes,
+// deletes or fetches them for admins.
+func (b *Bot) handleDownloadsCommand(chatID, userID int64, args []string) {
+	if len(args) == 0 {
+		b.handleStatusCommand(chatID)
+		return
+	}
+
+	usage := "Usage: /downloads [pause|resume|delete <task_id>[,<task_id>...] | fetch <task_id>]"
+	if !b.isUserAdmin(userID) {
+		b.sendTextMessage(chatID, "🚫 Access denied. Admin privileges required.")
+		return
+	}
+	if b.synology == nil {
+		b.sendTextMessage(chatID, "⚠️ Synology client not initialized")
+		return
+	}
+
+	var action func([]string) ([]TaskResult, error)
+	switch args[0] {
+	case "fetch":
+		b.handleDownloadsFetch(chatID, userID, args[1:])
+		return
+	case "pause":
+		action = b.synology.PauseTasks
+	case "resume":
+		action = b.synology.ResumeTasks
+	case "delete":
+		action = b.synology.DeleteTasks
+	default:
+		b.sendTextMessage(chatID, usage)
+		return
+	}
+
+	ids, err := parseTaskIDs(args[1:])
+	if err != nil {
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ %v\n%s", err, usage))
+		return
+	}
+
+	results, err := action(ids)
+	if err != nil {
+		log.Printf("Error running %s on tasks %v: %v", args[0], ids, err)
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ Failed to %s tasks: %v", args[0], err))
+		return
+	}
+
+	log.Printf("Admin %d ran %s on tasks %v", userID, args[0], ids)
+	b.sendLongMessage(chatID, formatTaskResults(strings.ToUpper(args[0][:1])+args[0][1:], ids, results))
+
+	// Refresh the cached status so /status reflects the change
+	if b.statusService != nil {
+		b.statusService.checkStatus()
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type fakeTaskManager struct {
+	tasks   []Task
+	calls   []string
+	results []TaskResult
+}
+
+func (f *fakeTaskManager) FetchTasks() ([]Task, error) {
+	return f.tasks, nil
+}
+
+func (f *fakeTaskManager) record(action string, ids []string) ([]TaskResult, error) {
+	f.calls = append(f.calls, action+" "+strings.Join(ids, ","))
+	return f.results, nil
+}
+
+func (f *fakeTaskManager) PauseTasks(ids []string) ([]TaskResult, error) {
+	return f.record("pause", ids)
+}
+
+func (f *fakeTaskManager) ResumeTasks(ids []string) ([]TaskResult, error) {
+	return f.record("resume", ids)
+}
+
+func (f *fakeTaskManager) DeleteTasks(ids []string) ([]TaskResult, error) {
+	return f.record("delete", ids)
+}
+
+func TestParseTaskIDs(t *testing.T) {
+	ids, err := parseTaskIDs([]string{"dbid_1,dbid_2", "dbid_1", "dbid_3"})
+	if err != nil || strings.Join(ids, ",") != "dbid_1,dbid_2,dbid_3" {
+		t.Errorf("unexpected result %v (%v)", ids, err)
+	}
+	for _, bad := range [][]string{nil, {","}, {"dbid_1&method=delete"}, {"../x"}} {
+		if _, err := parseTaskIDs(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestDownloadsCommandReportsPerTaskResults(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+	b.adminUsers[1] = true
+	tasks := &fakeTaskManager{results: []TaskResult{{ID: "dbid_1"}, {ID: "dbid_2", Error: 404}}}
+	b.synology = tasks
+
+	b.handleDownloadsCommand(1, 1, []string{"pause", "dbid_1,dbid_2", "dbid_3"})
+
+	if len(tasks.calls) != 1 || tasks.calls[0] != "pause dbid_1,dbid_2,dbid_3" {
+		t.Fatalf("unexpected calls %q", tasks.calls)
+	}
+	want := "📋 Pause results:\n\n✅ dbid_1\n❌ dbid_2: invalid task id\n❔ dbid_3: no result reported"
+	if texts := api.texts(); len(texts) != 1 || texts[0] != want {
+		t.Errorf("unexpected reply %q", texts)
+	}
+
+	// Non-admins cannot change tasks
+	b.handleDownloadsCommand(42, 42, []string{"delete", "dbid_1"})
+	if len(tasks.calls) != 1 {
+		t.Error("expected non-admins to be refused")
+	}
+}
+
+func TestTaskFiles(t *testing.T) {
+	var multi Task
+	if err := json.Unmarshal([]byte(`{"id":"dbid_1","title":"Show","status":"finished",
+		"additional":{"detail":{"destination":"downloads"},"file":[
+			{"filename":"e1.mkv","size":100},{"filename":"Show/e1.srt","size":2}]}}`), &multi); err != nil {
+		t.Fatal(err)
+	}
+	files, err := taskFiles(multi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].Name != "Show/e1.mkv" || files[0].Source != "e1.mkv" || files[0].Size != 100 || files[1].Name != "Show/e1.srt" {
+		t.Errorf("unexpected files %+v", files)
+	}
+	if multi.Additional.Detail.Destination != "downloads" {
+		t.Errorf("expected the destination to be parsed, got %q", multi.Additional.Detail.Destination)
+	}
+
+	files, err = taskFiles(Task{Title: "notes.zip", Size: 1024})
+	if err != nil || len(files) != 1 || files[0].Name != "notes.zip" || files[0].Size != 1024 {
+		t.Errorf("expected a task without a file list to be one file, got %+v (%v)", files, err)
+	}
+
+	var evil Task
+	evil.Title = "evil"
+	evil.Additional.File = append(evil.Additional.File, struct {
+		Name string `json:"filename"`
+		Size int64  `json:"size"`
+	}{Name: "../../etc/passwd"})
+	if _, err := taskFiles(evil); err == nil {
+		t.Error("expected a file name escaping the destination to be rejected")
+	}
+}
+
+func TestDownloadsFetch(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+	b.adminUsers[1] = true
+	b.synologyMount = t.TempDir()
+
+	tasks := &fakeTaskManager{}
+	b.synology = tasks
+	task := Task{ID: "dbid_1", Title: "Show", Status: "finished"}
+	task.Additional.Detail.Destination = "downloads"
+	if err := json.Unmarshal([]byte(`[{"filename":"e1.mkv","size":5},{"filename":"e1.srt","size":3}]`), &task.Additional.File); err != nil {
+		t.Fatal(err)
+	}
+	tasks.tasks = []Task{task, {ID: "dbid_2", Title: "big.iso", Status: "downloading"}}
+
+	dir := filepath.Join(b.synologyMount, "downloads", "Show")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "e1.mkv"), []byte("video"), 0644)
+	os.WriteFile(filepath.Join(dir, "e1.srt"), []byte("sub"), 0644)
+
+	b.handleDownloadsCommand(1, 1, []string{"fetch", "dbid_1"})
+	want := "📥 Copied 2 file(s) from 'Show' (8 B):\n\n• Show/e1.mkv\n• Show/e1.srt"
+	if texts := api.texts(); len(texts) != 1 || texts[0] != want {
+		t.Fatalf("unexpected reply %q", texts)
+	}
+	if data, err := os.ReadFile(b.localPath("Show/e1.mkv")); err != nil || string(data) != "video" {
+		t.Errorf("expected the file to be copied, got %q (%v)", data, err)
+	}
+	if meta, err := readMeta(b.storagePath, "Show/e1.srt"); err != nil || meta.Owner != 1 {
+		t.Errorf("expected metadata for the copied file, got %+v (%v)", meta, err)
+	}
+
+	b.handleDownloadsCommand(1, 1, []string{"fetch", "dbid_2"})
+	if texts := api.texts(); !strings.Contains(texts[len(texts)-1], "not finished") {
+		t.Errorf("expected an unfinished task to be refused, got %q", texts[len(texts)-1])
+	}
+
+	b.handleDownloadsCommand(1, 1, []string{"fetch", "dbid_9"})
+	if texts := api.texts(); texts[len(texts)-1] != "❌ Task dbid_9 not found" {
+		t.Errorf("unexpected reply %q", texts[len(texts)-1])
+	}
+
+	// A missing file stops the copy and reports what was copied before it
+	os.Remove(filepath.Join(dir, "e1.srt"))
+	b.handleDownloadsCommand(1, 1, []string{"fetch", "dbid_1"})
+	if texts := api.texts(); !strings.Contains(texts[len(texts)-1], "Copied 1 file(s)") || !strings.Contains(texts[len(texts)-1], "e1.srt not found") {
+		t.Errorf("expected a partial copy to be reported, got %q", texts[len(texts)-1])
+	}
+}
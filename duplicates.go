@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DuplicatesPageSize is how many groups one page of /admin duplicates lists.
+const DuplicatesPageSize = 10
+
+// duplicateGroup is a set of stored files with the same content. Copies
+// counts the distinct files on disk: members already hardlinked together
+// share one.
+type duplicateGroup struct {
+	SHA256 string
+	Size   int64
+	Paths  []string
+	Copies int
+}
+
+// Wasted is the space taken by all copies but one.
+func (g duplicateGroup) Wasted() int64 {
+	return int64(g.Copies-1) * g.Size
+}
+
+// Duplicates returns the entries whose checksum is shared with another entry,
+// ordered by checksum and path.
+func (idx *FileIndex) Duplicates() ([]FileMeta, error) {
+	return idx.query(`SELECT path, owner, size, mime, sha256, received_at FROM files
+		WHERE sha256 != '' AND sha256 IN (SELECT sha256 FROM files WHERE sha256 != '' GROUP BY sha256 HAVING COUNT(*) > 1)
+		ORDER BY sha256, path`)
+}
+
+// duplicateGroups groups the stored files of all users by content hash and
+// returns the groups with more than one member, most wasted space first.
+// Checksums come from the index when enabled, else from sidecars or hashing.
+func (b *Bot) duplicateGroups() ([]duplicateGroup, error) {
+	var metas []FileMeta
+	if b.index != nil {
+		var err error
+		if metas, err = b.index.Duplicates(); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, root := range b.dataRoots() {
+			err := walkStoredFiles(root, func(rel, src string, info os.FileInfo) error {
+				if m := b.fileMeta(rel, src, info); m.SHA256 != "" {
+					metas = append(metas, m)
+				}
+				return nil
+			})
+			if err != nil && !os.IsNotExist(err) {
+				return nil, fmt.Errorf("failed to walk storage directory: %w", err)
+			}
+		}
+	}
+
+	bySum := make(map[string]*duplicateGroup)
+	for _, m := range metas {
+		g := bySum[m.SHA256]
+		if g == nil {
+			g = &duplicateGroup{SHA256: m.SHA256, Size: m.Size}
+			bySum[m.SHA256] = g
+		}
+		g.Paths = append(g.Paths, m.Path)
+	}
+
+	var groups []duplicateGroup
+	for _, g := range bySum {
+		if len(g.Paths) < 2 {
+			continue
+		}
+		sort.Strings(g.Paths)
+		g.Copies = b.distinctFiles(g.Paths)
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Wasted() != groups[j].Wasted() {
+			return groups[i].Wasted() > groups[j].Wasted()
+		}
+		return groups[i].Paths[0] < groups[j].Paths[0]
+	})
+	return groups, nil
+}
+
+// distinctFiles counts the files on disk behind paths, telling hardlinks of
+// one file apart from copies.
+func (b *Bot) distinctFiles(paths []string) int {
+	var seen []os.FileInfo
+	for _, rel := range paths {
+		info, err := os.Stat(b.localPath(rel))
+		if err != nil {
+			continue
+		}
+		known := false
+		for _, s := range seen {
+			if os.SameFile(s, info) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			seen = append(seen, info)
+		}
+	}
+	return max(1, len(seen))
+}
+
+// formatDuplicatesPage renders page (1-based) of groups with the total waste.
+func formatDuplicatesPage(groups []duplicateGroup, page int) string {
+	var wasted int64
+	for _, g := range groups {
+		wasted += g.Wasted()
+	}
+	pages := max(1, (len(groups)+DuplicatesPageSize-1)/DuplicatesPageSize)
+	start := (page - 1) * DuplicatesPageSize
+	end := min(start+DuplicatesPageSize, len(groups))
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("👯 Duplicate files (page %d/%d):\n", page, pages))
+	for i, g := range groups[start:end] {
+		sb.WriteString(fmt.Sprintf("\n%d. %d files of %s, %s wasted (SHA-256 %s…)\n", start+i+1, len(g.Paths), formatSize(g.Size), formatSize(g.Wasted()), g.SHA256[:12]))
+		for _, p := range g.Paths {
+			sb.WriteString(fmt.Sprintf("   • %s\n", p))
+		}
+	}
+	sb.WriteString(fmt.Sprintf("\nTotal: %s wasted in %d groups", formatSize(wasted), len(groups)))
+	if page < pages {
+		sb.WriteString(fmt.Sprintf("\nNext page: /admin duplicates %d", page+1))
+	}
+	if wasted > 0 {
+		sb.WriteString("\nReplace copies with hardlinks: /admin duplicates link")
+	}
+	return sb.String()
+}
+
+// dedupeResult sums up a /admin duplicates link run.
+type dedupeResult struct {
+	Linked  int
+	Saved   int64
+	Skipped int
+}
+
+// linkDuplicates replaces the copies in each group with hardlinks to the most
+// recently modified member, so retention by mtime never comes early. Only
+// files with identical bytes on disk are linked: encrypted copies (random
+// nonces) and copies in data roots on other filesystems are skipped.
+func (b *Bot) linkDuplicates(groups []duplicateGroup) dedupeResult {
+	var res dedupeResult
+	for _, g := range groups {
+		var keeper string
+		var keeperInfo os.FileInfo
+		for _, rel := range g.Paths {
+			info, err := os.Stat(b.localPath(rel))
+			if err == nil && (keeperInfo == nil || info.ModTime().After(keeperInfo.ModTime())) {
+				keeper, keeperInfo = b.localPath(rel), info
+			}
+		}
+		if keeperInfo == nil {
+			continue
+		}
+		keeperSum, err := rawChecksum(keeper)
+		if err != nil {
+			log.Printf("Failed to hash %s: %v", keeper, err)
+			res.Skipped += len(g.Paths) - 1
+			continue
+		}
+
+		for _, rel := range g.Paths {
+			dst := b.localPath(rel)
+			info, err := os.Stat(dst)
+			if err != nil || os.SameFile(info, keeperInfo) {
+				continue
+			}
+			if sum, err := rawChecksum(dst); err != nil || !bytes.Equal(sum, keeperSum) {
+				res.Skipped++
+				continue
+			}
+			if err := replaceWithLink(keeper, dst); err != nil {
+				log.Printf("Failed to hardlink %s to %s: %v", rel, keeper, err)
+				res.Skipped++
+				continue
+			}
+			res.Linked++
+			res.Saved += info.Size()
+		}
+	}
+	return res
+}
+
+// rawChecksum hashes the bytes of the file at path as stored on disk.
+func rawChecksum(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// replaceWithLink atomically replaces dst with a hardlink to src. The link is
+// created under a hidden name beside dst first, so dst never goes missing.
+func replaceWithLink(src, dst string) error {
+	tmp := filepath.Join(filepath.Dir(dst), "."+filepath.Base(dst)+".dedup")
+	os.Remove(tmp)
+	if err := os.Link(src, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// handleAdminDuplicates lists files with identical content across all users,
+// or with "link" replaces their copies with hardlinks.
+func (b *Bot) handleAdminDuplicates(chatID int64, args []string) {
+	page, link := 1, false
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		switch {
+		case args[0] == "link":
+			link = true
+		case err != nil || n <= 0:
+			b.sendTextMessage(chatID, "Usage: /admin duplicates [page|link]")
+			return
+		default:
+			page = n
+		}
+	}
+
+	if !b.requireLocalStorage(chatID) {
+		return
+	}
+
+	groups, err := b.duplicateGroups()
+	if err != nil {
+		log.Printf("Error finding duplicates: %v", err)
+		b.sendTextMessage(chatID, "Failed to look for duplicates.")
+		return
+	}
+	if len(groups) == 0 {
+		b.sendTextMessage(chatID, "✅ No duplicate files found.")
+		return
+	}
+
+	if link {
+		res := b.linkDuplicates(groups)
+		log.Printf("Deduplicated %d files, %d bytes reclaimed, %d skipped", res.Linked, res.Saved, res.Skipped)
+		text := fmt.Sprintf("🔗 Replaced %d copies with hardlinks, reclaiming %s.", res.Linked, formatSize(res.Saved))
+		if res.Skipped > 0 {
+			text += fmt.Sprintf("\n%d files were skipped (different bytes on disk, e.g. encrypted, or another filesystem).", res.Skipped)
+		}
+		b.sendTextMessage(chatID, text)
+		return
+	}
+
+	if pages := (len(groups) + DuplicatesPageSize - 1) / DuplicatesPageSize; page > pages {
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ There are only %d pages", pages))
+		return
+	}
+	b.sendLongMessage(chatID, formatDuplicatesPage(groups, page))
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeDuplicateTree stores two groups of duplicates and one unique file.
+func writeDuplicateTree(t *testing.T, b *Bot) {
+	t.Helper()
+	big := strings.Repeat("b", 3000)
+	writeTestFile(t, b, "42/report.pdf", big)
+	writeTestFile(t, b, "7/copy of report.pdf", big)
+	writeTestFile(t, b, "7/again.pdf", big)
+	writeTestFile(t, b, "42/a.txt", "same")
+	writeTestFile(t, b, "100/b.txt", "same")
+	writeTestFile(t, b, "100/unique.txt", "unique")
+}
+
+func TestDuplicateGroups(t *testing.T) {
+	for _, indexed := range []bool{false, true} {
+		b := newTestBot(t)
+		writeDuplicateTree(t, b)
+		if indexed {
+			idx, err := OpenFileIndex(filepath.Join(t.TempDir(), "index.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer idx.Close()
+			if _, err := idx.Reindex(b.storagePath, b.dataRoots(), b.openPath); err != nil {
+				t.Fatal(err)
+			}
+			b.index = idx
+		}
+
+		groups, err := b.duplicateGroups()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(groups) != 2 {
+			t.Fatalf("indexed=%v: expected 2 groups, got %+v", indexed, groups)
+		}
+		if g := groups[0]; strings.Join(g.Paths, ",") != "42/report.pdf,7/again.pdf,7/copy of report.pdf" || g.Wasted() != 6000 {
+			t.Errorf("indexed=%v: unexpected first group %+v", indexed, g)
+		}
+		if g := groups[1]; strings.Join(g.Paths, ",") != "100/b.txt,42/a.txt" || g.Wasted() != 4 || g.SHA256 != sha256Hex("same") {
+			t.Errorf("indexed=%v: unexpected second group %+v", indexed, g)
+		}
+
+		text := formatDuplicatesPage(groups, 1)
+		for _, want := range []string{"3 files of 2.9 KB, 5.9 KB wasted", "• 7/copy of report.pdf", "Total: 5.9 KB wasted in 2 groups"} {
+			if !strings.Contains(text, want) {
+				t.Errorf("indexed=%v: expected %q in %q", indexed, want, text)
+			}
+		}
+	}
+}
+
+func TestLinkDuplicatesHardlinksCopies(t *testing.T) {
+	b := newTestBot(t)
+	writeDuplicateTree(t, b)
+	newest := time.Now().Add(time.Hour)
+	if err := os.Chtimes(b.localPath("7/again.pdf"), newest, newest); err != nil {
+		t.Fatal(err)
+	}
+
+	groups, err := b.duplicateGroups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := b.linkDuplicates(groups)
+	if res.Linked != 3 || res.Saved != 6004 || res.Skipped != 0 {
+		t.Errorf("unexpected result %+v", res)
+	}
+
+	keeper, _ := os.Stat(b.localPath("7/again.pdf"))
+	for _, rel := range []string{"42/report.pdf", "7/copy of report.pdf"} {
+		info, err := os.Stat(b.localPath(rel))
+		if err != nil || !os.SameFile(info, keeper) {
+			t.Errorf("expected %s to be a hardlink of the newest copy (%v)", rel, err)
+		}
+		if !info.ModTime().Equal(newest) {
+			t.Errorf("expected %s to carry the newest mtime, got %s", rel, info.ModTime())
+		}
+	}
+
+	// Linked copies are no longer counted as waste, and no temp files remain
+	groups, _ = b.duplicateGroups()
+	for _, g := range groups {
+		if g.Wasted() != 0 {
+			t.Errorf("expected no waste after linking, got %+v", g)
+		}
+	}
+	if files, _ := listStoredFilesRecursive(b.storagePath, b.dataRoots()); len(files) != 6 {
+		t.Errorf("expected the 6 stored files to remain, got %+v", files)
+	}
+}
+
+func TestLinkDuplicatesSkipsDifferentBytes(t *testing.T) {
+	b := newTestBot(t)
+	b.cipher = testCipher(t, 3)
+	for _, rel := range []string{"a.txt", "b.txt"} {
+		if _, err := b.saveFile(strings.NewReader("secret"), "documents", rel, "", 42); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	groups, err := b.duplicateGroups()
+	if err != nil || len(groups) != 1 {
+		t.Fatalf("expected the two encrypted copies as one group, got %+v (%v)", groups, err)
+	}
+	if res := b.linkDuplicates(groups); res.Linked != 0 || res.Skipped != 1 {
+		t.Errorf("expected encrypted copies to be skipped, got %+v", res)
+	}
+}
+
+func TestAdminDuplicatesUsage(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+
+	b.handleAdminDuplicates(1, []string{"zero"})
+	b.handleAdminDuplicates(1, nil)
+	texts := api.texts()
+	if len(texts) != 2 || !strings.HasPrefix(texts[0], "Usage: /admin duplicates") || !strings.Contains(texts[1], "No duplicate files") {
+		t.Errorf("unexpected replies %q", texts)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// captionFileName turns an edited caption into a file name: its first line,
+// sanitized, with the extension of current when it has none. It returns ""
+// for captions that give no usable name.
+func captionFileName(caption, current string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(caption), "\n")
+	name := sanitizeFileName(strings.TrimSpace(line))
+	if name == "" {
+		return ""
+	}
+	if path.Ext(name) == "" {
+		name += path.Ext(current)
+	}
+	return name
+}
+
+// handleEditedMessage renames a stored file when the caption of the message
+// it came from is edited. Edits of other messages are ignored.
+func (b *Bot) handleEditedMessage(message *tgbotapi.Message) {
+	if message.From == nil || !b.isChatAllowed(message.Chat.ID) || !b.isUserAllowed(message.From.ID) {
+		return
+	}
+	chatID, userID := message.Chat.ID, message.From.ID
+	upload, ok := b.savedUploads.Lookup(chatID, message.MessageID)
+	if !ok || upload.owner != userID || len(upload.paths) != 1 {
+		return
+	}
+
+	rel := upload.paths[0]
+	current := b.displayName(userID, rel)
+	if strings.HasSuffix(rel, compressedSuffix) && b.isCompressed(rel) {
+		current = strings.TrimSuffix(current, compressedSuffix)
+	}
+	name := captionFileName(message.Caption, current)
+	if name == "" {
+		return
+	}
+	from, to, err := b.resolveRename(userID, current, name)
+	if err != nil || from == to {
+		return
+	}
+
+	err = b.renameFile(from, to, false)
+	switch {
+	case err == nil:
+		b.savedUploads.Track(chatID, message.MessageID, userID, []string{to})
+		b.sendTextMessage(chatID, fmt.Sprintf("✏️ Renamed '%s' to '%s' to match the edited caption", b.displayName(userID, from), b.displayName(userID, to)))
+		log.Printf("User %d renamed %s to %s by editing its caption", userID, from, to)
+		b.audit(AuditEntry{User: userID, Action: "rename", Path: to, Detail: "from " + from + " (caption edit)"})
+	case errors.Is(err, errSourceMissing):
+		b.savedUploads.Forget(chatID, message.MessageID)
+	case errors.Is(err, errDestExists):
+		b.sendTextMessage(chatID, fmt.Sprintf("⚠️ '%s' already exists, so '%s' was not renamed. Use /rename to overwrite it.", b.displayName(userID, to), b.displayName(userID, from)))
+	default:
+		log.Printf("Error renaming %s after a caption edit: %v", from, err)
+		b.sendTextMessage(chatID, "❌ Failed to rename the file.")
+	}
+}
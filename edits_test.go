@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestCaptionFileName(t *testing.T) {
+	cases := map[string]string{
+		"Invoice March":         "Invoice March.pdf",
+		"  scan.png  \nignored": "scan.png",
+		"reports/v2":            "reports_v2.pdf",
+		"   ":                   "",
+	}
+	for caption, want := range cases {
+		if got := captionFileName(caption, "document_1_d1.pdf"); got != want {
+			t.Errorf("captionFileName(%q) = %q, want %q", caption, got, want)
+		}
+	}
+}
+
+// newEditTestBot returns a bot that stored the document sent as message 5.
+func newEditTestBot(t *testing.T) (*Bot, *mockTelegramAPI) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("scan"))
+	}))
+	t.Cleanup(srv.Close)
+
+	b := newDownloadTestBot(t, 5*time.Second)
+	b.savedUploads = newSavedReplies(MaxTrackedReplies)
+	api := &mockTelegramAPI{fileURL: srv.URL}
+	b.client = api
+
+	b.handleMessage(&tgbotapi.Message{
+		MessageID: 5,
+		From:      &tgbotapi.User{ID: 42},
+		Chat:      &tgbotapi.Chat{ID: 42},
+		Document:  &tgbotapi.Document{FileID: "d1", FileName: "scan.pdf", MimeType: "application/pdf"},
+	})
+	if !exists(b.localPath("scan.pdf")) {
+		t.Fatalf("expected the document to be stored, got %q", api.texts())
+	}
+	api.sent = nil
+	return b, api
+}
+
+func TestEditedCaptionRenamesStoredFile(t *testing.T) {
+	b, api := newEditTestBot(t)
+
+	edit := func(id int, from int64, caption string) {
+		b.handleEditedMessage(&tgbotapi.Message{MessageID: id, From: &tgbotapi.User{ID: from}, Chat: &tgbotapi.Chat{ID: 42}, Caption: caption})
+	}
+	edit(5, 42, "Tax 2024")
+
+	if exists(b.localPath("scan.pdf")) || !exists(b.localPath("Tax 2024.pdf")) {
+		t.Fatal("expected the file to be renamed after the caption")
+	}
+	if m, err := readMeta(b.storagePath, "Tax 2024.pdf"); err != nil || m.Owner != 42 {
+		t.Errorf("expected the metadata to follow, got %+v (%v)", m, err)
+	}
+	if texts := api.texts(); len(texts) != 1 || texts[0] != "✏️ Renamed 'scan.pdf' to 'Tax 2024.pdf' to match the edited caption" {
+		t.Errorf("unexpected replies %q", texts)
+	}
+
+	// A second edit renames the file again
+	edit(5, 42, "Tax 2024 final")
+	if !exists(b.localPath("Tax 2024 final.pdf")) {
+		t.Error("expected the second edit to rename the renamed file")
+	}
+}
+
+func TestEditsUnrelatedToStoredFilesAreIgnored(t *testing.T) {
+	b, api := newEditTestBot(t)
+	b.allowedUsers = map[int64]bool{42: true, 7: true}
+
+	for _, m := range []*tgbotapi.Message{
+		{MessageID: 6, From: &tgbotapi.User{ID: 42}, Chat: &tgbotapi.Chat{ID: 42}, Caption: "other"},     // not a stored upload
+		{MessageID: 5, From: &tgbotapi.User{ID: 7}, Chat: &tgbotapi.Chat{ID: 42}, Caption: "stolen"},     // someone else
+		{MessageID: 5, From: &tgbotapi.User{ID: 42}, Chat: &tgbotapi.Chat{ID: 42}, Text: "caption gone"}, // no caption
+	} {
+		b.handleEditedMessage(m)
+	}
+
+	if !exists(b.localPath("scan.pdf")) {
+		t.Error("expected the file to keep its name")
+	}
+	if texts := api.texts(); len(texts) != 0 {
+		t.Errorf("expected no replies, got %q", texts)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultErrorBufferSize is how many recent errors /admin errors keeps
+	// unless ERROR_BUFFER_SIZE says otherwise.
+	DefaultErrorBufferSize = 50
+	// MaxErrorBufferSize caps ERROR_BUFFER_SIZE.
+	MaxErrorBufferSize = 1000
+)
+
+// errorRecord is a failed operation kept for /admin errors.
+type errorRecord struct {
+	Time     time.Time
+	User     int64
+	Category ErrorCategory
+	Message  string
+}
+
+// errorRing keeps the last errors reported to users, dropping the oldest
+// once full. A nil *errorRing keeps nothing.
+type errorRing struct {
+	mu      sync.Mutex
+	entries []errorRecord
+	next    int
+	full    bool
+}
+
+func newErrorRing(size int) *errorRing {
+	return &errorRing{entries: make([]errorRecord, size)}
+}
+
+// parseErrorBufferSize parses ERROR_BUFFER_SIZE; 0 disables the buffer.
+func parseErrorBufferSize(s string) (int, error) {
+	if s == "" {
+		return DefaultErrorBufferSize, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 || n > MaxErrorBufferSize {
+		return 0, fmt.Errorf("invalid ERROR_BUFFER_SIZE %q: expected 0 to %d", s, MaxErrorBufferSize)
+	}
+	return n, nil
+}
+
+// Add records e, replacing the oldest entry when the ring is full.
+func (r *errorRing) Add(e errorRecord) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[r.next] = e
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Recent returns the kept errors, oldest first.
+func (r *errorRing) Recent() []errorRecord {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]errorRecord(nil), r.entries[:r.next]...)
+	}
+	return append(append([]errorRecord(nil), r.entries[r.next:]...), r.entries[:r.next]...)
+}
+
+// failureReply records a failed operation of userID for /admin errors and
+// returns the reply for it, as errorReply does.
+func (b *Bot) failureReply(userID int64, err error, fallback string) string {
+	b.recentErrors.Add(errorRecord{
+		Time:     time.Now(),
+		User:     userID,
+		Category: errorCategory(err),
+		Message:  err.Error(),
+	})
+	return errorReply(err, fallback)
+}
+
+// handleAdminErrors lists the recent errors, oldest first.
+func (b *Bot) handleAdminErrors(chatID int64) {
+	if b.recentErrors == nil {
+		b.sendTextMessage(chatID, "⚠️ Error buffer is disabled (ERROR_BUFFER_SIZE=0).")
+		return
+	}
+	records := b.recentErrors.Recent()
+	if len(records) == 0 {
+		b.sendTextMessage(chatID, "✅ No errors recorded since the bot started.")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🧯 Recent errors (%d, oldest first):\n\n", len(records)))
+	for _, e := range records {
+		sb.WriteString(fmt.Sprintf("• %s — user %d [%s]: %s\n", e.Time.Format("2006-01-02 15:04:05"), e.User, e.Category, e.Message))
+	}
+	b.sendLongMessage(chatID, sb.String())
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestErrorRingKeepsLatestInOrder(t *testing.T) {
+	r := newErrorRing(3)
+	if got := r.Recent(); len(got) != 0 {
+		t.Fatalf("expected an empty ring, got %+v", got)
+	}
+	for i := 1; i <= 7; i++ {
+		r.Add(errorRecord{User: int64(i), Message: fmt.Sprintf("error %d", i)})
+		if got := r.Recent(); len(got) != min(i, 3) || got[len(got)-1].User != int64(i) {
+			t.Fatalf("after %d errors: unexpected %+v", i, got)
+		}
+	}
+
+	got := r.Recent()
+	for i, want := range []int64{5, 6, 7} {
+		if got[i].User != want {
+			t.Errorf("entry %d: expected error %d, got %+v", i, want, got[i])
+		}
+	}
+}
+
+func TestParseErrorBufferSize(t *testing.T) {
+	if n, err := parseErrorBufferSize(""); err != nil || n != DefaultErrorBufferSize {
+		t.Errorf("expected the default, got %d (%v)", n, err)
+	}
+	if n, err := parseErrorBufferSize("0"); err != nil || n != 0 {
+		t.Errorf("expected 0 to disable the buffer, got %d (%v)", n, err)
+	}
+	for _, s := range []string{"-1", "many", fmt.Sprint(MaxErrorBufferSize + 1)} {
+		if _, err := parseErrorBufferSize(s); err == nil {
+			t.Errorf("expected %q to be rejected", s)
+		}
+	}
+}
+
+func TestFailureReplyRecordsError(t *testing.T) {
+	b := newTestBot(t)
+	b.recentErrors = newErrorRing(5)
+	api := &mockTelegramAPI{}
+	b.client = api
+
+	reply := b.failureReply(42, fmt.Errorf("write: %w", syscall.ENOSPC), "fallback")
+	if reply != categoryReplies[CategoryStorageFull] {
+		t.Errorf("expected the storage full reply, got %q", reply)
+	}
+	if reply := b.failureReply(7, errors.New("boom"), "fallback"); reply != "fallback" {
+		t.Errorf("expected the fallback reply, got %q", reply)
+	}
+
+	b.handleAdminErrors(1)
+	texts := api.texts()
+	if len(texts) != 1 {
+		t.Fatalf("expected one message, got %q", texts)
+	}
+	first := strings.Index(texts[0], "user 42 [storage_full]: write: no space left on device")
+	second := strings.Index(texts[0], "user 7 [internal]: boom")
+	if first < 0 || second < first {
+		t.Errorf("expected both errors oldest first, got %q", texts[0])
+	}
+}
+
+func TestAdminErrorsWithoutBuffer(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+
+	b.failureReply(42, errors.New("boom"), "fallback")
+	b.handleAdminErrors(1)
+	if texts := api.texts(); len(texts) != 1 || !strings.Contains(texts[0], "disabled") {
+		t.Errorf("expected the disabled notice, got %q", texts)
+	}
+}
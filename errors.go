@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"syscall"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// ErrorCategory classifies why an operation failed. Its value doubles as a
+// metrics or log label.
+type ErrorCategory string
+
+const (
+	CategoryQuotaExceeded ErrorCategory = "quota_exceeded"
+	CategoryTooLarge      ErrorCategory = "too_large"
+	CategoryNetwork       ErrorCategory = "network"
+	CategoryStorageFull   ErrorCategory = "storage_full"
+	CategoryForbidden     ErrorCategory = "forbidden"
+	// CategoryInternal is every failure not classified otherwise.
+	CategoryInternal ErrorCategory = "internal"
+)
+
+// categoryReplies are the default replies per category.
+var categoryReplies = map[ErrorCategory]string{
+	CategoryQuotaExceeded: "❌ You have used up your storage quota. Delete some files or contact the bot administrator.",
+	CategoryTooLarge:      "❌ The file is too large to be stored.",
+	CategoryNetwork:       "❌ The file could not be downloaded. Please try again.",
+	CategoryStorageFull:   "❌ Storage is full. Please contact the bot administrator.",
+	CategoryForbidden:     "❌ Storage denied access to the target folder. Please contact the bot administrator.",
+}
+
+// BotError is a failed operation with its category and the reply to the user.
+type BotError struct {
+	Category ErrorCategory
+	Message  string
+	Err      error
+}
+
+// newBotError wraps err in category with the category's default reply.
+func newBotError(category ErrorCategory, err error) *BotError {
+	return &BotError{Category: category, Message: categoryReplies[category], Err: err}
+}
+
+func (e *BotError) Error() string {
+	if e.Err == nil {
+		return string(e.Category)
+	}
+	return e.Err.Error()
+}
+
+func (e *BotError) Unwrap() error {
+	return e.Err
+}
+
+// classifyError returns err as a *BotError when its cause is known: storage
+// errors, network failures and Telegram's limit on bot downloads. Other errors
+// are returned unchanged.
+func classifyError(err error) error {
+	var be *BotError
+	switch {
+	case err == nil || errors.As(err, &be):
+		return err
+	case isStorageFull(err):
+		return newBotError(CategoryStorageFull, err)
+	case errors.Is(err, ErrStorageUnauthorized):
+		be := newBotError(CategoryForbidden, err)
+		be.Message = "❌ Storage rejected the bot's credentials. Please contact the bot administrator."
+		return be
+	case errors.Is(err, ErrStorageForbidden):
+		return newBotError(CategoryForbidden, err)
+	case isTelegramTooBig(err):
+		be := newBotError(CategoryTooLarge, err)
+		be.Message = "❌ Telegram does not let bots download files this large (20 MB)."
+		return be
+	case isNetworkError(err):
+		return newBotError(CategoryNetwork, err)
+	}
+	return err
+}
+
+// isStorageFull reports a full disk (ENOSPC, however wrapped) or a backend
+// reporting it is out of space.
+func isStorageFull(err error) bool {
+	return errors.Is(err, syscall.ENOSPC) || errors.Is(err, ErrStorageFull)
+}
+
+// isTelegramTooBig reports Telegram refusing a bot download over its limit.
+func isTelegramTooBig(err error) bool {
+	var tgErr *tgbotapi.Error
+	return errors.As(err, &tgErr) && strings.Contains(strings.ToLower(tgErr.Message), "file is too big")
+}
+
+// isNetworkError reports connection failures, timed out or stalled transfers,
+// which cancel their request context, and downloads that broke off.
+func isNetworkError(err error) bool {
+	var netErr net.Error
+	var dlErr *downloadError
+	return errors.As(err, &netErr) || errors.As(err, &dlErr) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// errorCategory returns the category of err, CategoryInternal if it has none.
+func errorCategory(err error) ErrorCategory {
+	var be *BotError
+	if errors.As(classifyError(err), &be) {
+		return be.Category
+	}
+	return CategoryInternal
+}
+
+// errorReply returns the reply for a failed operation: the BotError's message
+// for classified failures, fallback for everything else.
+func errorReply(err error, fallback string) string {
+	var be *BotError
+	if errors.As(classifyError(err), &be) && be.Message != "" {
+		return be.Message
+	}
+	return fallback
+}
+
+// alertStorageFull tells the admins that a save failed for lack of space,
+// once until a save succeeds again.
+func (b *Bot) alertStorageFull(rel string, owner int64, err error) {
+	log.Printf("Storage is full, failed to save %s from user %d: %v", rel, owner, err)
+	if !b.storageFullAlerted.CompareAndSwap(false, true) {
+		return
+	}
+	msg := fmt.Sprintf("⚠️ Storage is full: saving '%s' from user %d failed. Uploads will keep failing until space is freed.", rel, owner)
+	for _, adminID := range b.adminIDs() {
+		b.sendTextMessage(adminID, msg)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// failingStorage is a Storage whose saves fail with err.
+type failingStorage struct {
+	err error
+}
+
+func (s failingStorage) Save(name string, r io.Reader) (int64, error) { return 0, s.err }
+func (s failingStorage) List(dir string) ([]storedFile, error)        { return nil, nil }
+func (s failingStorage) Delete(name string) error                     { return nil }
+
+func TestDownloadAndSaveErrorCategories(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone" {
+			http.Error(w, "gone", http.StatusGone)
+			return
+		}
+		w.Write([]byte("content"))
+	}))
+	defer srv.Close()
+
+	refused, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refusedURL := "http://" + refused.Addr().String()
+	refused.Close()
+
+	cases := []struct {
+		name     string
+		api      *mockTelegramAPI
+		storage  Storage
+		fileID   string
+		category ErrorCategory
+	}{
+		{"telegram size limit", &mockTelegramAPI{fileErr: &tgbotapi.Error{Code: 400, Message: "Bad Request: file is too big"}}, nil, "f", CategoryTooLarge},
+		{"connection refused", &mockTelegramAPI{fileURL: refusedURL}, nil, "f", CategoryNetwork},
+		{"download failed", &mockTelegramAPI{fileURL: srv.URL}, nil, "gone", CategoryNetwork},
+		{"storage full", &mockTelegramAPI{fileURL: srv.URL}, failingStorage{fmt.Errorf("webdav PUT: %w", ErrStorageFull)}, "f", CategoryStorageFull},
+		{"storage forbidden", &mockTelegramAPI{fileURL: srv.URL}, failingStorage{fmt.Errorf("webdav PUT: %w", ErrStorageForbidden)}, "f", CategoryForbidden},
+		{"storage credentials", &mockTelegramAPI{fileURL: srv.URL}, failingStorage{fmt.Errorf("webdav PUT: %w", ErrStorageUnauthorized)}, "f", CategoryForbidden},
+		{"unknown", &mockTelegramAPI{fileURL: srv.URL}, failingStorage{errors.New("disk on fire")}, "f", CategoryInternal},
+	}
+	for _, c := range cases {
+		b := newDownloadTestBot(t, 5*time.Second)
+		b.client = c.api
+		if c.storage != nil {
+			b.storage = c.storage
+		}
+
+		_, err := b.downloadAndSave(mediaFile{MediaType: "documents", FileID: c.fileID}, "a.txt", 42, 42)
+		if err == nil {
+			t.Errorf("%s: expected an error", c.name)
+			continue
+		}
+		if got := errorCategory(err); got != c.category {
+			t.Errorf("%s: category %s, want %s (%v)", c.name, got, c.category, err)
+		}
+		var be *BotError
+		if c.category != CategoryInternal && !errors.As(err, &be) {
+			t.Errorf("%s: expected a *BotError, got %T", c.name, err)
+		}
+	}
+}
+
+func TestErrorReply(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{newBotError(CategoryNetwork, errors.New("reset")), categoryReplies[CategoryNetwork]},
+		{&BotError{Category: CategoryTooLarge, Message: "custom"}, "custom"},
+		{fmt.Errorf("save: %w", ErrStorageFull), categoryReplies[CategoryStorageFull]},
+		{fmt.Errorf("wrapped: %w", newBotError(CategoryQuotaExceeded, nil)), categoryReplies[CategoryQuotaExceeded]},
+		{errors.New("unclassified"), "fallback"},
+	}
+	for _, c := range cases {
+		if got := errorReply(c.err, "fallback"); got != c.want {
+			t.Errorf("errorReply(%v) = %q, want %q", c.err, got, c.want)
+		}
+	}
+}
+
+func TestHandleMediaRepliesByCategory(t *testing.T) {
+	b := newDownloadTestBot(t, 5*time.Second)
+	b.storage = failingStorage{ErrStorageFull}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("content"))
+	}))
+	defer srv.Close()
+	api := &mockTelegramAPI{fileURL: srv.URL}
+	b.client = api
+
+	b.handleMedia(*messageMedia(&tgbotapi.Message{Document: &tgbotapi.Document{FileID: "d", FileName: "a.txt", FileSize: 7}}), 42, 42)
+
+	if texts := api.texts(); len(texts) != 1 || texts[0] != categoryReplies[CategoryStorageFull] {
+		t.Errorf("unexpected replies %q", texts)
+	}
+}
+
+// diskFullReader yields some content, then fails the way a write to a full
+// disk does, as io.Copy would report it from the file.
+type diskFullReader struct {
+	done bool
+}
+
+func (r *diskFullReader) Read(p []byte) (int, error) {
+	if r.done {
+		return 0, &os.PathError{Op: "write", Path: "file", Err: syscall.ENOSPC}
+	}
+	r.done = true
+	return copy(p, "partial content"), nil
+}
+
+func TestSaveFileOnFullDisk(t *testing.T) {
+	b := newTestBot(t)
+	b.adminUsers[9] = true
+	api := &mockTelegramAPI{}
+	b.client = api
+
+	for i := 0; i < 2; i++ {
+		_, err := b.saveFile(&diskFullReader{}, "documents", "big.bin", "", 42)
+		if errorCategory(err) != CategoryStorageFull || errorReply(err, "fallback") != categoryReplies[CategoryStorageFull] {
+			t.Fatalf("expected a storage-full error, got %v", err)
+		}
+	}
+	if files, _ := listStoredFiles(b.storagePath); len(files) != 0 {
+		t.Errorf("expected the partial file to be removed, got %+v", files)
+	}
+	if texts := api.texts(); len(texts) != 1 || !strings.HasPrefix(texts[0], "⚠️ Storage is full") {
+		t.Fatalf("expected the admin to be alerted once, got %q", texts)
+	}
+
+	// A successful save re-arms the alert
+	if _, err := b.saveFile(strings.NewReader("ok"), "documents", "small.txt", "", 42); err != nil {
+		t.Fatal(err)
+	}
+	b.saveFile(&diskFullReader{}, "documents", "big.bin", "", 42)
+	if texts := api.texts(); len(texts) != 2 {
+		t.Errorf("expected a second alert after recovery, got %q", texts)
+	}
+}
+
+// truncatingServer declares more content than it sends, then drops the
+// connection, so the download fails midway.
+func truncatingServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "4096")
+		w.Write([]byte(strings.Repeat("x", 1024)))
+		w.(http.Flusher).Flush()
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conn.Close()
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDownloadAndSaveRemovesPartialFile(t *testing.T) {
+	srv := truncatingServer(t)
+	for _, encrypted := range []bool{false, true} {
+		b := newDownloadTestBot(t, 5*time.Second)
+		b.client = &mockTelegramAPI{fileURL: srv.URL}
+		if encrypted {
+			b.cipher = testCipher(t, 9)
+		}
+
+		if _, err := b.downloadAndSave(mediaFile{MediaType: "documents", FileID: "f"}, "cut.bin", 42, 42); err == nil {
+			t.Fatalf("encrypted=%v: expected the truncated download to fail", encrypted)
+		}
+		// Neither the content nor a sidecar may remain
+		filepath.Walk(b.storagePath, func(path string, info os.FileInfo, err error) error {
+			if err == nil && strings.Contains(info.Name(), "cut") {
+				t.Errorf("encrypted=%v: expected nothing left of the failed save, found %s", encrypted, path)
+			}
+			return nil
+		})
+	}
+}
+
+// partialStorage keeps what it read before failing, like a backend that
+// does not clean up after an interrupted upload.
+type partialStorage struct {
+	root string
+}
+
+func (s partialStorage) Save(name string, r io.Reader) (int64, error) {
+	buf := make([]byte, 8)
+	n, _ := io.ReadFull(r, buf)
+	if err := os.WriteFile(filepath.Join(s.root, name), buf[:n], 0644); err != nil {
+		return 0, err
+	}
+	return int64(n), errors.New("upload interrupted")
+}
+func (s partialStorage) List(dir string) ([]storedFile, error) { return nil, nil }
+func (s partialStorage) Delete(name string) error              { return os.Remove(filepath.Join(s.root, name)) }
+
+func TestSaveFileRemovesPartialUploadOfBackend(t *testing.T) {
+	b := newTestBot(t)
+	b.storage = partialStorage{b.storagePath}
+
+	if _, err := b.saveFile(strings.NewReader("some content"), "documents", "a.txt", "", 0); err == nil {
+		t.Fatal("expected the save to fail")
+	}
+	if files, _ := listStoredFiles(b.storagePath); len(files) != 0 {
+		t.Errorf("expected the partial upload to be deleted, got %+v", files)
+	}
+}
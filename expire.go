@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxExpireDelay is the longest delay /expire accepts.
+const MaxExpireDelay = 365 * 24 * time.Hour
+
+// parseExpireDelay parses the delay of /expire: a Go duration such as 90m or
+// 2h30m, optionally led by a number of days, e.g. 7d or 1d12h.
+func parseExpireDelay(s string) (time.Duration, error) {
+	invalid := fmt.Errorf("invalid delay %q: use e.g. 30m, 2h or 7d", s)
+
+	var delay time.Duration
+	rest := strings.ToLower(strings.TrimSpace(s))
+	if i := strings.IndexByte(rest, 'd'); i >= 0 {
+		days, err := strconv.Atoi(rest[:i])
+		if err != nil || days < 0 || days > int(MaxExpireDelay/(24*time.Hour)) {
+			return 0, invalid
+		}
+		delay, rest = time.Duration(days)*24*time.Hour, rest[i+1:]
+	}
+	if rest != "" {
+		d, err := time.ParseDuration(rest)
+		if err != nil || d <= 0 {
+			return 0, invalid
+		}
+		delay += d
+	}
+
+	if delay <= 0 {
+		return 0, invalid
+	}
+	if delay > MaxExpireDelay {
+		return 0, fmt.Errorf("delay %q is longer than %d days", s, MaxExpireDelay/(24*time.Hour))
+	}
+	return delay, nil
+}
+
+// setExpiry schedules the stored file rel for deletion by the janitor at at,
+// or cancels its deletion for a nil at. It reports whether a deletion was
+// scheduled before.
+func (b *Bot) setExpiry(rel string, owner int64, at *time.Time) (bool, error) {
+	src := b.localPath(rel)
+	info, err := os.Stat(src)
+	if err != nil || !info.Mode().IsRegular() {
+		return false, errSourceMissing
+	}
+
+	m := b.fileMeta(rel, src, info)
+	if m.Owner == 0 {
+		m.Owner = owner
+	}
+	scheduled := m.ExpiresAt != nil
+	m.ExpiresAt = at
+	return scheduled, writeMeta(b.storagePath, m)
+}
+
+// handleExpireCommand schedules (or with "off" cancels) the deletion of one
+// of the user's files.
+func (b *Bot) handleExpireCommand(chatID, userID int64, args string) {
+	if !b.requireLocalStorage(chatID) {
+		return
+	}
+
+	parts := splitArgs(args)
+	if len(parts) != 2 {
+		b.sendTextMessage(chatID, "Usage: /expire <file> <delay>|off\nDelete a file after e.g. 30m, 2h or 7d. Quote names containing spaces, e.g. /expire \"my file.pdf\" 1d")
+		return
+	}
+	name, err := cleanRelPath(parts[0])
+	if err != nil {
+		b.sendTextMessage(chatID, "❌ Invalid file name")
+		return
+	}
+
+	var at *time.Time
+	if !strings.EqualFold(parts[1], "off") {
+		delay, err := parseExpireDelay(parts[1])
+		if err != nil {
+			b.sendTextMessage(chatID, fmt.Sprintf("❌ %v", err))
+			return
+		}
+		t := time.Now().Add(delay).Truncate(time.Second)
+		at = &t
+	}
+
+	rel := b.userFile(userID, name)
+	scheduled, err := b.setExpiry(rel, userID, at)
+	switch {
+	case errors.Is(err, errSourceMissing):
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ File '%s' not found", name))
+	case err != nil:
+		log.Printf("Error updating expiry of %s: %v", rel, err)
+		b.sendTextMessage(chatID, "❌ Failed to update the file.")
+	case at != nil:
+		log.Printf("User %d scheduled %s for deletion at %s", userID, rel, at.Format(time.RFC3339))
+		b.sendTextMessage(chatID, fmt.Sprintf("⏳ '%s' will be deleted on %s", name, at.Format("2006-01-02 15:04 MST")))
+	case scheduled:
+		log.Printf("User %d cancelled the deletion of %s", userID, rel)
+		b.sendTextMessage(chatID, fmt.Sprintf("✅ '%s' will no longer be deleted", name))
+	default:
+		b.sendTextMessage(chatID, fmt.Sprintf("ℹ️ '%s' was not scheduled for deletion", name))
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseExpireDelay(t *testing.T) {
+	tests := map[string]time.Duration{
+		"30m":   30 * time.Minute,
+		"2h":    2 * time.Hour,
+		"7d":    7 * 24 * time.Hour,
+		"1d12h": 36 * time.Hour,
+		"365d":  MaxExpireDelay,
+	}
+	for input, want := range tests {
+		if got, err := parseExpireDelay(input); err != nil || got != want {
+			t.Errorf("parseExpireDelay(%q) = %s, %v; want %s", input, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "0", "0d", "-2h", "d", "1w", "2d-3h", "366d", "8760h1s"} {
+		if _, err := parseExpireDelay(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestExpireCommand(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+	writeTestFile(t, b, "share.zip", "content")
+	last := func() string {
+		texts := api.texts()
+		return texts[len(texts)-1]
+	}
+
+	before := time.Now()
+	b.handleExpireCommand(42, 42, " share.zip 2h")
+	if reply := last(); !strings.HasPrefix(reply, "⏳ 'share.zip' will be deleted on ") {
+		t.Fatalf("unexpected reply %q", reply)
+	}
+	m, err := readMeta(b.storagePath, "share.zip")
+	if err != nil || m.ExpiresAt == nil || m.Owner != 42 {
+		t.Fatalf("expected the deletion to be recorded, got %+v (%v)", m, err)
+	}
+	if d := m.ExpiresAt.Sub(before); d < 2*time.Hour-time.Second || d > 2*time.Hour+time.Minute {
+		t.Errorf("expected the deletion in 2h, got %s", d)
+	}
+
+	b.handleExpireCommand(42, 42, " share.zip off")
+	if reply := last(); reply != "✅ 'share.zip' will no longer be deleted" {
+		t.Errorf("unexpected reply to cancelling %q", reply)
+	}
+	if m, err := readMeta(b.storagePath, "share.zip"); err != nil || m.ExpiresAt != nil {
+		t.Errorf("expected the deletion to be cancelled, got %+v (%v)", m, err)
+	}
+	b.handleExpireCommand(42, 42, " share.zip off")
+	if reply := last(); reply != "ℹ️ 'share.zip' was not scheduled for deletion" {
+		t.Errorf("unexpected reply to cancelling again %q", reply)
+	}
+
+	b.handleExpireCommand(42, 42, " missing.zip 1d")
+	if reply := last(); reply != "❌ File 'missing.zip' not found" {
+		t.Errorf("unexpected reply for a missing file %q", reply)
+	}
+	b.handleExpireCommand(42, 42, " share.zip soon")
+	if reply := last(); !strings.HasPrefix(reply, "❌ invalid delay") {
+		t.Errorf("unexpected reply for a bad delay %q", reply)
+	}
+}
+
+func TestJanitorDeletesScheduledFiles(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+	for _, rel := range []string{"due.zip", "later.zip", "pinned.zip", "kept.zip"} {
+		writeTestFile(t, b, rel, "content")
+	}
+	b.handlePinCommand(42, 42, " pinned.zip", true)
+	for _, rel := range []string{"due.zip", "later.zip", "pinned.zip"} {
+		b.handleExpireCommand(42, 42, " "+rel+" 1h")
+	}
+	b.handleExpireCommand(42, 42, " later.zip 3h")
+
+	// Without RETENTION_DAYS only scheduled files go, pinned or not
+	removed, freed, err := b.sweepExpired(time.Now().Add(2 * time.Hour))
+	if err != nil || removed != 2 || freed != int64(2*len("content")) {
+		t.Fatalf("expected two files to be removed, got %d, %d (%v)", removed, freed, err)
+	}
+	for rel, kept := range map[string]bool{"due.zip": false, "pinned.zip": false, "later.zip": true, "kept.zip": true} {
+		if _, err := os.Stat(b.localPath(rel)); (err == nil) != kept {
+			t.Errorf("%s: expected kept=%v, got %v", rel, kept, err)
+		}
+	}
+	if _, err := readMeta(b.storagePath, "due.zip"); err == nil {
+		t.Error("expected the sidecar of the deleted file to be removed")
+	}
+}
@@ -0,0 +1,672 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// MaxFileNameLength is the longest stored file name in bytes (common filesystem limit).
+	MaxFileNameLength = 255
+	// sharedDirName is the folder inside the recipient's directory receiving /share copies.
+	sharedDirName = "shared"
+	// RenameConfirmTTL is how long a /rename overwrite confirmation can be answered.
+	RenameConfirmTTL = 10 * time.Minute
+)
+
+var (
+	errInvalidName   = errors.New("invalid file name")
+	errSourceMissing = errors.New("source file does not exist")
+	errDestExists    = errors.New("destination already exists")
+)
+
+// renameRequest is a rename awaiting overwrite confirmation.
+type renameRequest struct {
+	userID  int64
+	from    string
+	to      string
+	created time.Time
+	// destSize and destModTime describe the file at to the user was asked
+	// about, so a confirmation never overwrites content that came later
+	destSize    int64
+	destModTime time.Time
+}
+
+// newRenameRequest records a rename of from over the existing file to.
+func (b *Bot) newRenameRequest(userID int64, from, to string) renameRequest {
+	req := renameRequest{userID: userID, from: from, to: to, created: time.Now()}
+	if info, err := os.Stat(b.localPath(to)); err == nil {
+		req.destSize, req.destModTime = info.Size(), info.ModTime()
+	}
+	return req
+}
+
+// expired reports whether the confirmation of req can no longer be answered.
+func (req renameRequest) expired() bool {
+	return time.Since(req.created) > RenameConfirmTTL
+}
+
+// destChanged reports whether the file at req.to is no longer the one the
+// user agreed to overwrite. A destination that is gone changed nothing.
+func (b *Bot) destChanged(req renameRequest) bool {
+	info, err := os.Stat(b.localPath(req.to))
+	if err != nil {
+		return false
+	}
+	return info.Size() != req.destSize || !info.ModTime().Equal(req.destModTime)
+}
+
+// sanitizeFileName turns a user- or Telegram-supplied name into a safe single path segment.
+// It returns "" when nothing usable remains.
+func sanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r == '/' || r == '\\':
+			return '_'
+		case unicode.IsControl(r):
+			return -1
+		}
+		return r
+	}, name)
+
+	name = strings.TrimSpace(name)
+	name = strings.TrimLeft(name, ".")
+
+	for len(name) > MaxFileNameLength {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+
+	return strings.TrimSpace(name)
+}
+
+// translit maps common non-ASCII letters that do not decompose into ASCII.
+var translit = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya", 'є': "ye", 'і': "i", 'ї': "yi", 'ґ': "g",
+	'ß': "ss", 'æ': "ae", 'ø': "o", 'œ': "oe", 'ł': "l", 'đ': "d", 'þ': "th", 'ð': "d",
+}
+
+// asciiSafeName transliterates a sanitized name to ASCII: accents and other
+// combining marks are dropped, common letters are transliterated and anything
+// else (emoji, bidi controls, ...) is removed. Runs of spaces are collapsed.
+func asciiSafeName(name string) string {
+	var sb strings.Builder
+	for _, r := range norm.NFC.String(name) {
+		if r < utf8.RuneSelf {
+			sb.WriteRune(r)
+			continue
+		}
+
+		lower := unicode.ToLower(r)
+		if t, ok := translit[lower]; ok {
+			if lower != r && t != "" {
+				t = strings.ToUpper(t[:1]) + t[1:]
+			}
+			sb.WriteString(t)
+			continue
+		}
+
+		// Keep the ASCII base of decomposable letters (é -> e), drop everything else
+		for _, d := range norm.NFD.String(string(r)) {
+			if d < utf8.RuneSelf {
+				sb.WriteRune(d)
+			}
+		}
+	}
+
+	safe := strings.Join(strings.Fields(sb.String()), " ")
+	ext := path.Ext(safe)
+	safe = strings.TrimSpace(strings.TrimSuffix(safe, ext)) + ext
+	if strings.HasPrefix(safe, ".") || safe == "" {
+		// Nothing left of the stem (e.g. an emoji-only name)
+		safe = "file" + safe
+	}
+	return sanitizeFileName(safe)
+}
+
+// storedName returns the on-disk form of a sanitized name: the name itself,
+// its ASCII-safe form when ASCII_SAFE_NAMES is enabled, lowercased when
+// LOWERCASE_NAMES is.
+func (b *Bot) storedName(name string) string {
+	if b.asciiSafeNames {
+		name = asciiSafeName(name)
+	}
+	if b.lowercaseNames {
+		name = lowercaseName(name)
+	}
+	return name
+}
+
+// storedRelPath applies storedName to every segment of a cleaned relative path,
+// so users can refer to files by their original names.
+func (b *Bot) storedRelPath(rel string) string {
+	return b.storedRelPathCase(rel, b.lowercaseNames)
+}
+
+// storedRelPathCase is storedRelPath lowercasing only if lower is set.
+func (b *Bot) storedRelPathCase(rel string, lower bool) string {
+	if !b.asciiSafeNames && !lower {
+		return rel
+	}
+	segments := strings.Split(rel, "/")
+	for i, segment := range segments {
+		safe := segment
+		if b.asciiSafeNames {
+			safe = asciiSafeName(segment)
+		}
+		if lower {
+			safe = lowercaseName(safe)
+		}
+		if safe != "" {
+			segments[i] = safe
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// lowercaseName lowercases a sanitized name for LOWERCASE_NAMES, so names
+// differing only in case cannot collide on case-insensitive filesystems.
+func lowercaseName(name string) string {
+	// Lowercasing may lengthen some runes, so trim the result again
+	return sanitizeFileName(strings.ToLower(name))
+}
+
+// suffixedName returns name with " (n)" inserted before its extension, or name
+// itself for n == 0. The base is shortened as needed to respect MaxFileNameLength.
+func suffixedName(name string, n int) string {
+	if n == 0 {
+		return name
+	}
+
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	suffix := fmt.Sprintf(" (%d)", n)
+
+	for len(base)+len(suffix)+len(ext) > MaxFileNameLength && base != "" {
+		_, size := utf8.DecodeLastRuneInString(base)
+		base = base[:len(base)-size]
+	}
+	return base + suffix + ext
+}
+
+// cleanRelPath validates a slash-separated path supplied by a user. Every segment
+// is sanitized; empty, relative ("..") and hidden segments are rejected.
+func cleanRelPath(p string) (string, error) {
+	var segments []string
+	for _, segment := range strings.Split(p, "/") {
+		if strings.TrimSpace(segment) == "" {
+			return "", errInvalidName
+		}
+		if segment == "." || segment == ".." || isHiddenName(segment) {
+			return "", errInvalidName
+		}
+
+		clean := sanitizeFileName(segment)
+		if clean == "" {
+			return "", errInvalidName
+		}
+		segments = append(segments, clean)
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+// userDir returns the storage-relative directory of a user's files:
+// the storage root, or a per-user subfolder when PER_USER_DIRS is enabled.
+// Channels always get their own folder.
+func (b *Bot) userDir(userID int64) string {
+	if b.isChannel(userID) {
+		return channelDir(userID)
+	}
+	if !b.perUserDirs {
+		return ""
+	}
+	return strconv.FormatInt(userID, 10)
+}
+
+// userPath joins a user-relative name onto the user's directory.
+func (b *Bot) userPath(userID int64, name string) string {
+	return path.Join(b.userDir(userID), name)
+}
+
+// localPath returns the filesystem location of a storage-relative path, in
+// whichever storage root holds it.
+func (b *Bot) localPath(rel string) string {
+	if ls, ok := b.storage.(*LocalStorage); ok {
+		return ls.Locate(rel)
+	}
+	return filepath.Join(b.storagePath, filepath.FromSlash(rel))
+}
+
+// dataRoots returns the local directories holding stored files.
+func (b *Bot) dataRoots() []string {
+	if ls, ok := b.storage.(*LocalStorage); ok {
+		return ls.Roots()
+	}
+	return []string{b.storagePath}
+}
+
+// localPathBeside returns where rel would be stored in the root holding the
+// existing file sibling, so moves and hardlinks stay on one filesystem.
+func (b *Bot) localPathBeside(sibling, rel string) string {
+	root := b.storagePath
+	if ls, ok := b.storage.(*LocalStorage); ok {
+		root = ls.RootOf(sibling)
+	}
+	return filepath.Join(root, filepath.FromSlash(rel))
+}
+
+// requireLocalStorage replies with an explanation and returns false when the
+// configured backend does not support direct file management.
+func (b *Bot) requireLocalStorage(chatID int64) bool {
+	if _, ok := b.storage.(*LocalStorage); !ok {
+		b.sendTextMessage(chatID, "⚠️ This command is only supported with local storage")
+		return false
+	}
+	return true
+}
+
+// splitArgs splits command arguments on whitespace, keeping double-quoted parts together.
+func splitArgs(s string) []string {
+	var args []string
+	var current strings.Builder
+	inQuotes, hasArg := false, false
+
+	for _, r := range s {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			hasArg = true
+		case unicode.IsSpace(r) && !inQuotes:
+			if hasArg {
+				args = append(args, current.String())
+				current.Reset()
+				hasArg = false
+			}
+		default:
+			current.WriteRune(r)
+			hasArg = true
+		}
+	}
+	if hasArg {
+		args = append(args, current.String())
+	}
+	return args
+}
+
+// resolveRename validates rename arguments relative to the user's directory.
+// A new name without a folder keeps the file in the source's folder.
+func (b *Bot) resolveRename(userID int64, oldName, newName string) (string, string, error) {
+	from, err := cleanRelPath(oldName)
+	if err != nil {
+		return "", "", err
+	}
+	to, err := cleanRelPath(newName)
+	if err != nil {
+		return "", "", err
+	}
+
+	if !strings.Contains(to, "/") {
+		to = path.Join(path.Dir(from), to)
+	}
+
+	fromRel, toRel := b.userFile(userID, from), b.userPath(userID, b.storedRelPath(to))
+	// A compressed file keeps its ".gz" when renamed by its listed name
+	if strings.HasSuffix(fromRel, compressedSuffix) && !strings.HasSuffix(from, compressedSuffix) {
+		toRel += compressedSuffix
+	}
+	return fromRel, toRel, nil
+}
+
+// renameFile moves a stored file (and its metadata) from one storage-relative path to another.
+func (b *Bot) renameFile(from, to string, overwrite bool) error {
+	src := b.localPath(from)
+
+	info, err := os.Stat(src)
+	if err != nil || !info.Mode().IsRegular() {
+		return errSourceMissing
+	}
+
+	if from == to {
+		return nil
+	}
+
+	existing := b.localPath(to)
+	_, err = os.Stat(existing)
+	replacing := err == nil
+	if replacing && !overwrite {
+		return errDestExists
+	}
+
+	// Stay in the source's root so the move is a cheap rename
+	dst := b.localPathBeside(from, to)
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("failed to create destination folder: %w", err)
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return fmt.Errorf("failed to rename file: %w", err)
+	}
+	if existing != dst {
+		// The overwritten file lived in another root
+		os.Remove(existing)
+	}
+
+	// The metadata of the overwritten file no longer describes anything
+	if replacing {
+		b.forgetFile(to)
+	}
+	b.moveMeta(from, to)
+	return nil
+}
+
+// moveMeta relocates the metadata sidecar and index entry of a renamed file.
+func (b *Bot) moveMeta(from, to string) {
+	m, err := readMeta(b.storagePath, from)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Failed to read metadata for %s: %v", from, err)
+		}
+		return
+	}
+
+	m.Path = to
+	if path.Base(from) != path.Base(to) {
+		// The user picked a new name; the original display name no longer applies
+		m.OriginalName = ""
+	}
+	if err := writeMeta(b.storagePath, m); err != nil {
+		log.Printf("Failed to write metadata for %s: %v", to, err)
+		return
+	}
+	os.Remove(metaPath(b.storagePath, from))
+
+	if b.index != nil {
+		// Rename drops the row of to; its content link goes through the blob check
+		b.unlinkContent(to)
+		if err := b.index.Rename(from, to); err != nil {
+			log.Printf("Failed to update index for %s: %v", to, err)
+		}
+	}
+}
+
+// displayName strips the user's directory from a storage-relative path.
+func (b *Bot) displayName(userID int64, rel string) string {
+	if dir := b.userDir(userID); dir != "" {
+		return strings.TrimPrefix(rel, dir+"/")
+	}
+	return rel
+}
+
+func (b *Bot) handleRenameCommand(chatID, userID int64, args string) {
+	if !b.requireLocalStorage(chatID) {
+		return
+	}
+
+	parts := splitArgs(args)
+	if len(parts) != 2 {
+		b.sendTextMessage(chatID, "Usage: /rename <old> <new>\nQuote names containing spaces, e.g. /rename \"old name.pdf\" new.pdf")
+		return
+	}
+
+	from, to, err := b.resolveRename(userID, parts[0], parts[1])
+	if err != nil {
+		b.sendTextMessage(chatID, "❌ Invalid file name")
+		return
+	}
+
+	err = b.renameFile(from, to, false)
+	switch {
+	case err == nil:
+		b.sendTextMessage(chatID, fmt.Sprintf("✅ Renamed '%s' to '%s'", b.displayName(userID, from), b.displayName(userID, to)))
+		log.Printf("User %d renamed %s to %s", userID, from, to)
+		b.audit(AuditEntry{User: userID, Action: "rename", Path: to, Detail: "from " + from})
+	case errors.Is(err, errSourceMissing):
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ File '%s' not found", b.displayName(userID, from)))
+	case errors.Is(err, errDestExists):
+		b.confirmRenameOverwrite(chatID, b.newRenameRequest(userID, from, to))
+	default:
+		log.Printf("Error renaming %s: %v", from, err)
+		b.sendTextMessage(chatID, "❌ Failed to rename the file.")
+	}
+}
+
+// confirmRenameOverwrite asks the user whether an existing destination should be replaced.
+func (b *Bot) confirmRenameOverwrite(chatID int64, req renameRequest) {
+	token := newToken()
+
+	b.mu.Lock()
+	if b.pendingRenames == nil {
+		b.pendingRenames = make(map[string]renameRequest)
+	}
+	// Unanswered confirmations are dropped once they expire
+	for t, r := range b.pendingRenames {
+		if r.expired() {
+			delete(b.pendingRenames, t)
+		}
+	}
+	b.pendingRenames[token] = req
+	b.mu.Unlock()
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("⚠️ '%s' already exists. Overwrite it?", b.displayName(req.userID, req.to)))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Overwrite", "rename:yes:"+token),
+		tgbotapi.NewInlineKeyboardButtonData("Cancel", "rename:no:"+token),
+	))
+	if _, err := b.send(msg); err != nil {
+		log.Printf("Failed to send message: %v", err)
+	}
+}
+
+// handleRenameCallback resolves an overwrite confirmation and returns the resulting text.
+func (b *Bot) handleRenameCallback(userID int64, action, token string) string {
+	b.mu.Lock()
+	req, ok := b.pendingRenames[token]
+	if ok && req.userID == userID {
+		delete(b.pendingRenames, token)
+	}
+	b.mu.Unlock()
+
+	if !ok || req.userID != userID || req.expired() {
+		return "⚠️ This rename request has expired."
+	}
+	if action != "yes" {
+		return "Rename cancelled."
+	}
+	if b.destChanged(req) {
+		return fmt.Sprintf("⚠️ '%s' has changed since you were asked. Send /rename again to overwrite it.", b.displayName(userID, req.to))
+	}
+
+	if err := b.renameFile(req.from, req.to, true); err != nil {
+		if errors.Is(err, errSourceMissing) {
+			return fmt.Sprintf("❌ File '%s' not found", b.displayName(userID, req.from))
+		}
+		log.Printf("Error renaming %s: %v", req.from, err)
+		return "❌ Failed to rename the file."
+	}
+
+	log.Printf("User %d renamed %s to %s (overwrite)", userID, req.from, req.to)
+	b.audit(AuditEntry{User: userID, Action: "rename", Path: req.to, Detail: "from " + req.from + " (overwrite)"})
+	return fmt.Sprintf("✅ Renamed '%s' to '%s'", b.displayName(userID, req.from), b.displayName(userID, req.to))
+}
+
+func (b *Bot) handleShareCommand(chatID, userID int64, args string) {
+	if !b.requireLocalStorage(chatID) {
+		return
+	}
+	// Without user folders "shared/" would be one folder every user sees
+	if !b.perUserDirs {
+		b.sendTextMessage(chatID, "❌ Sharing needs a folder per user (PER_USER_DIRS), and all users share one folder here.")
+		return
+	}
+
+	parts := splitArgs(args)
+	if len(parts) != 2 {
+		b.sendTextMessage(chatID, "Usage: /share <file> <user_id>\nQuote names containing spaces, e.g. /share \"my file.pdf\" 123456789")
+		return
+	}
+
+	name, err := cleanRelPath(parts[0])
+	if err != nil {
+		b.sendTextMessage(chatID, "❌ Invalid file name")
+		return
+	}
+	recipient, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		b.sendTextMessage(chatID, "❌ Invalid user ID format")
+		return
+	}
+	if recipient == userID {
+		b.sendTextMessage(chatID, "❌ You cannot share a file with yourself.")
+		return
+	}
+	if !b.isUserAllowed(recipient) {
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ User %d is not an authorized user of this bot", recipient))
+		return
+	}
+
+	from := b.userFile(userID, name)
+	to, err := b.shareFile(from, userID, recipient)
+	switch {
+	case errors.Is(err, errSourceMissing):
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ File '%s' not found", name))
+		return
+	case err != nil:
+		log.Printf("Error sharing %s with %d: %v", from, recipient, err)
+		b.sendTextMessage(chatID, "❌ Failed to share the file.")
+		return
+	}
+	log.Printf("User %d shared %s with %d as %s", userID, from, recipient, to)
+
+	notice := fmt.Sprintf("📥 User %d shared '%s' with you. Stored as '%s'.", userID, path.Base(name), b.displayName(recipient, to))
+	if _, err := b.send(tgbotapi.NewMessage(recipient, notice)); err != nil {
+		log.Printf("Failed to notify %d about shared file: %v", recipient, err)
+		if isBlockedError(err) {
+			b.sendTextMessage(chatID, fmt.Sprintf("✅ Shared '%s' with %d, but they could not be notified (they have not started the bot or have blocked it).", name, recipient))
+			return
+		}
+		b.sendTextMessage(chatID, fmt.Sprintf("✅ Shared '%s' with %d, but the notification failed.", name, recipient))
+		return
+	}
+
+	b.sendTextMessage(chatID, fmt.Sprintf("✅ Shared '%s' with %d", name, recipient))
+}
+
+// shareFile places a copy of the storage-relative file from into the recipient's
+// shared folder, suffixing the name if taken, and returns the new path. The
+// copy is a hardlink where possible. Metadata is carried over.
+func (b *Bot) shareFile(from string, sender, recipient int64) (string, error) {
+	src := b.localPath(from)
+	info, err := os.Stat(src)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", errSourceMissing
+	}
+
+	if err := os.MkdirAll(b.localPathBeside(from, b.userPath(recipient, sharedDirName)), 0755); err != nil {
+		return "", fmt.Errorf("failed to create shared folder: %w", err)
+	}
+
+	var to string
+	for n := 0; ; n++ {
+		if n > MaxNameSuffix {
+			return "", fmt.Errorf("no free name for %s after %d attempts", from, MaxNameSuffix)
+		}
+		to = b.userPath(recipient, path.Join(sharedDirName, suffixedName(path.Base(from), n)))
+
+		if _, err := os.Stat(b.localPath(to)); err == nil {
+			continue
+		}
+		err := linkOrCopy(src, b.localPathBeside(from, to))
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return "", err
+		}
+	}
+
+	m := b.fileMeta(from, src, info)
+	m.Path = to
+	m.Owner = recipient
+	m.SharedBy = sender
+	m.Pinned = false
+	b.recordFile(m)
+	b.audit(AuditEntry{User: sender, Action: "share", Path: to, Size: m.Size, SHA256: m.SHA256, Detail: fmt.Sprintf("from %s to user %d", from, recipient)})
+
+	return to, nil
+}
+
+// fileMeta returns the metadata of the stored file rel at the local path src,
+// describing it from the file itself when it has no sidecar.
+func (b *Bot) fileMeta(rel, src string, info os.FileInfo) FileMeta {
+	m, err := readMeta(b.storagePath, rel)
+	if err == nil {
+		return m
+	}
+
+	m = FileMeta{Path: rel, ReceivedAt: info.ModTime()}
+	if m.SHA256, m.Size, err = hashFile(src, b.openPath); err != nil {
+		log.Printf("Failed to hash %s: %v", rel, err)
+	}
+	return m
+}
+
+// linkOrCopy hardlinks src to dst, falling back to a byte copy (e.g. across
+// filesystems). It never overwrites: an existing dst yields os.ErrExist.
+func linkOrCopy(src, dst string) error {
+	err := os.Link(src, dst)
+	if err == nil || errors.Is(err, os.ErrExist) {
+		return err
+	}
+	return copyFile(src, dst)
+}
+
+// copyFile copies the bytes of src to the new file dst, removing dst again
+// when the copy fails. An existing dst yields os.ErrExist.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open source: %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+	return nil
+}
+
+// newToken returns a short random identifier for callback data.
+func newToken() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func writeTestFile(t *testing.T, b *Bot, rel, content string) {
+	t.Helper()
+	path := b.localPath(rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSanitizeFileName(t *testing.T) {
+	tests := map[string]string{
+		"report.pdf":             "report.pdf",
+		"../../etc/passwd":       "_.._etc_passwd",
+		"  spaced name.txt":      "spaced name.txt",
+		".hidden":                "hidden",
+		"bad\x00\nname.txt":      "badname.txt",
+		"back\\slash.doc":        "back_slash.doc",
+		"...":                    "",
+		strings.Repeat("я", 200): strings.Repeat("я", 127),
+	}
+
+	for in, want := range tests {
+		if got := sanitizeFileName(in); got != want {
+			t.Errorf("sanitizeFileName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestCleanRelPath(t *testing.T) {
+	valid := map[string]string{
+		"a.txt":          "a.txt",
+		"docs/a.txt":     "docs/a.txt",
+		"docs/sub/a.txt": "docs/sub/a.txt",
+	}
+	for in, want := range valid {
+		got, err := cleanRelPath(in)
+		if err != nil || got != want {
+			t.Errorf("cleanRelPath(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+
+	for _, in := range []string{"../a.txt", "docs/../../a.txt", "/abs.txt", "docs//a.txt", ".meta/a.json", "docs/"} {
+		if _, err := cleanRelPath(in); !errors.Is(err, errInvalidName) {
+			t.Errorf("cleanRelPath(%q): expected errInvalidName, got %v", in, err)
+		}
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	got := splitArgs(` "old name.pdf"   new.pdf `)
+	want := []string{"old name.pdf", "new.pdf"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitArgs = %q, want %q", got, want)
+	}
+}
+
+func TestRenameFileSuccess(t *testing.T) {
+	b := newTestBot(t)
+	b.perUserDirs = true
+	writeTestFile(t, b, "42/old.txt", "content")
+	writeMeta(b.storagePath, FileMeta{Path: "42/old.txt", Owner: 42, Size: 7})
+
+	from, to, err := b.resolveRename(42, "old.txt", "new.txt")
+	if err != nil {
+		t.Fatalf("resolveRename failed: %v", err)
+	}
+	if from != "42/old.txt" || to != "42/new.txt" {
+		t.Fatalf("unexpected resolved paths %q -> %q", from, to)
+	}
+
+	if err := b.renameFile(from, to, false); err != nil {
+		t.Fatalf("renameFile failed: %v", err)
+	}
+
+	if _, err := os.Stat(b.localPath("42/old.txt")); !os.IsNotExist(err) {
+		t.Error("expected source to be gone")
+	}
+	data, err := os.ReadFile(b.localPath("42/new.txt"))
+	if err != nil || string(data) != "content" {
+		t.Errorf("expected renamed file with original content, got %q (%v)", data, err)
+	}
+
+	meta, err := readMeta(b.storagePath, "42/new.txt")
+	if err != nil || meta.Path != "42/new.txt" || meta.Owner != 42 {
+		t.Errorf("expected metadata to follow the file, got %+v (%v)", meta, err)
+	}
+	if _, err := readMeta(b.storagePath, "42/old.txt"); !os.IsNotExist(err) {
+		t.Error("expected old metadata sidecar to be removed")
+	}
+}
+
+func TestRenameIntoExplicitSubfolder(t *testing.T) {
+	b := newTestBot(t)
+	writeTestFile(t, b, "docs/a.txt", "a")
+
+	from, to, err := b.resolveRename(1, "docs/a.txt", "b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if to != "docs/b.txt" {
+		t.Errorf("expected a bare new name to stay in the source folder, got %q", to)
+	}
+
+	_, to, err = b.resolveRename(1, "docs/a.txt", "archive/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.renameFile(from, to, false); err != nil {
+		t.Fatalf("renameFile failed: %v", err)
+	}
+	if _, err := os.Stat(b.localPath("archive/b.txt")); err != nil {
+		t.Errorf("expected file in explicit subfolder: %v", err)
+	}
+
+	if _, _, err := b.resolveRename(1, "a.txt", "../escape.txt"); !errors.Is(err, errInvalidName) {
+		t.Errorf("expected traversal to be rejected, got %v", err)
+	}
+}
+
+func TestRenameFileMissingSource(t *testing.T) {
+	b := newTestBot(t)
+
+	if err := b.renameFile("missing.txt", "new.txt", false); !errors.Is(err, errSourceMissing) {
+		t.Errorf("expected errSourceMissing, got %v", err)
+	}
+}
+
+func TestRenameFileExistingDestination(t *testing.T) {
+	b := newTestBot(t)
+	writeTestFile(t, b, "a.txt", "first")
+	writeTestFile(t, b, "b.txt", "second")
+
+	if err := b.renameFile("a.txt", "b.txt", false); !errors.Is(err, errDestExists) {
+		t.Fatalf("expected errDestExists, got %v", err)
+	}
+	if data, _ := os.ReadFile(b.localPath("b.txt")); string(data) != "second" {
+		t.Error("destination must not change without confirmation")
+	}
+
+	// Confirming via the inline keyboard overwrites
+	b.pendingRenames = map[string]renameRequest{"tok": b.newRenameRequest(7, "a.txt", "b.txt")}
+
+	if reply := b.handleRenameCallback(8, "yes", "tok"); !strings.Contains(reply, "expired") {
+		t.Errorf("expected other users to be rejected, got %q", reply)
+	}
+	if reply := b.handleRenameCallback(7, "yes", "tok"); !strings.HasPrefix(reply, "✅") {
+		t.Fatalf("expected overwrite to succeed, got %q", reply)
+	}
+	if data, _ := os.ReadFile(b.localPath("b.txt")); string(data) != "first" {
+		t.Errorf("expected destination to be overwritten, got %q", data)
+	}
+	if reply := b.handleRenameCallback(7, "yes", "tok"); !strings.Contains(reply, "expired") {
+		t.Errorf("expected confirmation to be single-use, got %q", reply)
+	}
+}
+
+func TestRenameOverwriteForgetsReplacedMetadata(t *testing.T) {
+	b := newTestBot(t)
+	b.index = newTestIndex(t)
+	writeTestFile(t, b, "a.txt", "no sidecar")
+	writeTestFile(t, b, "b.txt", "replaced")
+	b.recordFile(FileMeta{Path: "b.txt", Owner: 7, Size: 8, SHA256: sha256Hex("replaced"), ReceivedAt: time.Now()})
+
+	if err := b.renameFile("a.txt", "b.txt", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readMeta(b.storagePath, "b.txt"); !os.IsNotExist(err) {
+		t.Errorf("expected the replaced file's sidecar to be gone, got %v", err)
+	}
+	if _, ok, err := b.index.Get("b.txt"); ok || err != nil {
+		t.Errorf("expected the replaced file's index entry to be gone (%v)", err)
+	}
+}
+
+func TestRenameConfirmationExpires(t *testing.T) {
+	b := newTestBot(t)
+	b.client = &mockTelegramAPI{}
+	writeTestFile(t, b, "a.txt", "first")
+	writeTestFile(t, b, "b.txt", "second")
+
+	stale := b.newRenameRequest(7, "a.txt", "b.txt")
+	stale.created = time.Now().Add(-RenameConfirmTTL - time.Minute)
+	b.pendingRenames = map[string]renameRequest{"old": stale}
+	if reply := b.handleRenameCallback(7, "yes", "old"); !strings.Contains(reply, "expired") {
+		t.Errorf("expected an old confirmation to be refused, got %q", reply)
+	}
+	if data, _ := os.ReadFile(b.localPath("b.txt")); string(data) != "second" {
+		t.Error("destination must not change after the confirmation expired")
+	}
+
+	// Asking again drops the confirmations nobody answered in time
+	b.pendingRenames["unanswered"] = stale
+	b.confirmRenameOverwrite(7, b.newRenameRequest(7, "a.txt", "b.txt"))
+	if _, ok := b.pendingRenames["unanswered"]; ok || len(b.pendingRenames) != 1 {
+		t.Errorf("expected only the new confirmation to be kept, got %v", b.pendingRenames)
+	}
+}
+
+func TestRenameConfirmationRechecksDestination(t *testing.T) {
+	b := newTestBot(t)
+	writeTestFile(t, b, "a.txt", "first")
+	writeTestFile(t, b, "b.txt", "second")
+	b.pendingRenames = map[string]renameRequest{"tok": b.newRenameRequest(7, "a.txt", "b.txt")}
+
+	// Other content arrives at the destination before the user confirms
+	writeTestFile(t, b, "b.txt", "newer content")
+	if reply := b.handleRenameCallback(7, "yes", "tok"); !strings.Contains(reply, "has changed") {
+		t.Errorf("expected the overwrite to be refused, got %q", reply)
+	}
+	if data, _ := os.ReadFile(b.localPath("b.txt")); string(data) != "newer content" {
+		t.Errorf("expected the newer destination to be kept, got %q", data)
+	}
+}
+
+func TestShareFileCopiesWithMetadata(t *testing.T) {
+	b := newTestBot(t)
+	b.perUserDirs = true
+	writeTestFile(t, b, "42/report.pdf", "quarterly")
+	writeMeta(b.storagePath, FileMeta{Path: "42/report.pdf", Owner: 42, Size: 9, MIME: "application/pdf", SHA256: "abc"})
+	writeTestFile(t, b, "7/shared/report.pdf", "already there")
+
+	to, err := b.shareFile("42/report.pdf", 42, 7)
+	if err != nil {
+		t.Fatalf("shareFile failed: %v", err)
+	}
+	if to != "7/shared/report (1).pdf" {
+		t.Errorf("expected a suffixed name next to the existing share, got %q", to)
+	}
+
+	if data, _ := os.ReadFile(b.localPath(to)); string(data) != "quarterly" {
+		t.Errorf("unexpected shared content %q", data)
+	}
+	if data, _ := os.ReadFile(b.localPath("42/report.pdf")); string(data) != "quarterly" {
+		t.Error("expected the sender's file to remain")
+	}
+
+	meta, err := readMeta(b.storagePath, to)
+	if err != nil || meta.Owner != 7 || meta.SharedBy != 42 || meta.MIME != "application/pdf" || meta.SHA256 != "abc" {
+		t.Errorf("expected metadata to be carried over, got %+v (%v)", meta, err)
+	}
+
+	if _, err := b.shareFile("42/missing.pdf", 42, 7); !errors.Is(err, errSourceMissing) {
+		t.Errorf("expected errSourceMissing, got %v", err)
+	}
+}
+
+func TestShareCommandNotifiesRecipient(t *testing.T) {
+	b := newTestBot(t)
+	b.perUserDirs = true
+	api := &mockTelegramAPI{}
+	b.client = api
+	b.allowedUsers = map[int64]bool{42: true, 7: true}
+	writeTestFile(t, b, "42/notes.txt", "hi")
+
+	b.handleShareCommand(42, 42, ` notes.txt 7`)
+
+	var toRecipient, toSender []string
+	for _, c := range api.sent {
+		msg := c.(tgbotapi.MessageConfig)
+		if msg.ChatID == 7 {
+			toRecipient = append(toRecipient, msg.Text)
+		} else {
+			toSender = append(toSender, msg.Text)
+		}
+	}
+	if len(toRecipient) != 1 || !strings.Contains(toRecipient[0], "shared/notes.txt") {
+		t.Errorf("expected the recipient to be notified, got %q", toRecipient)
+	}
+	if len(toSender) != 1 || toSender[0] != "✅ Shared 'notes.txt' with 7" {
+		t.Errorf("unexpected sender reply %q", toSender)
+	}
+
+	// Recipients outside the allowlist are refused
+	b.handleShareCommand(42, 42, ` notes.txt 99`)
+	if texts := api.texts(); !strings.Contains(texts[len(texts)-1], "not an authorized user") {
+		t.Errorf("expected unauthorized recipient to be refused, got %q", texts[len(texts)-1])
+	}
+}
+
+func TestShareCommandRefusals(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+	b.allowedUsers = map[int64]bool{42: true, 7: true}
+	writeTestFile(t, b, "notes.txt", "hi")
+
+	// Without user folders there is no folder of the recipient's own
+	b.handleShareCommand(42, 42, ` notes.txt 7`)
+	if texts := api.texts(); len(texts) != 1 || !strings.Contains(texts[0], "PER_USER_DIRS") {
+		t.Errorf("expected sharing to be refused without per-user folders, got %q", texts)
+	}
+	if exists(b.localPath("shared/notes.txt")) {
+		t.Error("expected no shared copy in the common folder")
+	}
+
+	b.perUserDirs = true
+	writeTestFile(t, b, "42/notes.txt", "hi")
+	api.sent = nil
+	b.handleShareCommand(42, 42, ` notes.txt 42`)
+	if texts := api.texts(); len(texts) != 1 || !strings.Contains(texts[0], "with yourself") {
+		t.Errorf("expected sharing with oneself to be refused, got %q", texts)
+	}
+}
+
+func TestShareCommandRecipientBlockedBot(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{sendErr: func(_ int, c tgbotapi.Chattable) error {
+		if msg, ok := c.(tgbotapi.MessageConfig); ok && msg.ChatID == 7 {
+			return &tgbotapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"}
+		}
+		return nil
+	}}
+	b.client = api
+	b.perUserDirs = true
+	writeTestFile(t, b, "42/notes.txt", "hi")
+
+	b.handleShareCommand(42, 42, ` notes.txt 7`)
+
+	if _, err := os.Stat(b.localPath("7/shared/notes.txt")); err != nil {
+		t.Errorf("expected the file to be shared anyway: %v", err)
+	}
+	texts := api.texts()
+	if len(texts) != 1 || !strings.Contains(texts[0], "could not be notified") {
+		t.Errorf("expected the sender to be told about the failed notification, got %q", texts)
+	}
+}
+
+func TestASCIISafeName(t *testing.T) {
+	tests := map[string]string{
+		"report.pdf":                 "report.pdf",
+		"📄 report 🚀.pdf":             "report.pdf",
+		"😀.txt":                      "file.txt",
+		"invoice\u202efdp.exe":       "invoicefdp.exe",
+		"\u200fright\u200e-left.doc": "right-left.doc",
+		"cafe\u0301 crème.txt":       "cafe creme.txt",
+		"Ångström.csv":               "Angstrom.csv",
+		"Отчёт за май.docx":          "Otchet za may.docx",
+		"Straße.jpg":                 "Strasse.jpg",
+	}
+	for in, want := range tests {
+		if got := asciiSafeName(in); got != want {
+			t.Errorf("asciiSafeName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestASCIISafeNamesKeepOriginalForListing(t *testing.T) {
+	b := newTestBot(t)
+	b.asciiSafeNames = true
+
+	saved, err := b.saveFile(strings.NewReader("data"), "documents", "Отчёт 📊.pdf", "application/pdf", 42)
+	if err != nil {
+		t.Fatalf("saveFile failed: %v", err)
+	}
+	if saved.Path != "Otchet.pdf" || saved.OriginalName != "Отчёт 📊.pdf" {
+		t.Fatalf("unexpected stored file %+v", saved)
+	}
+
+	// A fresh bot over the same storage recovers the mapping from the sidecar
+	restarted := &Bot{storagePath: b.storagePath, storage: b.storage, asciiSafeNames: true}
+	files, err := restarted.findFiles(42, "отчёт", ListLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name != "Отчёт 📊.pdf [Otchet.pdf]" {
+		t.Errorf("expected the original name in listings, got %+v", files)
+	}
+
+	// The original name can be used to refer to the file
+	from, _, err := restarted.resolveRename(42, "Отчёт.pdf", "final.pdf")
+	if err != nil || from != "Otchet.pdf" {
+		t.Errorf("expected the original name to resolve to the stored file, got %q (%v)", from, err)
+	}
+}
+
+func TestLowercaseNames(t *testing.T) {
+	b := newTestBot(t)
+	b.lowercaseNames = true
+	if got := b.storedRelPath("Reports/Q1 Report.PDF"); got != "reports/q1 report.pdf" {
+		t.Errorf("storedRelPath = %q", got)
+	}
+	b.asciiSafeNames = true
+	if got := b.storedName("Ångström.CSV"); got != "angstrom.csv" {
+		t.Errorf("storedName = %q", got)
+	}
+}
+
+func TestLowercaseNamesCollide(t *testing.T) {
+	b := newTestBot(t)
+	// Stored before LOWERCASE_NAMES was enabled
+	if err := os.WriteFile(filepath.Join(b.storagePath, "Old.TXT"), []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	b.lowercaseNames = true
+
+	first, err := b.saveFile(strings.NewReader("one"), "documents", "Report.pdf", "application/pdf", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := b.saveFile(strings.NewReader("two"), "documents", "report.PDF", "application/pdf", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Path != "report.pdf" || first.OriginalName != "Report.pdf" {
+		t.Errorf("unexpected first file %+v", first)
+	}
+	// The same name in another case is a collision on every filesystem
+	if second.Path != "report (1).pdf" || second.OriginalName != "report.PDF" {
+		t.Errorf("unexpected second file %+v", second)
+	}
+
+	if got := b.userFile(42, "REPORT.pdf"); got != "report.pdf" {
+		t.Errorf("expected a lookup in any case, got %q", got)
+	}
+	if got := b.userFile(42, "Old.TXT"); got != "Old.TXT" {
+		t.Errorf("expected files stored before to be found by their name, got %q", got)
+	}
+	matches, err := b.matchUserFiles(42, "REPORT*")
+	if err != nil || len(matches) != 2 {
+		t.Errorf("expected /get to ignore case, got %q (%v)", matches, err)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+const (
+	// fsckBatchSize is how many index entries /admin fsck reads per query,
+	// so the index is never held for the whole scan.
+	fsckBatchSize = 200
+	// fsckListLimit is how many paths per kind of problem the report names.
+	fsckListLimit = 10
+)
+
+// fsckReport is the drift found between the index and the stored files.
+type fsckReport struct {
+	Checked    int      // index entries checked
+	Unindexed  []string // stored files without an index entry
+	Missing    []string // index entries whose file is gone
+	Mismatched []string // index entries whose size or hash differs from the file
+}
+
+func (r fsckReport) clean() bool {
+	return len(r.Unindexed) == 0 && len(r.Missing) == 0 && len(r.Mismatched) == 0
+}
+
+// fsck cross-checks the index against the stored files, reading the index in
+// batches of fsckBatchSize. With fix the index follows the disk: stored files
+// are added, entries of gone files removed and mismatched ones updated, along
+// with their metadata sidecars.
+func (b *Bot) fsck(fix bool) (fsckReport, error) {
+	var report fsckReport
+	indexed := make(map[string]bool)
+	for after := ""; ; {
+		batch, err := b.index.Page(after, fsckBatchSize)
+		if err != nil {
+			return report, err
+		}
+		for _, entry := range batch {
+			indexed[entry.Path] = true
+			report.Checked++
+			b.fsckEntry(&report, entry, fix)
+		}
+		if len(batch) < fsckBatchSize {
+			break
+		}
+		after = batch[len(batch)-1].Path
+	}
+
+	for _, root := range b.dataRoots() {
+		err := walkStoredFiles(root, func(rel, path string, info os.FileInfo) error {
+			if indexed[rel] {
+				return nil
+			}
+			report.Unindexed = append(report.Unindexed, rel)
+			if !fix {
+				return nil
+			}
+			m, err := readMeta(b.storagePath, rel)
+			if err != nil {
+				m = FileMeta{ReceivedAt: info.ModTime()}
+			}
+			m.Path = rel
+			if m.SHA256, m.Size, err = hashFile(path, b.openPath); err != nil {
+				log.Printf("fsck: failed to hash %s: %v", rel, err)
+				return nil
+			}
+			b.recordFile(m)
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return report, fmt.Errorf("failed to walk storage directory: %w", err)
+		}
+	}
+	return report, nil
+}
+
+// fsckEntry checks one index entry against its file.
+func (b *Bot) fsckEntry(report *fsckReport, entry FileMeta, fix bool) {
+	path := b.localPath(entry.Path)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		report.Missing = append(report.Missing, entry.Path)
+		if fix {
+			b.forgetFile(entry.Path)
+		}
+		return
+	}
+
+	sum, size, err := hashFile(path, b.openPath)
+	if err != nil {
+		log.Printf("fsck: failed to hash %s: %v", entry.Path, err)
+		return
+	}
+	if size == entry.Size && (entry.SHA256 == "" || sum == entry.SHA256) {
+		return
+	}
+	report.Mismatched = append(report.Mismatched, entry.Path)
+	if fix {
+		m, err := readMeta(b.storagePath, entry.Path)
+		if err != nil {
+			m = entry
+		}
+		m.Path, m.Size, m.SHA256 = entry.Path, size, sum
+		b.recordFile(m)
+	}
+}
+
+// formatFsckReport renders report, naming up to fsckListLimit paths per
+// problem.
+func formatFsckReport(report fsckReport, fixed bool) string {
+	if report.clean() {
+		return fmt.Sprintf("✅ File index matches storage: %d entries checked.", report.Checked)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🩺 File index check: %d entries checked", report.Checked))
+	for _, section := range []struct {
+		title string
+		paths []string
+	}{
+		{"📄 Stored but not indexed", report.Unindexed},
+		{"🗑 Indexed but missing from disk", report.Missing},
+		{"⚠️ Size or checksum differs", report.Mismatched},
+	} {
+		if len(section.paths) == 0 {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("\n\n%s (%d):", section.title, len(section.paths)))
+		for _, p := range section.paths[:min(len(section.paths), fsckListLimit)] {
+			sb.WriteString("\n• " + p)
+		}
+		if extra := len(section.paths) - fsckListLimit; extra > 0 {
+			sb.WriteString(fmt.Sprintf("\n… and %d more", extra))
+		}
+	}
+
+	if fixed {
+		sb.WriteString("\n\n🔧 Repaired: the index now follows the files on disk.")
+	} else {
+		sb.WriteString("\n\nRun /admin fsck --fix to repair the index.")
+	}
+	return sb.String()
+}
+
+// handleAdminFsck checks the index against the stored files in the
+// background, repairing it with --fix.
+func (b *Bot) handleAdminFsck(chatID int64, args []string) {
+	if b.index == nil {
+		b.sendTextMessage(chatID, "⚠️ File index is not enabled (set INDEX_DB)")
+		return
+	}
+	if !b.requireLocalStorage(chatID) {
+		return
+	}
+
+	fix := false
+	switch {
+	case len(args) == 0:
+	case len(args) == 1 && args[0] == "--fix":
+		fix = true
+	default:
+		b.sendTextMessage(chatID, "Usage: /admin fsck [--fix]")
+		return
+	}
+
+	if !b.fsckRunning.CompareAndSwap(false, true) {
+		b.sendTextMessage(chatID, "⏳ A check is already running.")
+		return
+	}
+	b.sendTextMessage(chatID, "🔍 Checking the file index against storage…")
+	go func() {
+		defer b.fsckRunning.Store(false)
+		b.runFsck(chatID, fix)
+	}()
+}
+
+// runFsck runs fsck and sends the report to chatID.
+func (b *Bot) runFsck(chatID int64, fix bool) {
+	report, err := b.fsck(fix)
+	if err != nil {
+		log.Printf("Error checking the file index: %v", err)
+		b.sendTextMessage(chatID, "❌ Failed to check the file index.")
+		return
+	}
+	log.Printf("Admin %d checked the file index: %d entries, %d unindexed, %d missing, %d mismatched (fix: %v)",
+		chatID, report.Checked, len(report.Unindexed), len(report.Missing), len(report.Mismatched), fix)
+	b.sendLongMessage(chatID, formatFsckReport(report, fix))
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// newFsckTestBot indexes a tree of three files, then lets it drift: a file
+// stored behind the index's back, an indexed file deleted and one rewritten.
+func newFsckTestBot(t *testing.T) *Bot {
+	t.Helper()
+	b := newTestBot(t)
+	idx, err := OpenFileIndex(filepath.Join(t.TempDir(), "index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { idx.Close() })
+	b.index = idx
+
+	for rel, content := range map[string]string{"42/ok.txt": "fine", "42/gone.txt": "bye", "42/changed.txt": "before"} {
+		writeTestFile(t, b, rel, content)
+		b.recordFile(FileMeta{Path: rel, Owner: 42, Size: int64(len(content)), SHA256: sha256Hex(content), ReceivedAt: time.Now()})
+	}
+	writeTestFile(t, b, "7/stray.txt", "stray")
+	if err := b.storage.Delete("42/gone.txt"); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, b, "42/changed.txt", "after!")
+	return b
+}
+
+func TestFsckReportsDrift(t *testing.T) {
+	b := newFsckTestBot(t)
+
+	report, err := b.fsck(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Checked != 3 {
+		t.Errorf("expected 3 entries checked, got %d", report.Checked)
+	}
+	if strings.Join(report.Unindexed, ",") != "7/stray.txt" {
+		t.Errorf("unexpected unindexed files %v", report.Unindexed)
+	}
+	if strings.Join(report.Missing, ",") != "42/gone.txt" {
+		t.Errorf("unexpected missing files %v", report.Missing)
+	}
+	if strings.Join(report.Mismatched, ",") != "42/changed.txt" {
+		t.Errorf("unexpected mismatched files %v", report.Mismatched)
+	}
+
+	text := formatFsckReport(report, false)
+	for _, want := range []string{"3 entries checked", "not indexed (1):\n• 7/stray.txt", "missing from disk (1):\n• 42/gone.txt", "differs (1):\n• 42/changed.txt", "/admin fsck --fix"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected %q in %q", want, text)
+		}
+	}
+
+	// Without --fix nothing changes
+	if again, _ := b.fsck(false); again.clean() {
+		t.Error("expected the drift to remain without fix")
+	}
+}
+
+func TestFsckFixRepairsIndex(t *testing.T) {
+	b := newFsckTestBot(t)
+
+	if _, err := b.fsck(true); err != nil {
+		t.Fatal(err)
+	}
+	report, err := b.fsck(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.clean() || report.Checked != 3 {
+		t.Fatalf("expected a clean index of 3 entries after fix, got %+v", report)
+	}
+	if text := formatFsckReport(report, false); !strings.Contains(text, "matches storage: 3 entries") {
+		t.Errorf("unexpected clean report %q", text)
+	}
+
+	files, err := b.index.List("", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byPath := make(map[string]FileMeta)
+	for _, m := range files {
+		byPath[m.Path] = m
+	}
+	if m := byPath["42/changed.txt"]; m.Size != 6 || m.SHA256 != sha256Hex("after!") || m.Owner != 42 {
+		t.Errorf("expected the changed entry to be updated, got %+v", m)
+	}
+	if m, ok := byPath["7/stray.txt"]; !ok || m.SHA256 != sha256Hex("stray") {
+		t.Errorf("expected the stray file to be indexed, got %+v", m)
+	}
+	if _, ok := byPath["42/gone.txt"]; ok {
+		t.Error("expected the entry of the deleted file to be removed")
+	}
+	if m, err := readMeta(b.storagePath, "42/changed.txt"); err != nil || m.Size != 6 {
+		t.Errorf("expected the sidecar to be updated, got %+v, %v", m, err)
+	}
+}
+
+func TestAdminFsckCommand(t *testing.T) {
+	b := newFsckTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+	b.adminUsers[9] = true
+
+	admin := &tgbotapi.Message{From: &tgbotapi.User{ID: 9}, Chat: &tgbotapi.Chat{ID: 9}}
+	admin.Text = "/admin fsck --all"
+	b.handleAdminCommand(admin, 9, 9)
+	if texts := api.texts(); len(texts) != 1 || !strings.Contains(texts[0], "Usage: /admin fsck [--fix]") {
+		t.Fatalf("expected usage, got %v", texts)
+	}
+
+	b.fsckRunning.Store(true)
+	admin.Text = "/admin fsck"
+	b.handleAdminCommand(admin, 9, 9)
+	if texts := api.texts(); !strings.Contains(texts[len(texts)-1], "already running") {
+		t.Errorf("expected a second check to be refused, got %v", texts)
+	}
+	b.fsckRunning.Store(false)
+
+	b.runFsck(9, true)
+	if texts := api.texts(); !strings.Contains(texts[len(texts)-1], "🔧 Repaired") {
+		t.Errorf("expected the repair report, got %v", texts)
+	}
+
+	b.index = nil
+	b.handleAdminCommand(admin, 9, 9)
+	if texts := api.texts(); !strings.Contains(texts[len(texts)-1], "INDEX_DB") {
+		t.Errorf("expected the index to be required, got %v", texts)
+	}
+}
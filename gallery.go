@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"image"
+	_ "image/gif" // registers the decoders thumbnails are made with
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultGalleryTTL is how long a /gallery link stays valid.
+	DefaultGalleryTTL = 24 * time.Hour
+	// galleryMaxImages caps the images one gallery page shows, newest first.
+	galleryMaxImages = 500
+	// thumbnailSize is the longest side of a gallery thumbnail, in pixels.
+	thumbnailSize = 240
+	// maxDecodePixels is the largest image decoded for a thumbnail or
+	// RECOMPRESS_IMAGES; bigger ones are used as they are.
+	maxDecodePixels = 50_000_000
+)
+
+var errBadGalleryLink = errors.New("invalid or expired gallery link")
+
+// galleryServer is the HTTP server of HTTP_ADDR, serving the pages /gallery
+// links point to. Every URL it serves carries an expiry and a signature.
+type galleryServer struct {
+	addr    string
+	baseURL string // PUBLIC_URL, without a trailing slash
+	key     []byte // HMAC-SHA256 key signing the links
+	ttl     time.Duration
+}
+
+// newGalleryServer configures the server of HTTP_ADDR. Without PUBLIC_URL
+// links point to localhost; without GALLERY_SECRET links are signed with a
+// random key and stop working on restart.
+func newGalleryServer(addr, publicURL, secret string, ttl time.Duration) (*galleryServer, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid HTTP_ADDR %q: %w", addr, err)
+	}
+	if publicURL == "" {
+		if host == "" || host == "0.0.0.0" || host == "::" {
+			host = "localhost"
+		}
+		publicURL = "http://" + net.JoinHostPort(host, port)
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("invalid GALLERY_TTL %s: must be positive", ttl)
+	}
+
+	key := []byte(secret)
+	if secret == "" {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate gallery key: %w", err)
+		}
+	}
+	return &galleryServer{addr: addr, baseURL: strings.TrimRight(publicURL, "/"), key: key, ttl: ttl}, nil
+}
+
+// sign returns the signature of a link to userID's gallery (name empty) or
+// to one of its files, valid until expires.
+func (g *galleryServer) sign(userID int64, name string, expires int64) string {
+	mac := hmac.New(sha256.New, g.key)
+	fmt.Fprintf(mac, "%d\n%s\n%d", userID, name, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// link returns the signed URL of endpoint ("" for the page, "/file" or
+// "/thumb") for userID's file name.
+func (g *galleryServer) link(endpoint string, userID int64, name string, expires int64) string {
+	q := url.Values{}
+	q.Set("user", strconv.FormatInt(userID, 10))
+	if name != "" {
+		q.Set("name", name)
+	}
+	q.Set("exp", strconv.FormatInt(expires, 10))
+	q.Set("sig", g.sign(userID, name, expires))
+	return g.baseURL + "/gallery" + endpoint + "?" + q.Encode()
+}
+
+// verify checks the signature and expiry of a request, returning the user,
+// file name (empty for the page) and expiry it was signed for.
+func (g *galleryServer) verify(r *http.Request, now time.Time) (int64, string, int64, error) {
+	q := r.URL.Query()
+	userID, err := strconv.ParseInt(q.Get("user"), 10, 64)
+	if err != nil {
+		return 0, "", 0, errBadGalleryLink
+	}
+	expires, err := strconv.ParseInt(q.Get("exp"), 10, 64)
+	if err != nil || now.Unix() >= expires {
+		return 0, "", 0, errBadGalleryLink
+	}
+	name := q.Get("name")
+	if !hmac.Equal([]byte(q.Get("sig")), []byte(g.sign(userID, name, expires))) {
+		return 0, "", 0, errBadGalleryLink
+	}
+	return userID, name, expires, nil
+}
+
+// isGalleryImage reports whether a stored file is shown in galleries.
+func isGalleryImage(rel string) bool {
+	return previewImageTypes[mime.TypeByExtension(path.Ext(rel))]
+}
+
+// galleryImages returns the storage-relative paths of userID's images,
+// newest first, at most galleryMaxImages.
+func (b *Bot) galleryImages(userID int64) ([]string, error) {
+	files, err := b.matchUserFiles(userID, "*")
+	if err != nil {
+		return nil, err
+	}
+	var images []string
+	for _, rel := range files {
+		if isGalleryImage(rel) {
+			images = append(images, rel)
+		}
+		if len(images) == galleryMaxImages {
+			break
+		}
+	}
+	return images, nil
+}
+
+type galleryItem struct {
+	Name     string
+	FileURL  string
+	ThumbURL string
+}
+
+type galleryPage struct {
+	Title   string
+	Expires string
+	Images  []galleryItem
+}
+
+var galleryTemplate = template.Must(template.New("gallery").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 1em; }
+figure { display: inline-block; margin: .5em; width: 240px; vertical-align: top; }
+img { max-width: 240px; max-height: 240px; }
+figcaption { font-size: .8em; word-break: break-all; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{len .Images}} images · this page expires {{.Expires}}</p>
+{{range .Images}}<figure><a href="{{.FileURL}}"><img src="{{.ThumbURL}}" alt="{{.Name}}" loading="lazy"></a><figcaption>{{.Name}}</figcaption></figure>
+{{end}}</body>
+</html>
+`))
+
+// renderGallery writes the gallery page of userID's images, linking each to
+// its file with the page's expiry.
+func (b *Bot) renderGallery(w io.Writer, userID int64, images []string, expires int64) error {
+	page := galleryPage{
+		Title:   "Photos",
+		Expires: time.Unix(expires, 0).UTC().Format("2006-01-02 15:04 MST"),
+	}
+	for _, rel := range images {
+		page.Images = append(page.Images, galleryItem{
+			Name:     b.displayName(userID, rel),
+			FileURL:  b.gallery.link("/file", userID, rel, expires),
+			ThumbURL: b.gallery.link("/thumb", userID, rel, expires),
+		})
+	}
+	return galleryTemplate.Execute(w, page)
+}
+
+// galleryHandler serves the gallery pages, their files and thumbnails.
+func (b *Bot) galleryHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /gallery", func(w http.ResponseWriter, r *http.Request) {
+		userID, name, expires, err := b.gallery.verify(r, time.Now())
+		if err != nil || name != "" {
+			http.Error(w, errBadGalleryLink.Error(), http.StatusForbidden)
+			return
+		}
+		images, err := b.galleryImages(userID)
+		if err != nil {
+			log.Printf("Error listing gallery of user %d: %v", userID, err)
+			http.Error(w, "failed to list images", http.StatusInternalServerError)
+			return
+		}
+		var buf bytes.Buffer
+		if err := b.renderGallery(&buf, userID, images, expires); err != nil {
+			log.Printf("Error rendering gallery of user %d: %v", userID, err)
+			http.Error(w, "failed to render gallery", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "private, no-store")
+		w.Write(buf.Bytes())
+	})
+	mux.HandleFunc("GET /gallery/file", func(w http.ResponseWriter, r *http.Request) {
+		b.serveGalleryFile(w, r, false)
+	})
+	mux.HandleFunc("GET /gallery/thumb", func(w http.ResponseWriter, r *http.Request) {
+		b.serveGalleryFile(w, r, true)
+	})
+	return mux
+}
+
+// serveGalleryFile sends the image a signed link names, or a thumbnail of it.
+func (b *Bot) serveGalleryFile(w http.ResponseWriter, r *http.Request, thumb bool) {
+	_, rel, _, err := b.gallery.verify(r, time.Now())
+	if err != nil || rel == "" || !isGalleryImage(rel) {
+		http.Error(w, errBadGalleryLink.Error(), http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	if thumb && b.serveThumbnail(w, rel) {
+		return
+	}
+
+	f, err := b.openFile(rel)
+	if err != nil {
+		http.Error(w, "file not found", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	if !thumb {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(rel)}))
+	}
+	w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(rel)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	io.Copy(w, f)
+}
+
+// serveThumbnail sends a thumbnail of rel. It returns false when none can be
+// made, e.g. for WebP, and the image itself should be sent instead.
+func (b *Bot) serveThumbnail(w http.ResponseWriter, rel string) bool {
+	f, err := b.openFile(rel)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, MaxPreviewImageSize+1))
+	if err != nil || len(data) > MaxPreviewImageSize {
+		return false
+	}
+	small, err := thumbnail(bytes.NewReader(data))
+	if err != nil {
+		return false
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Write(small)
+	return true
+}
+
+// thumbnail scales the image in r down to thumbnailSize on its longest side
+// and encodes it as JPEG.
+func thumbnail(r io.ReadSeeker) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > maxDecodePixels {
+		return nil, errors.New("image too large for a thumbnail")
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w > thumbnailSize || h > thumbnailSize {
+		if w >= h {
+			w, h = thumbnailSize, max(1, h*thumbnailSize/bounds.Dx())
+		} else {
+			w, h = max(1, w*thumbnailSize/bounds.Dy()), thumbnailSize
+		}
+	}
+	// Nearest-neighbour scaling is enough for a preview
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		sy := bounds.Min.Y + y*bounds.Dy()/h
+		for x := 0; x < w; x++ {
+			dst.Set(x, y, src.At(bounds.Min.X+x*bounds.Dx()/w, sy))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// serveGallery runs the HTTP server of HTTP_ADDR until ctx is cancelled.
+func (b *Bot) serveGallery(ctx context.Context) {
+	srv := &http.Server{
+		Addr:              b.gallery.addr,
+		Handler:           b.galleryHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("Serving galleries on %s (links point to %s)", b.gallery.addr, b.gallery.baseURL)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("Gallery server stopped: %v", err)
+	}
+}
+
+// handleGalleryCommand sends the user a signed, expiring link to a page of
+// their images.
+func (b *Bot) handleGalleryCommand(chatID, userID int64) {
+	if b.gallery == nil {
+		b.sendTextMessage(chatID, "⚠️ Galleries are not enabled (set HTTP_ADDR)")
+		return
+	}
+	if !b.requireLocalStorage(chatID) {
+		return
+	}
+	images, err := b.galleryImages(userID)
+	if err != nil {
+		log.Printf("Error listing gallery of user %d: %v", userID, err)
+		b.sendTextMessage(chatID, "❌ Failed to list your images.")
+		return
+	}
+	if len(images) == 0 {
+		b.sendTextMessage(chatID, "🖼 No images to show yet. Send me some photos first.")
+		return
+	}
+
+	expires := time.Now().Add(b.gallery.ttl)
+	log.Printf("User %d opened a gallery of %d images until %s", userID, len(images), expires.Format(time.RFC3339))
+	count := fmt.Sprintf("%d images", len(images))
+	if len(images) == 1 {
+		count = "1 image"
+	}
+	b.sendTextMessage(chatID, fmt.Sprintf("🖼 Your gallery of %s, valid until %s:\n%s",
+		count, expires.UTC().Format("2006-01-02 15:04 MST"), b.gallery.link("", userID, "", expires.Unix())))
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newGalleryTestBot(t *testing.T) *Bot {
+	t.Helper()
+	b := newTestBot(t)
+	g, err := newGalleryServer(":8080", "https://photos.example/", "secret", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.gallery = g
+	return b
+}
+
+// testPNG encodes a w×h image.
+func testPNG(t *testing.T, w, h int) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestNewGalleryServer(t *testing.T) {
+	g, err := newGalleryServer(":8080", "", "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.baseURL != "http://localhost:8080" || len(g.key) != 32 {
+		t.Errorf("unexpected defaults %+v", g)
+	}
+	if g, _ := newGalleryServer(":8080", "https://photos.example/", "", time.Hour); g.baseURL != "https://photos.example" {
+		t.Errorf("expected the trailing slash to be dropped, got %q", g.baseURL)
+	}
+	if _, err := newGalleryServer("8080", "", "", time.Hour); err == nil {
+		t.Error("expected an error for an address without port")
+	}
+}
+
+func TestGalleryLinkSigning(t *testing.T) {
+	b := newGalleryTestBot(t)
+	now := time.Now()
+	expires := now.Add(time.Hour).Unix()
+
+	verify := func(link string, now time.Time) (int64, string, error) {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, link, nil)
+		userID, name, _, err := b.gallery.verify(r, now)
+		return userID, name, err
+	}
+
+	link := b.gallery.link("/file", 42, "42/cat & dog.jpg", expires)
+	if !strings.HasPrefix(link, "https://photos.example/gallery/file?") {
+		t.Fatalf("unexpected link %q", link)
+	}
+	if userID, name, err := verify(link, now); err != nil || userID != 42 || name != "42/cat & dog.jpg" {
+		t.Fatalf("expected the link to verify, got %d %q %v", userID, name, err)
+	}
+	if _, _, err := verify(link, now.Add(2*time.Hour)); err == nil {
+		t.Error("expected an expired link to be refused")
+	}
+
+	u, _ := url.Parse(link)
+	for key, value := range map[string]string{"name": "42/other.jpg", "user": "7", "exp": "9999999999", "sig": "forged"} {
+		q := u.Query()
+		q.Set(key, value)
+		tampered := *u
+		tampered.RawQuery = q.Encode()
+		if _, _, err := verify(tampered.String(), now); err == nil {
+			t.Errorf("expected a link with a changed %s to be refused", key)
+		}
+	}
+
+	other, _ := newGalleryServer(":8080", "", "other secret", time.Hour)
+	if _, _, _, err := other.verify(httptest.NewRequest(http.MethodGet, link, nil), now); err == nil {
+		t.Error("expected a link signed with another key to be refused")
+	}
+}
+
+func TestGalleryTemplateEscapesNames(t *testing.T) {
+	b := newGalleryTestBot(t)
+	var buf bytes.Buffer
+	if err := b.renderGallery(&buf, 42, []string{`<script>alert("x")</script>.jpg`, `a"b&c.png`}, time.Now().Add(time.Hour).Unix()); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+	if strings.Contains(page, "<script>") || strings.Contains(page, `a"b`) {
+		t.Errorf("expected file names to be escaped:\n%s", page)
+	}
+	for _, want := range []string{"&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt;.jpg", "a&#34;b&amp;c.png", "2 images", `href="https://photos.example/gallery/file?exp=`, `src="https://photos.example/gallery/thumb?exp=`} {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected %q in:\n%s", want, page)
+		}
+	}
+}
+
+func TestGalleryHandler(t *testing.T) {
+	b := newGalleryTestBot(t)
+	photo := testPNG(t, 960, 480)
+	writeTestFile(t, b, "photo.png", photo)
+	writeTestFile(t, b, "notes.txt", "not an image")
+
+	srv := httptest.NewServer(b.galleryHandler())
+	defer srv.Close()
+	get := func(link string) (*http.Response, []byte) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + strings.TrimPrefix(link, b.gallery.baseURL))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, body
+	}
+	expires := time.Now().Add(time.Hour).Unix()
+
+	resp, body := get(b.gallery.link("", 42, "", expires))
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "photo.png") || strings.Contains(string(body), "notes.txt") {
+		t.Fatalf("unexpected page %d:\n%s", resp.StatusCode, body)
+	}
+
+	resp, body = get(b.gallery.link("/file", 42, "photo.png", expires))
+	if resp.StatusCode != http.StatusOK || string(body) != photo || !strings.Contains(resp.Header.Get("Content-Disposition"), "photo.png") {
+		t.Errorf("unexpected file response %d %v", resp.StatusCode, resp.Header)
+	}
+
+	resp, body = get(b.gallery.link("/thumb", 42, "photo.png", expires))
+	thumb, err := jpeg.Decode(bytes.NewReader(body))
+	if resp.StatusCode != http.StatusOK || err != nil {
+		t.Fatalf("expected a JPEG thumbnail, got %d (%v)", resp.StatusCode, err)
+	}
+	if size := thumb.Bounds().Size(); size.X != thumbnailSize || size.Y != thumbnailSize/2 {
+		t.Errorf("unexpected thumbnail size %v", size)
+	}
+
+	// Signed links only reach images, and only with their own signature
+	if resp, _ := get(b.gallery.link("/file", 42, "notes.txt", expires)); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected a non-image to be refused, got %d", resp.StatusCode)
+	}
+	if resp, _ := get("/gallery/file?user=42&name=photo.png&exp=9999999999&sig=x"); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected an unsigned link to be refused, got %d", resp.StatusCode)
+	}
+	if resp, _ := get(b.gallery.link("", 42, "", time.Now().Add(-time.Minute).Unix())); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected an expired page to be refused, got %d", resp.StatusCode)
+	}
+}
+
+func TestGalleryCommand(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+
+	b.handleGalleryCommand(42, 42)
+	b.gallery, _ = newGalleryServer(":8080", "https://photos.example", "secret", time.Hour)
+	b.handleGalleryCommand(42, 42)
+	writeTestFile(t, b, "photo.png", testPNG(t, 10, 10))
+	b.handleGalleryCommand(42, 42)
+
+	texts := api.texts()
+	if len(texts) != 3 {
+		t.Fatalf("expected 3 replies, got %q", texts)
+	}
+	if !strings.Contains(texts[0], "HTTP_ADDR") || !strings.Contains(texts[1], "No images") {
+		t.Errorf("unexpected replies %q", texts[:2])
+	}
+	if !strings.HasPrefix(texts[2], "🖼 Your gallery of 1 image,") || !strings.Contains(texts[2], "\nhttps://photos.example/gallery?") {
+		t.Errorf("unexpected gallery reply %q", texts[2])
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Shared locations, contacts and polls carry no file; they are stored as
+// files generated from the message itself. So is plain text under
+// TEXT_MESSAGE_MODE=save.
+
+// textMessageMode selects what TEXT_MESSAGE_MODE does with text that is
+// neither a command nor a link.
+type textMessageMode int
+
+const (
+	textMessageHint   textMessageMode = iota // reply asking for a file
+	textMessageSave                          // store the text as a .txt note
+	textMessageIgnore                        // do not reply at all
+)
+
+// parseTextMessageMode parses TEXT_MESSAGE_MODE; empty means hint.
+func parseTextMessageMode(s string) (textMessageMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "hint":
+		return textMessageHint, nil
+	case "save":
+		return textMessageSave, nil
+	case "ignore":
+		return textMessageIgnore, nil
+	}
+	return textMessageHint, fmt.Errorf("invalid TEXT_MESSAGE_MODE %q (want hint, save or ignore)", s)
+}
+
+// handleTextMessage handles text that is neither a command nor a link, as
+// TEXT_MESSAGE_MODE says. Mistyped commands are never saved.
+func (b *Bot) handleTextMessage(chatID, userID int64, text string) {
+	switch {
+	case b.textMode == textMessageIgnore:
+		return
+	case b.textMode == textMessageHint || strings.HasPrefix(text, "/"):
+		b.sendTextMessage(chatID, "Please send me a file, photo, video, or audio to store.")
+		return
+	case b.isPaused():
+		b.sendTextMessage(chatID, "⏸ Uploads are temporarily unavailable due to maintenance. Please try again later.")
+		return
+	case !b.isMediaTypeAllowed(userID, "documents"):
+		b.sendTextMessage(chatID, fmt.Sprintf("🚫 You are not allowed to send documents. Permitted: %s", b.describeAllowedTypes(userID)))
+		return
+	}
+
+	fileName := fmt.Sprintf("note_%d.txt", time.Now().Unix())
+	saved, err := b.saveChatFile(strings.NewReader(text+"\n"), "documents", b.organizedName("documents", fileName, time.Now()), "text/plain", chatID, userID)
+	if err != nil {
+		log.Printf("Error saving text message: %v", err)
+		b.sendTextMessage(chatID, b.failureReply(userID, err, "Failed to save the text."))
+		return
+	}
+
+	b.replySaved(chatID, userID, saved)
+}
+
+// geoJSONFeature is a GeoJSON (RFC 7946) Feature with a Point geometry.
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   geoJSONPoint           `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"` // longitude, latitude
+}
+
+// locationGeoJSON serializes a location, with the venue details when it is one.
+func locationGeoJSON(loc tgbotapi.Location, venue *tgbotapi.Venue) ([]byte, error) {
+	props := make(map[string]interface{})
+	if loc.HorizontalAccuracy > 0 {
+		props["horizontal_accuracy"] = loc.HorizontalAccuracy
+	}
+	if loc.LivePeriod > 0 {
+		props["live_period"] = loc.LivePeriod
+	}
+	if loc.Heading > 0 {
+		props["heading"] = loc.Heading
+	}
+	if venue != nil {
+		props["name"] = venue.Title
+		if venue.Address != "" {
+			props["address"] = venue.Address
+		}
+	}
+
+	return json.MarshalIndent(geoJSONFeature{
+		Type:       "Feature",
+		Geometry:   geoJSONPoint{Type: "Point", Coordinates: [2]float64{loc.Longitude, loc.Latitude}},
+		Properties: props,
+	}, "", "  ")
+}
+
+// contactVCard serializes a contact as a vCard 3.0. A vCard supplied by the
+// sender is kept as is.
+func contactVCard(c *tgbotapi.Contact) []byte {
+	if strings.TrimSpace(c.VCard) != "" {
+		return []byte(c.VCard)
+	}
+
+	fullName := strings.TrimSpace(c.FirstName + " " + c.LastName)
+	lines := []string{
+		"BEGIN:VCARD",
+		"VERSION:3.0",
+		"N:" + vcardEscape(c.LastName) + ";" + vcardEscape(c.FirstName) + ";;;",
+		"FN:" + vcardEscape(fullName),
+	}
+	if c.PhoneNumber != "" {
+		lines = append(lines, "TEL;TYPE=CELL:"+vcardEscape(c.PhoneNumber))
+	}
+	if c.UserID != 0 {
+		lines = append(lines, fmt.Sprintf("X-TELEGRAM-ID:%d", c.UserID))
+	}
+	lines = append(lines, "END:VCARD")
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+// vcardEscape escapes text values per RFC 2426.
+func vcardEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+func (b *Bot) handleLocation(message *tgbotapi.Message, chatID, userID int64) {
+	data, err := locationGeoJSON(*message.Location, message.Venue)
+	if err != nil {
+		log.Printf("Error encoding location: %v", err)
+		b.sendTextMessage(chatID, "❌ Failed to save the location.")
+		return
+	}
+
+	fileName := fmt.Sprintf("location_%d.geojson", time.Now().Unix())
+	if message.Venue != nil {
+		if title := sanitizeFileName(message.Venue.Title); title != "" {
+			fileName = fmt.Sprintf("location_%d_%s.geojson", time.Now().Unix(), title)
+		}
+	}
+
+	saved, err := b.saveChatFile(bytes.NewReader(data), "locations", b.organizedName("locations", fileName, time.Now()), "application/geo+json", chatID, userID)
+	if err != nil {
+		log.Printf("Error handling location: %v", err)
+		b.sendTextMessage(chatID, b.failureReply(userID, err, "Failed to save the location."))
+		return
+	}
+
+	b.replySaved(chatID, userID, saved)
+}
+
+func (b *Bot) handleContact(contact *tgbotapi.Contact, chatID, userID int64) {
+	fileName := fmt.Sprintf("contact_%d.vcf", time.Now().Unix())
+	if name := sanitizeFileName(strings.TrimSpace(contact.FirstName + " " + contact.LastName)); name != "" {
+		fileName = fmt.Sprintf("contact_%d_%s.vcf", time.Now().Unix(), name)
+	}
+
+	saved, err := b.saveChatFile(bytes.NewReader(contactVCard(contact)), "contacts", b.organizedName("contacts", fileName, time.Now()), "text/vcard", chatID, userID)
+	if err != nil {
+		log.Printf("Error handling contact: %v", err)
+		b.sendTextMessage(chatID, b.failureReply(userID, err, "Failed to save the contact."))
+		return
+	}
+
+	b.replySaved(chatID, userID, saved)
+}
+
+// pollJSON is how a poll is stored. Vote counts are those Telegram reported
+// when the poll was sent or forwarded to the bot.
+type pollJSON struct {
+	Question        string           `json:"question"`
+	Type            string           `json:"type"`
+	Options         []pollOptionJSON `json:"options"`
+	TotalVoters     int              `json:"total_voters"`
+	Closed          bool             `json:"closed"`
+	Anonymous       bool             `json:"anonymous"`
+	MultipleAnswers bool             `json:"multiple_answers"`
+	CorrectOption   *int             `json:"correct_option,omitempty"` // quizzes only
+	Explanation     string           `json:"explanation,omitempty"`
+}
+
+type pollOptionJSON struct {
+	Text  string `json:"text"`
+	Votes int    `json:"votes"`
+}
+
+// pollJSONData serializes a poll with its options and vote counts.
+func pollJSONData(p *tgbotapi.Poll) ([]byte, error) {
+	out := pollJSON{
+		Question:        p.Question,
+		Type:            p.Type,
+		Options:         make([]pollOptionJSON, 0, len(p.Options)),
+		TotalVoters:     p.TotalVoterCount,
+		Closed:          p.IsClosed,
+		Anonymous:       p.IsAnonymous,
+		MultipleAnswers: p.AllowsMultipleAnswers,
+		Explanation:     p.Explanation,
+	}
+	for _, o := range p.Options {
+		out.Options = append(out.Options, pollOptionJSON{Text: o.Text, Votes: o.VoterCount})
+	}
+	// Telegram omits a correct option of 0, so it is only told apart from
+	// "unknown" for quizzes
+	if p.Type == "quiz" {
+		correct := p.CorrectOptionID
+		out.CorrectOption = &correct
+	}
+	return json.MarshalIndent(out, "", "  ")
+}
+
+func (b *Bot) handlePoll(poll *tgbotapi.Poll, chatID, userID int64) {
+	data, err := pollJSONData(poll)
+	if err != nil {
+		log.Printf("Error encoding poll: %v", err)
+		b.sendTextMessage(chatID, "❌ Failed to save the poll.")
+		return
+	}
+
+	fileName := fmt.Sprintf("poll_%d.json", time.Now().Unix())
+	if question := sanitizeFileName(poll.Question); question != "" {
+		fileName = fmt.Sprintf("poll_%d_%s.json", time.Now().Unix(), question)
+	}
+
+	saved, err := b.saveChatFile(bytes.NewReader(data), "polls", b.organizedName("polls", fileName, time.Now()), "application/json", chatID, userID)
+	if err != nil {
+		log.Printf("Error handling poll: %v", err)
+		b.sendTextMessage(chatID, b.failureReply(userID, err, "Failed to save the poll."))
+		return
+	}
+
+	b.replySaved(chatID, userID, saved)
+}
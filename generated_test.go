@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestLocationGeoJSON(t *testing.T) {
+	loc := tgbotapi.Location{Latitude: 52.52, Longitude: 13.405, HorizontalAccuracy: 15}
+	data, err := locationGeoJSON(loc, &tgbotapi.Venue{Location: loc, Title: "Alexanderplatz", Address: "Berlin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got geoJSONFeature
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if got.Type != "Feature" || got.Geometry.Type != "Point" {
+		t.Errorf("unexpected feature %+v", got)
+	}
+	// GeoJSON orders coordinates longitude first
+	if got.Geometry.Coordinates != [2]float64{13.405, 52.52} {
+		t.Errorf("unexpected coordinates %v", got.Geometry.Coordinates)
+	}
+	if got.Properties["name"] != "Alexanderplatz" || got.Properties["address"] != "Berlin" || got.Properties["horizontal_accuracy"] != 15.0 {
+		t.Errorf("unexpected properties %v", got.Properties)
+	}
+}
+
+func TestContactVCard(t *testing.T) {
+	card := string(contactVCard(&tgbotapi.Contact{PhoneNumber: "+49 30 1234", FirstName: "Anna, Maria", LastName: "Schmidt;Jr", UserID: 42}))
+
+	want := "BEGIN:VCARD\r\nVERSION:3.0\r\nN:Schmidt\\;Jr;Anna\\, Maria;;;\r\nFN:Anna\\, Maria Schmidt\\;Jr\r\nTEL;TYPE=CELL:+49 30 1234\r\nX-TELEGRAM-ID:42\r\nEND:VCARD\r\n"
+	if card != want {
+		t.Errorf("unexpected vCard:\n%q\nwant\n%q", card, want)
+	}
+
+	supplied := "BEGIN:VCARD\nVERSION:3.0\nFN:Bob\nEND:VCARD"
+	if got := string(contactVCard(&tgbotapi.Contact{FirstName: "Bob", VCard: supplied})); got != supplied {
+		t.Errorf("expected the sender's vCard to be kept, got %q", got)
+	}
+}
+
+func TestContactMessageIsStored(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+
+	b.handleMessage(&tgbotapi.Message{
+		From:    &tgbotapi.User{ID: 42},
+		Chat:    &tgbotapi.Chat{ID: 42},
+		Contact: &tgbotapi.Contact{PhoneNumber: "+1555", FirstName: "Bob"},
+	})
+
+	texts := api.texts()
+	if len(texts) != 1 || !strings.HasPrefix(texts[0], "✅ Saved 'contact_") || !strings.Contains(texts[0], "_Bob.vcf' (") {
+		t.Fatalf("unexpected reply %q", texts)
+	}
+	name := strings.SplitN(strings.TrimPrefix(texts[0], "✅ Saved '"), "'", 2)[0]
+	data, err := os.ReadFile(b.localPath(name))
+	if err != nil || !strings.Contains(string(data), "TEL;TYPE=CELL:+1555") {
+		t.Errorf("expected the vCard to be stored, got %q (%v)", data, err)
+	}
+}
+
+func TestPollJSONData(t *testing.T) {
+	data, err := pollJSONData(&tgbotapi.Poll{
+		Question:        "Lunch?",
+		Type:            "quiz",
+		Options:         []tgbotapi.PollOption{{Text: "Pizza", VoterCount: 3}, {Text: "Sushi", VoterCount: 1}},
+		TotalVoterCount: 4,
+		IsClosed:        true,
+		IsAnonymous:     true,
+		CorrectOptionID: 0,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got pollJSON
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if got.Question != "Lunch?" || got.Type != "quiz" || got.TotalVoters != 4 || !got.Closed || !got.Anonymous || got.MultipleAnswers {
+		t.Errorf("unexpected poll %+v", got)
+	}
+	if len(got.Options) != 2 || got.Options[0] != (pollOptionJSON{Text: "Pizza", Votes: 3}) || got.Options[1] != (pollOptionJSON{Text: "Sushi", Votes: 1}) {
+		t.Errorf("unexpected options %+v", got.Options)
+	}
+	if got.CorrectOption == nil || *got.CorrectOption != 0 {
+		t.Errorf("expected the first option to be the correct one, got %v", got.CorrectOption)
+	}
+
+	if data, err := pollJSONData(&tgbotapi.Poll{Question: "Day?", Type: "regular"}); err != nil || strings.Contains(string(data), "correct_option") || !strings.Contains(string(data), `"options": []`) {
+		t.Errorf("unexpected regular poll %s (%v)", data, err)
+	}
+}
+
+func TestPollMessageIsStored(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+
+	b.handleMessage(&tgbotapi.Message{
+		From: &tgbotapi.User{ID: 42},
+		Chat: &tgbotapi.Chat{ID: 42},
+		Poll: &tgbotapi.Poll{Question: "Lunch?", Type: "regular", Options: []tgbotapi.PollOption{{Text: "Pizza", VoterCount: 2}}},
+	})
+
+	texts := api.texts()
+	if len(texts) != 1 || !strings.HasPrefix(texts[0], "✅ Saved 'poll_") || !strings.Contains(texts[0], "_Lunch?.json' (") {
+		t.Fatalf("unexpected reply %q", texts)
+	}
+	name := strings.SplitN(strings.TrimPrefix(texts[0], "✅ Saved '"), "'", 2)[0]
+	data, err := os.ReadFile(b.localPath(name))
+	if err != nil || !strings.Contains(string(data), `"votes": 2`) {
+		t.Errorf("expected the poll to be stored, got %q (%v)", data, err)
+	}
+	if m, err := readMeta(b.storagePath, name); err != nil || m.MIME != "application/json" || m.Owner != 42 {
+		t.Errorf("unexpected metadata %+v (%v)", m, err)
+	}
+}
+
+func TestParseTextMessageMode(t *testing.T) {
+	for input, want := range map[string]textMessageMode{"": textMessageHint, "hint": textMessageHint, "Save": textMessageSave, " ignore ": textMessageIgnore} {
+		if got, err := parseTextMessageMode(input); err != nil || got != want {
+			t.Errorf("parseTextMessageMode(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+	if _, err := parseTextMessageMode("note"); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
+
+func TestTextMessageModes(t *testing.T) {
+	send := func(b *Bot, text string) []string {
+		t.Helper()
+		api := &mockTelegramAPI{}
+		b.client = api
+		b.handleMessage(&tgbotapi.Message{From: &tgbotapi.User{ID: 42}, Chat: &tgbotapi.Chat{ID: 42}, Text: text})
+		return api.texts()
+	}
+	stored := func(b *Bot) []storedFile {
+		t.Helper()
+		files, err := b.storage.List("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return files
+	}
+
+	b := newTestBot(t)
+	if texts := send(b, "buy milk"); len(texts) != 1 || !strings.HasPrefix(texts[0], "Please send me a file") {
+		t.Errorf("hint: unexpected reply %q", texts)
+	}
+	if files := stored(b); len(files) != 0 {
+		t.Errorf("hint: expected nothing stored, got %v", files)
+	}
+
+	b = newTestBot(t)
+	b.textMode = textMessageIgnore
+	if texts := send(b, "buy milk"); len(texts) != 0 {
+		t.Errorf("ignore: expected no reply, got %q", texts)
+	}
+	if files := stored(b); len(files) != 0 {
+		t.Errorf("ignore: expected nothing stored, got %v", files)
+	}
+
+	b = newTestBot(t)
+	b.textMode = textMessageSave
+	texts := send(b, "buy milk\nand bread")
+	if len(texts) != 1 || !strings.HasPrefix(texts[0], "✅ Saved 'note_") {
+		t.Fatalf("save: unexpected reply %q", texts)
+	}
+	name := strings.SplitN(strings.TrimPrefix(texts[0], "✅ Saved '"), "'", 2)[0]
+	if data, err := os.ReadFile(b.localPath(name)); err != nil || string(data) != "buy milk\nand bread\n" {
+		t.Errorf("save: expected the text to be stored, got %q (%v)", data, err)
+	}
+	if m, err := readMeta(b.storagePath, name); err != nil || m.MIME != "text/plain" || m.Owner != 42 {
+		t.Errorf("save: unexpected metadata %+v (%v)", m, err)
+	}
+
+	// A mistyped command is not worth a note
+	if texts := send(b, "/lsit"); len(texts) != 1 || !strings.HasPrefix(texts[0], "Please send me a file") {
+		t.Errorf("save: unexpected reply to an unknown command %q", texts)
+	}
+	if files := stored(b); len(files) != 1 {
+		t.Errorf("save: expected only the note to be stored, got %v", files)
+	}
+}
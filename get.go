@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultGetMaxFiles is how many files one /get sends unless GET_MAX_FILES
+// says otherwise.
+const DefaultGetMaxFiles = 10
+
+// parseGetMaxFiles parses GET_MAX_FILES.
+func parseGetMaxFiles(s string) (int, error) {
+	if s == "" {
+		return DefaultGetMaxFiles, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid GET_MAX_FILES %q: expected a positive number of files", s)
+	}
+	return n, nil
+}
+
+// globMatches reports whether the user-relative name matches pattern. A
+// pattern without a slash is matched against the base name in any folder,
+// one with a slash against the whole name.
+func globMatches(pattern, name string) bool {
+	if !strings.Contains(pattern, "/") {
+		name = path.Base(name)
+	}
+	ok, _ := filepath.Match(pattern, name)
+	return ok
+}
+
+// matchUserFiles returns the storage-relative paths of the user's files whose
+// listed name matches pattern, newest first. Under LOWERCASE_NAMES case is
+// ignored.
+func (b *Bot) matchUserFiles(userID int64, pattern string) ([]string, error) {
+	if b.lowercaseNames {
+		pattern = strings.ToLower(pattern)
+	}
+	dir := b.userDir(userID)
+	var files []storedFile
+	for _, root := range b.dataRoots() {
+		err := walkStoredFiles(filepath.Join(root, filepath.FromSlash(dir)), func(rel, _ string, info os.FileInfo) error {
+			rel = path.Join(dir, rel)
+			name := b.displayName(userID, rel)
+			if m, err := readMeta(b.storagePath, rel); err == nil {
+				name = uncompressedName(name, m)
+			}
+			if b.lowercaseNames {
+				name = strings.ToLower(name)
+			}
+			if globMatches(pattern, name) {
+				files = append(files, storedFile{Name: rel, ModTime: info.ModTime()})
+			}
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	sortNewestFirst(files)
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.Name
+	}
+	return paths, nil
+}
+
+// handleGetCommand sends back every file of the user matching a glob, up to
+// GET_MAX_FILES, as separate messages.
+func (b *Bot) handleGetCommand(chatID, userID int64, args string) {
+	if !b.requireLocalStorage(chatID) {
+		return
+	}
+
+	parts := splitArgs(args)
+	if len(parts) != 1 {
+		b.sendTextMessage(chatID, "Usage: /get <pattern>\nShell-style: * matches any characters, ? one, [abc] a set. Without a / the pattern matches names in any folder, e.g. /get \"*.pdf\" or /get \"2024/*/IMG_*\"")
+		return
+	}
+	pattern := parts[0]
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ Invalid pattern '%s'", pattern))
+		return
+	}
+
+	matches, err := b.matchUserFiles(userID, pattern)
+	if err != nil {
+		log.Printf("Error matching %q for user %d: %v", pattern, userID, err)
+		b.sendTextMessage(chatID, "❌ Failed to search stored files.")
+		return
+	}
+	if len(matches) == 0 {
+		b.sendTextMessage(chatID, fmt.Sprintf("🔍 No files matching '%s'", pattern))
+		return
+	}
+
+	toSend := matches
+	if len(toSend) > b.getMaxFiles {
+		toSend = toSend[:b.getMaxFiles]
+	}
+	sent := 0
+	for _, rel := range toSend {
+		if _, err := b.resendFile(chatID, rel, userID); err != nil {
+			log.Printf("Error sending %s for /get: %v", rel, err)
+			continue
+		}
+		sent++
+	}
+	log.Printf("User %d got %d of %d files matching %q", userID, sent, len(matches), pattern)
+
+	summary := fmt.Sprintf("📦 %d files matched '%s', %d sent.", len(matches), pattern, sent)
+	if failed := len(toSend) - sent; failed > 0 {
+		summary += fmt.Sprintf(" %d could not be sent (too large or unreadable).", failed)
+	}
+	if len(matches) > len(toSend) {
+		summary += fmt.Sprintf("\nOnly the %d newest are sent at once; narrow the pattern for the rest.", len(toSend))
+	}
+	b.sendTextMessage(chatID, summary)
+}
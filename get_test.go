@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestGlobMatches(t *testing.T) {
+	cases := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.pdf", "report.pdf", true},
+		{"*.pdf", "documents/2024/report.pdf", true}, // base name in any folder
+		{"*.pdf", "report.pdf.txt", false},
+		{"IMG_??.jpg", "photos/IMG_01.jpg", true},
+		{"photos/*.jpg", "photos/a.jpg", true},
+		{"photos/*.jpg", "photos/2024/a.jpg", false}, // * does not cross folders
+		{"[ab]*", "b.txt", true},
+	}
+	for _, c := range cases {
+		if got := globMatches(c.pattern, c.name); got != c.want {
+			t.Errorf("globMatches(%q, %q) = %v, want %v", c.pattern, c.name, got, c.want)
+		}
+	}
+}
+
+func TestGetCommandSendsMatches(t *testing.T) {
+	b := newTestBot(t)
+	b.perUserDirs = true
+	b.getMaxFiles = DefaultGetMaxFiles
+	api := &mockTelegramAPI{}
+	b.client = api
+	writeTestFile(t, b, "42/a.pdf", "a")
+	writeTestFile(t, b, "42/invoices/b.pdf", "b")
+	writeTestFile(t, b, "42/c.txt", "c")
+	writeTestFile(t, b, "7/other.pdf", "someone else's")
+
+	b.handleGetCommand(42, 42, ` "*.pdf"`)
+
+	var names []string
+	for _, c := range api.sent {
+		if doc, ok := c.(tgbotapi.DocumentConfig); ok {
+			names = append(names, doc.File.(tgbotapi.FileBytes).Name)
+		}
+	}
+	if len(names) != 2 || !strings.Contains(strings.Join(names, ","), "a.pdf") || !strings.Contains(strings.Join(names, ","), "b.pdf") {
+		t.Errorf("expected the user's two PDFs, got %q", names)
+	}
+	texts := api.texts()
+	if len(texts) != 1 || texts[0] != "📦 2 files matched '*.pdf', 2 sent." {
+		t.Errorf("unexpected summary %q", texts)
+	}
+}
+
+func TestGetCommandCapsSentFiles(t *testing.T) {
+	b := newTestBot(t)
+	b.getMaxFiles = 2
+	api := &mockTelegramAPI{}
+	b.client = api
+	for i, name := range []string{"old.log", "mid.log", "new.log"} {
+		writeTestFile(t, b, name, name)
+		mtime := time.Now().Add(time.Duration(i-3) * time.Hour)
+		if err := os.Chtimes(b.localPath(name), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b.handleGetCommand(42, 42, " *.log")
+
+	if len(api.sent) != 3 {
+		t.Fatalf("expected two files and a summary, got %d messages", len(api.sent))
+	}
+	for i, want := range []string{"new.log", "mid.log"} {
+		if doc := api.sent[i].(tgbotapi.DocumentConfig); doc.File.(tgbotapi.FileBytes).Name != want {
+			t.Errorf("message %d: expected %s, got %s", i, want, doc.File.(tgbotapi.FileBytes).Name)
+		}
+	}
+	if texts := api.texts(); len(texts) != 1 || !strings.HasPrefix(texts[0], "📦 3 files matched '*.log', 2 sent.\nOnly the 2 newest") {
+		t.Errorf("unexpected summary %q", texts)
+	}
+}
+
+func TestGetCommandRejections(t *testing.T) {
+	b := newTestBot(t)
+	b.getMaxFiles = DefaultGetMaxFiles
+	api := &mockTelegramAPI{}
+	b.client = api
+	writeTestFile(t, b, "a.txt", "a")
+
+	b.handleGetCommand(42, 42, "")
+	b.handleGetCommand(42, 42, " [abc")
+	b.handleGetCommand(42, 42, " *.pdf")
+
+	texts := api.texts()
+	want := []string{"Usage: /get", "❌ Invalid pattern '[abc'", "🔍 No files matching '*.pdf'"}
+	if len(texts) != len(want) {
+		t.Fatalf("unexpected replies %q", texts)
+	}
+	for i, w := range want {
+		if !strings.HasPrefix(texts[i], w) {
+			t.Errorf("reply %d: expected %q, got %q", i, w, texts[i])
+		}
+	}
+}
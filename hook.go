@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PostSaveHookTimeout bounds a single run of POST_SAVE_HOOK.
+const PostSaveHookTimeout = time.Minute
+
+// postSaveHook runs an executable after every successful save, with the file
+// path, owner and size as arguments. Runs happen in the background; their
+// failures never fail the save but are logged and counted. A nil
+// *postSaveHook runs nothing.
+type postSaveHook struct {
+	path    string
+	timeout time.Duration
+
+	running  sync.WaitGroup
+	failures atomic.Int64
+}
+
+// newPostSaveHook checks that path is an executable file.
+func newPostSaveHook(path string) (*postSaveHook, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid POST_SAVE_HOOK: %w", err)
+	}
+	if !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+		return nil, fmt.Errorf("invalid POST_SAVE_HOOK: %s is not an executable file", path)
+	}
+	return &postSaveHook{path: path, timeout: PostSaveHookTimeout}, nil
+}
+
+// Run starts the hook for a saved file.
+func (h *postSaveHook) Run(file string, owner, size int64) {
+	if h == nil {
+		return
+	}
+	h.running.Add(1)
+	go func() {
+		defer h.running.Done()
+		h.run(file, owner, size)
+	}()
+}
+
+func (h *postSaveHook) run(file string, owner, size int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, h.path, file, strconv.FormatInt(owner, 10), strconv.FormatInt(size, 10))
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// Don't wait on children of a killed hook still holding its output
+	cmd.WaitDelay = time.Second
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", h.timeout)
+	}
+
+	out := strings.TrimSpace(output.String())
+	if err != nil {
+		h.failures.Add(1)
+		log.Printf("Post-save hook failed for %s: %v: %s", file, err, out)
+		return
+	}
+	if out != "" {
+		log.Printf("Post-save hook for %s: %s", file, out)
+	}
+}
+
+// Wait blocks until all started runs have finished.
+func (h *postSaveHook) Wait() {
+	if h != nil {
+		h.running.Wait()
+	}
+}
+
+// Failures returns how many runs have failed.
+func (h *postSaveHook) Failures() int64 {
+	if h == nil {
+		return 0
+	}
+	return h.failures.Load()
+}
+
+// runPostSaveHook passes a saved file to POST_SAVE_HOOK: by its filesystem
+// path with local storage, by its storage-relative path otherwise.
+func (b *Bot) runPostSaveHook(m FileMeta) {
+	if b.postSaveHook == nil {
+		return
+	}
+	file := m.Path
+	if _, ok := b.storage.(*LocalStorage); ok {
+		file = b.localPath(m.Path)
+	}
+	b.postSaveHook.Run(file, m.Owner, m.Size)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeHookScript writes an executable shell script running body.
+func writeHookScript(t *testing.T, body string) string {
+	t.Helper()
+	script := filepath.Join(t.TempDir(), "hook.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return script
+}
+
+func TestPostSaveHookReceivesSavedFile(t *testing.T) {
+	b := newTestBot(t)
+	record := filepath.Join(t.TempDir(), "args")
+	hook, err := newPostSaveHook(writeHookScript(t, `printf '%s\n' "$@" > `+record))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.postSaveHook = hook
+
+	saved, err := b.saveFile(strings.NewReader("hello"), "documents", "notes.txt", "text/plain", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.Wait()
+
+	data, err := os.ReadFile(record)
+	if err != nil {
+		t.Fatalf("hook did not run: %v", err)
+	}
+	want := b.localPath(saved.Path) + "\n42\n5\n"
+	if string(data) != want {
+		t.Errorf("expected hook arguments %q, got %q", want, data)
+	}
+	if hook.Failures() != 0 {
+		t.Errorf("expected no failures, got %d", hook.Failures())
+	}
+}
+
+func TestPostSaveHookFailureDoesNotFailSave(t *testing.T) {
+	b := newTestBot(t)
+	hook, err := newPostSaveHook(writeHookScript(t, "echo broken >&2; exit 3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.postSaveHook = hook
+
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if _, err := b.saveFile(strings.NewReader("x"), "documents", name, "", 42); err != nil {
+			t.Fatalf("expected the save to succeed despite the hook, got %v", err)
+		}
+	}
+	hook.Wait()
+
+	if hook.Failures() != 2 {
+		t.Errorf("expected 2 counted failures, got %d", hook.Failures())
+	}
+	if !exists(b.localPath("a.txt")) || !exists(b.localPath("b.txt")) {
+		t.Error("expected both files to stay stored")
+	}
+}
+
+func TestPostSaveHookTimesOut(t *testing.T) {
+	hook, err := newPostSaveHook(writeHookScript(t, "exec sleep 10"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.timeout = 50 * time.Millisecond
+
+	start := time.Now()
+	hook.Run("file", 42, 1)
+	hook.Wait()
+	if time.Since(start) > 5*time.Second || hook.Failures() != 1 {
+		t.Errorf("expected the hook to be stopped and counted, took %s with %d failures", time.Since(start), hook.Failures())
+	}
+}
+
+func TestNewPostSaveHookRejectsNonExecutable(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "hook.sh")
+	if err := os.WriteFile(file, []byte("#!/bin/sh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{file, filepath.Dir(file), file + ".missing"} {
+		if _, err := newPostSaveHook(p); err == nil {
+			t.Errorf("expected %s to be rejected", p)
+		}
+	}
+}
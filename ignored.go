@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// ignoredMessageType names the message types the bot deliberately leaves
+// unanswered, or returns "" for any other message. Dice and games are stored
+// instead under STORE_DICE; the rest are service messages of group chats.
+func ignoredMessageType(message *tgbotapi.Message) string {
+	switch {
+	case message.Dice != nil:
+		return "dice"
+	case message.Game != nil:
+		return "game"
+	case message.Invoice != nil || message.SuccessfulPayment != nil:
+		return "payment"
+	case len(message.NewChatMembers) > 0 || message.LeftChatMember != nil:
+		return "chat member change"
+	case message.NewChatTitle != "" || len(message.NewChatPhoto) > 0 || message.DeleteChatPhoto:
+		return "chat update"
+	case message.GroupChatCreated || message.SuperGroupChatCreated || message.ChannelChatCreated:
+		return "chat creation"
+	case message.MigrateToChatID != 0 || message.MigrateFromChatID != 0:
+		return "chat migration"
+	case message.PinnedMessage != nil:
+		return "pinned message"
+	case message.MessageAutoDeleteTimerChanged != nil:
+		return "auto-delete timer change"
+	case message.ProximityAlertTriggered != nil:
+		return "proximity alert"
+	case message.VoiceChatScheduled != nil || message.VoiceChatStarted != nil ||
+		message.VoiceChatEnded != nil || message.VoiceChatParticipantsInvited != nil:
+		return "voice chat"
+	}
+	return ""
+}
+
+// gameRecord is how a dice throw or game message is stored under STORE_DICE.
+type gameRecord struct {
+	Type        string    `json:"type"` // dice or game
+	Emoji       string    `json:"emoji,omitempty"`
+	Value       int       `json:"value,omitempty"`
+	Title       string    `json:"title,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Date        time.Time `json:"date"`
+}
+
+// gameRecordJSON serializes the dice throw or game of message.
+func gameRecordJSON(message *tgbotapi.Message) ([]byte, error) {
+	rec := gameRecord{Date: message.Time().UTC()}
+	switch {
+	case message.Dice != nil:
+		rec.Type, rec.Emoji, rec.Value = "dice", message.Dice.Emoji, message.Dice.Value
+	case message.Game != nil:
+		rec.Type, rec.Title, rec.Description = "game", message.Game.Title, message.Game.Description
+	default:
+		return nil, fmt.Errorf("message %d is neither dice nor a game", message.MessageID)
+	}
+	return json.MarshalIndent(rec, "", "  ")
+}
+
+// handleIgnoredMessage stores dice and games under STORE_DICE and only logs
+// every other ignored type.
+func (b *Bot) handleIgnoredMessage(message *tgbotapi.Message, kind string, chatID, userID int64) {
+	if !b.storeDice || (kind != "dice" && kind != "game") {
+		log.Printf("Ignoring %s message from user %d in chat %d", kind, userID, chatID)
+		return
+	}
+	if b.isPaused() {
+		b.sendTextMessage(chatID, "⏸ Uploads are temporarily unavailable due to maintenance. Please try again later.")
+		return
+	}
+	if !b.isMediaTypeAllowed(userID, "documents") {
+		b.sendTextMessage(chatID, fmt.Sprintf("🚫 You are not allowed to send documents. Permitted: %s", b.describeAllowedTypes(userID)))
+		return
+	}
+
+	data, err := gameRecordJSON(message)
+	if err != nil {
+		log.Printf("Error encoding %s: %v", kind, err)
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ Failed to save the %s.", kind))
+		return
+	}
+
+	fileName := fmt.Sprintf("%s_%d.json", kind, time.Now().Unix())
+	saved, err := b.saveChatFile(bytes.NewReader(data), "documents", b.organizedName("documents", fileName, time.Now()), "application/json", chatID, userID)
+	if err != nil {
+		log.Printf("Error saving %s: %v", kind, err)
+		b.sendTextMessage(chatID, b.failureReply(userID, err, fmt.Sprintf("Failed to save the %s.", kind)))
+		return
+	}
+
+	b.replySaved(chatID, userID, saved)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestIgnoredMessageType(t *testing.T) {
+	tests := []struct {
+		message tgbotapi.Message
+		want    string
+	}{
+		{tgbotapi.Message{Dice: &tgbotapi.Dice{Emoji: "🎲", Value: 3}}, "dice"},
+		{tgbotapi.Message{Game: &tgbotapi.Game{Title: "Chess"}}, "game"},
+		{tgbotapi.Message{NewChatMembers: []tgbotapi.User{{ID: 7}}}, "chat member change"},
+		{tgbotapi.Message{PinnedMessage: &tgbotapi.Message{}}, "pinned message"},
+		{tgbotapi.Message{VoiceChatStarted: &tgbotapi.VoiceChatStarted{}}, "voice chat"},
+		{tgbotapi.Message{Text: "hello"}, ""},
+		{tgbotapi.Message{}, ""},
+	}
+	for _, tt := range tests {
+		if got := ignoredMessageType(&tt.message); got != tt.want {
+			t.Errorf("ignoredMessageType(%+v) = %q, want %q", tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestDiceIsIgnoredByDefault(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+
+	b.handleMessage(&tgbotapi.Message{From: &tgbotapi.User{ID: 42}, Chat: &tgbotapi.Chat{ID: 42}, Dice: &tgbotapi.Dice{Emoji: "🎲", Value: 6}})
+	b.handleMessage(&tgbotapi.Message{From: &tgbotapi.User{ID: 42}, Chat: &tgbotapi.Chat{ID: 42}, LeftChatMember: &tgbotapi.User{ID: 7}})
+
+	if texts := api.texts(); len(texts) != 0 {
+		t.Errorf("expected no reply, got %q", texts)
+	}
+	if files, err := b.storage.List(""); err != nil || len(files) != 0 {
+		t.Errorf("expected nothing stored, got %v (%v)", files, err)
+	}
+}
+
+func TestStoreDice(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+	b.storeDice = true
+
+	b.handleMessage(&tgbotapi.Message{From: &tgbotapi.User{ID: 42}, Chat: &tgbotapi.Chat{ID: 42}, Date: 1700000000, Dice: &tgbotapi.Dice{Emoji: "🎯", Value: 5}})
+
+	texts := api.texts()
+	if len(texts) != 1 || !strings.HasPrefix(texts[0], "✅ Saved 'dice_") {
+		t.Fatalf("unexpected reply %q", texts)
+	}
+	name := strings.SplitN(strings.TrimPrefix(texts[0], "✅ Saved '"), "'", 2)[0]
+	data, err := os.ReadFile(b.localPath(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got gameRecord
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if got.Type != "dice" || got.Emoji != "🎯" || got.Value != 5 || got.Date.Unix() != 1700000000 {
+		t.Errorf("unexpected record %+v", got)
+	}
+
+	// Service messages stay ignored
+	b.handleMessage(&tgbotapi.Message{From: &tgbotapi.User{ID: 42}, Chat: &tgbotapi.Chat{ID: 42}, NewChatTitle: "Family"})
+	if texts := api.texts(); len(texts) != 1 {
+		t.Errorf("expected no reply to a service message, got %q", texts[1:])
+	}
+}
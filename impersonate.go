@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// impersonatedCommands are the commands /admin as may run for another user.
+// Only commands that read storage or settings belong here, so support can
+// never change a user's files by impersonating them.
+var impersonatedCommands = map[string]func(b *Bot, chatID, owner int64, args []string){
+	"list": func(b *Bot, chatID, owner int64, _ []string) { b.handleListCommand(chatID, owner) },
+	"search": func(b *Bot, chatID, owner int64, args []string) {
+		b.handleSearchCommand(chatID, owner, strings.Join(args, " "))
+	},
+	"info": func(b *Bot, chatID, owner int64, args []string) {
+		b.handleInfoCommand(chatID, owner, strings.Join(args, " "))
+	},
+	"limits": func(b *Bot, chatID, owner int64, _ []string) { b.handleLimitsCommand(chatID, owner) },
+}
+
+// impersonatedCommandNames returns the commands /admin as accepts, sorted.
+func impersonatedCommandNames() []string {
+	names := make([]string, 0, len(impersonatedCommands))
+	for name := range impersonatedCommands {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// handleAdminAs runs a read-only command for the admin in chatID as if the
+// user in args[0] had sent it, against that user's storage and settings.
+func (b *Bot) handleAdminAs(chatID, adminID int64, args []string) {
+	usage := fmt.Sprintf("Usage: /admin as <user_id> <command> [args]\nCommands: %s", strings.Join(impersonatedCommandNames(), ", "))
+	if len(args) < 2 {
+		b.sendTextMessage(chatID, usage)
+		return
+	}
+
+	userID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		b.sendTextMessage(chatID, "❌ Invalid user ID format")
+		return
+	}
+	name := strings.ToLower(strings.TrimPrefix(args[1], "/"))
+	run, ok := impersonatedCommands[name]
+	if !ok {
+		b.sendTextMessage(chatID, fmt.Sprintf("🚫 /%s cannot be run as another user. Only read-only commands can: %s", name, strings.Join(impersonatedCommandNames(), ", ")))
+		return
+	}
+
+	log.Printf("Admin %d viewing /%s as user %d", adminID, name, userID)
+	b.sendTextMessage(chatID, fmt.Sprintf("👁 Impersonated view: /%s as user %d (read-only). The replies below show their storage, not yours.", name, userID))
+	run(b, chatID, userID, args[2:])
+}
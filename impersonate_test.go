@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestAdminAsScopesToUser(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+	b.perUserDirs = true
+	b.adminUsers = map[int64]bool{9: true}
+	writeTestFile(t, b, "42/report.pdf", "theirs")
+	writeTestFile(t, b, "43/other.pdf", "someone else's")
+	writeTestFile(t, b, "9/mine.pdf", "the admin's")
+
+	run := func(text string) []string {
+		t.Helper()
+		api.sent = nil
+		b.handleAdminCommand(&tgbotapi.Message{From: &tgbotapi.User{ID: 9}, Chat: &tgbotapi.Chat{ID: 9}, Text: text}, 9, 9)
+		texts := api.texts()
+		if len(texts) < 2 || !strings.Contains(texts[0], "Impersonated view") || !strings.Contains(texts[0], "user 42") {
+			t.Fatalf("%s: expected a labelled impersonated view, got %q", text, texts)
+		}
+		return texts[1:]
+	}
+
+	listed := strings.Join(run("/admin as 42 list"), "\n")
+	if !strings.Contains(listed, "report.pdf") || strings.Contains(listed, "other.pdf") || strings.Contains(listed, "mine.pdf") {
+		t.Errorf("expected only user 42's files, got:\n%s", listed)
+	}
+	if found := strings.Join(run("/admin as 42 /search pdf"), "\n"); !strings.Contains(found, "report.pdf") || strings.Contains(found, "mine.pdf") {
+		t.Errorf("expected the search to cover user 42's files, got:\n%s", found)
+	}
+	if info := run("/admin as 42 info report.pdf"); !strings.Contains(info[0], "report.pdf") || strings.Contains(info[0], "not found") {
+		t.Errorf("expected info on user 42's file, got %q", info)
+	}
+	if info := run("/admin as 42 info mine.pdf"); !strings.Contains(info[0], "not found") {
+		t.Errorf("expected the admin's own file not to be found, got %q", info)
+	}
+	if limits := run("/admin as 42 limits"); !strings.Contains(limits[0], "📁 Folder: /42") {
+		t.Errorf("expected user 42's folder, got %q", limits)
+	}
+}
+
+func TestAdminAsRefusesDestructiveCommands(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+	b.perUserDirs = true
+	b.adminUsers = map[int64]bool{9: true}
+	writeTestFile(t, b, "42/report.pdf", "theirs")
+
+	for _, text := range []string{"/admin as 42 rename report.pdf x.pdf", "/admin as 42 /delete report.pdf", "/admin as x list"} {
+		api.sent = nil
+		b.handleAdminCommand(&tgbotapi.Message{From: &tgbotapi.User{ID: 9}, Chat: &tgbotapi.Chat{ID: 9}, Text: text}, 9, 9)
+		if texts := api.texts(); len(texts) != 1 || strings.Contains(texts[0], "Impersonated view") {
+			t.Errorf("%s: expected a refusal, got %q", text, texts)
+		}
+	}
+	if _, err := os.Stat(b.localPath("42/report.pdf")); err != nil {
+		t.Errorf("expected the file to be untouched: %v", err)
+	}
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const indexSchema = `
+CREATE TABLE IF NOT EXISTS files (
+	path        TEXT PRIMARY KEY,
+	owner       INTEGER NOT NULL,
+	size        INTEGER NOT NULL,
+	mime        TEXT NOT NULL DEFAULT '',
+	sha256      TEXT NOT NULL DEFAULT '',
+	received_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS files_owner ON files(owner);
+CREATE INDEX IF NOT EXISTS files_received_at ON files(received_at);
+CREATE TABLE IF NOT EXISTS file_tags (
+	path TEXT NOT NULL,
+	tag  TEXT NOT NULL,
+	PRIMARY KEY (path, tag)
+);
+CREATE INDEX IF NOT EXISTS file_tags_tag ON file_tags(tag);
+CREATE TABLE IF NOT EXISTS content_links (
+	path   TEXT PRIMARY KEY,
+	sha256 TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS content_links_sha256 ON content_links(sha256);
+`
+
+// FileIndex is an optional SQLite-backed index of stored files used to serve
+// listings and searches without scanning the filesystem.
+type FileIndex struct {
+	db *sql.DB
+}
+
+// OpenFileIndex opens (or creates) the index database at path.
+func OpenFileIndex(path string) (*FileIndex, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open index: %w", err)
+	}
+
+	// SQLite allows a single writer; serialize access through one connection.
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(indexSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize index schema: %w", err)
+	}
+
+	return &FileIndex{db: db}, nil
+}
+
+// Close releases the underlying database.
+func (idx *FileIndex) Close() error {
+	return idx.db.Close()
+}
+
+// Put records or replaces the entry for m.Path.
+func (idx *FileIndex) Put(m FileMeta) error {
+	tx, err := idx.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin index transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := putMeta(tx, m); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit index transaction: %w", err)
+	}
+	return nil
+}
+
+// List returns up to limit entries under dir ("" for all), most recently received first.
+func (idx *FileIndex) List(dir string, limit int) ([]FileMeta, error) {
+	return idx.query(`SELECT path, owner, size, mime, sha256, received_at FROM files
+		WHERE path LIKE ? ESCAPE '\' ORDER BY received_at DESC, path LIMIT ?`, dirPattern(dir)+"%", limit)
+}
+
+// Search returns up to limit entries under dir whose path contains query (case-insensitive).
+func (idx *FileIndex) Search(dir, query string, limit int) ([]FileMeta, error) {
+	pattern := dirPattern(dir) + "%" + escapeLike(query) + "%"
+	return idx.query(`SELECT path, owner, size, mime, sha256, received_at FROM files
+		WHERE path LIKE ? ESCAPE '\' ORDER BY received_at DESC, path LIMIT ?`, pattern, limit)
+}
+
+// SearchTag returns up to limit entries under dir tagged with tag (see
+// normalizeTags), most recently received first.
+func (idx *FileIndex) SearchTag(dir, tag string, limit int) ([]FileMeta, error) {
+	return idx.query(`SELECT f.path, f.owner, f.size, f.mime, f.sha256, f.received_at FROM files f
+		JOIN file_tags t ON t.path = f.path
+		WHERE t.tag = ? AND f.path LIKE ? ESCAPE '\' ORDER BY f.received_at DESC, f.path LIMIT ?`, tag, dirPattern(dir)+"%", limit)
+}
+
+// Get returns the entry at path and whether there is one.
+func (idx *FileIndex) Get(path string) (FileMeta, bool, error) {
+	metas, err := idx.query(`SELECT path, owner, size, mime, sha256, received_at FROM files WHERE path = ?`, path)
+	if err != nil || len(metas) == 0 {
+		return FileMeta{}, false, err
+	}
+	return metas[0], true, nil
+}
+
+// Page returns up to limit entries with paths after the given one, in path
+// order, for going through the whole index in batches.
+func (idx *FileIndex) Page(after string, limit int) ([]FileMeta, error) {
+	return idx.query(`SELECT path, owner, size, mime, sha256, received_at FROM files
+		WHERE path > ? ORDER BY path LIMIT ?`, after, limit)
+}
+
+// Rename moves the entry at from to to, replacing any entry already at to.
+func (idx *FileIndex) Rename(from, to string) error {
+	tx, err := idx.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin index transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"files", "file_tags", "content_links"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE path = ?`, to); err != nil {
+			return fmt.Errorf("failed to replace index entry for %s: %w", to, err)
+		}
+		if _, err := tx.Exec(`UPDATE `+table+` SET path = ? WHERE path = ?`, to, from); err != nil {
+			return fmt.Errorf("failed to rename index entry for %s: %w", from, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit index transaction: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the entry at path, if any.
+func (idx *FileIndex) Delete(path string) error {
+	if _, err := idx.db.Exec(`DELETE FROM files WHERE path = ?; DELETE FROM file_tags WHERE path = ?`, path, path); err != nil {
+		return fmt.Errorf("failed to delete index entry for %s: %w", path, err)
+	}
+	return nil
+}
+
+// LinkContent records that path is a hardlink of the content store blob
+// sum (DEDUP_HARDLINK). When path was a link of another blob before, it
+// returns that blob and how many links it has left.
+func (idx *FileIndex) LinkContent(path, sum string) (string, int, error) {
+	tx, err := idx.db.Begin()
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to begin index transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	old, err := linkedContent(tx, path)
+	if err != nil {
+		return "", 0, err
+	}
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO content_links (path, sha256) VALUES (?, ?)`, path, sum); err != nil {
+		return "", 0, fmt.Errorf("failed to record content link of %s: %w", path, err)
+	}
+	var refs int
+	if old != "" && old != sum {
+		if refs, err = contentRefs(tx, old); err != nil {
+			return "", 0, err
+		}
+	} else {
+		old = ""
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", 0, fmt.Errorf("failed to commit index transaction: %w", err)
+	}
+	return old, refs, nil
+}
+
+// UnlinkContent forgets the content link of path and returns the blob it
+// linked to ("" for none) and how many links that blob has left.
+func (idx *FileIndex) UnlinkContent(path string) (string, int, error) {
+	tx, err := idx.db.Begin()
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to begin index transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	sum, err := linkedContent(tx, path)
+	if err != nil || sum == "" {
+		return "", 0, err
+	}
+	if _, err := tx.Exec(`DELETE FROM content_links WHERE path = ?`, path); err != nil {
+		return "", 0, fmt.Errorf("failed to remove content link of %s: %w", path, err)
+	}
+	refs, err := contentRefs(tx, sum)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", 0, fmt.Errorf("failed to commit index transaction: %w", err)
+	}
+	return sum, refs, nil
+}
+
+// LinkedContent returns the content store blob path is a link of, or "".
+func (idx *FileIndex) LinkedContent(path string) (string, error) {
+	return linkedContent(idx.db, path)
+}
+
+// ContentRefs returns how many stored files link to the blob sum.
+func (idx *FileIndex) ContentRefs(sum string) (int, error) {
+	return contentRefs(idx.db, sum)
+}
+
+// Reindex replaces the index contents with the files currently under the data
+// roots. Metadata sidecars under metaRoot are used where present and up to date;
+// otherwise the hash and size are recomputed from the content returned by open.
+func (idx *FileIndex) Reindex(metaRoot string, dataRoots []string, open fileOpener) (int, error) {
+	var metas []FileMeta
+
+	for _, root := range dataRoots {
+		err := walkStoredFiles(root, func(rel, path string, info os.FileInfo) error {
+			// A file modified after it was recorded has a stale sidecar
+			m, err := readMeta(metaRoot, rel)
+			stale := err != nil || m.SHA256 == "" || info.ModTime().After(m.ReceivedAt)
+			if m.ReceivedAt.IsZero() {
+				m.ReceivedAt = info.ModTime()
+			}
+			m.Path = rel
+
+			if stale {
+				if m.SHA256, m.Size, err = hashFile(path, open); err != nil {
+					return fmt.Errorf("failed to hash %s: %w", rel, err)
+				}
+			}
+
+			metas = append(metas, m)
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return 0, fmt.Errorf("failed to scan storage: %w", err)
+		}
+	}
+
+	tx, err := idx.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin index transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM files; DELETE FROM file_tags`); err != nil {
+		return 0, fmt.Errorf("failed to clear index: %w", err)
+	}
+
+	for _, m := range metas {
+		if err := putMeta(tx, m); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit index transaction: %w", err)
+	}
+
+	return len(metas), nil
+}
+
+func (idx *FileIndex) query(q string, args ...interface{}) ([]FileMeta, error) {
+	rows, err := idx.db.Query(q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("index query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var metas []FileMeta
+	for rows.Next() {
+		var m FileMeta
+		var received int64
+		if err := rows.Scan(&m.Path, &m.Owner, &m.Size, &m.MIME, &m.SHA256, &received); err != nil {
+			return nil, fmt.Errorf("failed to read index row: %w", err)
+		}
+		m.ReceivedAt = time.Unix(0, received)
+		metas = append(metas, m)
+	}
+
+	return metas, rows.Err()
+}
+
+func putMeta(tx *sql.Tx, m FileMeta) error {
+	_, err := tx.Exec(`INSERT OR REPLACE INTO files (path, owner, size, mime, sha256, received_at)
+		VALUES (?, ?, ?, ?, ?, ?)`, m.Path, m.Owner, m.Size, m.MIME, m.SHA256, m.ReceivedAt.UnixNano())
+	if err != nil {
+		return fmt.Errorf("failed to write index entry for %s: %w", m.Path, err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM file_tags WHERE path = ?`, m.Path); err != nil {
+		return fmt.Errorf("failed to write index tags for %s: %w", m.Path, err)
+	}
+	for _, tag := range m.Tags {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO file_tags (path, tag) VALUES (?, ?)`, m.Path, tag); err != nil {
+			return fmt.Errorf("failed to write index tags for %s: %w", m.Path, err)
+		}
+	}
+	return nil
+}
+
+// rowQuerier is a *sql.DB or *sql.Tx.
+type rowQuerier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func linkedContent(q rowQuerier, path string) (string, error) {
+	var sum string
+	err := q.QueryRow(`SELECT sha256 FROM content_links WHERE path = ?`, path).Scan(&sum)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up content link of %s: %w", path, err)
+	}
+	return sum, nil
+}
+
+func contentRefs(q rowQuerier, sum string) (int, error) {
+	var refs int
+	if err := q.QueryRow(`SELECT COUNT(*) FROM content_links WHERE sha256 = ?`, sum).Scan(&refs); err != nil {
+		return 0, fmt.Errorf("failed to count content links of %s: %w", sum, err)
+	}
+	return refs, nil
+}
+
+// dirPattern returns the escaped LIKE prefix matching paths under dir.
+func dirPattern(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	return escapeLike(dir) + "/"
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestIndex(t *testing.T) *FileIndex {
+	t.Helper()
+	idx, err := OpenFileIndex(filepath.Join(t.TempDir(), "index.db"))
+	if err != nil {
+		t.Fatalf("failed to open index: %v", err)
+	}
+	t.Cleanup(func() { idx.Close() })
+	return idx
+}
+
+func TestIndexPutAndList(t *testing.T) {
+	idx := newTestIndex(t)
+	base := time.Now()
+
+	entries := []FileMeta{
+		{Path: "old.pdf", Owner: 1, Size: 10, MIME: "application/pdf", SHA256: "aa", ReceivedAt: base.Add(-2 * time.Hour)},
+		{Path: "new.jpg", Owner: 2, Size: 20, MIME: "image/jpeg", SHA256: "bb", ReceivedAt: base},
+		{Path: "mid.mp4", Owner: 1, Size: 30, MIME: "video/mp4", SHA256: "cc", ReceivedAt: base.Add(-time.Hour)},
+	}
+	for _, m := range entries {
+		if err := idx.Put(m); err != nil {
+			t.Fatalf("Put(%s) failed: %v", m.Path, err)
+		}
+	}
+
+	got, err := idx.List("", 10)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(got))
+	}
+
+	want := []string{"new.jpg", "mid.mp4", "old.pdf"}
+	for i, name := range want {
+		if got[i].Path != name {
+			t.Errorf("entry %d: expected %s, got %s", i, name, got[i].Path)
+		}
+	}
+	if got[0].Owner != 2 || got[0].Size != 20 || got[0].MIME != "image/jpeg" || got[0].SHA256 != "bb" {
+		t.Errorf("unexpected entry contents: %+v", got[0])
+	}
+
+	limited, err := idx.List("", 2)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(limited) != 2 {
+		t.Errorf("expected limit to cap results at 2, got %d", len(limited))
+	}
+}
+
+func TestIndexPutReplacesExisting(t *testing.T) {
+	idx := newTestIndex(t)
+
+	idx.Put(FileMeta{Path: "a.txt", Owner: 1, Size: 1, ReceivedAt: time.Now()})
+	idx.Put(FileMeta{Path: "a.txt", Owner: 1, Size: 99, ReceivedAt: time.Now()})
+
+	got, err := idx.List("", 10)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(got) != 1 || got[0].Size != 99 {
+		t.Errorf("expected a single replaced entry with size 99, got %+v", got)
+	}
+}
+
+func TestIndexSearch(t *testing.T) {
+	idx := newTestIndex(t)
+	now := time.Now()
+
+	for _, name := range []string{"Report_2024.pdf", "holiday.jpg", "report-final.docx", "100%_done.txt"} {
+		idx.Put(FileMeta{Path: name, Owner: 1, ReceivedAt: now})
+	}
+
+	got, err := idx.Search("", "report", 10)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(got) != 2 {
+		t.Errorf("expected 2 case-insensitive matches for 'report', got %d", len(got))
+	}
+
+	got, err = idx.Search("", "%", 10)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(got) != 1 || got[0].Path != "100%_done.txt" {
+		t.Errorf("expected '%%' to be matched literally, got %+v", got)
+	}
+
+	got, err = idx.Search("", "missing", 10)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("expected no matches, got %d", len(got))
+	}
+}
+
+func TestIndexReindex(t *testing.T) {
+	idx := newTestIndex(t)
+	root := t.TempDir()
+
+	os.WriteFile(filepath.Join(root, "with-meta.txt"), []byte("hello"), 0644)
+	os.WriteFile(filepath.Join(root, "no-meta.txt"), []byte("world!"), 0644)
+	writeMeta(root, FileMeta{Path: "with-meta.txt", Owner: 42, Size: 5, MIME: "text/plain", SHA256: "cached", ReceivedAt: time.Now().Add(time.Second)})
+	os.WriteFile(filepath.Join(root, "stale.txt"), []byte("changed"), 0644)
+	writeMeta(root, FileMeta{Path: "stale.txt", Owner: 7, Size: 3, SHA256: "outdated", ReceivedAt: time.Now().Add(-time.Hour)})
+
+	// A stale entry that no longer exists on disk
+	idx.Put(FileMeta{Path: "gone.bin", Owner: 1, ReceivedAt: time.Now()})
+
+	count, err := idx.Reindex(root, []string{root}, func(p string) (io.ReadCloser, error) { return os.Open(p) })
+	if err != nil {
+		t.Fatalf("Reindex failed: %v", err)
+	}
+	if count != 3 {
+		t.Fatalf("expected 3 files reindexed, got %d", count)
+	}
+
+	got, err := idx.List("", 10)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+
+	byPath := make(map[string]FileMeta)
+	for _, m := range got {
+		byPath[m.Path] = m
+	}
+
+	if _, ok := byPath["gone.bin"]; ok {
+		t.Error("expected stale entry to be removed by reindex")
+	}
+	if _, ok := byPath[".meta/with-meta.txt.json"]; ok {
+		t.Error("expected metadata sidecars to be skipped")
+	}
+
+	withMeta := byPath["with-meta.txt"]
+	if withMeta.Owner != 42 || withMeta.MIME != "text/plain" || withMeta.SHA256 != "cached" {
+		t.Errorf("expected sidecar metadata to be preserved, got %+v", withMeta)
+	}
+
+	noMeta := byPath["no-meta.txt"]
+	if noMeta.Size != 6 {
+		t.Errorf("expected size 6, got %d", noMeta.Size)
+	}
+	// sha256("world!")
+	if noMeta.SHA256 != "711e9609339e92b03ddc0a211827dba421f38f9ed8b9d806e1ffdd8c15ffa03d" {
+		t.Errorf("expected hash to be computed, got %s", noMeta.SHA256)
+	}
+
+	stale := byPath["stale.txt"]
+	if stale.Owner != 7 || stale.Size != 7 || stale.SHA256 == "outdated" {
+		t.Errorf("expected stale sidecar hash and size to be recomputed, got %+v", stale)
+	}
+}
+
+func TestIndexTags(t *testing.T) {
+	idx := newTestIndex(t)
+	base := time.Now()
+	for _, m := range []FileMeta{
+		{Path: "u1/a.pdf", Tags: []string{"tax", "work"}, ReceivedAt: base.Add(-time.Hour)},
+		{Path: "u1/b.pdf", Tags: []string{"work"}, ReceivedAt: base},
+		{Path: "u2/c.pdf", Tags: []string{"work"}, ReceivedAt: base},
+	} {
+		if err := idx.Put(m); err != nil {
+			t.Fatalf("Put(%s) failed: %v", m.Path, err)
+		}
+	}
+
+	got, err := idx.SearchTag("u1", "work", 10)
+	if err != nil || len(got) != 2 || got[0].Path != "u1/b.pdf" || got[1].Path != "u1/a.pdf" {
+		t.Fatalf("unexpected tag search result %+v (%v)", got, err)
+	}
+
+	if err := idx.Rename("u1/a.pdf", "u1/renamed.pdf"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := idx.SearchTag("u1", "tax", 10); len(got) != 1 || got[0].Path != "u1/renamed.pdf" {
+		t.Errorf("expected the tags to follow a rename, got %+v", got)
+	}
+	if err := idx.Delete("u1/b.pdf"); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Put(FileMeta{Path: "u1/renamed.pdf", Tags: []string{"tax"}, ReceivedAt: base}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := idx.SearchTag("u1", "work", 10); len(got) != 0 {
+		t.Errorf("expected deleted and untagged files to be gone, got %+v", got)
+	}
+}
+
+func TestIndexContentLinks(t *testing.T) {
+	idx := newTestIndex(t)
+	for _, path := range []string{"u1/a.pdf", "u2/a.pdf"} {
+		if old, _, err := idx.LinkContent(path, "aa"); err != nil || old != "" {
+			t.Fatalf("LinkContent(%s) = %q, %v", path, old, err)
+		}
+	}
+	if refs, err := idx.ContentRefs("aa"); err != nil || refs != 2 {
+		t.Fatalf("expected 2 links, got %d (%v)", refs, err)
+	}
+
+	// Relinking a path to other content reports what it leaves behind
+	if old, refs, err := idx.LinkContent("u2/a.pdf", "bb"); err != nil || old != "aa" || refs != 1 {
+		t.Errorf("expected aa with 1 link left, got %q, %d (%v)", old, refs, err)
+	}
+	if err := idx.Rename("u1/a.pdf", "u1/b.pdf"); err != nil {
+		t.Fatal(err)
+	}
+	if sum, _ := idx.LinkedContent("u1/b.pdf"); sum != "aa" {
+		t.Errorf("expected the link to follow a rename, got %q", sum)
+	}
+	if sum, refs, err := idx.UnlinkContent("u1/b.pdf"); err != nil || sum != "aa" || refs != 0 {
+		t.Errorf("expected the last link of aa to go, got %q, %d (%v)", sum, refs, err)
+	}
+	if sum, _, err := idx.UnlinkContent("u1/b.pdf"); err != nil || sum != "" {
+		t.Errorf("expected no link left, got %q (%v)", sum, err)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// formatFileInfo renders the metadata of a stored file for /info.
+func formatFileInfo(name string, m FileMeta) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📄 %s\n\n", name))
+	sb.WriteString(fmt.Sprintf("Size: %s\n", formatSize(m.Size)))
+	sb.WriteString(fmt.Sprintf("Received: %s\n", m.ReceivedAt.Format("2006-01-02 15:04")))
+	if m.MediaType != "" {
+		sb.WriteString(fmt.Sprintf("Type: %s\n", m.MediaType))
+	}
+	if m.MIME != "" {
+		sb.WriteString(fmt.Sprintf("MIME: %s\n", m.MIME))
+	}
+	if m.OriginalSize > 0 {
+		sb.WriteString(fmt.Sprintf("Recompressed from: %s\n", formatSize(m.OriginalSize)))
+	}
+	if m.OriginalName != "" {
+		sb.WriteString(fmt.Sprintf("Sent as: %s\n", m.OriginalName))
+	}
+	if m.SharedBy != 0 {
+		sb.WriteString(fmt.Sprintf("Shared by: %d\n", m.SharedBy))
+	}
+	if m.SHA256 != "" {
+		sb.WriteString(fmt.Sprintf("SHA-256: %s\n", m.SHA256))
+	}
+	if len(m.Tags) > 0 {
+		sb.WriteString(fmt.Sprintf("Tags: %s\n", formatTags(m.Tags)))
+	}
+	if m.Pinned {
+		sb.WriteString("📌 Pinned\n")
+	}
+	if m.Protected {
+		sb.WriteString("🔒 Sent with protected content (forwarding and saving restricted)\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// handleInfoCommand shows the metadata of one of the user's files.
+func (b *Bot) handleInfoCommand(chatID, userID int64, args string) {
+	if !b.requireLocalStorage(chatID) {
+		return
+	}
+
+	parts := splitArgs(args)
+	if len(parts) != 1 {
+		b.sendTextMessage(chatID, "Usage: /info <file>\nQuote names containing spaces, e.g. /info \"my file.pdf\"")
+		return
+	}
+	name, err := cleanRelPath(parts[0])
+	if err != nil {
+		b.sendTextMessage(chatID, "❌ Invalid file name")
+		return
+	}
+
+	rel := b.userFile(userID, name)
+	src := b.localPath(rel)
+	info, err := os.Stat(src)
+	if err != nil || !info.Mode().IsRegular() {
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ File '%s' not found", name))
+		return
+	}
+
+	b.sendTextMessage(chatID, formatFileInfo(name, b.fileMeta(rel, src, info)))
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInfoCommandShowsMetadata(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+
+	writeTestFile(t, b, "secret.pdf", "content")
+	if err := writeMeta(b.storagePath, FileMeta{Path: "secret.pdf", Owner: 42, Size: 7, MIME: "application/pdf",
+		ReceivedAt: time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC), Pinned: true, Protected: true}); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, b, "plain.txt", "hello")
+
+	b.handleInfoCommand(42, 42, " secret.pdf")
+	b.handleInfoCommand(42, 42, " plain.txt")
+	b.handleInfoCommand(42, 42, " missing.txt")
+	b.handleInfoCommand(42, 42, "")
+
+	texts := api.texts()
+	if len(texts) != 4 {
+		t.Fatalf("expected four replies, got %q", texts)
+	}
+	for _, want := range []string{"📄 secret.pdf", "Size: 7 B", "Received: 2024-05-01 12:30", "MIME: application/pdf", "📌 Pinned", "🔒 Sent with protected content"} {
+		if !strings.Contains(texts[0], want) {
+			t.Errorf("expected %q in %q", want, texts[0])
+		}
+	}
+	// Files without a sidecar are described from disk
+	if !strings.Contains(texts[1], "Size: 5 B") || !strings.Contains(texts[1], "SHA-256: ") || strings.Contains(texts[1], "🔒") {
+		t.Errorf("unexpected info without a sidecar %q", texts[1])
+	}
+	if texts[2] != "❌ File 'missing.txt' not found" || !strings.HasPrefix(texts[3], "Usage: /info") {
+		t.Errorf("unexpected replies %q", texts[2:])
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// InlineResultLimit is the most results Telegram accepts per inline answer.
+	InlineResultLimit = 50
+	// inlineCandidateLimit bounds how many stored files an inline query
+	// looks at; files of other owners and files without a file_id are skipped.
+	inlineCandidateLimit = 200
+	// inlineCacheTime is how long Telegram may cache an answer, in seconds.
+	inlineCacheTime = 10
+)
+
+// inlineResult returns the cached inline result resending m, or nil if its
+// Telegram file cannot be resent inline (no file_id, or a video note).
+func inlineResult(id string, m FileMeta, title string) interface{} {
+	if m.FileID == "" {
+		return nil
+	}
+	switch m.MediaType {
+	case "photos":
+		r := tgbotapi.NewInlineQueryResultCachedPhoto(id, m.FileID)
+		r.Title = title
+		return r
+	case "videos":
+		return tgbotapi.NewInlineQueryResultCachedVideo(id, m.FileID, title)
+	case "audio":
+		return tgbotapi.NewInlineQueryResultCachedAudio(id, m.FileID)
+	case "voice":
+		return tgbotapi.NewInlineQueryResultCachedVoice(id, m.FileID, title)
+	case "stickers":
+		return tgbotapi.NewInlineQueryResultCachedSticker(id, m.FileID, title)
+	case "documents":
+		return tgbotapi.NewInlineQueryResultCachedDocument(id, m.FileID, title)
+	}
+	return nil
+}
+
+// inlineCandidates returns the storage-relative paths in the user's directory
+// whose name contains query, newest first; an empty query matches everything.
+func (b *Bot) inlineCandidates(userID int64, query string) ([]string, error) {
+	dir := b.userDir(userID)
+
+	if b.index != nil {
+		var metas []FileMeta
+		var err error
+		if query == "" {
+			metas, err = b.index.List(dir, inlineCandidateLimit)
+		} else {
+			metas, err = b.index.Search(dir, query, inlineCandidateLimit)
+		}
+		if err != nil {
+			return nil, err
+		}
+		paths := make([]string, len(metas))
+		for i, m := range metas {
+			paths[i] = m.Path
+		}
+		return paths, nil
+	}
+
+	all, err := b.storage.List(dir)
+	if err != nil {
+		return nil, err
+	}
+	query = strings.ToLower(query)
+	var paths []string
+	for _, f := range all {
+		if strings.Contains(strings.ToLower(f.Name), query) {
+			paths = append(paths, path.Join(dir, f.Name))
+		}
+		if len(paths) == inlineCandidateLimit {
+			break
+		}
+	}
+	return paths, nil
+}
+
+// inlineResults builds the answer to an inline query: the querying user's own
+// files matching query that can be resent by file_id.
+func (b *Bot) inlineResults(userID int64, query string) ([]interface{}, error) {
+	paths, err := b.inlineCandidates(userID, strings.TrimSpace(query))
+	if err != nil {
+		return nil, err
+	}
+
+	results := []interface{}{}
+	for _, rel := range paths {
+		m, err := readMeta(b.storagePath, rel)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Printf("Failed to read metadata for %s: %v", rel, err)
+			}
+			continue
+		}
+		if m.Owner != userID {
+			continue
+		}
+		if r := inlineResult(strconv.Itoa(len(results)), m, b.displayName(userID, rel)); r != nil {
+			results = append(results, r)
+		}
+		if len(results) == InlineResultLimit {
+			break
+		}
+	}
+	return results, nil
+}
+
+// handleInlineQuery answers "@bot <query>" with matching stored files.
+// Unauthorized users get an empty answer.
+func (b *Bot) handleInlineQuery(query *tgbotapi.InlineQuery) {
+	results := []interface{}{}
+	if b.isUserAllowed(query.From.ID) {
+		var err error
+		if results, err = b.inlineResults(query.From.ID, query.Query); err != nil {
+			log.Printf("Error answering inline query from user %d: %v", query.From.ID, err)
+			results = []interface{}{}
+		}
+	}
+
+	answer := tgbotapi.InlineConfig{
+		InlineQueryID: query.ID,
+		Results:       results,
+		CacheTime:     inlineCacheTime,
+		IsPersonal:    true,
+	}
+	if _, err := b.request(answer); err != nil {
+		log.Printf("Failed to answer inline query: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// writeUploadedFile stores a file with the sidecar of a Telegram upload.
+func writeUploadedFile(t *testing.T, b *Bot, rel string, owner int64, mediaType, fileID string) {
+	t.Helper()
+	writeTestFile(t, b, rel, "content")
+	m := FileMeta{Path: rel, Owner: owner, Size: 7, ReceivedAt: time.Now(), FileID: fileID, MediaType: mediaType}
+	if err := writeMeta(b.storagePath, m); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInlineResult(t *testing.T) {
+	photo, ok := inlineResult("1", FileMeta{FileID: "p1", MediaType: "photos"}, "cat.jpg").(tgbotapi.InlineQueryResultCachedPhoto)
+	if !ok || photo.ID != "1" || photo.PhotoID != "p1" || photo.Title != "cat.jpg" {
+		t.Errorf("unexpected photo result %+v", photo)
+	}
+
+	doc, ok := inlineResult("2", FileMeta{FileID: "d1", MediaType: "documents"}, "report.pdf").(tgbotapi.InlineQueryResultCachedDocument)
+	if !ok || doc.DocumentID != "d1" || doc.Title != "report.pdf" {
+		t.Errorf("unexpected document result %+v", doc)
+	}
+
+	for _, m := range []FileMeta{{MediaType: "documents"}, {FileID: "n1", MediaType: "video_notes"}, {FileID: "x", MediaType: "contacts"}} {
+		if r := inlineResult("3", m, "name"); r != nil {
+			t.Errorf("expected no result for %+v, got %+v", m, r)
+		}
+	}
+}
+
+func TestInlineResultsScopedToUser(t *testing.T) {
+	b := newTestBot(t)
+	writeUploadedFile(t, b, "report.pdf", 42, "documents", "d1")
+	writeUploadedFile(t, b, "report-7.pdf", 7, "documents", "d7")
+	writeUploadedFile(t, b, "holiday.jpg", 42, "photos", "p1")
+	writeTestFile(t, b, "report-old.pdf", "no sidecar")
+
+	results, err := b.inlineResults(42, " REPORT ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].(tgbotapi.InlineQueryResultCachedDocument).DocumentID != "d1" {
+		t.Errorf("expected only the user's own report, got %+v", results)
+	}
+
+	results, err = b.inlineResults(7, "holiday")
+	if err != nil || len(results) != 0 {
+		t.Errorf("expected no results for another user's file, got %+v (%v)", results, err)
+	}
+}
+
+func TestInlineQueryEmptyQueryListsRecentFiles(t *testing.T) {
+	b := newTestBot(t)
+	b.perUserDirs = true
+	api := &mockTelegramAPI{}
+	b.client = api
+	writeUploadedFile(t, b, "42/report.pdf", 42, "documents", "d1")
+	writeUploadedFile(t, b, "42/holiday.jpg", 42, "photos", "p1")
+
+	b.handleInlineQuery(&tgbotapi.InlineQuery{ID: "q1", From: &tgbotapi.User{ID: 42}})
+
+	b.allowedUsers[1] = true // restricts access to user 1
+	b.handleInlineQuery(&tgbotapi.InlineQuery{ID: "q2", From: &tgbotapi.User{ID: 42}, Query: "report"})
+
+	if len(api.requests) != 2 {
+		t.Fatalf("expected two answers, got %d", len(api.requests))
+	}
+	first := api.requests[0].(tgbotapi.InlineConfig)
+	if first.InlineQueryID != "q1" || !first.IsPersonal || len(first.Results) != 2 {
+		t.Errorf("expected both files for an empty query, got %+v", first)
+	}
+	if second := api.requests[1].(tgbotapi.InlineConfig); len(second.Results) != 0 {
+		t.Errorf("expected an empty answer for an unauthorized user, got %+v", second.Results)
+	}
+}
+
+func TestDownloadRecordsFileID(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("photo bytes"))
+	}))
+	defer srv.Close()
+
+	b := newDownloadTestBot(t, 5*time.Second)
+	b.client = &mockTelegramAPI{fileURL: srv.URL}
+
+	saved, err := b.downloadAndSave(mediaFile{MediaType: "photos", FileID: "p1", MIME: "image/jpeg"}, "cat.jpg", 42, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, err := readMeta(b.storagePath, saved.Path)
+	if err != nil || meta.FileID != "p1" || meta.MediaType != "photos" {
+		t.Errorf("expected the file_id in the sidecar, got %+v (%v)", meta, err)
+	}
+}
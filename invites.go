@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultInviteTTL is how long an invite from /admin invite stays valid.
+	DefaultInviteTTL = 24 * time.Hour
+	// invitePrefix marks an invite token in a /start deep-link payload.
+	invitePrefix = "invite_"
+)
+
+// Invite is a single-use token adding its redeemer to the allowed users.
+type Invite struct {
+	CreatedBy int64     `json:"created_by"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+var (
+	errInviteUnknown = errors.New("unknown or already used invite")
+	errInviteExpired = errors.New("invite expired")
+)
+
+// applyInvitedUsers adds the users who redeemed an invite to allowed. It does
+// nothing without an allowlist, where everyone is allowed anyway.
+func applyInvitedUsers(allowed map[int64]bool, state BotState) {
+	if len(allowed) == 0 {
+		return
+	}
+	for _, id := range state.InvitedUsers {
+		allowed[id] = true
+	}
+}
+
+// startPayload returns the deep-link payload of a /start command; ok is false
+// for other messages.
+func startPayload(text string) (payload string, ok bool) {
+	if text == "/start" {
+		return "", true
+	}
+	if rest, found := strings.CutPrefix(text, "/start "); found {
+		return strings.TrimSpace(rest), true
+	}
+	return "", false
+}
+
+// createInvite stores a new invite valid for ttl and returns its token.
+// Expired invites are dropped at the same time.
+func (b *Bot) createInvite(adminID int64, ttl time.Duration, now time.Time) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := b.state
+	invites := make(map[string]Invite, len(state.Invites)+1)
+	for token, inv := range state.Invites {
+		if now.Before(inv.ExpiresAt) {
+			invites[token] = inv
+		}
+	}
+	token := newToken()
+	invites[token] = Invite{CreatedBy: adminID, ExpiresAt: now.Add(ttl)}
+	state.Invites = invites
+	if err := saveState(b.statePath(), state); err != nil {
+		return "", err
+	}
+	b.state = state
+	return token, nil
+}
+
+// redeemInvite consumes token and adds userID to the allowed users, persisting
+// both. It returns the invite so its creator can be told.
+func (b *Bot) redeemInvite(userID int64, token string, now time.Time) (Invite, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	inv, ok := b.state.Invites[token]
+	if !ok {
+		return Invite{}, errInviteUnknown
+	}
+
+	state := b.state
+	invites := make(map[string]Invite, len(state.Invites))
+	for t, i := range state.Invites {
+		if t != token {
+			invites[t] = i
+		}
+	}
+	state.Invites = invites
+	expired := !now.Before(inv.ExpiresAt)
+	if !expired {
+		state.InvitedUsers = append(removeID(state.InvitedUsers, userID), userID)
+	}
+	if err := saveState(b.statePath(), state); err != nil {
+		return Invite{}, err
+	}
+	b.state = state
+
+	if expired {
+		return Invite{}, errInviteExpired
+	}
+	b.addUser(userID)
+	return inv, nil
+}
+
+// forgetInvitedUser stops re-adding userID on startup after an admin removed
+// them from the allowed users.
+func (b *Bot) forgetInvitedUser(userID int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !slices.Contains(b.state.InvitedUsers, userID) {
+		return nil
+	}
+	state := b.state
+	state.InvitedUsers = removeID(state.InvitedUsers, userID)
+	if err := saveState(b.statePath(), state); err != nil {
+		return err
+	}
+	b.state = state
+	return nil
+}
+
+// handleStartCommand answers /start. A deep-link invite payload from a user
+// not yet allowed redeems the invite; anything else is the plain welcome.
+func (b *Bot) handleStartCommand(chatID, userID int64, username, payload string) {
+	token, isInvite := strings.CutPrefix(payload, invitePrefix)
+	if b.isUserAllowed(userID) {
+		b.sendWelcomeMessage(chatID)
+		return
+	}
+	if !isInvite || token == "" {
+		b.logUnauthorized(userID, username)
+		b.sendUnauthorizedMessage(chatID)
+		return
+	}
+
+	inv, err := b.redeemInvite(userID, token, time.Now())
+	switch {
+	case errors.Is(err, errInviteExpired):
+		b.sendTextMessage(chatID, "⌛ This invite has expired. Please ask an administrator for a new one.")
+		return
+	case errors.Is(err, errInviteUnknown):
+		b.sendTextMessage(chatID, "❌ This invite is not valid or has already been used.")
+		return
+	case err != nil:
+		log.Printf("Error redeeming invite for user %d: %v", userID, err)
+		b.sendTextMessage(chatID, "❌ Failed to accept the invite. Please try again later.")
+		return
+	}
+
+	log.Printf("User %d (%s) joined with an invite from admin %d", userID, username, inv.CreatedBy)
+	b.sendTextMessage(chatID, "🎉 Invite accepted, you can now use the bot.")
+	b.sendWelcomeMessage(chatID)
+	b.sendTextMessage(inv.CreatedBy, fmt.Sprintf("👋 User %d (@%s) joined with your invite.", userID, username))
+}
+
+// handleAdminInvite creates an invite link, valid for DefaultInviteTTL or the
+// given duration.
+func (b *Bot) handleAdminInvite(chatID, adminID int64, args []string) {
+	ttl := DefaultInviteTTL
+	if len(args) > 0 {
+		d, err := time.ParseDuration(args[0])
+		if err != nil || d <= 0 {
+			b.sendTextMessage(chatID, "Usage: /admin invite [validity, e.g. 48h]")
+			return
+		}
+		ttl = d
+	}
+	if len(b.allowedUserIDs()) == 0 {
+		b.sendTextMessage(chatID, "ℹ️ No user restrictions configured. All users can already access the bot.")
+		return
+	}
+
+	token, err := b.createInvite(adminID, ttl, time.Now())
+	if err != nil {
+		log.Printf("Error saving invite: %v", err)
+		b.sendTextMessage(chatID, "❌ Failed to create the invite.")
+		return
+	}
+
+	payload := invitePrefix + token
+	link := "/start " + payload
+	if b.api != nil && b.api.Self.UserName != "" {
+		link = fmt.Sprintf("https://t.me/%s?start=%s", b.api.Self.UserName, payload)
+	}
+	b.sendTextMessage(chatID, fmt.Sprintf("🎟 Single-use invite, valid until %s:\n%s", time.Now().Add(ttl).Format("2006-01-02 15:04"), link))
+	log.Printf("Admin %d created an invite valid for %s", adminID, ttl)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func startMessage(userID int64, text string) *tgbotapi.Message {
+	return &tgbotapi.Message{Text: text, From: &tgbotapi.User{ID: userID, UserName: "newbie"}, Chat: &tgbotapi.Chat{ID: userID}}
+}
+
+func TestStartPayload(t *testing.T) {
+	cases := []struct {
+		text    string
+		payload string
+		ok      bool
+	}{
+		{"/start", "", true},
+		{"/start invite_abc123", "invite_abc123", true},
+		{"/start  invite_abc123 ", "invite_abc123", true},
+		{"/starter", "", false},
+		{"hello", "", false},
+	}
+	for _, c := range cases {
+		if payload, ok := startPayload(c.text); payload != c.payload || ok != c.ok {
+			t.Errorf("startPayload(%q) = %q, %v; want %q, %v", c.text, payload, ok, c.payload, c.ok)
+		}
+	}
+}
+
+func TestInviteAddsUserOnce(t *testing.T) {
+	b := newTestBot(t)
+	b.allowedUsers[1] = true
+	b.adminUsers[1] = true
+	api := &mockTelegramAPI{}
+	b.client = api
+
+	b.handleAdminInvite(1, 1, nil)
+	texts := api.texts()
+	if len(texts) != 1 || !strings.Contains(texts[0], "/start "+invitePrefix) {
+		t.Fatalf("expected an invite, got %q", texts)
+	}
+	token := strings.TrimPrefix(texts[0][strings.LastIndex(texts[0], invitePrefix):], invitePrefix)
+
+	b.handleMessage(startMessage(42, "/start "+invitePrefix+token))
+	if !b.isUserAllowed(42) {
+		t.Fatal("expected the invited user to be allowed")
+	}
+	texts = api.texts()
+	if !strings.HasPrefix(texts[1], "🎉 Invite accepted") || !strings.Contains(texts[len(texts)-1], "User 42 (@newbie) joined with your invite") {
+		t.Errorf("unexpected messages %q", texts)
+	}
+
+	// Reused by someone else
+	b.handleMessage(startMessage(43, "/start "+invitePrefix+token))
+	if b.isUserAllowed(43) {
+		t.Error("expected a used invite to be rejected")
+	}
+	if texts := api.texts(); texts[len(texts)-1] != "❌ This invite is not valid or has already been used." {
+		t.Errorf("unexpected reply %q", texts[len(texts)-1])
+	}
+
+	// The invited user is re-added after a restart
+	state, err := loadState(b.statePath())
+	if err != nil || len(state.Invites) != 0 {
+		t.Fatalf("expected the invite to be consumed, got %+v (%v)", state.Invites, err)
+	}
+	allowed := map[int64]bool{1: true}
+	applyInvitedUsers(allowed, state)
+	if !allowed[42] {
+		t.Errorf("expected user 42 to be restored, got %v", allowed)
+	}
+
+	// Removing the user forgets the invite
+	b.handleAdminRemoveUser(1, "42")
+	if state, _ := loadState(b.statePath()); len(state.InvitedUsers) != 0 {
+		t.Errorf("expected no invited users after removal, got %v", state.InvitedUsers)
+	}
+}
+
+func TestExpiredInviteIsRejected(t *testing.T) {
+	b := newTestBot(t)
+	b.allowedUsers[1] = true
+	api := &mockTelegramAPI{}
+	b.client = api
+
+	token, err := b.createInvite(1, time.Hour, time.Now().Add(-2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.handleMessage(startMessage(42, "/start "+invitePrefix+token))
+
+	if b.isUserAllowed(42) {
+		t.Error("expected an expired invite not to add the user")
+	}
+	if texts := api.texts(); len(texts) != 1 || !strings.HasPrefix(texts[0], "⌛ This invite has expired") {
+		t.Errorf("unexpected replies %q", texts)
+	}
+	if len(b.state.Invites) != 0 {
+		t.Errorf("expected the expired invite to be dropped, got %+v", b.state.Invites)
+	}
+}
+
+func TestPlainStart(t *testing.T) {
+	b := newTestBot(t)
+	b.allowedUsers[1] = true
+	api := &mockTelegramAPI{}
+	b.client = api
+
+	b.handleMessage(startMessage(1, "/start"))
+	b.handleMessage(startMessage(42, "/start"))
+	b.handleMessage(startMessage(42, "/start promo"))
+
+	texts := api.texts()
+	if len(texts) != 3 || !strings.Contains(texts[0], "Welcome") ||
+		!strings.Contains(texts[1], "Access Denied") || !strings.Contains(texts[2], "Access Denied") {
+		t.Errorf("unexpected replies %q", texts)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// organizeDescriptions describe the subfolders of each ORGANIZE mode.
+var organizeDescriptions = map[organizeMode]string{
+	organizeDate:     "sorted into YYYY/MM/DD folders",
+	organizeType:     "sorted into a folder per media type",
+	organizeTypeDate: "sorted by media type, then YYYY/MM/DD",
+}
+
+// uploadLimit is the largest file of mediaType that can be stored: its
+// configured limit, capped by the public Bot API's download limit.
+func (b *Bot) uploadLimit(mediaType string) int64 {
+	limit, _ := b.sizeLimits.limit(mediaType)
+	if !b.localBotAPI {
+		limit = min(limit, PublicAPIDownloadLimit)
+	}
+	return limit
+}
+
+// formatUserLimits describes what userID may upload and where it goes,
+// from the same settings handleMessage and handleMedia enforce.
+func (b *Bot) formatUserLimits(userID int64) string {
+	var sb strings.Builder
+	sb.WriteString("📏 Your limits:\n")
+
+	// Types with a limit of their own are listed after the common one
+	common := b.uploadLimit("")
+	var own []string
+	for _, mediaType := range mediaTypes {
+		if _, ok := sizeLimitVars[mediaType]; !ok || !b.isMediaTypeAllowed(userID, mediaType) {
+			continue
+		}
+		if limit := b.uploadLimit(mediaType); limit != common {
+			own = append(own, fmt.Sprintf("%s %s", mediaType, formatLimit(limit)))
+		}
+	}
+	sb.WriteString(fmt.Sprintf("\n📦 Max file size: %s", formatLimit(common)))
+	if len(own) > 0 {
+		sb.WriteString(fmt.Sprintf(" (%s)", strings.Join(own, ", ")))
+	}
+	if !b.localBotAPI {
+		sb.WriteString(fmt.Sprintf("\n   Bots can download at most %s from Telegram.", formatLimit(PublicAPIDownloadLimit)))
+	}
+	if b.minFileSize > 0 {
+		sb.WriteString(fmt.Sprintf("\n🔻 Min file size: %s", formatSize(b.minFileSize)))
+	}
+	sb.WriteString(fmt.Sprintf("\n🗂 Media types: %s", b.describeAllowedTypes(userID)))
+
+	folder := "/" + b.userDir(userID)
+	if !b.perUserDirs {
+		folder += " (shared by all users)"
+	}
+	if desc, ok := organizeDescriptions[b.organize]; ok {
+		folder += ", " + desc
+	}
+	sb.WriteString("\n📁 Folder: " + folder)
+
+	if b.retention > 0 {
+		sb.WriteString(fmt.Sprintf("\n⏳ Files are deleted after %d days unless pinned.", int(b.retention.Hours()/24)))
+	}
+	if !b.isUserTrusted(userID) {
+		sb.WriteString("\n🕵️ Your uploads wait for an admin's approval.")
+	}
+	if b.isPaused() {
+		sb.WriteString("\n⏸ Uploads are paused for maintenance.")
+	}
+	return sb.String()
+}
+
+// handleLimitsCommand tells the user what they may upload.
+func (b *Bot) handleLimitsCommand(chatID, userID int64) {
+	b.sendTextMessage(chatID, b.formatUserLimits(userID))
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestLimitsOfRestrictedUser(t *testing.T) {
+	b, api := newReviewTestBot(t)
+	limits, err := parseSizeLimits(func(name string) string {
+		return map[string]string{"MAX_FILE_SIZE": "50MB", "MAX_PHOTO_SIZE": "5MB", "MAX_VIDEO_SIZE": "100MB"}[name]
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.sizeLimits = limits
+	b.minFileSize = 1024
+	b.perUserDirs = true
+	b.organize = organizeDate
+	b.retention = 30 * 24 * time.Hour
+	b.state.Paused = true
+	if err := b.users.Update(42, func(s *UserSettings) { s.AllowedTypes = []string{"documents", "photos", "locations"} }); err != nil {
+		t.Fatal(err)
+	}
+
+	b.handleMessage(&tgbotapi.Message{From: &tgbotapi.User{ID: 42}, Chat: &tgbotapi.Chat{ID: 42}, Text: "/limits"})
+
+	text := strings.Join(api.texts(), "\n")
+	for _, want := range []string{
+		"📦 Max file size: 20 MB (photos 5 MB)",
+		"Bots can download at most 20 MB from Telegram.",
+		"🔻 Min file size: 1.0 KB",
+		"🗂 Media types: documents, photos, locations",
+		"📁 Folder: /42, sorted into YYYY/MM/DD folders",
+		"⏳ Files are deleted after 30 days unless pinned.",
+		"🕵️ Your uploads wait for an admin's approval.",
+		"⏸ Uploads are paused for maintenance.",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected %q in:\n%s", want, text)
+		}
+	}
+	// Videos are not permitted, so their own limit is not shown
+	if strings.Contains(text, "videos") {
+		t.Errorf("expected no limit of a forbidden type, got:\n%s", text)
+	}
+
+	// With a local Bot API server the configured limits apply as they are
+	api.sent = nil
+	b.localBotAPI = true
+	b.handleLimitsCommand(42, 42)
+	if text := api.texts()[0]; !strings.Contains(text, "📦 Max file size: 50 MB (photos 5 MB)") || strings.Contains(text, "Bots can download") {
+		t.Errorf("unexpected limits with a local Bot API:\n%s", text)
+	}
+}
+
+func TestLimitsOfUnrestrictedUser(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+
+	b.handleLimitsCommand(42, 42)
+
+	text := api.texts()[0]
+	for _, want := range []string{"📦 Max file size: 20 MB", "🗂 Media types: all media types", "📁 Folder: / (shared by all users)"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected %q in:\n%s", want, text)
+		}
+	}
+	for _, unexpected := range []string{"Min file size", "deleted after", "approval", "paused"} {
+		if strings.Contains(text, unexpected) {
+			t.Errorf("expected no %q line in:\n%s", unexpected, text)
+		}
+	}
+}
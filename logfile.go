@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultLogLines is how many lines /admin logs shows without N.
+	DefaultLogLines = 50
+	// MaxLogLines caps N of /admin logs.
+	MaxLogLines = 200
+	// MaxLogTailBytes is how much of the end of LOG_FILE /admin logs reads.
+	MaxLogTailBytes = 256 << 10
+)
+
+// botTokenPattern matches Telegram bot tokens, e.g. in logged API URLs.
+var botTokenPattern = regexp.MustCompile(`\d{5,}:[A-Za-z0-9_-]{30,}`)
+
+// redactTokens hides every bot token in s.
+func redactTokens(s string) string {
+	return botTokenPattern.ReplaceAllString(s, "<redacted>")
+}
+
+// logFile is LOG_FILE, written to besides the console. When the file is
+// rotated away (renamed or deleted) it is reopened by path before the next
+// write; a truncated file is simply appended to.
+type logFile struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+}
+
+func openLogFile(path string) (*logFile, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open LOG_FILE: %w", err)
+	}
+	return &logFile{path: path, f: f}, nil
+}
+
+func (l *logFile) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rotated() {
+		if f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600); err == nil {
+			l.f.Close()
+			l.f = f
+		}
+	}
+	return l.f.Write(p)
+}
+
+// rotated reports whether the path no longer names the open file.
+func (l *logFile) rotated() bool {
+	current, err := os.Stat(l.path)
+	if err != nil {
+		return true
+	}
+	open, err := l.f.Stat()
+	return err != nil || !os.SameFile(current, open)
+}
+
+func (l *logFile) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
+
+// tailLog returns the last n lines of the log at path, reading at most
+// MaxLogTailBytes from its end. Bot tokens are redacted.
+func tailLog(path string, n int) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	offset := max(info.Size()-MaxLogTailBytes, 0)
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	// The file may have been truncated since, which only means less to read
+	data, err := io.ReadAll(io.LimitReader(f, MaxLogTailBytes))
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		// Skip the line cut by the offset
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			data = data[i+1:]
+		} else {
+			data = nil
+		}
+	}
+
+	text := strings.TrimRight(string(data), "\n")
+	if text == "" {
+		return nil, nil
+	}
+	lines := strings.Split(text, "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	for i, line := range lines {
+		lines[i] = redactTokens(line)
+	}
+	return lines, nil
+}
+
+func (b *Bot) handleAdminLogs(chatID int64, args []string) {
+	n := DefaultLogLines
+	if len(args) > 0 {
+		v, err := strconv.Atoi(args[0])
+		if err != nil || v <= 0 {
+			b.sendTextMessage(chatID, "Usage: /admin logs [N]")
+			return
+		}
+		n = min(v, MaxLogLines)
+	}
+
+	if b.logFile == "" {
+		b.sendTextMessage(chatID, "📜 LOG_FILE is not set, the log only goes to the console.")
+		return
+	}
+
+	lines, err := tailLog(b.logFile, n)
+	if err != nil {
+		log.Printf("Error reading log file: %v", err)
+		b.sendTextMessage(chatID, "❌ Failed to read the log file.")
+		return
+	}
+	if len(lines) == 0 {
+		b.sendTextMessage(chatID, "📜 The log file is empty.")
+		return
+	}
+
+	b.sendLongMessage(chatID, fmt.Sprintf("📜 Last %d log lines:\n\n%s", len(lines), strings.Join(lines, "\n")))
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestTailLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.log")
+	var sb strings.Builder
+	for i := 1; i <= 10; i++ {
+		fmt.Fprintf(&sb, "line %d\n", i)
+	}
+	sb.WriteString("GET https://api.telegram.org/bot123456789:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw/getMe failed\n")
+	if err := os.WriteFile(path, []byte(sb.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, err := tailLog(path, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"line 9", "line 10", "GET https://api.telegram.org/bot<redacted>/getMe failed"}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Errorf("got %q, want %q", lines, want)
+	}
+	if lines, err := tailLog(path, 100); err != nil || len(lines) != 11 || lines[0] != "line 1" {
+		t.Errorf("expected the whole file, got %q (%v)", lines, err)
+	}
+
+	// Only the end of a large file is read, without the line the cut falls in
+	big := strings.Repeat("x", MaxLogTailBytes) + "\nlast\n"
+	if err := os.WriteFile(path, []byte(big), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if lines, err := tailLog(path, 5); err != nil || len(lines) != 1 || lines[0] != "last" {
+		t.Errorf("expected only the last line, got %d lines (%v)", len(lines), err)
+	}
+
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	if lines, err := tailLog(path, 5); err != nil || len(lines) != 0 {
+		t.Errorf("expected nothing from a truncated log, got %q (%v)", lines, err)
+	}
+}
+
+func TestLogFileReopensAfterRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.log")
+	lf, err := openLogFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lf.Close()
+
+	fmt.Fprintln(lf, "before")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintln(lf, "after")
+
+	if data, err := os.ReadFile(path); err != nil || string(data) != "after\n" {
+		t.Errorf("expected the new file to get later lines, got %q (%v)", data, err)
+	}
+	if data, err := os.ReadFile(path + ".1"); err != nil || string(data) != "before\n" {
+		t.Errorf("expected the rotated file to keep earlier lines, got %q (%v)", data, err)
+	}
+}
+
+func TestAdminLogs(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+	b.adminUsers[9] = true
+	send := func(text string) string {
+		t.Helper()
+		b.handleAdminCommand(&tgbotapi.Message{From: &tgbotapi.User{ID: 9}, Chat: &tgbotapi.Chat{ID: 9}, Text: text}, 9, 9)
+		texts := api.texts()
+		return texts[len(texts)-1]
+	}
+
+	if reply := send("/admin logs"); !strings.Contains(reply, "LOG_FILE is not set") {
+		t.Errorf("unexpected reply without LOG_FILE %q", reply)
+	}
+
+	b.logFile = filepath.Join(t.TempDir(), "bot.log")
+	if err := os.WriteFile(b.logFile, []byte("one\ntwo\nthree\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if reply := send("/admin logs 2"); reply != "📜 Last 2 log lines:\n\ntwo\nthree" {
+		t.Errorf("unexpected tail %q", reply)
+	}
+	if reply := send("/admin logs 1000"); !strings.HasPrefix(reply, "📜 Last 3 log lines:") {
+		t.Errorf("unexpected capped tail %q", reply)
+	}
+	if reply := send("/admin logs -1"); reply != "Usage: /admin logs [N]" {
+		t.Errorf("unexpected reply to a bad count %q", reply)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UnauthorizedLogWindow is how long repeated unauthorized attempts from one
+// user collapse into a single log line.
+const UnauthorizedLogWindow = 5 * time.Minute
+
+// logThrottle collapses repeated log lines per key. The first line of a key is
+// logged immediately; repeats within the window are only counted and reported
+// in one line when it ends. A nil logThrottle logs every line.
+type logThrottle struct {
+	window time.Duration
+	logf   func(format string, args ...any)
+
+	mu      sync.Mutex
+	pending map[string]*throttledLine
+}
+
+type throttledLine struct {
+	last       string
+	suppressed int
+}
+
+func newLogThrottle(window time.Duration) *logThrottle {
+	return &logThrottle{window: window, logf: log.Printf, pending: make(map[string]*throttledLine)}
+}
+
+// Printf logs the formatted line unless key was logged within the window.
+func (t *logThrottle) Printf(key, format string, args ...any) {
+	if t == nil {
+		log.Printf(format, args...)
+		return
+	}
+	line := fmt.Sprintf(format, args...)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p, ok := t.pending[key]; ok {
+		p.last = line
+		p.suppressed++
+		return
+	}
+	t.pending[key] = &throttledLine{last: line}
+	t.logf("%s", line)
+	time.AfterFunc(t.window, func() { t.flush(key) })
+}
+
+// flush ends the window of key, reporting the lines it suppressed.
+func (t *logThrottle) flush(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p := t.pending[key]
+	delete(t.pending, key)
+	if p != nil && p.suppressed > 0 {
+		t.logf("%s ...and %d more in the last %s", p.last, p.suppressed, shortDuration(t.window))
+	}
+}
+
+// shortDuration formats d without trailing zero units ("5m", not "5m0s").
+func shortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
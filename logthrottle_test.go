@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// capturedLog collects the lines of a logThrottle.
+type capturedLog struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (c *capturedLog) logf(format string, args ...any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lines = append(c.lines, fmt.Sprintf(format, args...))
+}
+
+func (c *capturedLog) snapshot() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.lines...)
+}
+
+func newCapturedThrottle(window time.Duration) (*logThrottle, *capturedLog) {
+	c := &capturedLog{}
+	t := newLogThrottle(window)
+	t.logf = c.logf
+	return t, c
+}
+
+func TestLogThrottleCollapsesRepeats(t *testing.T) {
+	throttle, captured := newCapturedThrottle(100 * time.Millisecond)
+
+	for i := 0; i < 48; i++ {
+		throttle.Printf("5", "Unauthorized access attempt from user %d", 5)
+	}
+	throttle.Printf("6", "Unauthorized access attempt from user %d", 6)
+
+	lines := captured.snapshot()
+	want := []string{"Unauthorized access attempt from user 5", "Unauthorized access attempt from user 6"}
+	if len(lines) != 2 || lines[0] != want[0] || lines[1] != want[1] {
+		t.Fatalf("expected only the first attempt of each user, got %q", lines)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(captured.snapshot()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	lines = captured.snapshot()
+	if len(lines) != 3 || lines[2] != "Unauthorized access attempt from user 5 ...and 47 more in the last 100ms" {
+		t.Fatalf("expected one summary line for user 5, got %q", lines)
+	}
+
+	// A new window logs the next attempt immediately again
+	throttle.Printf("5", "Unauthorized access attempt from user %d", 5)
+	if lines := captured.snapshot(); len(lines) != 4 {
+		t.Errorf("expected the attempt after the window to be logged, got %q", lines)
+	}
+}
+
+func TestShortDuration(t *testing.T) {
+	cases := map[time.Duration]string{
+		5 * time.Minute:  "5m",
+		90 * time.Second: "1m30s",
+		time.Hour:        "1h",
+		30 * time.Second: "30s",
+	}
+	for d, want := range cases {
+		if got := shortDuration(d); got != want {
+			t.Errorf("shortDuration(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
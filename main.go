@@ -1,14 +1,24 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"path"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -16,9 +26,16 @@ import (
 )
 
 const (
-	DefaultStoragePath     = "./files"
-	MaxFileSize            = 50 * 1024 * 1024 // 50MB
-	StatusUpdateInterval   = 5 * time.Minute
+	DefaultStoragePath   = "./files"
+	MaxFileSize          = 50 * 1024 * 1024 // 50MB
+	StatusUpdateInterval = 5 * time.Minute
+	ListLimit            = 20
+	MaxRecentLimit       = 500
+	MaxNameSuffix        = 1000
+
+	// DefaultWelcomeMessage greets users added by /admin add unless
+	// WELCOME_MESSAGE is set
+	DefaultWelcomeMessage = "🎉 You now have access to this bot. Send me any file and I'll store it for you."
 )
 
 // Task represents a download task
@@ -31,26 +48,129 @@ type Task struct {
 	Username   string `json:"username"`
 	Additional struct {
 		Detail struct {
-			CompletedTime int64 `json:"completed_time"`
-			StartedTime   int64 `json:"started_time"`
+			CompletedTime int64  `json:"completed_time"`
+			StartedTime   int64  `json:"started_time"`
+			Destination   string `json:"destination"`
 		} `json:"detail"`
 		File []struct {
-			Name string `json:"name"`
+			Name string `json:"filename"`
 			Size int64  `json:"size"`
 		} `json:"file"`
 	} `json:"additional"`
 }
 
 type Bot struct {
-	api           *tgbotapi.BotAPI
-	storagePath   string
-	allowedUsers  map[int64]bool
-	adminUsers    map[int64]bool
-	statusService *StatusService
+	api               *tgbotapi.BotAPI
+	client            TelegramAPI
+	storagePath       string
+	storage           Storage
+	statusService     *StatusService
+	synology          SynologyTaskManager
+	synologyMount     string
+	index             *FileIndex
+	replyChecksum     bool
+	replyVerbosity    replyVerbosity
+	perUserDirs       bool
+	cipher            *fileCipher
+	users             *UserDB
+	httpClient        *http.Client
+	fetchClient       *http.Client
+	httpTimeout       time.Duration
+	auditLog          *AuditLog
+	auditPath         string
+	asciiSafeNames    bool
+	lowercaseNames    bool
+	transcoder        *transcoder
+	transcodeVoice    transcodeMode
+	recentUploads     *recentUploads
+	correctExtensions bool
+	compressExts      map[string]bool
+	rejectProtected   bool
+	storeDice         bool // STORE_DICE: keep dice and games as JSON
+	// storeChannelPosts (STORE_CHANNEL_POSTS) stores media posted in channels
+	// the bot is an admin of; channels holds the IDs seen, stored under
+	// channel_<id>
+	storeChannelPosts bool
+	channels          sync.Map
+	organize          organizeMode
+	textMode          textMessageMode
+	savedReplies      *savedReplies
+	savedUploads      *savedReplies // media messages by ID, for caption edits
+	quiet             *quietSummaries
+	allowedChats      map[int64]bool // empty allows every chat
+	getMaxFiles       int
+	throughput        *throughputHistogram
+	appendDuplicates  bool
+	appendMu          sync.Mutex // serializes APPEND_ON_DUPLICATE writes
+	// dedupHardlink (DEDUP_HARDLINK) makes stored files hardlinks into the
+	// content store, with the links counted in the index
+	dedupHardlink     bool
+	contentMu         sync.Mutex // serializes content store links and unlinks
+	fsckRunning       atomic.Bool
+	recompress        *recompressSettings // nil unless RECOMPRESS_IMAGES
+	localBotAPI       bool
+	recentErrors      *errorRing
+	logFile           string // LOG_FILE, read by /admin logs
+	postSaveHook      *postSaveHook
+	minFileSize       int64
+	sizeLimits        sizeLimits
+	retention         time.Duration
+	retentionInterval time.Duration
+	// lastSnapshot is the date of the last storage snapshot; only the
+	// janitor uses it
+	lastSnapshot string
+
+	// gallery serves the pages of /gallery links; nil without HTTP_ADDR
+	gallery *galleryServer
+
+	// storageFullAlerted is set once admins were told the storage is full,
+	// until a save succeeds again
+	storageFullAlerted atomic.Bool
+	// diskWarnPercent (DISK_WARN_PERCENT, 0 = off) is the disk usage admins
+	// are warned at; diskWarned is set until usage drops below it again
+	diskWarnPercent int
+	diskWarned      atomic.Bool
+	statfs          func(path string) (diskStats, error)
+	// unauthorizedLog collapses repeated unauthorized attempts per user
+	unauthorizedLog *logThrottle
+
+	// hideAdmins (HIDE_ADMINS) keeps /admins from regular users; adminNames
+	// caches the names it resolves
+	hideAdmins bool
+	adminNames *adminNameCache
+	// welcomeMessage (WELCOME_MESSAGE) greets users added by /admin add
+	welcomeMessage string
+
+	// usersMu guards allowedUsers and adminUsers, which admin commands change
+	// while other handlers read them
+	usersMu      sync.RWMutex
+	allowedUsers map[int64]bool
+	adminUsers   map[int64]bool
+
+	// trustedUsers (TRUSTED_USERS, fixed at startup) upload directly; other
+	// allowed users' uploads wait in pending for admin review
+	trustedUsers map[int64]bool
+	pending      *PendingStore
+
+	mu             sync.Mutex
+	state          BotState
+	pendingRenames map[string]renameRequest
 }
 
-func NewBot(token, storagePath string, allowedUsers, adminUsers []int64) (*Bot, error) {
-	bot, err := tgbotapi.NewBotAPI(token)
+// NewBot creates the bot described by cfg. All other settings are read
+// through cfg.Env, so bots in one process can be configured apart.
+func NewBot(cfg botConfig) (*Bot, error) {
+	token, storagePath, env := cfg.Token, cfg.StoragePath, cfg.Env
+
+	// A self-hosted Bot API server lifts the public API's download limit
+	apiURL := strings.TrimRight(env("TELEGRAM_API_URL"), "/")
+	var bot *tgbotapi.BotAPI
+	var err error
+	if apiURL != "" {
+		bot, err = tgbotapi.NewBotAPIWithAPIEndpoint(token, apiURL+"/bot%s/%s")
+	} else {
+		bot, err = tgbotapi.NewBotAPI(token)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create bot: %w", err)
 	}
@@ -59,276 +179,831 @@ func NewBot(token, storagePath string, allowedUsers, adminUsers []int64) (*Bot,
 	if err := os.MkdirAll(storagePath, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create storage directory: %w", err)
 	}
+	if err := checkWritable(storagePath); err != nil {
+		return nil, err
+	}
 
 	// Convert slices to maps for faster lookups
 	userMap := make(map[int64]bool)
-	for _, userID := range allowedUsers {
+	for _, userID := range cfg.AllowedUsers {
 		userMap[userID] = true
 	}
 
 	adminMap := make(map[int64]bool)
-	for _, userID := range adminUsers {
+	for _, userID := range cfg.AdminUsers {
 		adminMap[userID] = true
 	}
 
 	// Build Synology client from environment
-	host := os.Getenv("SYNOLOGY_HOST")
+	host := env("SYNOLOGY_HOST")
 	if host == "" {
 		host = "192.168.1.34"
 	}
 
-	port := os.Getenv("SYNOLOGY_PORT")
+	port := env("SYNOLOGY_PORT")
 	if port == "" {
 		port = "5000"
 	}
 
-	username := os.Getenv("SYNOLOGY_USERNAME")
+	username := env("SYNOLOGY_USERNAME")
 	if username == "" {
-		log.Fatal("SYNOLOGY_USERNAME environment variable is required")
+		return nil, fmt.Errorf("SYNOLOGY_USERNAME environment variable is required")
 	}
 
-	password := os.Getenv("SYNOLOGY_PASSWORD")
+	password := env("SYNOLOGY_PASSWORD")
 	if password == "" {
-		log.Fatal("SYNOLOGY_PASSWORD environment variable is required")
+		return nil, fmt.Errorf("SYNOLOGY_PASSWORD environment variable is required")
+	}
+
+	// Only needed for accounts with 2-step verification
+	otp := env("SYNOLOGY_OTP")
+
+	var api TelegramAPI = bot
+	if apiURL != "" {
+		api = localBotAPI{BotAPI: bot, baseURL: apiURL}
+		log.Printf("Using the Bot API server at %s", apiURL)
+	}
+	sendConcurrency, err := parseSendConcurrency(env("SEND_CONCURRENCY"))
+	if err != nil {
+		return nil, err
+	}
+	client := newRetryClient(api, sendConcurrency)
+	synClient := NewSynologyHTTPClient(host, port, username, password, otp)
+	pollInterval, err := env.duration("SYNOLOGY_POLL_INTERVAL", StatusUpdateInterval)
+	if err != nil {
+		return nil, err
+	}
+	statusSvc := NewStatusService(synClient, adminMap, client, pollInterval)
+	if err := statusSvc.PersistStatuses(filepath.Join(storagePath, statusFileName)); err != nil {
+		return nil, err
+	}
+
+	storage, err := newStorageBackend(env, storagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Enable encryption at rest when a key is configured
+	var fc *fileCipher
+	if encoded := env("ENCRYPTION_KEY"); encoded != "" {
+		key, err := parseEncryptionKey(encoded)
+		if err != nil {
+			return nil, err
+		}
+		if fc, err = newFileCipher(key); err != nil {
+			return nil, err
+		}
+		log.Printf("Encryption at rest enabled")
+	}
+
+	httpTimeout, err := env.duration("HTTP_TIMEOUT", DefaultHTTPTimeout)
+	if err != nil {
+		return nil, err
+	}
+	httpClient, err := newHTTPClient(httpTimeout, env("DOWNLOAD_PROXY"))
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := OpenUserDB(filepath.Join(storagePath, userDBName))
+	if err != nil {
+		return nil, err
 	}
 
-	synClient := NewSynologyHTTPClient(host, port, username, password)
-	statusSvc := NewStatusService(synClient, adminMap, bot, StatusUpdateInterval)
+	state, err := loadState(filepath.Join(storagePath, stateFileName))
+	if err != nil {
+		return nil, err
+	}
+	if state.Paused {
+		log.Printf("Uploads are paused (resume with /admin resume)")
+	}
+	if len(state.PromotedAdmins) > 0 || len(state.DemotedAdmins) > 0 {
+		applyAdminChanges(adminMap, state)
+		log.Printf("Applied persisted admin changes: %d admin users", len(adminMap))
+	}
+	applyInvitedUsers(userMap, state)
+
+	// Open the optional audit log
+	auditPath := env("AUDIT_LOG")
+	var auditLog *AuditLog
+	if auditPath != "" {
+		if auditLog, err = OpenAuditLog(auditPath); err != nil {
+			return nil, err
+		}
+		log.Printf("Audit log enabled: %s", auditPath)
+	}
+
+	// Only serve the chats in ALLOWED_CHATS when it is set
+	allowedChats := make(map[int64]bool)
+	for _, chatID := range parseAllowedUsers(env("ALLOWED_CHATS")) {
+		allowedChats[chatID] = true
+	}
+	if len(allowedChats) > 0 {
+		log.Printf("Restricted to %d allowed chats", len(allowedChats))
+	}
+
+	// Hold back uploads of users outside TRUSTED_USERS for review
+	trustedMap := make(map[int64]bool)
+	for _, userID := range parseAllowedUsers(env("TRUSTED_USERS")) {
+		trustedMap[userID] = true
+	}
+	var pending *PendingStore
+	if len(trustedMap) > 0 {
+		if pending, err = OpenPendingStore(filepath.Join(storagePath, pendingFileName)); err != nil {
+			return nil, err
+		}
+		log.Printf("Uploads of %d trusted users are stored directly, others await review (%d pending)", len(trustedMap), pending.Len())
+	}
+
+	minFileSize, err := parseMinFileSize(env("MIN_FILE_SIZE"))
+	if err != nil {
+		return nil, err
+	}
+	sizeLimits, err := parseSizeLimits(env)
+	if err != nil {
+		return nil, err
+	}
+	replyVerbosity, err := parseReplyVerbosity(env("REPLY_VERBOSITY"))
+	if err != nil {
+		return nil, err
+	}
+	transcodeVoice, err := parseTranscodeMode(env("TRANSCODE_VOICE"))
+	if err != nil {
+		return nil, err
+	}
+	organize, err := parseOrganizeMode(env("ORGANIZE"))
+	if err != nil {
+		return nil, err
+	}
+	var gallery *galleryServer
+	if addr := env("HTTP_ADDR"); addr != "" {
+		ttl, err := env.duration("GALLERY_TTL", DefaultGalleryTTL)
+		if err != nil {
+			return nil, err
+		}
+		if gallery, err = newGalleryServer(addr, env("PUBLIC_URL"), env("GALLERY_SECRET"), ttl); err != nil {
+			return nil, err
+		}
+	}
+	var recompress *recompressSettings
+	if env.bool("RECOMPRESS_IMAGES") {
+		quality, err := parseRecompressQuality(env("RECOMPRESS_QUALITY"))
+		if err != nil {
+			return nil, err
+		}
+		maxDimension, err := parseRecompressMaxDimension(env("RECOMPRESS_MAX_DIMENSION"))
+		if err != nil {
+			return nil, err
+		}
+		recompress = &recompressSettings{Quality: quality, MaxDimension: maxDimension}
+	}
+	textMode, err := parseTextMessageMode(env("TEXT_MESSAGE_MODE"))
+	if err != nil {
+		return nil, err
+	}
+	getMaxFiles, err := parseGetMaxFiles(env("GET_MAX_FILES"))
+	if err != nil {
+		return nil, err
+	}
+	// Reject re-sends of the same file within a short window when enabled
+	var recent *recentUploads
+	if env.bool("DUPLICATE_CHECK") {
+		window, err := env.duration("DUPLICATE_WINDOW", DefaultDuplicateWindow)
+		if err != nil {
+			return nil, err
+		}
+		recent = newRecentUploads(window)
+	}
+
+	errorBufferSize, err := parseErrorBufferSize(env("ERROR_BUFFER_SIZE"))
+	if err != nil {
+		return nil, err
+	}
+	var recentErrors *errorRing
+	if errorBufferSize > 0 {
+		recentErrors = newErrorRing(errorBufferSize)
+	}
+
+	// Run an external program after each save when configured
+	var saveHook *postSaveHook
+	if hookPath := env("POST_SAVE_HOOK"); hookPath != "" {
+		saveHook, err = newPostSaveHook(hookPath)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Post-save hook enabled: %s", hookPath)
+	}
+
+	retention, err := parseRetentionDays(env("RETENTION_DAYS"))
+	if err != nil {
+		return nil, err
+	}
+	retentionInterval, err := env.duration("RETENTION_INTERVAL", DefaultRetentionInterval)
+	if err != nil {
+		return nil, err
+	}
+	diskWarnPercent, err := parseDiskWarnPercent(env("DISK_WARN_PERCENT"))
+	if err != nil {
+		return nil, err
+	}
+
+	transcoder := newTranscoder()
+	if transcodeVoice != transcodeOff && !transcoder.available() {
+		log.Printf("TRANSCODE_VOICE is set but ffmpeg is missing; voice messages are kept as OGG only")
+	}
+
+	// Open the optional file index
+	var index *FileIndex
+	if indexPath := env("INDEX_DB"); indexPath != "" {
+		index, err = OpenFileIndex(indexPath)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("File index enabled: %s", indexPath)
+	}
+	dedupHardlink := env.bool("DEDUP_HARDLINK")
+	if _, local := storage.(*LocalStorage); dedupHardlink && (index == nil || !local || fc != nil) {
+		log.Printf("DEDUP_HARDLINK needs INDEX_DB, local storage and no ENCRYPTION_KEY; storing files without deduplication")
+		dedupHardlink = false
+	}
 
 	return &Bot{
-		api:           bot,
-		storagePath:   storagePath,
-		allowedUsers:  userMap,
-		adminUsers:    adminMap,
-		statusService: statusSvc,
+		api:               bot,
+		client:            client,
+		storagePath:       storagePath,
+		storage:           storage,
+		allowedUsers:      userMap,
+		adminUsers:        adminMap,
+		trustedUsers:      trustedMap,
+		pending:           pending,
+		statusService:     statusSvc,
+		synology:          synClient,
+		synologyMount:     env("SYNOLOGY_MOUNT"),
+		index:             index,
+		replyChecksum:     env.bool("REPLY_CHECKSUM"),
+		replyVerbosity:    replyVerbosity,
+		perUserDirs:       env.bool("PER_USER_DIRS"),
+		cipher:            fc,
+		users:             users,
+		httpClient:        httpClient,
+		fetchClient:       newFetchClient(httpTimeout, env.bool("ALLOW_PRIVATE_FETCH")),
+		httpTimeout:       httpTimeout,
+		unauthorizedLog:   newLogThrottle(UnauthorizedLogWindow),
+		auditLog:          auditLog,
+		auditPath:         auditPath,
+		asciiSafeNames:    env.bool("ASCII_SAFE_NAMES"),
+		lowercaseNames:    env.bool("LOWERCASE_NAMES"),
+		transcoder:        transcoder,
+		transcodeVoice:    transcodeVoice,
+		recentUploads:     recent,
+		correctExtensions: env.bool("CORRECT_EXTENSIONS"),
+		compressExts:      parseCompressExtensions(env("COMPRESS_EXTENSIONS")),
+		rejectProtected:   env.bool("REJECT_PROTECTED"),
+		storeDice:         env.bool("STORE_DICE"),
+		storeChannelPosts: env.bool("STORE_CHANNEL_POSTS"),
+		hideAdmins:        env.bool("HIDE_ADMINS"),
+		welcomeMessage:    env("WELCOME_MESSAGE"),
+		adminNames:        newAdminNameCache(AdminNameCacheTTL),
+		appendDuplicates:  env.bool("APPEND_ON_DUPLICATE"),
+		dedupHardlink:     dedupHardlink,
+		recompress:        recompress,
+		organize:          organize,
+		textMode:          textMode,
+		gallery:           gallery,
+		savedReplies:      newSavedReplies(MaxTrackedReplies),
+		savedUploads:      newSavedReplies(MaxTrackedReplies),
+		quiet:             newQuietSummaries(QuietSummaryDelay),
+		allowedChats:      allowedChats,
+		getMaxFiles:       getMaxFiles,
+		throughput:        newThroughputHistogram(),
+		localBotAPI:       apiURL != "",
+		postSaveHook:      saveHook,
+		recentErrors:      recentErrors,
+		logFile:           env("LOG_FILE"),
+		minFileSize:       minFileSize,
+		sizeLimits:        sizeLimits,
+		retention:         retention,
+		retentionInterval: retentionInterval,
+		diskWarnPercent:   diskWarnPercent,
+		statfs:            statDisk,
+		state:             state,
 	}, nil
 }
 
-func (b *Bot) Start() {
+// storageTypeRoots reads the per-media-type storage roots (STORAGE_PHOTOS,
+// STORAGE_VIDEOS, ...). Types without a variable use STORAGE_PATH.
+func storageTypeRoots(env envLookup) map[string]string {
+	roots := make(map[string]string)
+	for _, mediaType := range mediaTypes {
+		if root := env("STORAGE_" + strings.ToUpper(mediaType)); root != "" {
+			roots[mediaType] = root
+		}
+	}
+	return roots
+}
+
+// parseChatStorageMap parses CHAT_STORAGE_MAP, comma-separated
+// <chat_id>:<path> pairs routing the files of a chat to their own root.
+func parseChatStorageMap(s string) (map[int64]string, error) {
+	roots := make(map[int64]string)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, root, ok := strings.Cut(entry, ":")
+		chatID, err := strconv.ParseInt(strings.TrimSpace(id), 10, 64)
+		root = strings.TrimSpace(root)
+		if !ok || err != nil || root == "" {
+			return nil, fmt.Errorf("invalid CHAT_STORAGE_MAP entry %q, expected <chat_id>:<path>", entry)
+		}
+		if _, dup := roots[chatID]; dup {
+			return nil, fmt.Errorf("CHAT_STORAGE_MAP maps chat %d twice", chatID)
+		}
+		roots[chatID] = filepath.Clean(root)
+	}
+	return roots, nil
+}
+
+// newStorageBackend builds the Storage selected by STORAGE_BACKEND.
+// The local storage path is always used for metadata and the index.
+func newStorageBackend(env envLookup, storagePath string) (Storage, error) {
+	switch backend := env("STORAGE_BACKEND"); backend {
+	case "", "local":
+		typeRoots := storageTypeRoots(env)
+		chatRoots, err := parseChatStorageMap(env("CHAT_STORAGE_MAP"))
+		if err != nil {
+			return nil, err
+		}
+		if len(typeRoots) == 0 && len(chatRoots) == 0 {
+			return NewLocalStorage(storagePath), nil
+		}
+		for mediaType, root := range typeRoots {
+			if err := os.MkdirAll(root, 0755); err != nil {
+				return nil, fmt.Errorf("failed to create storage directory for %s: %w", mediaType, err)
+			}
+			if err := checkWritable(root); err != nil {
+				return nil, fmt.Errorf("invalid storage root for %s: %w", mediaType, err)
+			}
+			log.Printf("Storing %s in %s", mediaType, root)
+		}
+		for chatID, root := range chatRoots {
+			if err := os.MkdirAll(root, 0755); err != nil {
+				return nil, fmt.Errorf("failed to create storage directory for chat %d: %w", chatID, err)
+			}
+			if err := checkWritable(root); err != nil {
+				return nil, fmt.Errorf("invalid storage root for chat %d: %w", chatID, err)
+			}
+			log.Printf("Storing files from chat %d in %s", chatID, root)
+		}
+		return NewRoutedLocalStorage(storagePath, typeRoots, chatRoots), nil
+	case "webdav":
+		if env("CHAT_STORAGE_MAP") != "" {
+			return nil, fmt.Errorf("CHAT_STORAGE_MAP is only supported by the local storage backend")
+		}
+		webdavURL := env("WEBDAV_URL")
+		if webdavURL == "" {
+			return nil, fmt.Errorf("WEBDAV_URL is required for the webdav storage backend")
+		}
+		log.Printf("Using WebDAV storage backend: %s", webdavURL)
+		return NewWebDAVStorage(webdavURL, env("WEBDAV_USERNAME"), env("WEBDAV_PASSWORD")), nil
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q", backend)
+	}
+}
+
+// Start processes updates until ctx is cancelled.
+func (b *Bot) Start(ctx context.Context) {
 	b.api.Debug = false
 
 	log.Printf("Authorized on account %s", b.api.Self.UserName)
+	b.registerCommands()
 
 	// Start the status monitoring service
 	if b.statusService != nil {
 		b.statusService.Start()
 	}
 
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
+	updates := b.pollUpdates(ctx)
 
-	updates := b.api.GetUpdatesChan(u)
+	if b.gallery != nil {
+		go b.serveGallery(ctx)
+	}
 
-	for update := range updates {
-		if update.Message != nil {
-			b.handleMessage(update.Message)
+	// Delete files past RETENTION_DAYS or /expire, snapshot storage and watch
+	// free space in the background
+	if _, ok := b.storage.(*LocalStorage); ok {
+		if b.retention > 0 {
+			log.Printf("Deleting files older than %s (checked every %s)", b.retention, b.retentionInterval)
 		}
+		go b.runJanitor(ctx, b.retentionInterval)
+	} else if b.retention > 0 {
+		log.Printf("Warning: RETENTION_DAYS is only supported with local storage")
 	}
-}
 
-func (b *Bot) handleMessage(message *tgbotapi.Message) {
-	chatID := message.Chat.ID
-	userID := message.From.ID
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+			switch {
+			case update.Message != nil:
+				b.handleMessage(update.Message)
+			case update.ChannelPost != nil:
+				b.handleChannelPost(update.ChannelPost)
+			case update.EditedMessage != nil:
+				b.handleEditedMessage(update.EditedMessage)
+			case update.CallbackQuery != nil:
+				b.handleCallbackQuery(update.CallbackQuery)
+			case update.InlineQuery != nil:
+				b.handleInlineQuery(update.InlineQuery)
+			case update.MessageReaction != nil:
+				b.handleMessageReaction(update.MessageReaction)
+			}
+		}
+	}
+}
 
-	// Check if user is authorized
-	if !b.isUserAllowed(userID) {
-		log.Printf("Unauthorized access attempt from user %d (%s)", userID, message.From.UserName)
-		b.sendUnauthorizedMessage(chatID)
+// handleCallbackQuery dispatches inline keyboard button presses.
+func (b *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
+	if !b.isUserAllowed(query.From.ID) {
+		b.request(tgbotapi.NewCallback(query.ID, "Access denied"))
 		return
 	}
 
-	// Handle different types of content
+	var reply string
+	parts := strings.SplitN(query.Data, ":", 3)
 	switch {
-	case message.Document != nil:
-		b.handleDocument(message.Document, chatID, message.MessageID)
-	case message.Photo != nil && len(message.Photo) > 0:
-		// Get the largest photo
-		photo := message.Photo[len(message.Photo)-1]
-		b.handlePhoto(&photo, chatID, message.MessageID)
-	case message.Video != nil:
-		b.handleVideo(message.Video, chatID, message.MessageID)
-	case message.Audio != nil:
-		b.handleAudio(message.Audio, chatID, message.MessageID)
-	case message.Voice != nil:
-		b.handleVoice(message.Voice, chatID, message.MessageID)
-	case message.VideoNote != nil:
-		b.handleVideoNote(message.VideoNote, chatID, message.MessageID)
-	case message.Sticker != nil:
-		b.handleSticker(message.Sticker, chatID, message.MessageID)
-	case message.Text == "/start":
-		b.sendWelcomeMessage(chatID)
-	case message.Text == "/help":
-		b.sendHelpMessage(chatID)
-	case message.Text == "/id":
-		b.sendUserIDMessage(chatID, userID, message.From)
-	case message.Text == "/status":
-		b.handleStatusCommand(chatID)
-	case strings.HasPrefix(message.Text, "/admin"):
-		b.handleAdminCommand(message, chatID, userID)
-	case message.Text != "":
-		b.sendTextMessage(chatID, "Please send me a file, photo, video, or audio to store.")
+	case len(parts) == 3 && parts[0] == "rename":
+		reply = b.handleRenameCallback(query.From.ID, parts[1], parts[2])
+	case len(parts) == 3 && parts[0] == "review":
+		reply = b.handleReviewCallback(query.From.ID, parts[1], parts[2])
 	default:
-		b.sendTextMessage(chatID, "Unsupported message type. Please send me a file.")
-	}
-}
-
-func (b *Bot) handleDocument(document *tgbotapi.Document, chatID int64, messageID int) {
-	if document.FileSize > MaxFileSize {
-		b.sendTextMessage(chatID, fmt.Sprintf("File too large. Maximum size is %d MB", MaxFileSize/(1024*1024)))
-		return
+		reply = "Unknown action"
 	}
 
-	fileName := document.FileName
-	if fileName == "" {
-		fileName = fmt.Sprintf("document_%d_%s", time.Now().Unix(), document.FileID)
+	if _, err := b.request(tgbotapi.NewCallback(query.ID, "")); err != nil {
+		log.Printf("Failed to answer callback: %v", err)
 	}
 
-	if err := b.downloadAndSave(document.FileID, fileName, chatID); err != nil {
-		log.Printf("Error handling document: %v", err)
-		b.sendTextMessage(chatID, "Failed to save the document.")
-		return
+	if query.Message != nil {
+		edit := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, reply)
+		if _, err := b.send(edit); err != nil {
+			log.Printf("Failed to edit message: %v", err)
+		}
 	}
-
-	// Force status update when file is received
-	b.forceStatusUpdate(chatID)
-
-	b.sendTextMessage(chatID, fmt.Sprintf("✅ '%s'", fileName))
 }
 
-func (b *Bot) handlePhoto(photo *tgbotapi.PhotoSize, chatID int64, messageID int) {
-	fileName := fmt.Sprintf("photo_%d_%s.jpg", time.Now().Unix(), photo.FileID)
+func (b *Bot) handleMessage(message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	userID := message.From.ID
 
-	if err := b.downloadAndSave(photo.FileID, fileName, chatID); err != nil {
-		log.Printf("Error handling photo: %v", err)
-		b.sendTextMessage(chatID, "Failed to save the photo.")
+	if !b.isChatAllowed(chatID) {
+		b.logDisallowedChat(chatID, userID)
+		b.sendTextMessage(chatID, "🚫 This bot is not enabled for this chat.")
 		return
 	}
 
-	// Force status update when file is received
-	b.forceStatusUpdate(chatID)
+	// /start may carry an invite for users not yet allowed
+	if payload, ok := startPayload(message.Text); ok {
+		b.handleStartCommand(chatID, userID, message.From.UserName, payload)
+		return
+	}
 
-	b.sendTextMessage(chatID, fmt.Sprintf("✅ Photo '%s' saved successfully!", fileName))
-}
+	// Check if user is authorized
+	if !b.isUserAllowed(userID) {
+		b.logUnauthorized(userID, message.From.UserName)
+		b.sendUnauthorizedMessage(chatID)
+		return
+	}
 
-func (b *Bot) handleVideo(video *tgbotapi.Video, chatID int64, messageID int) {
-	if video.FileSize > MaxFileSize {
-		b.sendTextMessage(chatID, fmt.Sprintf("Video too large. Maximum size is %d MB", MaxFileSize/(1024*1024)))
+	mediaType := messageMediaType(message)
+	if mediaType != "" && b.isPaused() {
+		b.sendTextMessage(chatID, "⏸ Uploads are temporarily unavailable due to maintenance. Please try again later.")
 		return
 	}
 
-	fileName := fmt.Sprintf("video_%d_%s.mp4", time.Now().Unix(), video.FileID)
+	// Enforce per-user media type restrictions before storing anything
+	if mediaType != "" && !b.isMediaTypeAllowed(userID, mediaType) {
+		log.Printf("User %d is not allowed to send %s", userID, mediaType)
+		b.sendTextMessage(chatID, fmt.Sprintf("🚫 You are not allowed to send %s. Permitted: %s", mediaType, b.describeAllowedTypes(userID)))
+		return
+	}
 
-	if err := b.downloadAndSave(video.FileID, fileName, chatID); err != nil {
-		log.Printf("Error handling video: %v", err)
-		b.sendTextMessage(chatID, "Failed to save the video.")
+	// Ignore accidental double sends of the same file
+	if mediaType != "" && b.recentUploads.Seen(userID, messageFileUniqueID(message)) {
+		log.Printf("Ignoring duplicate %s from user %d", mediaType, userID)
+		b.sendTextMessage(chatID, "♻️ Looks like a duplicate, already saved.")
 		return
 	}
 
-	// Force status update when file is received
-	b.forceStatusUpdate(chatID)
+	if cmd, args, ok := parseUserCommand(message.Text); ok {
+		cmd.Run(b, commandCall{Message: message, ChatID: chatID, UserID: userID, Args: args})
+		return
+	}
 
-	b.sendTextMessage(chatID, fmt.Sprintf("✅ Video '%s' saved successfully!", fileName))
+	// Handle different types of content
+	media := messageMedia(message)
+	switch {
+	case media != nil:
+		b.handleMedia(*media, chatID, userID)
+	case message.Location != nil:
+		b.handleLocation(message, chatID, userID)
+	case message.Contact != nil:
+		b.handleContact(message.Contact, chatID, userID)
+	case message.Poll != nil:
+		b.handlePoll(message.Poll, chatID, userID)
+	case isMessageURL(message.Text):
+		b.handleURLMessage(chatID, userID, message.Text)
+	case message.Text != "":
+		b.handleTextMessage(chatID, userID, message.Text)
+	case ignoredMessageType(message) != "":
+		b.handleIgnoredMessage(message, ignoredMessageType(message), chatID, userID)
+	default:
+		b.sendTextMessage(chatID, "Unsupported message type. Please send me a file.")
+	}
 }
 
-func (b *Bot) handleAudio(audio *tgbotapi.Audio, chatID int64, messageID int) {
-	if audio.FileSize > MaxFileSize {
-		b.sendTextMessage(chatID, fmt.Sprintf("Audio too large. Maximum size is %d MB", MaxFileSize/(1024*1024)))
-		return
+// downloadAndSave fetches the Telegram file of media received in chatID and
+// stores it for owner as fileName (or a suffixed variant if the name is
+// taken). It returns the stored file's metadata.
+// Downloads below MIN_FILE_SIZE are not kept and yield a *tooSmallError;
+// other failures with a known cause are returned as a *BotError.
+func (b *Bot) downloadAndSave(media mediaFile, fileName string, chatID, owner int64) (FileMeta, error) {
+	// Get the download URL from Telegram
+	fileURL, err := b.client.GetFileDirectURL(media.FileID)
+	if err != nil {
+		return FileMeta{}, classifyError(fmt.Errorf("failed to get file info: %w", err))
 	}
 
-	fileName := audio.FileName
-	if fileName == "" {
-		fileName = fmt.Sprintf("audio_%d_%s.mp3", time.Now().Unix(), audio.FileID)
+	// Download file from Telegram. A transfer that breaks off is resumed if the
+	// server supports ranges, else started over
+	start := time.Now()
+	var saved FileMeta
+	for attempt := 0; ; attempt++ {
+		saved, err = b.downloadOnce(fileURL, media, fileName, chatID, owner)
+		var broken *downloadError
+		if !errors.As(err, &broken) || broken.Ranges || attempt == MaxDownloadRestarts {
+			break
+		}
+		log.Printf("Download of %s broke off after %d bytes and cannot be resumed, starting over", fileName, broken.Offset)
 	}
+	if err != nil {
+		return saved, err
+	}
+	elapsed := time.Since(start)
+	mbps := throughputMBps(saved.Size, elapsed)
+	log.Printf("Downloaded %s: %d bytes in %s (%.2f MB/s)", saved.Path, saved.Size, elapsed.Round(time.Millisecond), mbps)
+	b.throughput.Observe(mbps)
+	return saved, nil
+}
 
-	if err := b.downloadAndSave(audio.FileID, fileName, chatID); err != nil {
-		log.Printf("Error handling audio: %v", err)
-		b.sendTextMessage(chatID, "Failed to save the audio.")
-		return
+// downloadOnce downloads fileURL into storage, recompressing images if
+// configured, and returns the stored file.
+func (b *Bot) downloadOnce(fileURL string, media mediaFile, fileName string, chatID, owner int64) (FileMeta, error) {
+	body, err := b.download(fileURL)
+	if err != nil {
+		return FileMeta{}, newBotError(CategoryNetwork, err)
 	}
+	defer body.Close()
 
-	// Force status update when file is received
-	b.forceStatusUpdate(chatID)
+	var content io.Reader = body
+	var originalSize int64
+	if b.recompresses(media.MediaType, media.MIME) {
+		if content, originalSize, err = b.recompressUpload(body, fileName); err != nil {
+			return FileMeta{}, newBotError(CategoryNetwork, err)
+		}
+	}
+	saved, err := b.saveFileChecked(content, media.MediaType, fileName, media.MIME, chatID, owner, func(m *FileMeta) error {
+		if err := b.checkMinSize(m.Size); err != nil {
+			return err
+		}
+		// Remember the Telegram file so inline queries can resend it, unless
+		// what is stored is no longer that file
+		if originalSize == 0 {
+			m.FileID = media.FileID
+		}
+		m.MediaType, m.OriginalSize, m.Protected = media.MediaType, originalSize, media.Protected
+		return nil
+	})
+	if err != nil {
+		return saved, classifyError(err)
+	}
+	return saved, nil
+}
 
-	b.sendTextMessage(chatID, fmt.Sprintf("✅ Audio '%s' saved successfully!", fileName))
+// checkMinSize refuses content below MIN_FILE_SIZE: most likely a broken
+// upload, not worth keeping.
+func (b *Bot) checkMinSize(size int64) error {
+	if size < b.minFileSize {
+		return &tooSmallError{Size: size}
+	}
+	return nil
 }
 
-func (b *Bot) handleVoice(voice *tgbotapi.Voice, chatID int64, messageID int) {
-	fileName := fmt.Sprintf("voice_%d_%s.ogg", time.Now().Unix(), voice.FileID)
+// saveFile writes the content of r into the owner's directory as fileName (a
+// name or a path relative to that directory), in the storage root configured
+// for mediaType, and records its metadata. A taken name is handled by the
+// owner's collisionStrategy: suffix stores the content as " (1)", " (2)", ...;
+// overwrite replaces the owner's own unpinned, unprotected stored file (local
+// storage only, else suffix);
+// reject fails with errNameTaken; append adds a text document to the
+// existing file and falls back to suffix for other content. The returned
+// metadata holds the final path and the hex-encoded SHA-256 of the written content.
+// With CORRECT_EXTENSIONS a mislabelled well-known type gets its proper extension.
+// Documents matching COMPRESS_EXTENSIONS are stored gzip-compressed as
+// <name>.gz; checksum and size are always those of the original content.
+func (b *Bot) saveFile(r io.Reader, mediaType, fileName, mimeType string, owner int64) (FileMeta, error) {
+	return b.saveFileChecked(r, mediaType, fileName, mimeType, owner, owner, nil)
+}
 
-	if err := b.downloadAndSave(voice.FileID, fileName, chatID); err != nil {
-		log.Printf("Error handling voice: %v", err)
-		b.sendTextMessage(chatID, "Failed to save the voice message.")
-		return
+// saveChatFile is saveFile for content owner sent in chatID, whose
+// CHAT_STORAGE_MAP root it is stored in.
+func (b *Bot) saveChatFile(r io.Reader, mediaType, fileName, mimeType string, chatID, owner int64) (FileMeta, error) {
+	return b.saveFileChecked(r, mediaType, fileName, mimeType, chatID, owner, nil)
+}
+
+// saveFileChecked is saveChatFile with accept, when not nil, deciding from the
+// stored content's metadata whether to keep it, and completing that metadata
+// before it is recorded. Refused content is removed before it replaces a
+// stored file or is recorded, and accept's error is returned. Content appended
+// to an existing file is checked with the size it grows the file to.
+func (b *Bot) saveFileChecked(r io.Reader, mediaType, fileName, mimeType string, chatID, owner int64, accept func(m *FileMeta) error) (FileMeta, error) {
+	original := fileName
+	if b.correctExtensions {
+		var contentType, ext string
+		r, contentType, ext = sniffExtension(r, fileName)
+		if ext != "" {
+			log.Printf("Content of %s looks like %s, storing it as %s", fileName, contentType, ext)
+			fileName = withExtension(fileName, ext)
+			mimeType = contentType
+		}
+	}
+	fileName = b.storedRelPath(fileName)
+	compress := b.compresses(mediaType, fileName)
+
+	strategy := b.collisionStrategy(owner)
+	if b.appendsDuplicates(strategy, mediaType, mimeType, compress) {
+		m, rest, ok, err := b.appendToExisting(r, b.userPath(owner, fileName), mediaType, owner, accept)
+		if ok {
+			return m, err
+		}
+		r = rest
 	}
 
-	// Force status update when file is received
-	b.forceStatusUpdate(chatID)
+	hasher := sha256.New()
+	plain := &countingReader{r: io.TeeReader(r, hasher)}
 
-	b.sendTextMessage(chatID, fmt.Sprintf("✅ Voice message '%s' saved successfully!", fileName))
-}
+	var rel string
+	for n := 0; ; n++ {
+		if n > MaxNameSuffix {
+			return FileMeta{}, fmt.Errorf("no free name for %s after %d attempts", fileName, MaxNameSuffix)
+		}
+		rel = b.userPath(owner, suffixedName(fileName, n))
+		if compress {
+			rel += compressedSuffix
+		}
 
-func (b *Bot) handleVideoNote(videoNote *tgbotapi.VideoNote, chatID int64, messageID int) {
-	fileName := fmt.Sprintf("videonote_%d_%s.mp4", time.Now().Unix(), videoNote.FileID)
+		err := b.storeContent(rel, mediaType, chatID, compress, plain)
+		if err == nil {
+			break
+		}
+		if errors.Is(err, os.ErrExist) && plain.n == 0 && strategy == CollisionReject {
+			return FileMeta{}, nameTakenError(fileName)
+		}
+		// Only retry while nothing has been consumed from r
+		if !errors.Is(err, os.ErrExist) || plain.n > 0 {
+			if !errors.Is(err, os.ErrExist) {
+				b.removePartial(rel)
+			}
+			if isStorageFull(err) {
+				b.alertStorageFull(rel, owner, err)
+			}
+			return FileMeta{}, err
+		}
+	}
+	b.storageFullAlerted.Store(false)
+
+	m := FileMeta{
+		Path:       rel,
+		Owner:      owner,
+		Size:       plain.n,
+		MIME:       mimeType,
+		SHA256:     hex.EncodeToString(hasher.Sum(nil)),
+		ReceivedAt: time.Now(),
+		Compressed: compress,
+	}
+	if original != fileName {
+		m.OriginalName = original
+	}
+	if accept != nil {
+		if err := accept(&m); err != nil {
+			if derr := b.storage.Delete(rel); derr != nil {
+				log.Printf("Failed to remove refused %s: %v", rel, derr)
+			}
+			log.Printf("Not keeping %s from user %d: %v", rel, owner, err)
+			return FileMeta{}, err
+		}
+	}
 
-	if err := b.downloadAndSave(videoNote.FileID, fileName, chatID); err != nil {
-		log.Printf("Error handling video note: %v", err)
-		b.sendTextMessage(chatID, "Failed to save the video note.")
-		return
+	// Under the overwrite strategy the new file takes the taken name over
+	var replaced bool
+	if _, local := b.storage.(*LocalStorage); local && strategy == CollisionOverwrite {
+		target := b.userPath(owner, fileName)
+		if compress {
+			target += compressedSuffix
+		}
+		if rel != target {
+			err := b.replaceable(target, owner)
+			if err == nil {
+				err = b.replaceStored(rel, target)
+			}
+			if err != nil {
+				log.Printf("Keeping %s: %v", rel, err)
+			} else {
+				rel, replaced = target, true
+				m.Path = rel
+			}
+		}
 	}
 
-	// Force status update when file is received
-	b.forceStatusUpdate(chatID)
+	log.Printf("File saved: %s from user %d", rel, owner)
 
-	b.sendTextMessage(chatID, fmt.Sprintf("✅ Video note '%s' saved successfully!", fileName))
+	b.recordFile(m)
+	entry := AuditEntry{User: owner, Action: "save", Path: rel, Size: m.Size, SHA256: m.SHA256}
+	if replaced {
+		entry.Detail = "replaced the stored file"
+	}
+	b.audit(entry)
+	b.runPostSaveHook(m)
+	if m.Size >= DiskCheckSaveSize {
+		b.checkDiskSpace()
+	}
+	return m, nil
 }
 
-func (b *Bot) handleSticker(sticker *tgbotapi.Sticker, chatID int64, messageID int) {
-	fileName := fmt.Sprintf("sticker_%d_%s.webp", time.Now().Unix(), sticker.FileID)
-
-	if err := b.downloadAndSave(sticker.FileID, fileName, chatID); err != nil {
-		log.Printf("Error handling sticker: %v", err)
-		b.sendTextMessage(chatID, "Failed to save the sticker.")
-		return
+// storeContent copies r into the storage backend as rel, compressing it if
+// asked and encrypting it when enabled. Backends with per-chat or per-type
+// roots route it by chatID and mediaType.
+func (b *Bot) storeContent(rel, mediaType string, chatID int64, compress bool, r io.Reader) error {
+	if compress {
+		compressed := gzipReader(r)
+		defer compressed.Close()
+		r = compressed
+	}
+	if b.cipher != nil {
+		encrypted := b.cipher.encryptReader(r)
+		defer encrypted.Close()
+		r = encrypted
 	}
 
-	// Force status update when file is received
-	b.forceStatusUpdate(chatID)
-
-	b.sendTextMessage(chatID, fmt.Sprintf("✅ Sticker '%s' saved successfully!", fileName))
+	var err error
+	if rs, ok := b.storage.(routedSaver); ok {
+		_, err = rs.SaveFor(chatID, mediaType, rel, r)
+	} else {
+		_, err = b.storage.Save(rel, r)
+	}
+	return err
 }
 
-func (b *Bot) downloadAndSave(fileID, fileName string, chatID int64) error {
-	// Get file info from Telegram
-	file, err := b.api.GetFile(tgbotapi.FileConfig{FileID: fileID})
-	if err != nil {
-		return fmt.Errorf("failed to get file info: %w", err)
+// checksumNote returns the checksum line appended to success replies when REPLY_CHECKSUM is enabled.
+func (b *Bot) checksumNote(checksum string) string {
+	if !b.replyChecksum || checksum == "" {
+		return ""
 	}
+	return fmt.Sprintf("\nSHA-256: %s", checksum)
+}
 
-	// Download file from Telegram
-	fileURL := file.Link(b.api.Token)
-	resp, err := http.Get(fileURL)
-	if err != nil {
-		return fmt.Errorf("failed to download file: %w", err)
+// recordFile writes the metadata sidecar and updates the index for a saved file.
+// Failures are logged only: the file itself is already stored.
+func (b *Bot) recordFile(m FileMeta) {
+	if err := writeMeta(b.storagePath, m); err != nil {
+		log.Printf("Failed to write metadata for %s: %v", m.Path, err)
 	}
-	defer resp.Body.Close()
 
-	// Create local file directly in storage path
-	filePath := filepath.Join(b.storagePath, fileName)
-	localFile, err := os.Create(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to create local file: %w", err)
+	if b.index != nil {
+		if err := b.index.Put(m); err != nil {
+			log.Printf("Failed to index %s: %v", m.Path, err)
+		}
 	}
-	defer localFile.Close()
+	b.linkContent(m)
+}
 
-	// Copy content
-	_, err = io.Copy(localFile, resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to save file content: %w", err)
+// forgetFile removes the metadata sidecar and index entry of a deleted file.
+func (b *Bot) forgetFile(rel string) {
+	if err := os.Remove(metaPath(b.storagePath, rel)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to remove metadata for %s: %v", rel, err)
 	}
 
-	log.Printf("File saved: %s from user %d", filePath, chatID)
-	return nil
+	b.unlinkContent(rel)
+	if b.index != nil {
+		if err := b.index.Delete(rel); err != nil {
+			log.Printf("Failed to remove %s from index: %v", rel, err)
+		}
+	}
 }
 
 func (b *Bot) sendWelcomeMessage(chatID int64) {
@@ -338,10 +1013,12 @@ I can help you store various types of files:
 • Documents (PDF, DOC, TXT, etc.)
 • Photos and Images
 • Videos
+• Animations (GIFs)
 • Audio files
 • Voice messages
 • Video notes
 • Stickers
+• Locations, contacts and polls
 
 Just send me any file and I'll store it safely for you!
 
@@ -351,18 +1028,22 @@ Use /id to get your Telegram user ID.`
 	b.sendTextMessage(chatID, message)
 }
 
-func (b *Bot) sendHelpMessage(chatID int64) {
-	message := `📖 Available Commands:
-
-/start - Show welcome message
-/help - Show this help message
-/id - Show your Telegram user ID`
+func (b *Bot) sendHelpMessage(chatID, userID int64) {
+	b.sendLongMessage(chatID, b.helpText(userID))
+}
 
-	// Add admin commands if user is admin
-	if b.isUserAdmin(chatID) {
-		message += `
-/admin - Admin commands (list, add, remove users)`
+// helpText is the /help reply for userID, listing admin commands to admins.
+func (b *Bot) helpText(userID int64) string {
+	var sb strings.Builder
+	sb.WriteString("📖 Available Commands:\n\n")
+	commands := userCommands
+	if b.isUserAdmin(userID) {
+		commands = append(slices.Clone(commands), adminUserCommands...)
 	}
+	for _, c := range commands {
+		sb.WriteString(c.usage() + " - " + c.Summary + "\n")
+	}
+	message := strings.TrimSuffix(sb.String(), "\n")
 
 	message += `
 
@@ -370,14 +1051,19 @@ func (b *Bot) sendHelpMessage(chatID int64) {
 • Documents: Any file type (max 50MB)
 • Photos: JPG, PNG, etc.
 • Videos: MP4, AVI, etc. (max 50MB)
+• Animations: GIFs, saved as MP4 or GIF as Telegram delivers them
 • Audio: MP3, WAV, etc. (max 50MB)
 • Voice messages: OGG format
 • Video notes: Circular videos
 • Stickers: WEBP format
+• Locations: saved as GeoJSON
+• Contacts: saved as vCard (.vcf)
+• Polls: question, options and vote counts saved as JSON
+• Links: send an http(s) URL to store the file behind it
 
 Files are stored with timestamps and file IDs for easy identification.`
 
-	b.sendTextMessage(chatID, message)
+	return message
 }
 
 func (b *Bot) sendUserIDMessage(chatID int64, userID int64, user *tgbotapi.User) {
@@ -407,76 +1093,31 @@ This ID can be used by bot administrators to grant you access to restricted bots
 
 func (b *Bot) sendTextMessage(chatID int64, text string) {
 	msg := tgbotapi.NewMessage(chatID, text)
-	if _, err := b.api.Send(msg); err != nil {
+	if _, err := b.send(msg); err != nil {
 		log.Printf("Failed to send message: %v", err)
 	}
 }
 
 func (b *Bot) isUserAdmin(userID int64) bool {
+	b.usersMu.RLock()
+	defer b.usersMu.RUnlock()
 	return b.adminUsers[userID]
 }
 
-func (b *Bot) handleAdminCommand(message *tgbotapi.Message, chatID int64, userID int64) {
-	if !b.isUserAdmin(userID) {
-		b.sendTextMessage(chatID, "🚫 Access denied. Admin privileges required.")
-		return
-	}
-
-	parts := strings.Fields(message.Text)
-	if len(parts) < 2 {
-		b.sendAdminHelp(chatID)
-		return
-	}
-
-	command := parts[1]
-	switch command {
-	case "list":
-		b.handleAdminListUsers(chatID)
-	case "add":
-		if len(parts) < 3 {
-			b.sendTextMessage(chatID, "Usage: /admin add <user_id>")
-			return
-		}
-		b.handleAdminAddUser(chatID, parts[2])
-	case "remove":
-		if len(parts) < 3 {
-			b.sendTextMessage(chatID, "Usage: /admin remove <user_id>")
-			return
-		}
-		b.handleAdminRemoveUser(chatID, parts[2])
-	case "status":
-		b.handleAdminStatus(chatID)
-	default:
-		b.sendAdminHelp(chatID)
-	}
-}
-
-func (b *Bot) sendAdminHelp(chatID int64) {
-	message := `🔧 Admin Commands:
-
-/admin list - List all allowed users
-/admin add <user_id> - Add user to allowed list
-/admin remove <user_id> - Remove user from allowed list
-/admin status - Show bot statistics
-
-Example: /admin add 123456789`
-
-	b.sendTextMessage(chatID, message)
-}
-
 func (b *Bot) handleAdminListUsers(chatID int64) {
-	if len(b.allowedUsers) == 0 {
+	ids := b.allowedUserIDs()
+	if len(ids) == 0 {
 		b.sendTextMessage(chatID, "📝 No user restrictions configured. All users can access the bot.")
 		return
 	}
 
 	var userList []string
-	for userID := range b.allowedUsers {
+	for _, userID := range ids {
 		userList = append(userList, strconv.FormatInt(userID, 10))
 	}
 
 	message := fmt.Sprintf("👥 Allowed Users (%d total):\n\n%s", len(userList), strings.Join(userList, "\n"))
-	b.sendTextMessage(chatID, message)
+	b.sendLongMessage(chatID, message)
 }
 
 func (b *Bot) handleAdminAddUser(chatID int64, userIDStr string) {
@@ -486,14 +1127,39 @@ func (b *Bot) handleAdminAddUser(chatID int64, userIDStr string) {
 		return
 	}
 
-	if b.allowedUsers[userID] {
+	if !b.addUser(userID) {
 		b.sendTextMessage(chatID, fmt.Sprintf("ℹ️ User %d is already in the allowed list", userID))
 		return
 	}
 
-	b.allowedUsers[userID] = true
-	b.sendTextMessage(chatID, fmt.Sprintf("✅ User %d added to allowed list", userID))
 	log.Printf("Admin %d added user %d to allowed list", chatID, userID)
+
+	err = b.greetUser(userID)
+	switch {
+	case err == nil:
+		b.sendTextMessage(chatID, fmt.Sprintf("✅ User %d added to allowed list and told so", userID))
+	case isBlockedError(err):
+		b.sendTextMessage(chatID, fmt.Sprintf("✅ User %d added to allowed list, but they could not be told (they have not started the bot or have blocked it). Ask them to send /start to the bot.", userID))
+	default:
+		log.Printf("Failed to greet user %d: %v", userID, err)
+		b.sendTextMessage(chatID, fmt.Sprintf("✅ User %d added to allowed list, but the welcome message failed.", userID))
+	}
+}
+
+// greetUser tells a newly authorized user they have access, with
+// WELCOME_MESSAGE (or a default) followed by the help text. Telegram refuses
+// the message with 403 if they never started the bot.
+func (b *Bot) greetUser(userID int64) error {
+	greeting := b.welcomeMessage
+	if greeting == "" {
+		greeting = DefaultWelcomeMessage
+	}
+	for _, part := range splitMessage(greeting+"\n\n"+b.helpText(userID), MaxMessageLength) {
+		if _, err := b.send(tgbotapi.NewMessage(userID, part)); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (b *Bot) handleAdminRemoveUser(chatID int64, userIDStr string) {
@@ -503,12 +1169,15 @@ func (b *Bot) handleAdminRemoveUser(chatID int64, userIDStr string) {
 		return
 	}
 
-	if !b.allowedUsers[userID] {
+	if !b.removeUser(userID) {
 		b.sendTextMessage(chatID, fmt.Sprintf("ℹ️ User %d is not in the allowed list", userID))
 		return
 	}
 
-	delete(b.allowedUsers, userID)
+	if err := b.forgetInvitedUser(userID); err != nil {
+		log.Printf("Error saving invited users: %v", err)
+	}
+
 	b.sendTextMessage(chatID, fmt.Sprintf("✅ User %d removed from allowed list", userID))
 	log.Printf("Admin %d removed user %d from allowed list", chatID, userID)
 }
@@ -526,7 +1195,7 @@ func (b *Bot) handleStatusCommand(chatID int64) {
 	}
 
 	message := b.statusService.FormatStatusMessage()
-	b.sendTextMessage(chatID, message)
+	b.sendLongMessage(chatID, message)
 }
 
 // forceStatusUpdate forces an immediate status update
@@ -546,26 +1215,248 @@ func (b *Bot) forceStatusUpdate(chatID int64) {
 	}
 
 	message := b.statusService.FormatStatusMessage()
-	b.sendTextMessage(chatID, message)
+	b.sendLongMessage(chatID, message)
+}
+
+// handleListCommand lists the newest files of owner, replying in chatID.
+func (b *Bot) handleListCommand(chatID, owner int64) {
+	files, err := b.findFiles(owner, "", ListLimit)
+	if err != nil {
+		log.Printf("Error listing files: %v", err)
+		b.sendTextMessage(chatID, "Failed to list stored files.")
+		return
+	}
+
+	if len(files) == 0 {
+		b.sendTextMessage(chatID, "📁 No files stored yet.")
+		return
+	}
+
+	b.sendLongMessage(chatID, formatFileList(fmt.Sprintf("📁 Recent files (%d shown):", len(files)), files))
+}
+
+// handleSearchCommand lists the files of owner matching query, replying in chatID.
+func (b *Bot) handleSearchCommand(chatID, owner int64, query string) {
+	if query == "" {
+		b.sendTextMessage(chatID, "Usage: /search <text> or /search tag:<tag>")
+		return
+	}
+
+	files, err := b.findFiles(owner, query, ListLimit)
+	if err != nil {
+		log.Printf("Error searching files: %v", err)
+		b.sendTextMessage(chatID, "Failed to search stored files.")
+		return
+	}
+
+	if len(files) == 0 {
+		b.sendTextMessage(chatID, fmt.Sprintf("🔍 No files matching '%s'", query))
+		return
+	}
+
+	b.sendLongMessage(chatID, formatFileList(fmt.Sprintf("🔍 Files matching '%s' (%d shown):", query, len(files)), files))
+}
+
+// findFiles returns up to limit files in the user's directory whose name contains query,
+// newest first. It uses the index when enabled and falls back to listing the storage backend.
+func (b *Bot) findFiles(userID int64, query string, limit int) ([]storedFile, error) {
+	if len(query) > len("tag:") && strings.EqualFold(query[:len("tag:")], "tag:") {
+		return b.findTagged(userID, query[len("tag:"):], limit)
+	}
+	dir := b.userDir(userID)
+
+	if b.index != nil {
+		var metas []FileMeta
+		var err error
+		if query == "" {
+			metas, err = b.index.List(dir, limit)
+		} else {
+			metas, err = b.index.Search(dir, query, limit)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		files := make([]storedFile, 0, len(metas))
+		for _, m := range metas {
+			files = append(files, storedFile{Name: b.listedName(userID, m.Path), Size: m.Size, ModTime: m.ReceivedAt})
+		}
+		return files, nil
+	}
+
+	all, err := b.storage.List(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	query = strings.ToLower(query)
+	var files []storedFile
+	for _, f := range all {
+		f.Name = b.listedName(userID, path.Join(dir, f.Name))
+		if !strings.Contains(strings.ToLower(f.Name), query) {
+			continue
+		}
+		files = append(files, f)
+		if len(files) == limit {
+			break
+		}
+	}
+	return files, nil
+}
+
+// listedName returns the name shown in listings for a storage-relative path: the
+// user-relative name (without the ".gz" of compressed files), prefixed by the
+// original name if it was stored ASCII-safe and marked when pinned.
+func (b *Bot) listedName(userID int64, rel string) string {
+	name := b.displayName(userID, rel)
+	m, err := readMeta(b.storagePath, rel)
+	if err != nil {
+		return name
+	}
+	name = uncompressedName(name, m)
+	if m.OriginalName != "" {
+		name = fmt.Sprintf("%s [%s]", m.OriginalName, name)
+	}
+	if m.Pinned {
+		name = "📌 " + name
+	}
+	return name
+}
+
+func formatFileList(header string, files []storedFile) string {
+	var sb strings.Builder
+	sb.WriteString(header)
+	sb.WriteString("\n\n")
+	for _, f := range files {
+		sb.WriteString(fmt.Sprintf("• %s (%s, %s)\n", f.Name, formatSize(f.Size), f.ModTime.Format("2006-01-02 15:04")))
+	}
+	return sb.String()
+}
+
+// handleAdminRecent lists the most recently modified files across all users.
+func (b *Bot) handleAdminRecent(chatID int64, args []string) {
+	limit := ListLimit
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
+			b.sendTextMessage(chatID, "Usage: /admin recent [N]")
+			return
+		}
+		limit = min(n, MaxRecentLimit)
+	}
+
+	if !b.requireLocalStorage(chatID) {
+		return
+	}
+
+	files, err := b.recentFiles(limit)
+	if err != nil {
+		log.Printf("Error listing recent files: %v", err)
+		b.sendTextMessage(chatID, "Failed to list stored files.")
+		return
+	}
+
+	if len(files) == 0 {
+		b.sendTextMessage(chatID, "📁 No files stored yet.")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🕒 Recent uploads (%d shown):\n\n", len(files)))
+	for _, f := range files {
+		owner := "unknown"
+		if f.Owner != 0 {
+			owner = strconv.FormatInt(f.Owner, 10)
+		}
+		sb.WriteString(fmt.Sprintf("• %s — %s by %s (%s)\n", f.ModTime.Format("2006-01-02 15:04"), f.Name, owner, formatSize(f.Size)))
+	}
+	b.sendLongMessage(chatID, sb.String())
+}
+
+// recentFiles returns up to limit files across all users, newest first. Files
+// without a metadata sidecar are attributed to their per-user directory.
+func (b *Bot) recentFiles(limit int) ([]storedFile, error) {
+	files, err := listStoredFilesRecursive(b.storagePath, b.dataRoots())
+	if err != nil {
+		return nil, err
+	}
+	if len(files) > limit {
+		files = files[:limit]
+	}
+
+	for i, f := range files {
+		if f.Owner != 0 || !b.perUserDirs {
+			continue
+		}
+		dir, _, found := strings.Cut(f.Name, "/")
+		if id, err := strconv.ParseInt(dir, 10, 64); found && err == nil {
+			files[i].Owner = id
+		}
+	}
+	return files, nil
+}
+
+func (b *Bot) handleAdminReindex(chatID int64) {
+	if b.index == nil {
+		b.sendTextMessage(chatID, "⚠️ File index is not enabled (set INDEX_DB)")
+		return
+	}
+
+	if !b.requireLocalStorage(chatID) {
+		return
+	}
+
+	count, err := b.index.Reindex(b.storagePath, b.dataRoots(), b.openPath)
+	if err != nil {
+		log.Printf("Error rebuilding index: %v", err)
+		b.sendTextMessage(chatID, "❌ Failed to rebuild the file index.")
+		return
+	}
+
+	b.sendTextMessage(chatID, fmt.Sprintf("✅ File index rebuilt: %d files", count))
+	log.Printf("Admin %d rebuilt the file index (%d files)", chatID, count)
 }
 
 func (b *Bot) handleAdminStatus(chatID int64) {
-	allowedCount := len(b.allowedUsers)
-	adminCount := len(b.adminUsers)
+	allowedCount := len(b.allowedUserIDs())
+	adminIDs := b.adminIDs()
+	adminCount := len(adminIDs)
+
+	admins := make([]string, 0, adminCount)
+	for _, id := range adminIDs {
+		admins = append(admins, strconv.FormatInt(id, 10))
+	}
+
+	uploads := "accepted"
+	if b.isPaused() {
+		uploads = "paused"
+	}
+
+	counters := ""
+	if b.postSaveHook != nil {
+		counters = fmt.Sprintf("🪝 Post-save hook failures: %d\n", b.postSaveHook.Failures())
+	}
+	if rates := b.throughput.String(); rates != "" {
+		counters += fmt.Sprintf("🚀 Downloads by MB/s: %s\n", rates)
+	}
 
 	message := fmt.Sprintf(`📊 Bot Status:
 
 👥 Allowed Users: %d
-🔧 Admin Users: %d
+🔧 Admin Users: %d (%s)
 📁 Storage Path: %s
-🤖 Bot Username: @%s
+📥 Uploads: %s
+%s🤖 Bot Username: @%s
 
-Memory: Runtime statistics available via process monitoring`, allowedCount, adminCount, b.storagePath, b.api.Self.UserName)
+Memory: Runtime statistics available via process monitoring`, allowedCount, adminCount, strings.Join(admins, ", "), b.storagePath, uploads, counters, b.api.Self.UserName)
 
 	b.sendTextMessage(chatID, message)
 }
 
 func (b *Bot) isUserAllowed(userID int64) bool {
+	b.usersMu.RLock()
+	defer b.usersMu.RUnlock()
+
 	if len(b.allowedUsers) == 0 {
 		// If no users are configured, allow everyone (backward compatibility)
 		return true
@@ -573,6 +1464,53 @@ func (b *Bot) isUserAllowed(userID int64) bool {
 	return b.allowedUsers[userID]
 }
 
+// addUser adds userID to the allowed list, reporting false if it was already there.
+func (b *Bot) addUser(userID int64) bool {
+	b.usersMu.Lock()
+	defer b.usersMu.Unlock()
+
+	if b.allowedUsers[userID] {
+		return false
+	}
+	b.allowedUsers[userID] = true
+	return true
+}
+
+// removeUser removes userID from the allowed list, reporting false if it was not there.
+func (b *Bot) removeUser(userID int64) bool {
+	b.usersMu.Lock()
+	defer b.usersMu.Unlock()
+
+	if !b.allowedUsers[userID] {
+		return false
+	}
+	delete(b.allowedUsers, userID)
+	return true
+}
+
+// allowedUserIDs returns the allowed users in ascending order.
+func (b *Bot) allowedUserIDs() []int64 {
+	b.usersMu.RLock()
+	defer b.usersMu.RUnlock()
+	return sortedIDs(b.allowedUsers)
+}
+
+// logUnauthorized logs an access attempt by a user not allowed, throttled per user.
+func (b *Bot) logUnauthorized(userID int64, username string) {
+	b.unauthorizedLog.Printf(strconv.FormatInt(userID, 10), "Unauthorized access attempt from user %d (%s)", userID, username)
+}
+
+// isChatAllowed reports whether messages from chatID are processed:
+// every chat unless ALLOWED_CHATS is set.
+func (b *Bot) isChatAllowed(chatID int64) bool {
+	return len(b.allowedChats) == 0 || b.allowedChats[chatID]
+}
+
+// logDisallowedChat logs a message from a chat outside ALLOWED_CHATS, throttled per chat.
+func (b *Bot) logDisallowedChat(chatID, userID int64) {
+	b.unauthorizedLog.Printf("chat "+strconv.FormatInt(chatID, 10), "Ignoring message from chat %d (user %d): not in ALLOWED_CHATS", chatID, userID)
+}
+
 func (b *Bot) sendUnauthorizedMessage(chatID int64) {
 	message := `🚫 Access Denied
 
@@ -609,56 +1547,92 @@ func parseAllowedUsers(allowedUsersStr string) []int64 {
 	return users
 }
 
+// envLookup returns the value of a configuration variable, "" when unset.
+type envLookup func(name string) string
+
+// bool reports whether the named variable is set to a true value.
+func (env envLookup) bool(name string) bool {
+	value, err := strconv.ParseBool(env(name))
+	return err == nil && value
+}
+
+// duration parses the named variable as a positive duration (e.g. "30s"),
+// returning def when it is unset.
+func (env envLookup) duration(name string, def time.Duration) (time.Duration, error) {
+	value := env(name)
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s %q: expected a positive duration such as 30s", name, value)
+	}
+	return d, nil
+}
+
 func main() {
+	log.Printf("Starting %s", versionString())
+
 	// Load .env file if it exists
 	err := godotenv.Load()
 	if err != nil {
 		log.Println("No .env file found, using environment variables directly")
 	}
 
-	token := os.Getenv("TELEGRAM_BOT_TOKEN")
-	if token == "" {
-		log.Fatal("TELEGRAM_BOT_TOKEN environment variable is required")
+	// Copy the log to LOG_FILE for /admin logs
+	if path := os.Getenv("LOG_FILE"); path != "" {
+		lf, err := openLogFile(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer lf.Close()
+		log.SetOutput(io.MultiWriter(os.Stderr, lf))
+		log.Printf("Logging to %s", path)
 	}
 
-	storagePath := os.Getenv("STORAGE_PATH")
-	if storagePath == "" {
-		storagePath = DefaultStoragePath
+	configs, err := loadBotConfigs(os.Getenv)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	// Parse allowed users list
-	allowedUsersStr := os.Getenv("ALLOWED_USERS")
-	allowedUsers := parseAllowedUsers(allowedUsersStr)
-
-	// Parse admin users list
-	adminUsersStr := os.Getenv("ADMIN_USERS")
-	adminUsers := parseAllowedUsers(adminUsersStr)
+	var bots []*Bot
+	for _, cfg := range configs {
+		if len(cfg.AllowedUsers) > 0 {
+			log.Printf("%s: access restricted to %d users: %v", cfg.Name, len(cfg.AllowedUsers), cfg.AllowedUsers)
+		} else {
+			log.Printf("Warning: %s: no user restrictions configured. Bot is accessible to all users.", cfg.Name)
+		}
 
-	if len(allowedUsers) > 0 {
-		log.Printf("Bot access restricted to %d users: %v", len(allowedUsers), allowedUsers)
-	} else {
-		log.Printf("Warning: No user restrictions configured. Bot is accessible to all users.")
-	}
+		if len(cfg.AdminUsers) > 0 {
+			log.Printf("%s: %d admin users: %v", cfg.Name, len(cfg.AdminUsers), cfg.AdminUsers)
+		} else {
+			log.Printf("Warning: %s: no admin users configured. Admin functions disabled.", cfg.Name)
+		}
 
-	if len(adminUsers) > 0 {
-		log.Printf("Bot has %d admin users: %v", len(adminUsers), adminUsers)
-	} else {
-		log.Printf("Warning: No admin users configured. Admin functions disabled.")
-	}
+		bot, err := NewBot(cfg)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %v", cfg.Name, err)
+		}
+		// Ensure cleanup of resources on exit
+		defer bot.Close()
+		bots = append(bots, bot)
 
-	bot, err := NewBot(token, storagePath, allowedUsers, adminUsers)
-	if err != nil {
-		log.Fatal("Failed to create bot:", err)
+		log.Printf("%s started successfully. Storage path: %s", cfg.Name, cfg.StoragePath)
 	}
 
-	log.Printf("Bot started successfully. Storage path: %s", storagePath)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Ensure cleanup of resources on exit
-	defer func() {
-		if bot.statusService != nil {
-			bot.statusService.Stop()
-		}
-	}()
+	// Tell systemd we are up and keep its watchdog fed
+	notifier := newSDNotifier()
+	if err := notifier.Notify("READY=1"); err != nil {
+		log.Printf("Failed to notify systemd: %v", err)
+	}
+	if timeout, ok := watchdogInterval(); ok {
+		go notifier.runWatchdog(ctx, timeout)
+	}
 
-	bot.Start()
+	runBots(ctx, bots)
+	log.Printf("Shutting down")
+	notifier.Notify("STOPPING=1")
 }
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func newTestBot(t *testing.T) *Bot {
+	t.Helper()
+	dir := t.TempDir()
+	users, err := OpenUserDB(filepath.Join(dir, userDBName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Bot{
+		storagePath:  dir,
+		storage:      NewLocalStorage(dir),
+		allowedUsers: make(map[int64]bool),
+		adminUsers:   make(map[int64]bool),
+		users:        users,
+		transcoder:   &transcoder{},
+	}
+}
+
+func TestSaveFileReturnsChecksumOfWrittenBytes(t *testing.T) {
+	b := newTestBot(t)
+	content := bytes.Repeat([]byte("telegram file payload "), 1000)
+
+	saved, err := b.saveFile(bytes.NewReader(content), "documents", "payload.bin", "application/octet-stream", 42)
+	if err != nil {
+		t.Fatalf("saveFile failed: %v", err)
+	}
+	checksum := saved.SHA256
+
+	written, err := os.ReadFile(filepath.Join(b.storagePath, "payload.bin"))
+	if err != nil {
+		t.Fatalf("failed to read stored file: %v", err)
+	}
+	if !bytes.Equal(written, content) {
+		t.Fatal("stored content differs from input")
+	}
+
+	sum := sha256.Sum256(written)
+	if checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("returned checksum %s does not match written bytes", checksum)
+	}
+
+	meta, err := readMeta(b.storagePath, "payload.bin")
+	if err != nil {
+		t.Fatalf("failed to read metadata: %v", err)
+	}
+	if meta.SHA256 != checksum || meta.Owner != 42 || meta.Size != int64(len(content)) {
+		t.Errorf("unexpected metadata: %+v", meta)
+	}
+}
+
+func TestChecksumNote(t *testing.T) {
+	b := newTestBot(t)
+
+	if note := b.checksumNote("abc"); note != "" {
+		t.Errorf("expected no checksum note when disabled, got %q", note)
+	}
+
+	b.replyChecksum = true
+	if note := b.checksumNote("abc"); note != "\nSHA-256: abc" {
+		t.Errorf("unexpected checksum note: %q", note)
+	}
+}
+
+func TestRecentFilesAcrossUsers(t *testing.T) {
+	b := newTestBot(t)
+	b.perUserDirs = true
+
+	base := time.Now().Add(-time.Hour)
+	files := []struct {
+		rel   string
+		owner int64
+		age   time.Duration
+	}{
+		{"42/oldest.txt", 0, 50 * time.Minute},
+		{"42/docs/newest.pdf", 42, 0},
+		{"7/middle.jpg", 7, 20 * time.Minute},
+		{"7/newer.jpg", 0, 10 * time.Minute},
+		{"shared.txt", 0, 30 * time.Minute},
+	}
+	for _, f := range files {
+		writeTestFile(t, b, f.rel, f.rel)
+		if f.owner != 0 {
+			writeMeta(b.storagePath, FileMeta{Path: f.rel, Owner: f.owner})
+		}
+		mtime := base.Add(-f.age)
+		os.Chtimes(b.localPath(f.rel), mtime, mtime)
+	}
+
+	recent, err := b.recentFiles(4)
+	if err != nil {
+		t.Fatalf("recentFiles failed: %v", err)
+	}
+
+	want := []storedFile{
+		{Name: "42/docs/newest.pdf", Owner: 42},
+		{Name: "7/newer.jpg", Owner: 7},
+		{Name: "7/middle.jpg", Owner: 7},
+		{Name: "shared.txt", Owner: 0},
+	}
+	if len(recent) != len(want) {
+		t.Fatalf("expected %d files (N cap), got %d", len(want), len(recent))
+	}
+	for i, w := range want {
+		if recent[i].Name != w.Name || recent[i].Owner != w.Owner {
+			t.Errorf("entry %d: got %s (owner %d), want %s (owner %d)", i, recent[i].Name, recent[i].Owner, w.Name, w.Owner)
+		}
+	}
+}
+
+func TestSaveFileSuffixesDuplicateNames(t *testing.T) {
+	b := newTestBot(t)
+	b.perUserDirs = true
+	b.cipher = testCipher(t, 5)
+
+	var paths []string
+	for _, content := range []string{"first", "second", "third"} {
+		saved, err := b.saveFile(strings.NewReader(content), "documents", "report.pdf", "application/pdf", 42)
+		if err != nil {
+			t.Fatalf("saveFile failed: %v", err)
+		}
+		paths = append(paths, saved.Path)
+	}
+
+	want := []string{"42/report.pdf", "42/report (1).pdf", "42/report (2).pdf"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Fatalf("expected paths %q, got %q", want, paths)
+	}
+
+	for i, content := range []string{"first", "second", "third"} {
+		r, err := b.openFile(want[i])
+		if err != nil {
+			t.Fatalf("openFile(%s) failed: %v", want[i], err)
+		}
+		data, _ := io.ReadAll(r)
+		r.Close()
+		if string(data) != content {
+			t.Errorf("%s: expected %q, got %q", want[i], content, data)
+		}
+	}
+
+	// Another user's directory is independent
+	saved, err := b.saveFile(strings.NewReader("other"), "documents", "report.pdf", "application/pdf", 7)
+	if err != nil || saved.Path != "7/report.pdf" {
+		t.Errorf("expected 7/report.pdf, got %q (%v)", saved.Path, err)
+	}
+}
+
+func TestSuffixedName(t *testing.T) {
+	tests := []struct {
+		name string
+		n    int
+		want string
+	}{
+		{"report.pdf", 0, "report.pdf"},
+		{"report.pdf", 1, "report (1).pdf"},
+		{"archive.tar.gz", 2, "archive.tar (2).gz"},
+		{"README", 3, "README (3)"},
+	}
+	for _, tt := range tests {
+		if got := suffixedName(tt.name, tt.n); got != tt.want {
+			t.Errorf("suffixedName(%q, %d) = %q, want %q", tt.name, tt.n, got, tt.want)
+		}
+	}
+
+	long := strings.Repeat("a", MaxFileNameLength-4) + ".txt"
+	if got := suffixedName(long, 12); len(got) > MaxFileNameLength || !strings.HasSuffix(got, " (12).txt") {
+		t.Errorf("expected suffixed long name within the limit, got %d bytes: %q", len(got), got)
+	}
+}
+
+func TestSaveFileRoutesMediaTypesToRoots(t *testing.T) {
+	b := newTestBot(t)
+	roots := map[string]string{
+		"photos":    t.TempDir(),
+		"videos":    t.TempDir(),
+		"documents": t.TempDir(),
+	}
+	b.storage = NewRoutedLocalStorage(b.storagePath, roots, nil)
+
+	uploads := map[string]string{
+		"photos":    "photo.jpg",
+		"videos":    "clip.mp4",
+		"documents": "report.pdf",
+		"voice":     "voice.ogg",
+	}
+	for mediaType, name := range uploads {
+		if _, err := b.saveFile(strings.NewReader(mediaType), mediaType, name, "", 42); err != nil {
+			t.Fatalf("saveFile(%s) failed: %v", mediaType, err)
+		}
+	}
+
+	for mediaType, name := range uploads {
+		root, ok := roots[mediaType]
+		if !ok {
+			root = b.storagePath
+		}
+		if data, err := os.ReadFile(filepath.Join(root, name)); err != nil || string(data) != mediaType {
+			t.Errorf("expected %s in the %s root, got %q (%v)", name, mediaType, data, err)
+		}
+		if b.localPath(name) != filepath.Join(root, name) {
+			t.Errorf("expected localPath(%s) to resolve to its root, got %s", name, b.localPath(name))
+		}
+	}
+
+	// All roots share one namespace and one listing
+	saved, err := b.saveFile(strings.NewReader("again"), "documents", "photo.jpg", "", 42)
+	if err != nil || saved.Path != "photo (1).jpg" {
+		t.Errorf("expected a name taken in another root to be suffixed, got %q (%v)", saved.Path, err)
+	}
+	if files, _ := b.storage.List(""); len(files) != 5 {
+		t.Errorf("expected a merged listing of 5 files, got %+v", files)
+	}
+	if recent, _ := b.recentFiles(10); len(recent) != 5 {
+		t.Errorf("expected /admin recent to cover every root, got %+v", recent)
+	}
+
+	// Renames stay within the file's root
+	if err := b.renameFile("clip.mp4", "movie.mp4", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(roots["videos"], "movie.mp4")); err != nil {
+		t.Errorf("expected the renamed video to stay in its root: %v", err)
+	}
+}
+
+func TestSaveFileRoutesMappedChatsToRoots(t *testing.T) {
+	b := newTestBot(t)
+	b.perUserDirs = true
+	chatRoot, photoRoot := t.TempDir(), t.TempDir()
+	b.storage = NewRoutedLocalStorage(b.storagePath, map[string]string{"photos": photoRoot}, map[int64]string{-100: chatRoot})
+
+	// User 7 writes in the mapped group, user 42 in their private chat
+	for _, c := range []struct{ chatID, owner int64 }{{-100, 7}, {42, 42}} {
+		if _, err := b.saveChatFile(strings.NewReader("doc"), "documents", "report.pdf", "", c.chatID, c.owner); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := b.saveChatFile(strings.NewReader("photo"), "photos", "cat.jpg", "", c.chatID, c.owner); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The chat root wins over the per-type root and keeps the per-user folder
+	for _, rel := range []string{"7/report.pdf", "7/cat.jpg"} {
+		if _, err := os.Stat(filepath.Join(chatRoot, filepath.FromSlash(rel))); err != nil {
+			t.Errorf("expected %s in the chat root: %v", rel, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(b.storagePath, "42", "report.pdf")); err != nil {
+		t.Errorf("expected an unmapped chat to use the default root: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(photoRoot, "42", "cat.jpg")); err != nil {
+		t.Errorf("expected an unmapped chat to use the per-type root: %v", err)
+	}
+	if got := b.localPath("7/report.pdf"); got != filepath.Join(chatRoot, "7", "report.pdf") {
+		t.Errorf("expected localPath to resolve to the chat root, got %s", got)
+	}
+}
+
+func TestGroupUploadsBelongToTheSender(t *testing.T) {
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("report-bytes"))
+	}))
+	defer files.Close()
+
+	b := newDownloadTestBot(t, time.Second)
+	api := &mockTelegramAPI{fileURL: files.URL}
+	b.client = api
+	b.perUserDirs = true
+	b.allowedUsers = map[int64]bool{42: true}
+
+	send := func(text string, doc *tgbotapi.Document) string {
+		t.Helper()
+		api.sent = nil
+		b.handleMessage(&tgbotapi.Message{From: &tgbotapi.User{ID: 42}, Chat: &tgbotapi.Chat{ID: -100, Type: "group"}, Text: text, Document: doc})
+		return strings.Join(api.texts(), "\n")
+	}
+
+	send("", &tgbotapi.Document{FileID: "f", FileName: "report.pdf"})
+	if _, err := os.Stat(b.localPath("42/report.pdf")); err != nil {
+		t.Fatalf("expected the upload in the sender's folder: %v", err)
+	}
+	if listed := send("/list", nil); !strings.Contains(listed, "report.pdf") {
+		t.Errorf("expected /list in the group to show the sender's file, got %q", listed)
+	}
+	if found := send("/search report", nil); !strings.Contains(found, "report.pdf") {
+		t.Errorf("expected /search in the group to find the sender's file, got %q", found)
+	}
+	if info := send("/info report.pdf", nil); strings.Contains(info, "not found") {
+		t.Errorf("expected /info to find the file /list showed, got %q", info)
+	}
+}
+
+func TestParseChatStorageMap(t *testing.T) {
+	roots, err := parseChatStorageMap(" -1001234:/srv/team/ , 42:/srv/alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int64]string{-1001234: "/srv/team", 42: "/srv/alice"}; !reflect.DeepEqual(roots, want) {
+		t.Errorf("got %v, want %v", roots, want)
+	}
+	if roots, err := parseChatStorageMap(""); err != nil || len(roots) != 0 {
+		t.Errorf("expected an empty map, got %v (%v)", roots, err)
+	}
+	for _, bad := range []string{"42", "abc:/srv", "42:", "42:/a,42:/b"} {
+		if _, err := parseChatStorageMap(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestCheckWritable(t *testing.T) {
+	dir := t.TempDir()
+	if err := checkWritable(dir); err != nil {
+		t.Fatalf("expected a temp dir to be writable: %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected the probe file to be removed, found %v", entries)
+	}
+
+	// A file where a directory is expected fails for any user
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkWritable(file); err == nil || !strings.Contains(err.Error(), "is not writable") {
+		t.Errorf("expected %s to be reported as not writable, got %v", file, err)
+	}
+}
+
+func TestStorageBackendRefusesReadOnlyRoot(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("directory permissions do not apply to root")
+	}
+	readOnly := t.TempDir()
+	if err := os.Chmod(readOnly, 0555); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(readOnly, 0755) })
+
+	if err := checkWritable(readOnly); err == nil {
+		t.Error("expected a read-only directory to be reported")
+	}
+
+	t.Setenv("STORAGE_VIDEOS", readOnly)
+	_, err := newStorageBackend(os.Getenv, t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "videos") {
+		t.Errorf("expected the read-only videos root to be refused, got %v", err)
+	}
+}
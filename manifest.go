@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// manifestName is the checksum file /manifest stores in the folder it covers.
+const manifestName = "SHA256SUMS"
+
+// manifestEntry is one line of a manifest: a path relative to its folder.
+type manifestEntry struct {
+	Path   string
+	SHA256 string
+}
+
+// formatManifest renders entries in the format of sha256sum, which
+// sha256sum -c checks. Names with a backslash or newline are escaped the way
+// sha256sum does it, with a leading backslash on the line.
+func formatManifest(entries []manifestEntry) []byte {
+	var buf bytes.Buffer
+	for _, e := range entries {
+		name := e.Path
+		if strings.ContainsAny(name, "\\\n") {
+			name = strings.NewReplacer("\\", "\\\\", "\n", "\\n").Replace(name)
+			buf.WriteByte('\\')
+		}
+		fmt.Fprintf(&buf, "%s  %s\n", e.SHA256, name)
+	}
+	return buf.Bytes()
+}
+
+// folderManifest returns the checksum of every file below the storage folder
+// dir, in all data roots, sorted by path. The folder's own manifest is left
+// out. Checksums recorded in sidecars are reused; other files are hashed.
+func (b *Bot) folderManifest(dir string) ([]manifestEntry, error) {
+	var entries []manifestEntry
+	for _, root := range b.dataRoots() {
+		err := walkStoredFiles(filepath.Join(root, filepath.FromSlash(dir)), func(rel, src string, info os.FileInfo) error {
+			if rel == manifestName {
+				return nil
+			}
+			m := b.fileMeta(path.Join(dir, rel), src, info)
+			if m.SHA256 == "" {
+				return fmt.Errorf("failed to hash %s", rel)
+			}
+			entries = append(entries, manifestEntry{Path: rel, SHA256: m.SHA256})
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries, nil
+}
+
+// handleManifestCommand stores a SHA256SUMS file for one of the user's
+// folders (the top level by default) and sends it back.
+func (b *Bot) handleManifestCommand(chatID, userID int64, args string) {
+	if !b.requireLocalStorage(chatID) {
+		return
+	}
+
+	parts := splitArgs(args)
+	if len(parts) > 1 {
+		b.sendTextMessage(chatID, "Usage: /manifest [folder]\nQuote names containing spaces, e.g. /manifest \"my photos\"")
+		return
+	}
+	folder := ""
+	if len(parts) == 1 {
+		name, err := cleanRelPath(parts[0])
+		if err != nil {
+			b.sendTextMessage(chatID, "❌ Invalid folder name")
+			return
+		}
+		folder = b.storedRelPath(name)
+	}
+	dir := b.userPath(userID, folder)
+
+	entries, err := b.folderManifest(dir)
+	if err != nil {
+		log.Printf("Error building manifest of %s: %v", dir, err)
+		b.sendTextMessage(chatID, "❌ Failed to compute the checksums.")
+		return
+	}
+	if len(entries) == 0 {
+		b.sendTextMessage(chatID, "📁 No files stored in that folder.")
+		return
+	}
+	data := formatManifest(entries)
+
+	// A new manifest replaces the previous one
+	rel := path.Join(dir, manifestName)
+	if exists(b.localPath(rel)) {
+		if err := b.storage.Delete(rel); err != nil {
+			log.Printf("Error removing old manifest %s: %v", rel, err)
+			b.sendTextMessage(chatID, "❌ Failed to replace the existing manifest.")
+			return
+		}
+		b.forgetFile(rel)
+	}
+	saved, err := b.saveFile(bytes.NewReader(data), "documents", path.Join(folder, manifestName), "text/plain", userID)
+	if err != nil {
+		log.Printf("Error saving manifest %s: %v", rel, err)
+		b.sendTextMessage(chatID, b.failureReply(userID, err, "❌ Failed to save the manifest."))
+		return
+	}
+
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: manifestName, Bytes: data})
+	doc.Caption = fmt.Sprintf("🧾 Checksums of %d files, saved as '%s'. Verify with: sha256sum -c %s", len(entries), b.displayName(userID, saved.Path), manifestName)
+	if _, err := b.send(doc); err != nil {
+		log.Printf("Failed to send manifest: %v", err)
+		b.sendTextMessage(chatID, fmt.Sprintf("✅ Manifest saved as '%s', but sending it failed.", b.displayName(userID, saved.Path)))
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestManifestCommandMatchesFiles(t *testing.T) {
+	b := newTestBot(t)
+	b.perUserDirs = true
+	api := &mockTelegramAPI{}
+	b.client = api
+
+	writeTestFile(t, b, "42/a.txt", "alpha")
+	writeTestFile(t, b, "42/sub/b.txt", "beta")
+	writeTestFile(t, b, "7/other.txt", "not mine")
+	// A recorded checksum is reused instead of hashing the file again
+	writeTestFile(t, b, "42/cached.bin", "content")
+	if err := writeMeta(b.storagePath, FileMeta{Path: "42/cached.bin", Owner: 42, SHA256: "cafe"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := sha256Hex("alpha") + "  a.txt\n" +
+		"cafe  cached.bin\n" +
+		sha256Hex("beta") + "  sub/b.txt\n"
+
+	for i := 0; i < 2; i++ {
+		b.handleManifestCommand(42, 42, "")
+
+		stored, err := os.ReadFile(b.localPath("42/" + manifestName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(stored) != want {
+			t.Errorf("run %d: stored manifest\n%s\nwant\n%s", i, stored, want)
+		}
+		doc, ok := api.sent[len(api.sent)-1].(tgbotapi.DocumentConfig)
+		if !ok || string(doc.File.(tgbotapi.FileBytes).Bytes) != want || !strings.Contains(doc.Caption, "sha256sum -c SHA256SUMS") {
+			t.Errorf("run %d: unexpected document %+v", i, api.sent[len(api.sent)-1])
+		}
+	}
+	if _, err := os.Stat(b.localPath("42/" + manifestName + " (1)")); err == nil {
+		t.Error("expected the manifest to be replaced, not suffixed")
+	}
+
+	b.handleManifestCommand(42, 42, " sub")
+	if stored, _ := os.ReadFile(b.localPath("42/sub/" + manifestName)); string(stored) != sha256Hex("beta")+"  b.txt\n" {
+		t.Errorf("unexpected manifest of a subfolder %q", stored)
+	}
+
+	b.handleManifestCommand(42, 42, " empty")
+	if texts := api.texts(); len(texts) != 1 || !strings.HasPrefix(texts[0], "📁 No files") {
+		t.Errorf("unexpected replies %q", texts)
+	}
+}
+
+func TestFormatManifestEscapesNames(t *testing.T) {
+	got := string(formatManifest([]manifestEntry{{Path: "plain.txt", SHA256: "aa"}, {Path: "odd\\name\nx", SHA256: "bb"}}))
+	if want := "aa  plain.txt\n\\bb  odd\\\\name\\nx\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
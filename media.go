@@ -0,0 +1,348 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// DefaultMinFileSize rejects empty files unless MIN_FILE_SIZE says otherwise.
+const DefaultMinFileSize = 1
+
+// parseMinFileSize parses MIN_FILE_SIZE in bytes; 0 accepts every file.
+func parseMinFileSize(s string) (int64, error) {
+	if s == "" {
+		return DefaultMinFileSize, nil
+	}
+	size, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid MIN_FILE_SIZE %q: expected a number of bytes", s)
+	}
+	return size, nil
+}
+
+// sizeLimitVars names the variables setting the upload limit of one media type.
+var sizeLimitVars = map[string]string{
+	"documents":   "MAX_DOC_SIZE",
+	"photos":      "MAX_PHOTO_SIZE",
+	"videos":      "MAX_VIDEO_SIZE",
+	"animations":  "MAX_ANIMATION_SIZE",
+	"audio":       "MAX_AUDIO_SIZE",
+	"voice":       "MAX_VOICE_SIZE",
+	"video_notes": "MAX_VIDEO_NOTE_SIZE",
+	"stickers":    "MAX_STICKER_SIZE",
+}
+
+// sizeLimits are the upload limits in bytes. Media types without their own
+// limit use global, and a zero global means MaxFileSize.
+type sizeLimits struct {
+	global  int64
+	perType map[string]int64
+}
+
+// limit returns the limit for mediaType and whether it is the type's own.
+func (l sizeLimits) limit(mediaType string) (int64, bool) {
+	if size, ok := l.perType[mediaType]; ok {
+		return size, true
+	}
+	if l.global > 0 {
+		return l.global, false
+	}
+	return MaxFileSize, false
+}
+
+// parseSizeLimits reads MAX_FILE_SIZE and the per-type MAX_*_SIZE variables
+// through getenv.
+func parseSizeLimits(getenv func(string) string) (sizeLimits, error) {
+	var limits sizeLimits
+	var err error
+	if s := getenv("MAX_FILE_SIZE"); s != "" {
+		if limits.global, err = parseByteSize("MAX_FILE_SIZE", s); err != nil {
+			return sizeLimits{}, err
+		}
+	}
+	for mediaType, name := range sizeLimitVars {
+		s := getenv(name)
+		if s == "" {
+			continue
+		}
+		size, err := parseByteSize(name, s)
+		if err != nil {
+			return sizeLimits{}, err
+		}
+		if limits.perType == nil {
+			limits.perType = make(map[string]int64)
+		}
+		limits.perType[mediaType] = size
+	}
+	return limits, nil
+}
+
+// parseByteSize parses a positive size in bytes with an optional KB, MB or GB
+// suffix (powers of 1024), e.g. "10MB".
+func parseByteSize(name, s string) (int64, error) {
+	num, mult := strings.ToUpper(strings.TrimSpace(s)), int64(1)
+	for _, u := range []struct {
+		suffix string
+		mult   int64
+	}{{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}} {
+		if strings.HasSuffix(num, u.suffix) {
+			num, mult = strings.TrimSpace(strings.TrimSuffix(num, u.suffix)), u.mult
+			break
+		}
+	}
+	size, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || size <= 0 {
+		return 0, fmt.Errorf("invalid %s %q: expected a positive size such as 10MB", name, s)
+	}
+	return size * mult, nil
+}
+
+// formatLimit renders a limit in whole megabytes where possible.
+func formatLimit(size int64) string {
+	if size%(1024*1024) == 0 {
+		return fmt.Sprintf("%d MB", size/(1024*1024))
+	}
+	return formatSize(size)
+}
+
+// tooLargeMessage names the limit a file exceeded.
+func tooLargeMessage(media mediaFile, limit int64, perType bool) string {
+	if perType {
+		return fmt.Sprintf("%s too large. Maximum %s size is %s", media.limitLabel, media.Label, formatLimit(limit))
+	}
+	return fmt.Sprintf("%s too large. Maximum size is %s", media.limitLabel, formatLimit(limit))
+}
+
+// publicAPILimitMessage explains that media above PublicAPIDownloadLimit can't
+// be fetched through the public Bot API. Admins are told how to lift it.
+func (b *Bot) publicAPILimitMessage(media mediaFile, userID int64) string {
+	text := fmt.Sprintf("❌ This %s is %s, but Telegram only lets bots download files up to %s through the public Bot API, so it could not be stored.",
+		media.Label, formatSize(media.Size), formatLimit(PublicAPIDownloadLimit))
+	if b.isUserAdmin(userID) {
+		text += "\n\nTo store larger files, run a local Bot API server (telegram-bot-api) and point TELEGRAM_API_URL at it."
+	}
+	return text
+}
+
+// tooSmallError reports a file below MIN_FILE_SIZE. Declared is set when the
+// size reported by Telegram was checked before downloading.
+type tooSmallError struct {
+	Size     int64
+	Declared bool
+}
+
+func (e *tooSmallError) Error() string {
+	if e.Declared {
+		return fmt.Sprintf("declared size %d is below the minimum", e.Size)
+	}
+	return fmt.Sprintf("downloaded size %d is below the minimum", e.Size)
+}
+
+// tooSmallMessage explains why a file was not kept.
+func tooSmallMessage(label string, err *tooSmallError, min int64) string {
+	if err.Size == 0 {
+		return fmt.Sprintf("❌ The %s arrived empty (0 B) and was discarded. Please send it again.", label)
+	}
+	return fmt.Sprintf("❌ The %s is only %s, below the %s minimum, and was not saved.", label, formatSize(err.Size), formatSize(min))
+}
+
+// mediaFile is the Telegram file carried by a media message, with what is
+// needed to store it.
+type mediaFile struct {
+	MediaType    string // storage media type, e.g. "video_notes"
+	Label        string // as named in replies, e.g. "video note"
+	FileID       string
+	FileUniqueID string
+	Size         int64  // declared by Telegram, 0 if unknown
+	Name         string // suggested by the sender, "" if none
+	MIME         string
+	// Protected is set for media sent with content protection, which
+	// Telegram clients refuse to forward or save
+	Protected bool
+	// MessageID is the message carrying the file
+	MessageID int
+
+	// Generated names are <prefix>_<unix time>_<file ID><ext>
+	prefix, ext string
+	// limitLabel names the content in the too-large reply
+	limitLabel string
+}
+
+// messageMedia returns the file of a media message, or nil for messages
+// without one. For photos it is the largest size.
+func messageMedia(message *tgbotapi.Message) *mediaFile {
+	m := messageFile(message)
+	if m != nil {
+		m.Protected = message.HasProtectedContent
+		m.MessageID = message.MessageID
+	}
+	return m
+}
+
+// messageMediaType returns the media type of a message, or "" for messages
+// without content to store. File media take theirs from messageFile.
+func messageMediaType(message *tgbotapi.Message) string {
+	if m := messageFile(message); m != nil {
+		return m.MediaType
+	}
+	switch {
+	case message.Location != nil:
+		return "locations"
+	case message.Contact != nil:
+		return "contacts"
+	case message.Poll != nil:
+		return "polls"
+	}
+	return ""
+}
+
+func messageFile(message *tgbotapi.Message) *mediaFile {
+	switch {
+	case message.Animation != nil:
+		a := message.Animation
+		return &mediaFile{MediaType: "animations", Label: "animation", FileID: a.FileID, FileUniqueID: a.FileUniqueID,
+			Size: int64(a.FileSize), Name: a.FileName, MIME: a.MimeType, prefix: "animation", ext: animationExt(a.MimeType), limitLabel: "Animation"}
+	case message.Document != nil:
+		d := message.Document
+		return &mediaFile{MediaType: "documents", Label: "document", FileID: d.FileID, FileUniqueID: d.FileUniqueID,
+			Size: int64(d.FileSize), Name: d.FileName, MIME: d.MimeType, prefix: "document", limitLabel: "File"}
+	case len(message.Photo) > 0:
+		p := message.Photo[len(message.Photo)-1]
+		return &mediaFile{MediaType: "photos", Label: "photo", FileID: p.FileID, FileUniqueID: p.FileUniqueID,
+			Size: int64(p.FileSize), MIME: "image/jpeg", prefix: "photo", ext: ".jpg", limitLabel: "Photo"}
+	case message.Video != nil:
+		v := message.Video
+		return &mediaFile{MediaType: "videos", Label: "video", FileID: v.FileID, FileUniqueID: v.FileUniqueID,
+			Size: int64(v.FileSize), MIME: v.MimeType, prefix: "video", ext: ".mp4", limitLabel: "Video"}
+	case message.Audio != nil:
+		a := message.Audio
+		return &mediaFile{MediaType: "audio", Label: "audio", FileID: a.FileID, FileUniqueID: a.FileUniqueID,
+			Size: int64(a.FileSize), Name: a.FileName, MIME: a.MimeType, prefix: "audio", ext: ".mp3", limitLabel: "Audio"}
+	case message.Voice != nil:
+		v := message.Voice
+		return &mediaFile{MediaType: "voice", Label: "voice message", FileID: v.FileID, FileUniqueID: v.FileUniqueID,
+			Size: int64(v.FileSize), MIME: v.MimeType, prefix: "voice", ext: ".ogg", limitLabel: "Voice message"}
+	case message.VideoNote != nil:
+		v := message.VideoNote
+		return &mediaFile{MediaType: "video_notes", Label: "video note", FileID: v.FileID, FileUniqueID: v.FileUniqueID,
+			Size: int64(v.FileSize), MIME: "video/mp4", prefix: "videonote", ext: ".mp4", limitLabel: "Video note"}
+	case message.Sticker != nil:
+		s := message.Sticker
+		return &mediaFile{MediaType: "stickers", Label: "sticker", FileID: s.FileID, FileUniqueID: s.FileUniqueID,
+			Size: int64(s.FileSize), MIME: "image/webp", prefix: "sticker", ext: ".webp", limitLabel: "Sticker"}
+	}
+	return nil
+}
+
+// animationExt returns the extension of an animation: Telegram converts most
+// GIFs to silent MP4 videos, but some arrive as real GIFs.
+func animationExt(mimeType string) string {
+	if mimeType == "image/gif" {
+		return ".gif"
+	}
+	return ".mp4"
+}
+
+// fileName returns the sanitized suggested name, or a generated one.
+func (m mediaFile) fileName(now time.Time) string {
+	if name := sanitizeFileName(m.Name); name != "" {
+		return name
+	}
+	return fmt.Sprintf("%s_%d_%s%s", m.prefix, now.Unix(), m.FileID, m.ext)
+}
+
+// formatPeek describes media as Telegram declared it, with the name it would
+// be stored under.
+func formatPeek(media mediaFile, name string) string {
+	size := "unknown"
+	if media.Size > 0 {
+		size = formatSize(media.Size)
+	}
+	mimeType := media.MIME
+	if mimeType == "" {
+		mimeType = "unknown"
+	}
+	text := fmt.Sprintf("🔎 %s (%s)\n\nfile_id: %s\nfile_unique_id: %s\nDeclared size: %s\nMIME: %s\nWould be stored as: %s",
+		media.Label, media.MediaType, media.FileID, media.FileUniqueID, size, mimeType, name)
+	if media.Protected {
+		text += "\n🔒 Sent with protected content"
+	}
+	return text
+}
+
+// handlePeekCommand reports the Telegram metadata of the media message it
+// replies to, without downloading or storing anything.
+func (b *Bot) handlePeekCommand(message *tgbotapi.Message, chatID int64) {
+	var media *mediaFile
+	if message.ReplyToMessage != nil {
+		media = messageMedia(message.ReplyToMessage)
+	}
+	if media == nil {
+		b.sendTextMessage(chatID, "Usage: reply to a file, photo, video or other media message with /peek to see its details without storing it")
+		return
+	}
+	b.sendTextMessage(chatID, formatPeek(*media, b.storedRelPath(b.organizedName(media.MediaType, media.fileName(time.Now()), time.Now()))))
+}
+
+// handleMedia downloads the file of a media message userID sent in chatID and
+// stores it for them.
+func (b *Bot) handleMedia(media mediaFile, chatID, userID int64) {
+	if media.Protected && b.rejectProtected {
+		log.Printf("Rejecting protected %s %s from user %d", media.Label, media.FileID, userID)
+		b.sendTextMessage(chatID, fmt.Sprintf("🔒 This %s was sent with forwarding and saving restricted, so it was not stored.", media.Label))
+		return
+	}
+	if limit, perType := b.sizeLimits.limit(media.MediaType); media.Size > limit {
+		b.sendTextMessage(chatID, tooLargeMessage(media, limit, perType))
+		return
+	}
+	// Telegram leaves the size out when it does not know it; such files are
+	// checked once downloaded
+	if media.Size > 0 && media.Size < b.minFileSize {
+		log.Printf("Rejecting %s %s: declared size %d", media.Label, media.FileID, media.Size)
+		b.sendTextMessage(chatID, tooSmallMessage(media.Label, &tooSmallError{Size: media.Size, Declared: true}, b.minFileSize))
+		return
+	}
+	// getFile would fail with a bare error, so explain the limit up front
+	if media.Size > PublicAPIDownloadLimit && !b.localBotAPI {
+		log.Printf("Rejecting %s %s: declared size %d exceeds the public Bot API download limit", media.Label, media.FileID, media.Size)
+		b.sendTextMessage(chatID, b.publicAPILimitMessage(media, userID))
+		return
+	}
+
+	if !b.isUserTrusted(userID) {
+		b.handleUntrustedMedia(media, chatID, userID)
+		return
+	}
+
+	saved, err := b.downloadAndSave(media, b.organizedName(media.MediaType, media.fileName(time.Now()), time.Now()), chatID, userID)
+	var tooSmall *tooSmallError
+	if errors.As(err, &tooSmall) {
+		log.Printf("Discarded %s from user %d: %v", media.Label, userID, err)
+		b.sendTextMessage(chatID, tooSmallMessage(media.Label, tooSmall, b.minFileSize))
+		return
+	}
+	if err != nil {
+		log.Printf("Error handling %s (%s): %v", media.Label, errorCategory(err), err)
+		b.sendTextMessage(chatID, b.failureReply(userID, err, fmt.Sprintf("Failed to save the %s.", media.Label)))
+		return
+	}
+	b.recentUploads.Mark(userID, media.FileUniqueID)
+
+	// Force status update when file is received
+	b.forceStatusUpdate(chatID)
+
+	files := []FileMeta{saved}
+	if media.MediaType == "voice" {
+		files = b.convertVoice(saved)
+	}
+	// Remember the message so editing its caption can rename the file
+	b.savedUploads.Track(chatID, media.MessageID, userID, []string{files[0].Path})
+	b.replySaved(chatID, userID, files...)
+}
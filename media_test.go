@@ -0,0 +1,462 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestMessageMediaFileNames(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	cases := []struct {
+		message tgbotapi.Message
+		media   string
+		name    string
+		mime    string
+	}{
+		{tgbotapi.Message{Document: &tgbotapi.Document{FileID: "d1", FileName: "report.pdf", MimeType: "application/pdf"}}, "documents", "report.pdf", "application/pdf"},
+		{tgbotapi.Message{Document: &tgbotapi.Document{FileID: "d2"}}, "documents", "document_1700000000_d2", ""},
+		{tgbotapi.Message{Photo: []tgbotapi.PhotoSize{{FileID: "small"}, {FileID: "p1"}}}, "photos", "photo_1700000000_p1.jpg", "image/jpeg"},
+		{tgbotapi.Message{Video: &tgbotapi.Video{FileID: "v1", MimeType: "video/quicktime"}}, "videos", "video_1700000000_v1.mp4", "video/quicktime"},
+		{tgbotapi.Message{Animation: &tgbotapi.Animation{FileID: "g1", MimeType: "video/mp4"}, Document: &tgbotapi.Document{FileID: "g1"}}, "animations", "animation_1700000000_g1.mp4", "video/mp4"},
+		{tgbotapi.Message{Animation: &tgbotapi.Animation{FileID: "g2", MimeType: "image/gif"}}, "animations", "animation_1700000000_g2.gif", "image/gif"},
+		{tgbotapi.Message{Animation: &tgbotapi.Animation{FileID: "g3", FileName: "cat.mp4", MimeType: "video/mp4"}}, "animations", "cat.mp4", "video/mp4"},
+		{tgbotapi.Message{Audio: &tgbotapi.Audio{FileID: "a1", FileName: "song/1.mp3", MimeType: "audio/mpeg"}}, "audio", "song_1.mp3", "audio/mpeg"},
+		{tgbotapi.Message{Audio: &tgbotapi.Audio{FileID: "a2"}}, "audio", "audio_1700000000_a2.mp3", ""},
+		{tgbotapi.Message{Voice: &tgbotapi.Voice{FileID: "o1", MimeType: "audio/ogg"}}, "voice", "voice_1700000000_o1.ogg", "audio/ogg"},
+		{tgbotapi.Message{VideoNote: &tgbotapi.VideoNote{FileID: "n1"}}, "video_notes", "videonote_1700000000_n1.mp4", "video/mp4"},
+		{tgbotapi.Message{Sticker: &tgbotapi.Sticker{FileID: "s1"}}, "stickers", "sticker_1700000000_s1.webp", "image/webp"},
+	}
+	for _, c := range cases {
+		m := messageMedia(&c.message)
+		if m == nil {
+			t.Fatalf("expected media for %+v", c.message)
+		}
+		if m.MediaType != c.media || m.fileName(now) != c.name || m.MIME != c.mime {
+			t.Errorf("got %s %q %q; want %s %q %q", m.MediaType, m.fileName(now), m.MIME, c.media, c.name, c.mime)
+		}
+		if m.MediaType != messageMediaType(&c.message) {
+			t.Errorf("media type %s disagrees with messageMediaType %s", m.MediaType, messageMediaType(&c.message))
+		}
+	}
+
+	if m := messageMedia(&tgbotapi.Message{Text: "hello"}); m != nil {
+		t.Errorf("expected no media for a text message, got %+v", m)
+	}
+}
+
+func TestHandleMediaSizeLimits(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+
+	tooBig := MaxFileSize + 1
+	for _, message := range []*tgbotapi.Message{
+		{Document: &tgbotapi.Document{FileID: "d", FileSize: tooBig}},
+		{Video: &tgbotapi.Video{FileID: "v", FileSize: tooBig}},
+		{Audio: &tgbotapi.Audio{FileID: "a", FileSize: tooBig}},
+	} {
+		b.handleMedia(*messageMedia(message), 42, 42)
+	}
+
+	want := []string{
+		"File too large. Maximum size is 50 MB",
+		"Video too large. Maximum size is 50 MB",
+		"Audio too large. Maximum size is 50 MB",
+	}
+	if texts := api.texts(); strings.Join(texts, "|") != strings.Join(want, "|") {
+		t.Errorf("unexpected replies %q", texts)
+	}
+}
+
+func TestHandleMediaStoresEachType(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("content of " + strings.TrimPrefix(r.URL.Path, "/")))
+	}))
+	defer srv.Close()
+
+	b := newDownloadTestBot(t, 5*time.Second)
+	api := &mockTelegramAPI{fileURL: srv.URL}
+	b.client = api
+
+	cases := []struct {
+		message *tgbotapi.Message
+		stored  string // pattern of the stored name
+		mime    string
+	}{
+		{&tgbotapi.Message{Document: &tgbotapi.Document{FileID: "d1", FileName: "report.pdf", MimeType: "application/pdf"}}, `report\.pdf`, "application/pdf"},
+		{&tgbotapi.Message{Photo: []tgbotapi.PhotoSize{{FileID: "p1"}}}, `photo_\d+_p1\.jpg`, "image/jpeg"},
+		{&tgbotapi.Message{Video: &tgbotapi.Video{FileID: "v1", MimeType: "video/mp4"}}, `video_\d+_v1\.mp4`, "video/mp4"},
+		{&tgbotapi.Message{Animation: &tgbotapi.Animation{FileID: "g1", MimeType: "video/mp4"}, Document: &tgbotapi.Document{FileID: "g1", MimeType: "video/mp4"}}, `animation_\d+_g1\.mp4`, "video/mp4"},
+		{&tgbotapi.Message{Audio: &tgbotapi.Audio{FileID: "a1", FileName: "song.mp3", MimeType: "audio/mpeg"}}, `song\.mp3`, "audio/mpeg"},
+		{&tgbotapi.Message{Voice: &tgbotapi.Voice{FileID: "o1", MimeType: "audio/ogg"}}, `voice_\d+_o1\.ogg`, "audio/ogg"},
+		{&tgbotapi.Message{VideoNote: &tgbotapi.VideoNote{FileID: "n1"}}, `videonote_\d+_n1\.mp4`, "video/mp4"},
+		{&tgbotapi.Message{Sticker: &tgbotapi.Sticker{FileID: "s1"}}, `sticker_\d+_s1\.webp`, "image/webp"},
+	}
+	for i, c := range cases {
+		c.message.From = &tgbotapi.User{ID: 42}
+		c.message.Chat = &tgbotapi.Chat{ID: 42}
+		b.handleMessage(c.message)
+
+		texts := api.texts()
+		if len(texts) != i+1 {
+			t.Fatalf("expected one reply per message, got %q", texts)
+		}
+		match := regexp.MustCompile(`^✅ Saved '(` + c.stored + `)' \(\d+ B\)$`).FindStringSubmatch(texts[i])
+		if match == nil {
+			t.Errorf("unexpected reply %q for %s", texts[i], c.stored)
+			continue
+		}
+		meta, err := readMeta(b.storagePath, match[1])
+		if err != nil || meta.MIME != c.mime || meta.Owner != 42 {
+			t.Errorf("unexpected metadata for %s: %+v (%v)", match[1], meta, err)
+		}
+	}
+}
+
+func TestHandleAnimation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("GIF89a"))
+	}))
+	defer srv.Close()
+
+	b := newDownloadTestBot(t, 5*time.Second)
+	b.sizeLimits = sizeLimits{perType: map[string]int64{"animations": 1 << 20}}
+	api := &mockTelegramAPI{fileURL: srv.URL}
+	b.client = api
+
+	// Telegram sets Document too; the animation must win
+	send := func(size int) {
+		b.handleMessage(&tgbotapi.Message{
+			From:      &tgbotapi.User{ID: 42},
+			Chat:      &tgbotapi.Chat{ID: 42},
+			Animation: &tgbotapi.Animation{FileID: "gif", FileUniqueID: fmt.Sprint("u", size), FileName: "dance.gif", MimeType: "image/gif", FileSize: size},
+			Document:  &tgbotapi.Document{FileID: "gif", FileName: "dance.gif", MimeType: "image/gif", FileSize: size},
+		})
+	}
+	send(2 << 20)
+	send(6)
+
+	texts := api.texts()
+	if len(texts) != 2 || texts[0] != "Animation too large. Maximum animation size is 1 MB" || texts[1] != "✅ Saved 'dance.gif' (6 B)" {
+		t.Fatalf("unexpected replies %q", texts)
+	}
+	meta, err := readMeta(b.storagePath, "dance.gif")
+	if err != nil || meta.MIME != "image/gif" {
+		t.Errorf("unexpected metadata %+v (%v)", meta, err)
+	}
+}
+
+func TestParseMinFileSize(t *testing.T) {
+	for in, want := range map[string]int64{"": DefaultMinFileSize, "0": 0, "1024": 1024} {
+		if got, err := parseMinFileSize(in); err != nil || got != want {
+			t.Errorf("parseMinFileSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"-1", "1KB"} {
+		if _, err := parseMinFileSize(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestHandleMediaRejectsDeclaredUndersized(t *testing.T) {
+	downloads := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+	}))
+	defer srv.Close()
+
+	b := newDownloadTestBot(t, 5*time.Second)
+	b.minFileSize = 10
+	api := &mockTelegramAPI{fileURL: srv.URL}
+	b.client = api
+
+	b.handleMedia(*messageMedia(&tgbotapi.Message{Document: &tgbotapi.Document{FileID: "d1", FileName: "small.txt", FileSize: 5}}), 42, 42)
+
+	if downloads != 0 {
+		t.Errorf("expected no download for a declared undersized file, got %d", downloads)
+	}
+	if texts := api.texts(); len(texts) != 1 || texts[0] != "❌ The document is only 5 B, below the 10 B minimum, and was not saved." {
+		t.Errorf("unexpected replies %q", texts)
+	}
+}
+
+func TestHandleMediaChecksUnknownSizeAfterDownload(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/full" {
+			w.Write([]byte("content"))
+		}
+	}))
+	defer srv.Close()
+
+	b := newDownloadTestBot(t, 5*time.Second)
+	b.minFileSize = 1
+	api := &mockTelegramAPI{fileURL: srv.URL}
+	b.client = api
+
+	// Telegram omits file_size when it does not know it
+	b.handleMedia(*messageMedia(&tgbotapi.Message{Document: &tgbotapi.Document{FileID: "full", FileName: "a.txt"}}), 42, 42)
+	b.handleMedia(*messageMedia(&tgbotapi.Message{Document: &tgbotapi.Document{FileID: "empty", FileName: "b.txt"}}), 42, 42)
+
+	if data, err := os.ReadFile(b.localPath("a.txt")); err != nil || string(data) != "content" {
+		t.Errorf("expected a file of unknown size to be stored, got %q (%v)", data, err)
+	}
+	if exists(b.localPath("b.txt")) {
+		t.Error("expected an empty download of unknown size to be discarded")
+	}
+	if texts := api.texts(); len(texts) != 2 || !strings.Contains(texts[1], "arrived empty (0 B) and was discarded") {
+		t.Errorf("unexpected replies %q", texts)
+	}
+}
+
+func TestHandleMediaDiscardsUndersizedDownload(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/tiny" {
+			w.Write([]byte("abc"))
+		}
+	}))
+	defer srv.Close()
+
+	b := newDownloadTestBot(t, 5*time.Second)
+	b.minFileSize = 10
+	api := &mockTelegramAPI{fileURL: srv.URL}
+	b.client = api
+
+	// Declared big enough, but the download is empty or short
+	b.handleMedia(*messageMedia(&tgbotapi.Message{Document: &tgbotapi.Document{FileID: "empty", FileName: "a.bin", FileSize: 100}}), 42, 42)
+	b.handleMedia(*messageMedia(&tgbotapi.Message{Document: &tgbotapi.Document{FileID: "tiny", FileName: "b.bin", FileSize: 100}}), 42, 42)
+
+	texts := api.texts()
+	if len(texts) != 2 || !strings.Contains(texts[0], "arrived empty (0 B) and was discarded") ||
+		texts[1] != "❌ The document is only 3 B, below the 10 B minimum, and was not saved." {
+		t.Fatalf("unexpected replies %q", texts)
+	}
+	for _, name := range []string{"a.bin", "b.bin"} {
+		if _, err := os.Stat(b.localPath(name)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be deleted, got %v", name, err)
+		}
+		if _, err := readMeta(b.storagePath, name); err == nil {
+			t.Errorf("expected the metadata of %s to be removed", name)
+		}
+	}
+}
+
+func TestSizeLimitsPerType(t *testing.T) {
+	env := map[string]string{"MAX_FILE_SIZE": "20MB", "MAX_PHOTO_SIZE": "5MB", "MAX_VIDEO_SIZE": "2GB", "MAX_DOC_SIZE": "1048576"}
+	limits, err := parseSizeLimits(func(name string) string { return env[name] })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		mediaType string
+		limit     int64
+		perType   bool
+	}{
+		{"photos", 5 << 20, true},
+		{"videos", 2 << 30, true},
+		{"documents", 1 << 20, true},
+		{"audio", 20 << 20, false},
+		{"voice", 20 << 20, false},
+		{"video_notes", 20 << 20, false},
+		{"stickers", 20 << 20, false},
+	}
+	for _, c := range cases {
+		if limit, perType := limits.limit(c.mediaType); limit != c.limit || perType != c.perType {
+			t.Errorf("limit(%s) = %d, %v; want %d, %v", c.mediaType, limit, perType, c.limit, c.perType)
+		}
+	}
+
+	// Without any variable every type keeps the single global limit
+	var unset sizeLimits
+	for _, mediaType := range mediaTypes {
+		if limit, perType := unset.limit(mediaType); limit != MaxFileSize || perType {
+			t.Errorf("default limit(%s) = %d, %v", mediaType, limit, perType)
+		}
+	}
+
+	for _, bad := range []string{"0", "-5MB", "10TB", "big"} {
+		if _, err := parseSizeLimits(func(name string) string {
+			if name == "MAX_AUDIO_SIZE" {
+				return bad
+			}
+			return ""
+		}); err == nil || !strings.Contains(err.Error(), "MAX_AUDIO_SIZE") {
+			t.Errorf("expected MAX_AUDIO_SIZE=%q to be rejected, got %v", bad, err)
+		}
+	}
+}
+
+func TestHandleMediaReportsExceededLimit(t *testing.T) {
+	b := newTestBot(t)
+	b.sizeLimits = sizeLimits{global: 20 << 20, perType: map[string]int64{"photos": 5 << 20, "videos": 1536 << 10}}
+	api := &mockTelegramAPI{}
+	b.client = api
+
+	for _, message := range []*tgbotapi.Message{
+		{Photo: []tgbotapi.PhotoSize{{FileID: "p", FileSize: 6 << 20}}},
+		{Video: &tgbotapi.Video{FileID: "v", FileSize: 2 << 20}},
+		{Voice: &tgbotapi.Voice{FileID: "o", FileSize: 21 << 20}},
+	} {
+		b.handleMedia(*messageMedia(message), 42, 42)
+	}
+
+	want := []string{
+		"Photo too large. Maximum photo size is 5 MB",
+		"Video too large. Maximum video size is 1.5 MB",
+		"Voice message too large. Maximum size is 20 MB",
+	}
+	if texts := api.texts(); strings.Join(texts, "|") != strings.Join(want, "|") {
+		t.Errorf("unexpected replies %q", texts)
+	}
+}
+
+func TestHandleMediaRecordsProtectedContent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secret"))
+	}))
+	defer srv.Close()
+
+	b := newDownloadTestBot(t, 5*time.Second)
+	b.client = &mockTelegramAPI{fileURL: srv.URL}
+
+	message := &tgbotapi.Message{HasProtectedContent: true, Document: &tgbotapi.Document{FileID: "d", FileName: "secret.pdf"}}
+	b.handleMedia(*messageMedia(message), 42, 42)
+	b.handleMedia(*messageMedia(&tgbotapi.Message{Document: &tgbotapi.Document{FileID: "d", FileName: "open.pdf"}}), 42, 42)
+
+	if meta, err := readMeta(b.storagePath, "secret.pdf"); err != nil || !meta.Protected || meta.FileID != "d" {
+		t.Errorf("expected the protected flag in the sidecar, got %+v (%v)", meta, err)
+	}
+	if meta, err := readMeta(b.storagePath, "open.pdf"); err != nil || meta.Protected {
+		t.Errorf("expected no protected flag, got %+v (%v)", meta, err)
+	}
+}
+
+func TestHandleMediaRejectsProtectedContent(t *testing.T) {
+	downloads := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		w.Write([]byte("secret"))
+	}))
+	defer srv.Close()
+
+	b := newDownloadTestBot(t, 5*time.Second)
+	api := &mockTelegramAPI{fileURL: srv.URL}
+	b.client = api
+	b.rejectProtected = true
+
+	b.handleMedia(*messageMedia(&tgbotapi.Message{HasProtectedContent: true, Photo: []tgbotapi.PhotoSize{{FileID: "p"}}}), 42, 42)
+
+	if downloads != 0 {
+		t.Errorf("expected no download, got %d", downloads)
+	}
+	if files, _ := listStoredFiles(b.storagePath); len(files) != 0 {
+		t.Errorf("expected nothing stored, got %+v", files)
+	}
+	if texts := api.texts(); len(texts) != 1 || !strings.HasPrefix(texts[0], "🔒 This photo was sent with forwarding and saving restricted") {
+		t.Errorf("unexpected reply %q", texts)
+	}
+}
+
+func TestHandleMediaPublicAPIDownloadLimit(t *testing.T) {
+	video := func(size int) mediaFile {
+		return *messageMedia(&tgbotapi.Message{Video: &tgbotapi.Video{FileID: "v1", FileSize: size}})
+	}
+
+	cases := []struct {
+		name     string
+		size     int
+		local    bool
+		admin    bool
+		getsFile bool
+	}{
+		{"at the limit", PublicAPIDownloadLimit, false, false, true},
+		{"above the limit", PublicAPIDownloadLimit + 1, false, false, false},
+		{"above the limit, admin", 35 * 1024 * 1024, false, true, false},
+		{"above the limit, local Bot API", 35 * 1024 * 1024, true, false, true},
+	}
+	for _, c := range cases {
+		b := newTestBot(t)
+		api := &mockTelegramAPI{fileErr: errors.New("getFile failed")}
+		b.client = api
+		b.localBotAPI = c.local
+		if c.admin {
+			b.adminUsers[42] = true
+		}
+
+		b.handleMedia(video(c.size), 42, 42)
+
+		if got := len(api.fileIDs) == 1; got != c.getsFile {
+			t.Errorf("%s: expected getFile called %v, got %d calls", c.name, c.getsFile, len(api.fileIDs))
+		}
+		texts := api.texts()
+		if len(texts) != 1 {
+			t.Fatalf("%s: expected one reply, got %q", c.name, texts)
+		}
+		explained := strings.Contains(texts[0], "up to 20 MB through the public Bot API")
+		if explained == c.getsFile {
+			t.Errorf("%s: unexpected reply %q", c.name, texts[0])
+		}
+		if hinted := strings.Contains(texts[0], "TELEGRAM_API_URL"); hinted != (c.admin && !c.getsFile) {
+			t.Errorf("%s: unexpected local Bot API hint in %q", c.name, texts[0])
+		}
+	}
+}
+
+func TestPeekReportsMetadataPerType(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+
+	cases := []struct {
+		message *tgbotapi.Message
+		want    []string
+	}{
+		{&tgbotapi.Message{Document: &tgbotapi.Document{FileID: "d1", FileUniqueID: "ud1", FileSize: 2048, FileName: "report.pdf", MimeType: "application/pdf"}},
+			[]string{"🔎 document (documents)", "file_id: d1", "file_unique_id: ud1", "Declared size: 2.0 KB", "MIME: application/pdf", "Would be stored as: report.pdf"}},
+		{&tgbotapi.Message{Photo: []tgbotapi.PhotoSize{{FileID: "small"}, {FileID: "p1", FileUniqueID: "up1", FileSize: 10}}},
+			[]string{"🔎 photo (photos)", "file_id: p1", "file_unique_id: up1", "Declared size: 10 B", "MIME: image/jpeg", "Would be stored as: photo_"}},
+		{&tgbotapi.Message{Video: &tgbotapi.Video{FileID: "v1", FileUniqueID: "uv1", MimeType: "video/mp4"}},
+			[]string{"🔎 video (videos)", "file_id: v1", "Declared size: unknown", "_v1.mp4"}},
+		{&tgbotapi.Message{Audio: &tgbotapi.Audio{FileID: "a1", FileUniqueID: "ua1", FileName: "song.mp3", MimeType: "audio/mpeg"}},
+			[]string{"🔎 audio (audio)", "MIME: audio/mpeg", "Would be stored as: song.mp3"}},
+		{&tgbotapi.Message{Voice: &tgbotapi.Voice{FileID: "o1", FileUniqueID: "uo1"}},
+			[]string{"🔎 voice message (voice)", "MIME: unknown", "_o1.ogg"}},
+		{&tgbotapi.Message{VideoNote: &tgbotapi.VideoNote{FileID: "n1", FileUniqueID: "un1"}},
+			[]string{"🔎 video note (video_notes)", "MIME: video/mp4", "Would be stored as: videonote_"}},
+		{&tgbotapi.Message{HasProtectedContent: true, Sticker: &tgbotapi.Sticker{FileID: "s1", FileUniqueID: "us1"}},
+			[]string{"🔎 sticker (stickers)", "_s1.webp", "🔒 Sent with protected content"}},
+	}
+	for i, c := range cases {
+		b.handlePeekCommand(&tgbotapi.Message{Text: "/peek", ReplyToMessage: c.message}, 42)
+		texts := api.texts()
+		if len(texts) != i+1 {
+			t.Fatalf("expected one reply per peek, got %q", texts)
+		}
+		for _, want := range c.want {
+			if !strings.Contains(texts[i], want) {
+				t.Errorf("expected %q in %q", want, texts[i])
+			}
+		}
+	}
+	if files, _ := listStoredFiles(b.storagePath); len(files) != 0 {
+		t.Errorf("expected nothing stored, got %+v", files)
+	}
+
+	b.handlePeekCommand(&tgbotapi.Message{Text: "/peek"}, 42)
+	b.handlePeekCommand(&tgbotapi.Message{Text: "/peek", ReplyToMessage: &tgbotapi.Message{Text: "hello"}}, 42)
+	for _, text := range api.texts()[len(cases):] {
+		if !strings.HasPrefix(text, "Usage: reply to") {
+			t.Errorf("expected usage, got %q", text)
+		}
+	}
+}
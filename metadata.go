@@ -0,0 +1,230 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// metaDirName is the hidden directory inside the storage root that holds metadata sidecars.
+const metaDirName = ".meta"
+
+// FileMeta describes a stored file. It is persisted as a JSON sidecar next to the
+// storage tree and mirrored into the optional SQLite index.
+type FileMeta struct {
+	Path       string    `json:"path"` // relative to the storage root, slash-separated
+	Owner      int64     `json:"owner"`
+	Size       int64     `json:"size"`
+	MIME       string    `json:"mime,omitempty"`
+	SHA256     string    `json:"sha256,omitempty"`
+	ReceivedAt time.Time `json:"received_at"`
+	SharedBy   int64     `json:"shared_by,omitempty"` // sender, for files received via /share
+	// OriginalName is the name as sent when it was stored under an ASCII-safe
+	// or lowercased name
+	OriginalName string `json:"original_name,omitempty"`
+	// Pinned files are protected from retention (/pin)
+	Pinned bool `json:"pinned,omitempty"`
+	// Tags are the file's normalized tags (/tag)
+	Tags []string `json:"tags,omitempty"`
+	// ExpiresAt is when the janitor deletes the file, as scheduled by
+	// /expire; it applies to pinned files too
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// FileID is Telegram's file_id of an uploaded file, which lets inline
+	// queries resend it; MediaType is the kind of file it identifies
+	FileID    string `json:"file_id,omitempty"`
+	MediaType string `json:"media_type,omitempty"`
+	// Protected files were sent with content protection (no forwarding or saving)
+	Protected bool `json:"protected,omitempty"`
+	// Compressed files are stored gzip-compressed under their name plus ".gz";
+	// Size and SHA256 describe the original content
+	Compressed bool `json:"compressed,omitempty"`
+	// OriginalSize is the size an image had as received before
+	// RECOMPRESS_IMAGES re-encoded it smaller
+	OriginalSize int64 `json:"original_size,omitempty"`
+	// Appended is how many bytes APPEND_ON_DUPLICATE just added to an
+	// existing file; Size is then the new total. Not stored
+	Appended int64 `json:"-"`
+}
+
+// storedFile is a single entry returned by file listings.
+type storedFile struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+	Owner   int64 // only set by owner-aware listings; 0 if unknown
+}
+
+// metaPath returns the sidecar location for a file stored at rel.
+func metaPath(root, rel string) string {
+	return filepath.Join(root, metaDirName, filepath.FromSlash(rel)+".json")
+}
+
+// writeMeta stores the metadata sidecar for m.Path.
+func writeMeta(root string, m FileMeta) error {
+	path := metaPath(root, m.Path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create metadata directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write metadata: %w", err)
+	}
+	return nil
+}
+
+// readMeta loads the metadata sidecar for the file stored at rel.
+func readMeta(root, rel string) (FileMeta, error) {
+	var m FileMeta
+
+	data, err := os.ReadFile(metaPath(root, rel))
+	if err != nil {
+		return m, err
+	}
+
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("failed to parse metadata: %w", err)
+	}
+	return m, nil
+}
+
+// isHiddenName reports whether a directory entry is internal to the bot (sidecars, trash, etc.).
+func isHiddenName(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
+
+// listStoredFiles returns the regular files directly inside dir, newest first.
+func listStoredFiles(dir string) ([]storedFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read storage directory: %w", err)
+	}
+
+	var files []storedFile
+	for _, entry := range entries {
+		if entry.IsDir() || isHiddenName(entry.Name()) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		files = append(files, storedFile{
+			Name:    entry.Name(),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+	}
+
+	sortNewestFirst(files)
+	return files, nil
+}
+
+// walkStoredFiles calls fn for every regular file under root, skipping hidden
+// entries. rel is root-relative and slash-separated.
+func walkStoredFiles(root string, fn func(rel, path string, info os.FileInfo) error) error {
+	return filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		if isHiddenName(entry.Name()) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		return fn(filepath.ToSlash(rel), path, info)
+	})
+}
+
+// listStoredFilesRecursive returns every regular file under the data roots, newest
+// first. Names are root-relative and slash-separated; owners come from the
+// metadata sidecars under metaRoot.
+func listStoredFilesRecursive(metaRoot string, dataRoots []string) ([]storedFile, error) {
+	var files []storedFile
+	for _, root := range dataRoots {
+		err := walkStoredFiles(root, func(rel, _ string, info os.FileInfo) error {
+			f := storedFile{Name: rel, Size: info.Size(), ModTime: info.ModTime()}
+			if m, err := readMeta(metaRoot, rel); err == nil {
+				f.Owner = m.Owner
+			}
+			files = append(files, f)
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to walk storage directory: %w", err)
+		}
+	}
+
+	sortNewestFirst(files)
+	return files, nil
+}
+
+func sortNewestFirst(files []storedFile) {
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime.After(files[j].ModTime)
+	})
+}
+
+// formatSize renders a byte count in human-readable units.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// fileOpener opens a stored file and returns its plaintext content.
+type fileOpener func(path string) (io.ReadCloser, error)
+
+// hashFile returns the hex-encoded SHA-256 and size of the content read through open.
+func hashFile(path string, open fileOpener) (string, int64, error) {
+	f, err := open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"unicode"
+)
+
+// MaxNoteSize caps the size of a note file built up with /note.
+const MaxNoteSize = 256 * 1024
+
+var errNoteTooLarge = errors.New("note too large")
+
+// parseNoteArgs splits "/note <title> <text...>" arguments. The title may be
+// quoted; the text is everything after it, line breaks included.
+func parseNoteArgs(args string) (string, string, error) {
+	args = strings.TrimLeftFunc(args, unicode.IsSpace)
+
+	var title, text string
+	if strings.HasPrefix(args, `"`) {
+		end := strings.IndexByte(args[1:], '"')
+		if end < 0 {
+			return "", "", errors.New("unterminated title")
+		}
+		title, text = args[1:end+1], args[end+2:]
+	} else {
+		end := strings.IndexFunc(args, unicode.IsSpace)
+		if end < 0 {
+			end = len(args)
+		}
+		title, text = args[:end], args[end:]
+	}
+
+	title = sanitizeFileName(strings.TrimSuffix(title, ".txt"))
+	text = strings.TrimSpace(text)
+	if title == "" || text == "" {
+		return "", "", errors.New("usage")
+	}
+	return title + ".txt", text, nil
+}
+
+// appendNote adds text as a new line of the note name in owner's directory,
+// creating it if needed, and returns the note's metadata.
+func (b *Bot) appendNote(owner int64, name, text string) (FileMeta, error) {
+	rel := b.userFile(owner, name)
+	entry := text + "\n"
+
+	dst := b.localPath(rel)
+	info, err := os.Stat(dst)
+	if os.IsNotExist(err) {
+		if len(entry) > MaxNoteSize {
+			return FileMeta{}, errNoteTooLarge
+		}
+		return b.saveFile(strings.NewReader(entry), "documents", name, "text/plain", owner)
+	}
+	if err != nil {
+		return FileMeta{}, err
+	}
+	if !info.Mode().IsRegular() {
+		return FileMeta{}, errInvalidName
+	}
+
+	f, err := b.openFile(rel)
+	if err != nil {
+		return FileMeta{}, err
+	}
+	existing, err := io.ReadAll(io.LimitReader(f, MaxNoteSize+1))
+	f.Close()
+	if err != nil {
+		return FileMeta{}, err
+	}
+	if len(existing) > 0 && !bytes.HasSuffix(existing, []byte("\n")) {
+		existing = append(existing, '\n')
+	}
+	content := append(existing, entry...)
+	if len(content) > MaxNoteSize {
+		return FileMeta{}, errNoteTooLarge
+	}
+
+	data := content
+	if b.isCompressed(rel) {
+		if data, err = gzipBytes(content); err != nil {
+			return FileMeta{}, fmt.Errorf("failed to compress note: %w", err)
+		}
+	}
+	if b.cipher != nil {
+		encrypted := b.cipher.encryptReader(bytes.NewReader(data))
+		data, err = io.ReadAll(encrypted)
+		encrypted.Close()
+		if err != nil {
+			return FileMeta{}, fmt.Errorf("failed to encrypt note: %w", err)
+		}
+	}
+	if err := writeFileAtomic(dst, data); err != nil {
+		return FileMeta{}, err
+	}
+
+	m := b.fileMeta(rel, dst, info)
+	sum := sha256.Sum256(content)
+	m.Size, m.SHA256 = int64(len(content)), hex.EncodeToString(sum[:])
+	if m.Owner == 0 {
+		m.Owner = owner
+	}
+	b.recordFile(m)
+	b.audit(AuditEntry{User: owner, Action: "append", Path: rel, Size: m.Size, SHA256: m.SHA256})
+	return m, nil
+}
+
+// handleNoteCommand appends to a note, or lists the user's notes without arguments.
+func (b *Bot) handleNoteCommand(chatID, userID int64, args string) {
+	if _, ok := b.storage.(*LocalStorage); !ok {
+		b.sendTextMessage(chatID, "⚠️ Notes are only available with local storage.")
+		return
+	}
+	if strings.TrimSpace(args) == "" {
+		b.handleNoteList(chatID, userID)
+		return
+	}
+
+	name, text, err := parseNoteArgs(args)
+	if err != nil {
+		b.sendTextMessage(chatID, "Usage: /note <title> <text>\nThe text may span several lines; quote titles containing spaces.\n/note alone lists your notes.")
+		return
+	}
+	if b.isPaused() {
+		b.sendTextMessage(chatID, "⏸ Uploads are temporarily unavailable due to maintenance. Please try again later.")
+		return
+	}
+
+	saved, err := b.appendNote(userID, name, text)
+	switch {
+	case errors.Is(err, errNoteTooLarge):
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ Note '%s' would exceed %s. Start a new note.", name, formatSize(MaxNoteSize)))
+	case errors.Is(err, errInvalidName):
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ '%s' is not a file", name))
+	case err != nil:
+		log.Printf("Error saving note %s: %v", name, err)
+		b.sendTextMessage(chatID, b.failureReply(userID, err, "Failed to save the note."))
+	default:
+		b.sendTextMessage(chatID, fmt.Sprintf("📝 Note '%s' saved (%s)", b.displayName(userID, saved.Path), formatSize(saved.Size)))
+	}
+}
+
+// handleNoteList lists the .txt files in the user's directory.
+func (b *Bot) handleNoteList(chatID, userID int64) {
+	files, err := b.storage.List(b.userDir(userID))
+	if err != nil {
+		log.Printf("Error listing notes: %v", err)
+		b.sendTextMessage(chatID, "Failed to list notes.")
+		return
+	}
+
+	var notes []storedFile
+	for _, f := range files {
+		if strings.HasSuffix(f.Name, compressedSuffix) && b.isCompressed(b.userPath(userID, f.Name)) {
+			f.Name = strings.TrimSuffix(f.Name, compressedSuffix)
+		}
+		if strings.EqualFold(path.Ext(f.Name), ".txt") {
+			notes = append(notes, f)
+		}
+	}
+	if len(notes) == 0 {
+		b.sendTextMessage(chatID, "📝 No notes yet. Start one with /note <title> <text>")
+		return
+	}
+
+	b.sendLongMessage(chatID, formatFileList(fmt.Sprintf("📝 Notes (%d):", len(notes)), notes))
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestParseNoteArgs(t *testing.T) {
+	cases := []struct{ args, name, text string }{
+		{" groceries milk", "groceries.txt", "milk"},
+		{" groceries.txt milk\neggs ", "groceries.txt", "milk\neggs"},
+		{"\n\"trip ideas\" Lisbon\n  Porto", "trip ideas.txt", "Lisbon\n  Porto"},
+		{" a/../b text", "a_.._b.txt", "text"},
+	}
+	for _, c := range cases {
+		name, text, err := parseNoteArgs(c.args)
+		if err != nil || name != c.name || text != c.text {
+			t.Errorf("parseNoteArgs(%q) = %q, %q, %v; want %q, %q", c.args, name, text, err, c.name, c.text)
+		}
+	}
+
+	for _, args := range []string{" groceries", " \"open quote", " ...  text"} {
+		if _, _, err := parseNoteArgs(args); err == nil {
+			t.Errorf("expected %q to be rejected", args)
+		}
+	}
+}
+
+func TestNoteCreateAndAppend(t *testing.T) {
+	b := newTestBot(t)
+	b.perUserDirs = true
+	api := &mockTelegramAPI{}
+	b.client = api
+
+	note := func(text string) {
+		b.handleMessage(&tgbotapi.Message{From: &tgbotapi.User{ID: 42}, Chat: &tgbotapi.Chat{ID: 42}, Text: text})
+	}
+	note("/note todo buy milk")
+	note("/note todo call Ann\nabout the trip")
+
+	data, err := os.ReadFile(b.localPath("42/todo.txt"))
+	if err != nil || string(data) != "buy milk\ncall Ann\nabout the trip\n" {
+		t.Fatalf("unexpected note content %q (%v)", data, err)
+	}
+	meta, err := readMeta(b.storagePath, "42/todo.txt")
+	if err != nil || meta.Size != int64(len(data)) || meta.Owner != 42 || meta.MIME != "text/plain" {
+		t.Errorf("expected metadata to follow appends, got %+v (%v)", meta, err)
+	}
+	if texts := api.texts(); len(texts) != 2 || texts[1] != "📝 Note 'todo.txt' saved (33 B)" {
+		t.Errorf("unexpected replies %q", texts)
+	}
+}
+
+func TestNoteAppendEncrypted(t *testing.T) {
+	b := newTestBot(t)
+	b.cipher = testCipher(t, 7)
+	if _, err := b.appendNote(42, "log.txt", "first"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.appendNote(42, "log.txt", "second"); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := b.openPath(b.localPath("log.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if data, err := io.ReadAll(f); err != nil || string(data) != "first\nsecond\n" {
+		t.Errorf("unexpected decrypted note %q (%v)", data, err)
+	}
+}
+
+func TestNoteSizeCap(t *testing.T) {
+	b := newTestBot(t)
+	writeTestFile(t, b, "big.txt", strings.Repeat("x", MaxNoteSize-2))
+
+	if _, err := b.appendNote(42, "big.txt", "toolong"); err != errNoteTooLarge {
+		t.Fatalf("expected errNoteTooLarge, got %v", err)
+	}
+	if info, _ := os.Stat(b.localPath("big.txt")); info.Size() != MaxNoteSize-2 {
+		t.Errorf("expected the note to be left alone, size %d", info.Size())
+	}
+}
+
+func TestNoteList(t *testing.T) {
+	b := newTestBot(t)
+	b.perUserDirs = true
+	api := &mockTelegramAPI{}
+	b.client = api
+
+	b.handleNoteCommand(42, 42, "")
+	writeTestFile(t, b, "42/todo.txt", "milk\n")
+	writeTestFile(t, b, "42/photo.jpg", "jpeg")
+	writeTestFile(t, b, "7/other.txt", "not mine\n")
+	b.handleNoteCommand(42, 42, "")
+
+	texts := api.texts()
+	if len(texts) != 2 || !strings.HasPrefix(texts[0], "📝 No notes yet") {
+		t.Fatalf("unexpected replies %q", texts)
+	}
+	if !strings.HasPrefix(texts[1], "📝 Notes (1):\n\n• todo.txt (5 B, ") {
+		t.Errorf("unexpected list %q", texts[1])
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// organizeMode selects the subfolders ORGANIZE puts received files in.
+type organizeMode int
+
+const (
+	organizeFlat     organizeMode = iota // directly in the user's directory
+	organizeDate                         // YYYY/MM/DD of the day received
+	organizeType                         // one folder per media type
+	organizeTypeDate                     // media type, then YYYY/MM/DD
+)
+
+// parseOrganizeMode parses ORGANIZE; empty means flat.
+func parseOrganizeMode(s string) (organizeMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "flat":
+		return organizeFlat, nil
+	case "date":
+		return organizeDate, nil
+	case "type":
+		return organizeType, nil
+	case "type-date":
+		return organizeTypeDate, nil
+	}
+	return organizeFlat, fmt.Errorf("invalid ORGANIZE %q (want flat, date, type or type-date)", s)
+}
+
+// organizedName returns the user-relative name a file of mediaType received
+// at the given time is saved under: name inside the folders selected by
+// ORGANIZE. Every handler storing received content goes through it; files
+// created from stored ones (notes, conversions, manifests) keep the path
+// they are given.
+func (b *Bot) organizedName(mediaType, name string, received time.Time) string {
+	date := received.Format("2006/01/02")
+	switch b.organize {
+	case organizeDate:
+		return path.Join(date, name)
+	case organizeType:
+		return path.Join(mediaType, name)
+	case organizeTypeDate:
+		return path.Join(mediaType, date, name)
+	}
+	return name
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestParseOrganizeMode(t *testing.T) {
+	for in, want := range map[string]organizeMode{"": organizeFlat, "flat": organizeFlat, " Date ": organizeDate, "type": organizeType, "TYPE-DATE": organizeTypeDate} {
+		if got, err := parseOrganizeMode(in); err != nil || got != want {
+			t.Errorf("parseOrganizeMode(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := parseOrganizeMode("by-month"); err == nil {
+		t.Error("expected an unknown mode to be rejected")
+	}
+}
+
+func TestOrganizedNameLayouts(t *testing.T) {
+	received := time.Date(2024, time.March, 7, 23, 30, 0, 0, time.UTC)
+	cases := []struct {
+		mode     organizeMode
+		perUser  bool
+		wantName string
+	}{
+		{organizeFlat, false, "photo.jpg"},
+		{organizeDate, false, "2024/03/07/photo.jpg"},
+		{organizeType, false, "photos/photo.jpg"},
+		{organizeTypeDate, false, "photos/2024/03/07/photo.jpg"},
+		{organizeTypeDate, true, "42/photos/2024/03/07/photo.jpg"},
+	}
+	for _, c := range cases {
+		b := newTestBot(t)
+		b.organize, b.perUserDirs = c.mode, c.perUser
+
+		saved, err := b.saveFile(strings.NewReader("jpeg"), "photos", b.organizedName("photos", "photo.jpg", received), "image/jpeg", 42)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if saved.Path != c.wantName {
+			t.Errorf("mode %d: expected %s, got %s", c.mode, c.wantName, saved.Path)
+		}
+		if !exists(b.localPath(saved.Path)) {
+			t.Errorf("mode %d: expected %s to exist", c.mode, saved.Path)
+		}
+	}
+}
+
+func TestOrganizeAppliesToHandlers(t *testing.T) {
+	b := newTestBot(t)
+	b.organize = organizeTypeDate
+	b.savedReplies = newSavedReplies(MaxTrackedReplies)
+	b.client = &mockTelegramAPI{}
+
+	b.handleMessage(&tgbotapi.Message{
+		From:    &tgbotapi.User{ID: 42},
+		Chat:    &tgbotapi.Chat{ID: 42},
+		Contact: &tgbotapi.Contact{PhoneNumber: "+1555", FirstName: "Bob"},
+	})
+
+	reply, ok := b.savedReplies.Lookup(42, 1)
+	if !ok || len(reply.paths) != 1 {
+		t.Fatalf("expected the save to be confirmed, got %+v", reply)
+	}
+	if want := path.Join("contacts", time.Now().Format("2006/01/02")); path.Dir(reply.paths[0]) != want {
+		t.Errorf("expected the contact in %s, got %s", want, reply.paths[0])
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+)
+
+// setPinned records whether the stored file rel is protected from retention.
+// Files without a sidecar get one describing them.
+func (b *Bot) setPinned(rel string, owner int64, pinned bool) error {
+	src := b.localPath(rel)
+	info, err := os.Stat(src)
+	if err != nil || !info.Mode().IsRegular() {
+		return errSourceMissing
+	}
+
+	m := b.fileMeta(rel, src, info)
+	if m.Owner == 0 {
+		m.Owner = owner
+	}
+	m.Pinned = pinned
+	return writeMeta(b.storagePath, m)
+}
+
+// handlePinCommand pins (or unpins) one of the user's files.
+func (b *Bot) handlePinCommand(chatID, userID int64, args string, pin bool) {
+	command := "unpin"
+	if pin {
+		command = "pin"
+	}
+	if !b.requireLocalStorage(chatID) {
+		return
+	}
+
+	parts := splitArgs(args)
+	if len(parts) != 1 {
+		b.sendTextMessage(chatID, fmt.Sprintf("Usage: /%s <file>\nQuote names containing spaces, e.g. /%s \"my file.pdf\"", command, command))
+		return
+	}
+	name, err := cleanRelPath(parts[0])
+	if err != nil {
+		b.sendTextMessage(chatID, "❌ Invalid file name")
+		return
+	}
+
+	rel := b.userFile(userID, name)
+	err = b.setPinned(rel, userID, pin)
+	switch {
+	case errors.Is(err, errSourceMissing):
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ File '%s' not found", name))
+	case err != nil:
+		log.Printf("Error updating pin of %s: %v", rel, err)
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ Failed to %s the file.", command))
+	case pin:
+		log.Printf("User %d pinned %s", userID, rel)
+		b.sendTextMessage(chatID, fmt.Sprintf("📌 Pinned '%s'; it will not be deleted automatically", name))
+	default:
+		log.Printf("User %d unpinned %s", userID, rel)
+		b.sendTextMessage(chatID, fmt.Sprintf("✅ Unpinned '%s'", name))
+	}
+}
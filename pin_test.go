@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPinnedFileSurvivesRetention(t *testing.T) {
+	b := newTestBot(t)
+	b.retention = 24 * time.Hour
+	api := &mockTelegramAPI{}
+	b.client = api
+
+	old := time.Now().Add(-48 * time.Hour)
+	for _, rel := range []string{"keep.pdf", "drop.pdf"} {
+		writeTestFile(t, b, rel, "content")
+		os.Chtimes(b.localPath(rel), old, old)
+	}
+
+	b.handlePinCommand(42, 42, " keep.pdf", true)
+	if texts := api.texts(); len(texts) != 1 || !strings.HasPrefix(texts[0], "📌 Pinned 'keep.pdf'") {
+		t.Fatalf("unexpected reply %q", texts)
+	}
+
+	if removed, _, err := b.sweepExpired(time.Now()); err != nil || removed != 1 {
+		t.Fatalf("expected one file to be removed, got %d (%v)", removed, err)
+	}
+	if _, err := os.Stat(b.localPath("keep.pdf")); err != nil {
+		t.Errorf("expected the pinned file to survive: %v", err)
+	}
+	if _, err := os.Stat(b.localPath("drop.pdf")); !os.IsNotExist(err) {
+		t.Error("expected the unpinned sibling to be removed")
+	}
+
+	// Unpinned files expire again
+	b.handlePinCommand(42, 42, " keep.pdf", false)
+	if removed, _, _ := b.sweepExpired(time.Now()); removed != 1 {
+		t.Errorf("expected the unpinned file to be removed, got %d", removed)
+	}
+}
+
+func TestPinShownInListing(t *testing.T) {
+	b := newTestBot(t)
+	b.client = &mockTelegramAPI{}
+	writeTestFile(t, b, "notes.txt", "hi")
+
+	b.handlePinCommand(42, 42, " notes.txt", true)
+	if got := b.listedName(42, "notes.txt"); got != "📌 notes.txt" {
+		t.Errorf("expected the pin marker, got %q", got)
+	}
+
+	b.handlePinCommand(42, 42, " missing.txt", true)
+	if texts := b.client.(*mockTelegramAPI).texts(); texts[len(texts)-1] != "❌ File 'missing.txt' not found" {
+		t.Errorf("unexpected reply %q", texts[len(texts)-1])
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// MaxPreviewChars is how much of a text file /preview shows.
+	MaxPreviewChars = 3000
+	// MaxPreviewImageSize is the largest image /preview uploads, Telegram's
+	// limit for photos.
+	MaxPreviewImageSize = 10 * 1024 * 1024
+)
+
+var (
+	errNotPreviewable    = errors.New("not a text file or image")
+	errTooLargeToPreview = errors.New("image too large to preview")
+)
+
+// previewImageTypes are the sniffed content types /preview sends as a photo.
+var previewImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// textPreview returns the first MaxPreviewChars characters of head, the start
+// of a file, and whether anything was cut off; more reports that the file
+// continues after head. It fails for content that is not UTF-8 text.
+func textPreview(head []byte, more bool) (string, bool, error) {
+	if more {
+		// The read may have stopped inside a character
+		for i := 0; i < utf8.UTFMax-1 && len(head) > 0 && !utf8.Valid(head); i++ {
+			head = head[:len(head)-1]
+		}
+	}
+	if !utf8.Valid(head) || bytes.IndexByte(head, 0) >= 0 {
+		return "", false, errNotPreviewable
+	}
+
+	text := string(head)
+	n := 0
+	for i := range text {
+		if n == MaxPreviewChars {
+			return text[:i], true, nil
+		}
+		n++
+	}
+	return text, more, nil
+}
+
+// previewMessage formats a text preview of name as a code block.
+func previewMessage(chatID int64, name, text string, truncated bool) tgbotapi.MessageConfig {
+	header := fmt.Sprintf("📄 %s", name)
+	if truncated {
+		header += fmt.Sprintf(" (first %d characters)", MaxPreviewChars)
+	}
+	msg := tgbotapi.NewMessage(chatID, html.EscapeString(header)+"\n<pre>"+html.EscapeString(text)+"</pre>")
+	msg.ParseMode = tgbotapi.ModeHTML
+	return msg
+}
+
+// previewFile shows the stored file rel in chatID: the start of a text file,
+// or an image as a photo, by its cached file_id when it was received as one.
+func (b *Bot) previewFile(chatID int64, rel, name string) error {
+	src := b.localPath(rel)
+	info, err := os.Stat(src)
+	if err != nil || !info.Mode().IsRegular() {
+		return errSourceMissing
+	}
+	m := b.fileMeta(rel, src, info)
+
+	if m.FileID != "" && m.MediaType == "photos" {
+		_, err := b.send(tgbotapi.NewPhoto(chatID, tgbotapi.FileID(m.FileID)))
+		if err == nil || !isFileIDError(err) {
+			return err
+		}
+		log.Printf("Cached file_id of %s was rejected (%v), uploading it instead", rel, err)
+	}
+
+	f, err := b.openFile(rel)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	head := make([]byte, MaxPreviewChars*utf8.UTFMax)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	head = head[:n]
+	more := err == nil
+
+	if previewImageTypes[http.DetectContentType(head)] {
+		size := info.Size()
+		if m.Compressed {
+			size = m.Size
+		}
+		if size > MaxPreviewImageSize {
+			return errTooLargeToPreview
+		}
+		data, err := io.ReadAll(io.MultiReader(bytes.NewReader(head), f))
+		if err != nil {
+			return err
+		}
+		_, err = b.send(tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: path.Base(uncompressedName(rel, m)), Bytes: data}))
+		return err
+	}
+
+	text, truncated, err := textPreview(head, more)
+	if err != nil {
+		return err
+	}
+	_, err = b.send(previewMessage(chatID, name, text, truncated))
+	return err
+}
+
+// handlePreviewCommand shows one of the user's text files or images without
+// sending the file itself.
+func (b *Bot) handlePreviewCommand(chatID, userID int64, args string) {
+	if !b.requireLocalStorage(chatID) {
+		return
+	}
+
+	parts := splitArgs(args)
+	if len(parts) != 1 {
+		b.sendTextMessage(chatID, "Usage: /preview <file>\nQuote names containing spaces, e.g. /preview \"my notes.txt\"")
+		return
+	}
+	name, err := cleanRelPath(parts[0])
+	if err != nil {
+		b.sendTextMessage(chatID, "❌ Invalid file name")
+		return
+	}
+
+	rel := b.userFile(userID, name)
+	err = b.previewFile(chatID, rel, name)
+	switch {
+	case errors.Is(err, errSourceMissing):
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ File '%s' not found", name))
+	case errors.Is(err, errNotPreviewable):
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ '%s' is a binary file and cannot be previewed. Use /resend to get the file itself.", name))
+	case errors.Is(err, errTooLargeToPreview):
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ '%s' is too large to preview. Images up to %s can be previewed; use /resend to get the file itself.", name, formatLimit(MaxPreviewImageSize)))
+	case err != nil:
+		log.Printf("Error previewing %s: %v", rel, err)
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ Failed to preview '%s'.", name))
+	}
+}
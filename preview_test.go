@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"html"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestTextPreviewTruncates(t *testing.T) {
+	long := strings.Repeat("ä", MaxPreviewChars+10)
+	text, truncated, err := textPreview([]byte(long), false)
+	if err != nil || !truncated || utf8.RuneCountInString(text) != MaxPreviewChars {
+		t.Errorf("expected %d characters cut off, got %d (%v, %v)", MaxPreviewChars, utf8.RuneCountInString(text), truncated, err)
+	}
+
+	// A read stopping inside a character is not mistaken for binary data
+	head := []byte("short ä")
+	text, truncated, err = textPreview(head[:len(head)-1], true)
+	if err != nil || text != "short " || !truncated {
+		t.Errorf("unexpected preview %q (%v, %v)", text, truncated, err)
+	}
+
+	if text, truncated, err := textPreview([]byte("line 1\nline 2\n"), false); err != nil || truncated || text != "line 1\nline 2\n" {
+		t.Errorf("expected the whole short file, got %q (%v, %v)", text, truncated, err)
+	}
+}
+
+func TestTextPreviewRejectsBinary(t *testing.T) {
+	for _, data := range [][]byte{{0x7f, 'E', 'L', 'F', 0, 0, 1}, {0xff, 0xfe, 0xfd}} {
+		if _, _, err := textPreview(data, false); err != errNotPreviewable {
+			t.Errorf("expected %x to be rejected, got %v", data, err)
+		}
+	}
+}
+
+func TestPreviewCommandText(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+	writeTestFile(t, b, "notes.txt", "<b>todo</b>\n"+strings.Repeat("x", 2*MaxPreviewChars))
+
+	b.handlePreviewCommand(42, 42, " notes.txt")
+
+	if len(api.sent) != 1 {
+		t.Fatalf("expected one message, got %d", len(api.sent))
+	}
+	msg := api.sent[0].(tgbotapi.MessageConfig)
+	if msg.ParseMode != tgbotapi.ModeHTML || !strings.HasPrefix(msg.Text, "📄 notes.txt (first 3000 characters)\n<pre>&lt;b&gt;todo&lt;/b&gt;\n") {
+		t.Errorf("unexpected preview %.80q", msg.Text)
+	}
+	_, block, _ := strings.Cut(msg.Text, "<pre>")
+	if shown := html.UnescapeString(strings.TrimSuffix(block, "</pre>")); utf8.RuneCountInString(shown) != MaxPreviewChars {
+		t.Errorf("expected the text to be cut at %d characters, got %d", MaxPreviewChars, utf8.RuneCountInString(shown))
+	}
+}
+
+func TestPreviewCommandRejectsBinary(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+	writeTestFile(t, b, "app.bin", "\x7fELF\x02\x01\x01\x00\x00\x00")
+
+	b.handlePreviewCommand(42, 42, " app.bin")
+	b.handlePreviewCommand(42, 42, " missing.txt")
+
+	texts := api.texts()
+	if len(texts) != 2 || !strings.Contains(texts[0], "binary file and cannot be previewed") || !strings.Contains(texts[1], "not found") {
+		t.Errorf("unexpected replies %q", texts)
+	}
+}
+
+func TestPreviewCommandImage(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, b, "sketch.png", buf.String())
+	cached, err := b.saveFile(strings.NewReader("jpeg"), "photos", "photo.jpg", "image/jpeg", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached.FileID, cached.MediaType = "cached-id", "photos"
+	b.recordFile(cached)
+
+	b.handlePreviewCommand(42, 42, " sketch.png")
+	b.handlePreviewCommand(42, 42, " photo.jpg")
+
+	if len(api.sent) != 2 {
+		t.Fatalf("expected two photos, got %d messages: %q", len(api.sent), api.texts())
+	}
+	upload, ok := api.sent[0].(tgbotapi.PhotoConfig)
+	if !ok || !bytes.Equal(upload.File.(tgbotapi.FileBytes).Bytes, buf.Bytes()) {
+		t.Errorf("expected the PNG to be uploaded as a photo, got %+v", api.sent[0])
+	}
+	byID, ok := api.sent[1].(tgbotapi.PhotoConfig)
+	if !ok || byID.File != tgbotapi.FileID("cached-id") {
+		t.Errorf("expected the cached file_id to be reused, got %+v", api.sent[1])
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Password-protected export layout (/protect):
+//
+//	magic (8 bytes) | log2(N) (1) | r (1) | p (1) | salt (16) | nonce (12) | ciphertext and tag
+//
+// The key is scrypt(password, salt, N, r, p) with 32 bytes, and the whole
+// file is sealed with AES-256-GCM using the header before the ciphertext as
+// additional data. The README shows how to decrypt it with Python.
+const (
+	protectMagic    = "TGFSPWD1"
+	protectLogN     = 15
+	protectR        = 8
+	protectP        = 1
+	protectSaltSize = 16
+	protectHeader   = len(protectMagic) + 3 + protectSaltSize + encNonceSize
+	// protectSuffix is appended to the name of the exported copy.
+	protectSuffix = ".enc"
+)
+
+const (
+	// MaxProtectSize caps files /protect encrypts, which happens in memory.
+	MaxProtectSize = 20 * 1024 * 1024
+	// MinProtectPasswordLength is the shortest password /protect accepts.
+	MinProtectPasswordLength = 10
+)
+
+var (
+	errWeakPassword   = fmt.Errorf("password must be at least %d characters and not a single repeated character", MinProtectPasswordLength)
+	errNotProtected   = errors.New("not a password-protected file")
+	errWrongPassword  = errors.New("wrong password or corrupted file")
+	errTooLargeToLock = errors.New("file too large to protect")
+)
+
+// checkPasswordStrength rejects short passwords and ones made of one
+// repeated character.
+func checkPasswordStrength(password string) error {
+	if utf8.RuneCountInString(password) < MinProtectPasswordLength {
+		return errWeakPassword
+	}
+	first, _ := utf8.DecodeRuneInString(password)
+	for _, r := range password {
+		if r != first {
+			return nil
+		}
+	}
+	return errWeakPassword
+}
+
+// protectAEAD derives the key for password and salt and returns its cipher.
+func protectAEAD(password string, salt []byte, logN, r, p int) (cipher.AEAD, error) {
+	key, err := scryptKey(password, salt, 1<<logN, r, p, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptWithPassword seals data with a key derived from password.
+func encryptWithPassword(data []byte, password string) ([]byte, error) {
+	header := make([]byte, 0, protectHeader)
+	header = append(header, protectMagic...)
+	header = append(header, protectLogN, protectR, protectP)
+	salt := make([]byte, protectSaltSize)
+	nonce := make([]byte, encNonceSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	header = append(append(header, salt...), nonce...)
+
+	aead, err := protectAEAD(password, salt, protectLogN, protectR, protectP)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(header, nonce, data, header), nil
+}
+
+// decryptWithPassword opens a file made by encryptWithPassword.
+func decryptWithPassword(data []byte, password string) ([]byte, error) {
+	if len(data) < protectHeader || string(data[:len(protectMagic)]) != protectMagic {
+		return nil, errNotProtected
+	}
+	params := data[len(protectMagic):]
+	logN, r, p := int(params[0]), int(params[1]), int(params[2])
+	if logN < 1 || logN > 20 || r == 0 || p == 0 {
+		return nil, errNotProtected
+	}
+	salt := params[3 : 3+protectSaltSize]
+	nonce := params[3+protectSaltSize : 3+protectSaltSize+encNonceSize]
+
+	aead, err := protectAEAD(password, salt, logN, r, p)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := aead.Open(nil, nonce, data[protectHeader:], data[:protectHeader])
+	if err != nil {
+		return nil, errWrongPassword
+	}
+	return plain, nil
+}
+
+// readForProtect returns the content of the stored file rel, up to
+// MaxProtectSize.
+func (b *Bot) readForProtect(rel string) ([]byte, error) {
+	if info, err := os.Stat(b.localPath(rel)); err != nil || !info.Mode().IsRegular() {
+		return nil, errSourceMissing
+	}
+	f, err := b.openFile(rel)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, MaxProtectSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxProtectSize {
+		return nil, errTooLargeToLock
+	}
+	return data, nil
+}
+
+// handleProtectCommand sends back a password-encrypted copy of one of the
+// user's files. The password is not stored, and the message carrying it is
+// deleted from the chat.
+func (b *Bot) handleProtectCommand(message *tgbotapi.Message, chatID, userID int64, args string) {
+	parts := splitArgs(args)
+	if len(parts) == 2 {
+		del := tgbotapi.NewDeleteMessage(chatID, message.MessageID)
+		if _, err := b.request(del); err != nil {
+			log.Printf("Failed to delete the /protect message of user %d: %v", userID, err)
+		}
+	}
+
+	if !b.requireLocalStorage(chatID) {
+		return
+	}
+	if len(parts) != 2 {
+		b.sendTextMessage(chatID, "Usage: /protect <file> <password>\nQuote names containing spaces, e.g. /protect \"my file.pdf\" <password>")
+		return
+	}
+	name, err := cleanRelPath(parts[0])
+	if err != nil {
+		b.sendTextMessage(chatID, "❌ Invalid file name")
+		return
+	}
+	if err := checkPasswordStrength(parts[1]); err != nil {
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ Weak password: the %s.", err))
+		return
+	}
+
+	rel := b.userFile(userID, name)
+	data, err := b.readForProtect(rel)
+	switch {
+	case errors.Is(err, errSourceMissing):
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ File '%s' not found", name))
+		return
+	case errors.Is(err, errTooLargeToLock):
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ '%s' is too large to protect. Maximum size is %s", name, formatLimit(MaxProtectSize)))
+		return
+	case err != nil:
+		log.Printf("Error reading %s for /protect: %v", rel, err)
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ Failed to read '%s'.", name))
+		return
+	}
+
+	sealed, err := encryptWithPassword(data, parts[1])
+	if err != nil {
+		log.Printf("Error encrypting %s: %v", rel, err)
+		b.sendTextMessage(chatID, "❌ Failed to encrypt the file.")
+		return
+	}
+
+	exportName := path.Base(name) + protectSuffix
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: exportName, Bytes: sealed})
+	doc.Caption = fmt.Sprintf("🔐 '%s' encrypted with your password (AES-256-GCM, scrypt key). The password was not stored; see the README for how to decrypt it.", name)
+	if _, err := b.send(doc); err != nil {
+		log.Printf("Failed to send protected copy of %s: %v", rel, err)
+		b.sendTextMessage(chatID, "❌ Failed to send the encrypted file.")
+		return
+	}
+	log.Printf("User %d exported a password-protected copy of %s", userID, rel)
+	b.audit(AuditEntry{User: userID, Action: "protect", Path: rel, Size: int64(len(data))})
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const testPassword = "correct horse battery"
+
+func TestEncryptWithPasswordRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("sensitive payload "), 500)
+
+	sealed, err := encryptWithPassword(data, testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(sealed, []byte(protectMagic)) || len(sealed) != protectHeader+len(data)+16 {
+		t.Fatalf("unexpected layout: %d bytes", len(sealed))
+	}
+	if bytes.Contains(sealed, data[:64]) {
+		t.Error("expected the plaintext not to appear in the export")
+	}
+
+	plain, err := decryptWithPassword(sealed, testPassword)
+	if err != nil || !bytes.Equal(plain, data) {
+		t.Fatalf("round trip failed: %v", err)
+	}
+
+	// A second export of the same data uses a fresh salt and nonce
+	again, _ := encryptWithPassword(data, testPassword)
+	if bytes.Equal(again, sealed) {
+		t.Error("expected exports to differ")
+	}
+}
+
+func TestDecryptWithPasswordRejects(t *testing.T) {
+	sealed, err := encryptWithPassword([]byte("secret"), testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 1
+	header := bytes.Clone(sealed)
+	header[len(protectMagic)+3] ^= 1 // salt
+
+	cases := []struct {
+		name     string
+		data     []byte
+		password string
+		want     error
+	}{
+		{"wrong password", sealed, "wrong horse battery", errWrongPassword},
+		{"tampered ciphertext", tampered, testPassword, errWrongPassword},
+		{"tampered header", header, testPassword, errWrongPassword},
+		{"not an export", []byte("plain text file"), testPassword, errNotProtected},
+		{"truncated", sealed[:protectHeader-1], testPassword, errNotProtected},
+	}
+	for _, c := range cases {
+		if _, err := decryptWithPassword(c.data, c.password); !errors.Is(err, c.want) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, err)
+		}
+	}
+}
+
+func TestCheckPasswordStrength(t *testing.T) {
+	for _, p := range []string{"short", "aaaaaaaaaaaa", "ääääääääää"} {
+		if checkPasswordStrength(p) == nil {
+			t.Errorf("expected %q to be rejected", p)
+		}
+	}
+	for _, p := range []string{testPassword, "ü1ü2ü3ü4ü5"} {
+		if err := checkPasswordStrength(p); err != nil {
+			t.Errorf("expected %q to be accepted, got %v", p, err)
+		}
+	}
+}
+
+func TestProtectCommandSendsEncryptedCopy(t *testing.T) {
+	b := newTestBot(t)
+	b.cipher = testCipher(t, 5)
+	api := &mockTelegramAPI{}
+	b.client = api
+	if _, err := b.saveFile(strings.NewReader("tax return"), "documents", "tax 2024.pdf", "application/pdf", 42); err != nil {
+		t.Fatal(err)
+	}
+
+	b.handleProtectCommand(&tgbotapi.Message{MessageID: 9}, 42, 42, ` "tax 2024.pdf" `+`"`+testPassword+`"`)
+
+	if len(api.sent) != 1 {
+		t.Fatalf("expected one document, got %d messages: %q", len(api.sent), api.texts())
+	}
+	doc, ok := api.sent[0].(tgbotapi.DocumentConfig)
+	if !ok {
+		t.Fatalf("expected a document, got %T", api.sent[0])
+	}
+	file := doc.File.(tgbotapi.FileBytes)
+	if file.Name != "tax 2024.pdf.enc" {
+		t.Errorf("unexpected name %s", file.Name)
+	}
+	if plain, err := decryptWithPassword(file.Bytes, testPassword); err != nil || string(plain) != "tax return" {
+		t.Errorf("expected the decrypted original, got %q (%v)", plain, err)
+	}
+
+	// The message carrying the password is deleted
+	if len(api.requests) != 1 {
+		t.Fatalf("expected the command message to be deleted, got %d requests", len(api.requests))
+	}
+	if del, ok := api.requests[0].(tgbotapi.DeleteMessageConfig); !ok || del.MessageID != 9 {
+		t.Errorf("unexpected request %+v", api.requests[0])
+	}
+}
+
+func TestProtectCommandRejections(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+	writeTestFile(t, b, "big.bin", strings.Repeat("x", MaxProtectSize+1))
+
+	for _, args := range []string{"", " only-a-name", " missing.txt Tr0ub4dor&3", " big.bin Tr0ub4dor&3", " big.bin short"} {
+		b.handleProtectCommand(&tgbotapi.Message{MessageID: 1}, 42, 42, args)
+	}
+
+	texts := api.texts()
+	want := []string{"Usage: /protect", "Usage: /protect", "not found", "too large to protect. Maximum size is 20 MB", "Weak password"}
+	if len(texts) != len(want) {
+		t.Fatalf("expected %d replies, got %q", len(want), texts)
+	}
+	for i, w := range want {
+		if !strings.Contains(texts[i], w) {
+			t.Errorf("reply %d: expected %q in %q", i, w, texts[i])
+		}
+	}
+	for _, c := range api.sent {
+		if _, ok := c.(tgbotapi.DocumentConfig); ok {
+			t.Error("expected no document to be sent")
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const (
+	// MaxQRTextLength is the longest /qr input in bytes, well within QR capacity
+	// at medium error correction.
+	MaxQRTextLength = 1024
+	// qrImageSize is the width and height of generated QR codes in pixels.
+	qrImageSize = 512
+)
+
+// encodeQR renders text as a QR code PNG.
+func encodeQR(text string) ([]byte, error) {
+	switch {
+	case strings.TrimSpace(text) == "":
+		return nil, fmt.Errorf("empty text")
+	case len(text) > MaxQRTextLength:
+		return nil, fmt.Errorf("text is %d bytes, the limit is %d", len(text), MaxQRTextLength)
+	case !utf8.ValidString(text):
+		return nil, fmt.Errorf("text is not valid UTF-8")
+	}
+	return qrcode.Encode(text, qrcode.Medium, qrImageSize)
+}
+
+// handleQRCommand stores a QR code of text in the user's directory and sends it back.
+func (b *Bot) handleQRCommand(chatID, userID int64, text string) {
+	if strings.TrimSpace(text) == "" {
+		b.sendTextMessage(chatID, "Usage: /qr <text>")
+		return
+	}
+	if len(text) > MaxQRTextLength {
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ Text is too long for a QR code (%d bytes, max %d)", len(text), MaxQRTextLength))
+		return
+	}
+	if b.isPaused() {
+		b.sendTextMessage(chatID, "⏸ Uploads are temporarily unavailable due to maintenance. Please try again later.")
+		return
+	}
+
+	png, err := encodeQR(text)
+	if err != nil {
+		log.Printf("Error generating QR code: %v", err)
+		b.sendTextMessage(chatID, "❌ Failed to generate the QR code.")
+		return
+	}
+
+	fileName := fmt.Sprintf("qr_%d.png", time.Now().Unix())
+	saved, err := b.saveChatFile(bytes.NewReader(png), "photos", b.organizedName("photos", fileName, time.Now()), "image/png", chatID, userID)
+	if err != nil {
+		log.Printf("Error saving QR code: %v", err)
+		b.sendTextMessage(chatID, b.failureReply(userID, err, "Failed to save the QR code."))
+		return
+	}
+
+	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: fileName, Bytes: png})
+	photo.Caption = fmt.Sprintf("✅ QR code saved as '%s'", b.displayName(userID, saved.Path)) + b.checksumNote(saved.SHA256)
+	if _, err := b.send(photo); err != nil {
+		log.Printf("Failed to send QR code: %v", err)
+		b.sendTextMessage(chatID, fmt.Sprintf("✅ QR code saved as '%s', but sending it failed.", b.displayName(userID, saved.Path)))
+	}
+}
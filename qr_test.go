@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"os"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/makiuchi-d/gozxing"
+	qrreader "github.com/makiuchi-d/gozxing/qrcode"
+)
+
+func decodeQR(t *testing.T, data []byte) string {
+	t.Helper()
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("not a PNG: %v", err)
+	}
+	bmp, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := qrreader.NewQRCodeReader().Decode(bmp, nil)
+	if err != nil {
+		t.Fatalf("failed to decode QR code: %v", err)
+	}
+	return result.GetText()
+}
+
+func TestQRCommandStoresDecodablePNG(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+	text := "https://example.com/путь?q=1"
+
+	b.handleQRCommand(42, 42, text)
+
+	if len(api.sent) != 1 {
+		t.Fatalf("expected one photo to be sent, got %d messages", len(api.sent))
+	}
+	photo, ok := api.sent[0].(tgbotapi.PhotoConfig)
+	if !ok {
+		t.Fatalf("expected a photo, got %T", api.sent[0])
+	}
+	name := strings.TrimSuffix(strings.TrimPrefix(photo.Caption, "✅ QR code saved as '"), "'")
+	data, err := os.ReadFile(b.localPath(name))
+	if err != nil {
+		t.Fatalf("expected the QR code to be stored: %v", err)
+	}
+	if got := decodeQR(t, data); got != text {
+		t.Errorf("QR code decodes to %q, want %q", got, text)
+	}
+}
+
+func TestQRCommandRejectsInvalidInput(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+
+	b.handleQRCommand(42, 42, "   ")
+	b.handleQRCommand(42, 42, strings.Repeat("x", MaxQRTextLength+1))
+
+	texts := api.texts()
+	if len(texts) != 2 || !strings.HasPrefix(texts[0], "Usage") || !strings.Contains(texts[1], "too long") {
+		t.Errorf("unexpected replies %q", texts)
+	}
+	if files, _ := b.storage.List(""); len(files) != 0 {
+		t.Errorf("expected nothing to be stored, got %v", files)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QuietSummaryDelay is how long after the last file saved in quiet mode the
+// summary is sent; every further file postpones it again.
+const QuietSummaryDelay = time.Minute
+
+// quietBatch counts the files saved for one user since the last summary.
+type quietBatch struct {
+	files int
+	size  int64
+	since time.Time
+	send  func(text string)
+	timer *time.Timer
+}
+
+// quietSummaries replaces the save replies of users in quiet mode with one
+// summary per burst, sent once no file arrived for delay. A nil value sends
+// nothing.
+type quietSummaries struct {
+	delay time.Duration
+
+	mu      sync.Mutex
+	batches map[int64]*quietBatch
+}
+
+func newQuietSummaries(delay time.Duration) *quietSummaries {
+	return &quietSummaries{delay: delay, batches: make(map[int64]*quietBatch)}
+}
+
+// Add counts saved towards userID's next summary, which send delivers.
+func (q *quietSummaries) Add(userID int64, saved []FileMeta, send func(text string)) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	batch, ok := q.batches[userID]
+	if !ok {
+		batch = &quietBatch{since: time.Now(), send: send}
+		batch.timer = time.AfterFunc(q.delay, func() { q.Flush(userID) })
+		q.batches[userID] = batch
+	} else {
+		batch.timer.Reset(q.delay)
+	}
+	batch.files += len(saved)
+	for _, m := range saved {
+		batch.size += m.Size
+	}
+}
+
+// Flush sends userID's pending summary now, if there is one.
+func (q *quietSummaries) Flush(userID int64) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	batch, ok := q.batches[userID]
+	if ok {
+		batch.timer.Stop()
+		delete(q.batches, userID)
+	}
+	q.mu.Unlock()
+
+	if ok {
+		batch.send(quietSummary(batch.files, batch.size, time.Since(batch.since)))
+	}
+}
+
+// FlushAll sends every pending summary, e.g. before shutting down.
+func (q *quietSummaries) FlushAll() {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	users := make([]int64, 0, len(q.batches))
+	for userID := range q.batches {
+		users = append(users, userID)
+	}
+	q.mu.Unlock()
+
+	for _, userID := range users {
+		q.Flush(userID)
+	}
+}
+
+// quietSummary formats the summary of files saved over span.
+func quietSummary(files int, size int64, span time.Duration) string {
+	noun := "files"
+	if files == 1 {
+		noun = "file"
+	}
+	minutes := int((span + time.Minute - 1) / time.Minute)
+	period := "minute"
+	if minutes > 1 {
+		period = fmt.Sprintf("%d minutes", minutes)
+	}
+	return fmt.Sprintf("🔕 %d %s saved in the last %s (%s)", files, noun, period, formatSize(size))
+}
+
+// isQuiet reports whether userID turned save replies off with /quiet.
+func (b *Bot) isQuiet(userID int64) bool {
+	return b.quiet != nil && b.users.Get(userID).Quiet
+}
+
+// handleQuietCommand shows or changes the user's quiet mode.
+func (b *Bot) handleQuietCommand(chatID, userID int64, args string) {
+	var on bool
+	switch strings.ToLower(strings.TrimSpace(args)) {
+	case "":
+		state := "off: every saved file is confirmed"
+		if b.users.Get(userID).Quiet {
+			state = "on: saved files are summarized once a burst is over"
+		}
+		b.sendTextMessage(chatID, fmt.Sprintf("🔕 Quiet mode is %s.\nUsage: /quiet on|off", state))
+		return
+	case "on":
+		on = true
+	case "off":
+	default:
+		b.sendTextMessage(chatID, "Usage: /quiet on|off")
+		return
+	}
+
+	if err := b.users.Update(userID, func(s *UserSettings) { s.Quiet = on }); err != nil {
+		log.Printf("Failed to save quiet mode of user %d: %v", userID, err)
+		b.sendTextMessage(chatID, "❌ Failed to save the setting.")
+		return
+	}
+	if on {
+		b.sendTextMessage(chatID, "🔕 Quiet mode on. Instead of a reply per file you get one summary a minute after the last file; errors are still reported.")
+		return
+	}
+	b.quiet.Flush(userID)
+	b.sendTextMessage(chatID, "🔔 Quiet mode off. Every saved file is confirmed again.")
+}
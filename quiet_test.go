@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// newQuietTestBot returns a bot whose user 42 is in quiet mode, with summaries
+// sent delay after the last file.
+func newQuietTestBot(t *testing.T, delay time.Duration) (*Bot, *mockTelegramAPI) {
+	t.Helper()
+	b := newTestBot(t)
+	b.quiet = newQuietSummaries(delay)
+	api := &mockTelegramAPI{}
+	b.client = api
+	b.handleQuietCommand(42, 42, " on")
+	api.sent = nil
+	return b, api
+}
+
+func TestQuietModeSuppressesConfirmations(t *testing.T) {
+	b, api := newQuietTestBot(t, time.Hour)
+	if !b.users.Get(42).Quiet {
+		t.Fatal("expected quiet mode to be persisted")
+	}
+
+	saved, err := b.saveFile(strings.NewReader("content"), "documents", "a.txt", "text/plain", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.replySaved(42, 42, saved)
+	if texts := api.texts(); len(texts) != 0 {
+		t.Errorf("expected no confirmation in quiet mode, got %q", texts)
+	}
+
+	// Errors are still reported
+	b.handleMedia(mediaFile{MediaType: "documents", Label: "document", FileID: "big", Size: MaxFileSize + 1, limitLabel: "File"}, 42, 42)
+	if texts := api.texts(); len(texts) != 1 || !strings.Contains(texts[0], "too large") {
+		t.Errorf("expected the error to be reported, got %q", texts)
+	}
+
+	// Other users are unaffected
+	b.replySaved(7, 7, saved)
+	if texts := api.texts(); len(texts) != 2 || !strings.HasPrefix(texts[1], "✅ Saved") {
+		t.Errorf("expected a normal confirmation for user 7, got %q", texts)
+	}
+
+	// Turning quiet mode off sends the pending summary right away
+	b.handleQuietCommand(42, 42, " off")
+	texts := api.texts()
+	if len(texts) != 4 || texts[2] != "🔕 1 file saved in the last minute (7 B)" || !strings.HasPrefix(texts[3], "🔔 Quiet mode off") {
+		t.Errorf("unexpected replies %q", texts)
+	}
+}
+
+func TestQuietModeBatchesSummary(t *testing.T) {
+	b, api := newQuietTestBot(t, 50*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		b.replySaved(42, 42, FileMeta{Path: "f", Size: 1024})
+		time.Sleep(20 * time.Millisecond)
+	}
+	// Each file postponed the summary, so it has not been sent yet
+	if texts := api.texts(); len(texts) != 0 {
+		t.Fatalf("expected the summary to wait for the burst to end, got %q", texts)
+	}
+	b.replySaved(42, 42, FileMeta{Path: "g", Size: 1024}, FileMeta{Path: "h", Size: 1024})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(api.texts()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if texts := api.texts(); len(texts) != 1 || texts[0] != "🔕 5 files saved in the last minute (5.0 KB)" {
+		t.Errorf("expected one summary of the burst, got %q", texts)
+	}
+}
+
+func TestQuietSummary(t *testing.T) {
+	if got := quietSummary(12, 3<<20, 150*time.Second); got != "🔕 12 files saved in the last 3 minutes (3.0 MB)" {
+		t.Errorf("unexpected summary %q", got)
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// MaxTrackedReplies bounds how many save confirmations are remembered for
+// reactions; the oldest are forgotten first.
+const MaxTrackedReplies = 1000
+
+const (
+	// reactionDelete on a save confirmation deletes the files it names.
+	reactionDelete = "🗑"
+	// reactionPin on a save confirmation pins the files it names.
+	reactionPin = "⭐"
+)
+
+// messageReactionUpdated is Telegram's message_reaction update, which the
+// vendored telegram-bot-api predates.
+type messageReactionUpdated struct {
+	Chat        tgbotapi.Chat  `json:"chat"`
+	MessageID   int            `json:"message_id"`
+	User        *tgbotapi.User `json:"user"`
+	OldReaction []reactionType `json:"old_reaction"`
+	NewReaction []reactionType `json:"new_reaction"`
+}
+
+// reactionType is a reaction; only type "emoji" carries Emoji.
+type reactionType struct {
+	Type  string `json:"type"`
+	Emoji string `json:"emoji"`
+}
+
+// added returns the emoji reactions in r that were not there before, without
+// variation selectors.
+func (r messageReactionUpdated) added() []string {
+	old := make(map[string]bool)
+	for _, t := range r.OldReaction {
+		old[normalizeEmoji(t.Emoji)] = true
+	}
+	var added []string
+	for _, t := range r.NewReaction {
+		if e := normalizeEmoji(t.Emoji); t.Type == "emoji" && !old[e] {
+			added = append(added, e)
+		}
+	}
+	return added
+}
+
+func normalizeEmoji(s string) string {
+	return strings.ReplaceAll(s, "\ufe0f", "")
+}
+
+// botUpdate is an update as Telegram sends it: tgbotapi.Update plus the
+// fields the library does not know yet.
+type botUpdate struct {
+	tgbotapi.Update
+	MessageReaction *messageReactionUpdated `json:"message_reaction"`
+}
+
+// decodeUpdates decodes the result of getUpdates.
+func decodeUpdates(result json.RawMessage) ([]botUpdate, error) {
+	var updates []botUpdate
+	if err := json.Unmarshal(result, &updates); err != nil {
+		return nil, fmt.Errorf("failed to decode updates: %w", err)
+	}
+	return updates, nil
+}
+
+// savedReply is a save confirmation: who sent the files and where they went.
+type savedReply struct {
+	owner int64 // the sender's user ID, also in groups
+	paths []string
+}
+
+// savedReplies maps save confirmations (chat and message ID) to the files
+// they confirm, so reactions to them can act on those files. A nil
+// *savedReplies tracks nothing.
+type savedReplies struct {
+	limit int
+
+	mu      sync.Mutex
+	byMsg   map[string]savedReply
+	ordered []string // keys, oldest first
+}
+
+func newSavedReplies(limit int) *savedReplies {
+	return &savedReplies{limit: limit, byMsg: make(map[string]savedReply)}
+}
+
+func replyKey(chatID int64, messageID int) string {
+	return fmt.Sprintf("%d/%d", chatID, messageID)
+}
+
+// Track remembers that messageID in chatID confirmed the paths owner sent.
+func (s *savedReplies) Track(chatID int64, messageID int, owner int64, paths []string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := replyKey(chatID, messageID)
+	if _, ok := s.byMsg[key]; !ok {
+		s.ordered = append(s.ordered, key)
+	}
+	s.byMsg[key] = savedReply{owner: owner, paths: paths}
+	for len(s.ordered) > s.limit {
+		delete(s.byMsg, s.ordered[0])
+		s.ordered = s.ordered[1:]
+	}
+}
+
+// Lookup returns the files confirmed by messageID in chatID.
+func (s *savedReplies) Lookup(chatID int64, messageID int) (savedReply, bool) {
+	if s == nil {
+		return savedReply{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.byMsg[replyKey(chatID, messageID)]
+	return r, ok
+}
+
+// Forget drops the confirmation, once its files are gone.
+func (s *savedReplies) Forget(chatID int64, messageID int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := replyKey(chatID, messageID)
+	if _, ok := s.byMsg[key]; !ok {
+		return
+	}
+	delete(s.byMsg, key)
+	for i, k := range s.ordered {
+		if k == key {
+			s.ordered = append(s.ordered[:i], s.ordered[i+1:]...)
+			break
+		}
+	}
+}
+
+// handleMessageReaction applies 🗑 (delete) and ⭐ (pin) reactions the owner
+// adds to one of the bot's save confirmations. Reactions to other messages,
+// or by other users, are ignored.
+func (b *Bot) handleMessageReaction(r *messageReactionUpdated) {
+	if r.User == nil || !b.isUserAllowed(r.User.ID) {
+		return
+	}
+	saved, ok := b.savedReplies.Lookup(r.Chat.ID, r.MessageID)
+	if !ok || saved.owner != r.User.ID {
+		log.Printf("Ignoring reaction of user %d to untracked message %d", r.User.ID, r.MessageID)
+		return
+	}
+
+	for _, emoji := range r.added() {
+		switch emoji {
+		case reactionDelete:
+			b.deleteByReaction(r.Chat.ID, saved)
+			b.savedReplies.Forget(r.Chat.ID, r.MessageID)
+			return
+		case reactionPin:
+			b.pinByReaction(r.Chat.ID, saved)
+		}
+	}
+}
+
+// deleteByReaction deletes the files of a save confirmation.
+func (b *Bot) deleteByReaction(chatID int64, saved savedReply) {
+	var deleted, missing []string
+	for _, rel := range saved.paths {
+		name := b.displayName(saved.owner, rel)
+		if err := b.storage.Delete(rel); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				missing = append(missing, name)
+				continue
+			}
+			log.Printf("Error deleting %s: %v", rel, err)
+			b.sendTextMessage(chatID, fmt.Sprintf("❌ Failed to delete '%s'.", name))
+			continue
+		}
+		b.forgetFile(rel)
+		b.audit(AuditEntry{User: saved.owner, Action: "delete", Path: rel, Detail: "🗑 reaction"})
+		log.Printf("User %d deleted %s by reaction", saved.owner, rel)
+		deleted = append(deleted, name)
+	}
+
+	if len(deleted) > 0 {
+		b.sendTextMessage(chatID, fmt.Sprintf("🗑 Deleted '%s'", strings.Join(deleted, "', '")))
+	}
+	if len(missing) > 0 {
+		b.sendTextMessage(chatID, fmt.Sprintf("⚠️ '%s' no longer exists", strings.Join(missing, "', '")))
+	}
+}
+
+// pinByReaction pins the files of a save confirmation.
+func (b *Bot) pinByReaction(chatID int64, saved savedReply) {
+	if _, ok := b.storage.(*LocalStorage); !ok {
+		b.sendTextMessage(chatID, "⚠️ Pinning is only supported with local storage")
+		return
+	}
+	var pinned []string
+	for _, rel := range saved.paths {
+		name := b.displayName(saved.owner, rel)
+		err := b.setPinned(rel, saved.owner, true)
+		switch {
+		case errors.Is(err, errSourceMissing):
+			b.sendTextMessage(chatID, fmt.Sprintf("⚠️ '%s' no longer exists", name))
+		case err != nil:
+			log.Printf("Error pinning %s: %v", rel, err)
+			b.sendTextMessage(chatID, fmt.Sprintf("❌ Failed to pin '%s'.", name))
+		default:
+			log.Printf("User %d pinned %s by reaction", saved.owner, rel)
+			pinned = append(pinned, name)
+		}
+	}
+	if len(pinned) > 0 {
+		b.sendTextMessage(chatID, fmt.Sprintf("📌 Pinned '%s'; it will not be deleted automatically", strings.Join(pinned, "', '")))
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// reaction returns the reaction update of user to messageID, changing the
+// emoji from before to after.
+func reaction(user int64, messageID int, before, after []string) *messageReactionUpdated {
+	r := &messageReactionUpdated{Chat: tgbotapi.Chat{ID: user}, MessageID: messageID, User: &tgbotapi.User{ID: user}}
+	for _, e := range before {
+		r.OldReaction = append(r.OldReaction, reactionType{Type: "emoji", Emoji: e})
+	}
+	for _, e := range after {
+		r.NewReaction = append(r.NewReaction, reactionType{Type: "emoji", Emoji: e})
+	}
+	return r
+}
+
+// newReactionTestBot returns a bot with a stored file confirmed to user 42;
+// the confirmation is message 1.
+func newReactionTestBot(t *testing.T) (*Bot, *mockTelegramAPI, FileMeta) {
+	t.Helper()
+	b := newTestBot(t)
+	b.savedReplies = newSavedReplies(MaxTrackedReplies)
+	api := &mockTelegramAPI{}
+	b.client = api
+
+	saved, err := b.saveFile(strings.NewReader("content"), "documents", "report.pdf", "application/pdf", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.replySaved(42, 42, saved)
+	api.sent = nil
+	return b, api, saved
+}
+
+func TestDecodeUpdatesWithMessageReaction(t *testing.T) {
+	raw := json.RawMessage(`[
+		{"update_id": 7, "message": {"message_id": 3, "chat": {"id": 42}, "text": "hi"}},
+		{"update_id": 8, "message_reaction": {"chat": {"id": 42}, "message_id": 3, "user": {"id": 42},
+			"date": 1700000000, "old_reaction": [], "new_reaction": [{"type": "emoji", "emoji": "⭐️"}]}}
+	]`)
+	updates, err := decodeUpdates(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updates) != 2 || updates[0].Message == nil || updates[0].Message.Text != "hi" || updates[0].MessageReaction != nil {
+		t.Fatalf("unexpected first update %+v", updates)
+	}
+	r := updates[1].MessageReaction
+	if updates[1].UpdateID != 8 || r == nil || r.MessageID != 3 || r.User.ID != 42 {
+		t.Fatalf("unexpected reaction update %+v", updates[1])
+	}
+	if added := r.added(); len(added) != 1 || added[0] != reactionPin {
+		t.Errorf("expected ⭐ without variation selector, got %q", added)
+	}
+}
+
+func TestReactionAddedIgnoresKeptReactions(t *testing.T) {
+	r := reaction(42, 1, []string{"👍", "⭐"}, []string{"⭐", "🗑"})
+	if added := r.added(); len(added) != 1 || added[0] != reactionDelete {
+		t.Errorf("expected only the new 🗑, got %q", added)
+	}
+}
+
+func TestReplySavedTracksConfirmation(t *testing.T) {
+	b, _, saved := newReactionTestBot(t)
+
+	got, ok := b.savedReplies.Lookup(42, 1)
+	if !ok || got.owner != 42 || len(got.paths) != 1 || got.paths[0] != saved.Path {
+		t.Errorf("expected message 1 to track %s, got %+v (%v)", saved.Path, got, ok)
+	}
+	if _, ok := b.savedReplies.Lookup(42, 2); ok {
+		t.Error("expected other messages to be unknown")
+	}
+}
+
+func TestSavedRepliesForgetsOldest(t *testing.T) {
+	s := newSavedReplies(2)
+	for id := 1; id <= 3; id++ {
+		s.Track(42, id, 42, []string{"f"})
+	}
+	if _, ok := s.Lookup(42, 1); ok {
+		t.Error("expected the oldest confirmation to be forgotten")
+	}
+	for _, id := range []int{2, 3} {
+		if _, ok := s.Lookup(42, id); !ok {
+			t.Errorf("expected confirmation %d to be kept", id)
+		}
+	}
+}
+
+func TestDeleteReaction(t *testing.T) {
+	b, api, saved := newReactionTestBot(t)
+
+	b.handleMessageReaction(reaction(42, 1, nil, []string{"🗑"}))
+
+	if exists(b.localPath(saved.Path)) {
+		t.Error("expected the file to be deleted")
+	}
+	if _, err := readMeta(b.storagePath, saved.Path); err == nil {
+		t.Error("expected the sidecar to be removed")
+	}
+	if texts := api.texts(); len(texts) != 1 || texts[0] != "🗑 Deleted 'report.pdf'" {
+		t.Errorf("unexpected replies %q", texts)
+	}
+	if _, ok := b.savedReplies.Lookup(42, 1); ok {
+		t.Error("expected the confirmation to be forgotten")
+	}
+}
+
+func TestPinReaction(t *testing.T) {
+	b, api, saved := newReactionTestBot(t)
+
+	b.handleMessageReaction(reaction(42, 1, nil, []string{"⭐"}))
+
+	m, err := readMeta(b.storagePath, saved.Path)
+	if err != nil || !m.Pinned {
+		t.Errorf("expected the file to be pinned, got %+v (%v)", m, err)
+	}
+	if texts := api.texts(); len(texts) != 1 || !strings.HasPrefix(texts[0], "📌 Pinned 'report.pdf'") {
+		t.Errorf("unexpected replies %q", texts)
+	}
+	if !exists(b.localPath(saved.Path)) {
+		t.Error("expected the file to stay")
+	}
+}
+
+func TestReactionsIgnoredOutsideConfirmations(t *testing.T) {
+	b, api, saved := newReactionTestBot(t)
+
+	for _, r := range []*messageReactionUpdated{
+		reaction(42, 99, nil, []string{"🗑"}),        // unknown message
+		reaction(7, 1, nil, []string{"🗑"}),          // someone else
+		reaction(42, 1, nil, []string{"👍"}),         // no action
+		reaction(42, 1, []string{"🗑"}, nil),         // removed reaction
+		{Chat: tgbotapi.Chat{ID: 42}, MessageID: 1}, // anonymous
+	} {
+		b.handleMessageReaction(r)
+	}
+
+	if !exists(b.localPath(saved.Path)) {
+		t.Error("expected the file to stay")
+	}
+	if m, _ := readMeta(b.storagePath, saved.Path); m.Pinned {
+		t.Error("expected the file to stay unpinned")
+	}
+	if texts := api.texts(); len(texts) != 0 {
+		t.Errorf("expected no replies, got %q", texts)
+	}
+}
+
+func TestReactionsInGroups(t *testing.T) {
+	b := newTestBot(t)
+	b.perUserDirs = true
+	b.allowedUsers = map[int64]bool{7: true, 42: true}
+	b.savedReplies = newSavedReplies(MaxTrackedReplies)
+	api := &mockTelegramAPI{}
+	b.client = api
+
+	saved, err := b.saveChatFile(strings.NewReader("content"), "documents", "report.pdf", "application/pdf", -100, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.replySaved(-100, 42, saved)
+	api.sent = nil
+
+	inGroup := func(user int64) *messageReactionUpdated {
+		r := reaction(user, 1, nil, []string{"🗑"})
+		r.Chat.ID = -100
+		return r
+	}
+	b.handleMessageReaction(inGroup(7))
+	if !exists(b.localPath(saved.Path)) {
+		t.Fatal("expected another member's reaction to be ignored")
+	}
+	b.handleMessageReaction(inGroup(42))
+	if exists(b.localPath(saved.Path)) {
+		t.Error("expected the sender's reaction in the group to delete the file")
+	}
+	if texts := api.texts(); len(texts) != 1 || texts[0] != "🗑 Deleted 'report.pdf'" {
+		t.Errorf("unexpected replies %q", texts)
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"strconv"
+)
+
+const (
+	// DefaultRecompressQuality is the JPEG quality RECOMPRESS_IMAGES encodes with.
+	DefaultRecompressQuality = 85
+	// DefaultRecompressMaxDimension is the longest side, in pixels, images
+	// are scaled down to.
+	DefaultRecompressMaxDimension = 2560
+)
+
+var errNotRecompressible = errors.New("not a JPEG or PNG image")
+
+// recompressSettings are the RECOMPRESS_* settings.
+type recompressSettings struct {
+	Quality      int // JPEG quality, 1-100
+	MaxDimension int // longest side in pixels; 0 keeps the size
+}
+
+// parseRecompressQuality parses RECOMPRESS_QUALITY.
+func parseRecompressQuality(s string) (int, error) {
+	if s == "" {
+		return DefaultRecompressQuality, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > 100 {
+		return 0, fmt.Errorf("invalid RECOMPRESS_QUALITY %q: expected 1 to 100", s)
+	}
+	return n, nil
+}
+
+// parseRecompressMaxDimension parses RECOMPRESS_MAX_DIMENSION; 0 disables
+// scaling.
+func parseRecompressMaxDimension(s string) (int, error) {
+	if s == "" {
+		return DefaultRecompressMaxDimension, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid RECOMPRESS_MAX_DIMENSION %q: expected a number of pixels", s)
+	}
+	return n, nil
+}
+
+// recompresses reports whether a received file is re-encoded under
+// RECOMPRESS_IMAGES: photos and JPEG or PNG documents.
+func (b *Bot) recompresses(mediaType, mimeType string) bool {
+	if b.recompress == nil || (mediaType != "photos" && mediaType != "documents") {
+		return false
+	}
+	return mimeType == "image/jpeg" || mimeType == "image/png"
+}
+
+// recompressUpload reads the received image in r and returns the content to
+// store along with the size it had as received when it was recompressed, or 0
+// when it is stored as it came.
+func (b *Bot) recompressUpload(r io.Reader, name string) (io.Reader, int64, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	smaller, err := recompressImage(data, *b.recompress)
+	switch {
+	case err != nil:
+		log.Printf("Not recompressing %s: %v", name, err)
+	case len(smaller) < len(data):
+		log.Printf("Recompressed %s from %d to %d bytes", name, len(data), len(smaller))
+		return bytes.NewReader(smaller), int64(len(data)), nil
+	default:
+		log.Printf("Keeping %s as received: recompressing would not make it smaller", name)
+	}
+	return bytes.NewReader(data), 0, nil
+}
+
+// recompressImage re-encodes a JPEG or PNG image, upright as its EXIF
+// orientation says and scaled down to settings.MaxDimension. JPEGs are
+// encoded with settings.Quality; PNGs stay lossless at best compression. The
+// result may be larger than data.
+func recompressImage(data []byte, settings recompressSettings) ([]byte, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || (format != "jpeg" && format != "png") {
+		return nil, errNotRecompressible
+	}
+	if cfg.Width*cfg.Height > maxDecodePixels {
+		return nil, fmt.Errorf("image of %dx%d pixels is too large to decode", cfg.Width, cfg.Height)
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	img := toRGBA(src)
+	// Re-encoding drops EXIF, so bake the orientation into the pixels
+	if format == "jpeg" {
+		img = orientImage(img, jpegOrientation(data))
+	}
+	img = scaleDown(img, settings.MaxDimension)
+
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: settings.Quality})
+	} else {
+		err = (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", format, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// toRGBA copies img into an RGBA image with its origin at 0,0.
+func toRGBA(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+	return dst
+}
+
+// jpegOrientation returns the EXIF orientation (1-8) of a JPEG, or 1 when it
+// has none.
+func jpegOrientation(data []byte) int {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		// Start of scan: no more metadata segments follow
+		if marker == 0xDA {
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return 1
+		}
+		if segment := data[i+4 : end]; marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		i = end
+	}
+	return 1
+}
+
+// exifOrientation reads the orientation tag from the first IFD of a TIFF
+// structure, the payload of a JPEG's Exif segment.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for n := 0; n < count; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			if o := int(order.Uint16(tiff[entry+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// orientImage turns img as EXIF orientation o says so it displays upright
+// without the tag.
+func orientImage(img *image.RGBA, o int) *image.RGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	// from maps a pixel of the result to the pixel of img it comes from
+	var from func(x, y int) (int, int)
+	switch o {
+	case 2: // mirrored horizontally
+		from = func(x, y int) (int, int) { return w - 1 - x, y }
+	case 3: // rotated 180°
+		from = func(x, y int) (int, int) { return w - 1 - x, h - 1 - y }
+	case 4: // mirrored vertically
+		from = func(x, y int) (int, int) { return x, h - 1 - y }
+	case 5: // transposed
+		from = func(x, y int) (int, int) { return y, x }
+	case 6: // to be rotated 90° clockwise
+		from = func(x, y int) (int, int) { return y, h - 1 - x }
+	case 7: // transversed
+		from = func(x, y int) (int, int) { return w - 1 - y, h - 1 - x }
+	case 8: // to be rotated 90° counter-clockwise
+		from = func(x, y int) (int, int) { return w - 1 - y, x }
+	default:
+		return img
+	}
+
+	dw, dh := w, h
+	if o >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			sx, sy := from(x, y)
+			copy(dst.Pix[dst.PixOffset(x, y):][:4], img.Pix[img.PixOffset(sx, sy):][:4])
+		}
+	}
+	return dst
+}
+
+// scaleDown shrinks img so neither side exceeds maxDim, averaging the source
+// pixels each result pixel covers. Smaller images and maxDim 0 leave it as is.
+func scaleDown(img *image.RGBA, maxDim int) *image.RGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if maxDim <= 0 || (w <= maxDim && h <= maxDim) {
+		return img
+	}
+	dw, dh := maxDim, max(1, h*maxDim/w)
+	if h > w {
+		dw, dh = max(1, w*maxDim/h), maxDim
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*h/dh, max((y+1)*h/dh, y*h/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*w/dw, max((x+1)*w/dw, x*w/dw+1)
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := img.Pix[img.PixOffset(x0, sy):]
+				for sx := 0; sx < x1-x0; sx++ {
+					for c := 0; c < 4; c++ {
+						sum[c] += int(row[sx*4+c])
+					}
+				}
+			}
+			n := (x1 - x0) * (y1 - y0)
+			px := dst.Pix[dst.PixOffset(x, y):]
+			for c := 0; c < 4; c++ {
+				px[c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fixtureImage is a w×h gradient with some noise, like a photo compresses.
+func fixtureImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	seed := uint32(1)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			seed = seed*1664525 + 1013904223
+			noise := uint8(seed >> 28)
+			img.Set(x, y, color.RGBA{R: uint8(x*255/w) + noise, G: uint8(y*255/h) + noise, B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func encodeJPEG(t *testing.T, img image.Image, quality int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withOrientation inserts an Exif segment with orientation o after the SOI
+// marker of a JPEG.
+func withOrientation(data []byte, o uint16) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	entry := make([]byte, 12)
+	binary.BigEndian.PutUint16(entry, 0x0112)
+	binary.BigEndian.PutUint16(entry[2:], 3) // SHORT
+	binary.BigEndian.PutUint32(entry[4:], 1)
+	binary.BigEndian.PutUint16(entry[8:], o)
+	payload := append(append([]byte("Exif\x00\x00"), tiff...), append(entry, 0, 0, 0, 0)...)
+
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	out := append([]byte{}, data[:2]...)
+	out = append(append(out, segment...), payload...)
+	return append(out, data[2:]...)
+}
+
+func TestParseRecompressSettings(t *testing.T) {
+	if q, err := parseRecompressQuality(""); err != nil || q != DefaultRecompressQuality {
+		t.Errorf("expected the default quality, got %d, %v", q, err)
+	}
+	for _, bad := range []string{"0", "101", "high"} {
+		if _, err := parseRecompressQuality(bad); err == nil {
+			t.Errorf("expected an error for quality %q", bad)
+		}
+	}
+	if d, err := parseRecompressMaxDimension("0"); err != nil || d != 0 {
+		t.Errorf("expected 0 to disable scaling, got %d, %v", d, err)
+	}
+	if _, err := parseRecompressMaxDimension("-1"); err == nil {
+		t.Error("expected an error for a negative dimension")
+	}
+}
+
+func TestRecompressImageShrinksFixture(t *testing.T) {
+	original := encodeJPEG(t, fixtureImage(1200, 900), 100)
+	smaller, err := recompressImage(original, recompressSettings{Quality: 75, MaxDimension: 600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(smaller) >= len(original)/2 {
+		t.Errorf("expected the JPEG to shrink well below %d bytes, got %d", len(original), len(smaller))
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(smaller))
+	if err != nil || format != "jpeg" || cfg.Width != 600 || cfg.Height != 450 {
+		t.Errorf("expected a 600x450 JPEG, got %s %dx%d (%v)", format, cfg.Width, cfg.Height, err)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, fixtureImage(800, 400)); err != nil {
+		t.Fatal(err)
+	}
+	smaller, err = recompressImage(buf.Bytes(), recompressSettings{Quality: 75, MaxDimension: 400})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, format, err = image.DecodeConfig(bytes.NewReader(smaller))
+	if err != nil || format != "png" || cfg.Width != 400 || cfg.Height != 200 || len(smaller) >= buf.Len() {
+		t.Errorf("expected a smaller 400x200 PNG, got %s %dx%d of %d bytes (%v)", format, cfg.Width, cfg.Height, len(smaller), err)
+	}
+
+	if _, err := recompressImage([]byte("GIF89a not really"), recompressSettings{Quality: 75}); err == nil {
+		t.Error("expected other formats to be refused")
+	}
+}
+
+func TestRecompressImageAppliesOrientation(t *testing.T) {
+	// Red left half, blue right half
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 40; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= 20 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	data := withOrientation(encodeJPEG(t, img, 95), 6)
+	if o := jpegOrientation(data); o != 6 {
+		t.Fatalf("expected orientation 6, got %d", o)
+	}
+
+	out, err := recompressImage(data, recompressSettings{Quality: 95})
+	if err != nil {
+		t.Fatal(err)
+	}
+	upright, err := jpeg.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := upright.Bounds().Size(); size.X != 20 || size.Y != 40 {
+		t.Fatalf("expected the image turned to 20x40, got %v", size)
+	}
+	// Turned clockwise, the left half is now on top
+	if r, _, b, _ := upright.At(10, 5).RGBA(); r < b {
+		t.Errorf("expected red at the top, got r=%d b=%d", r, b)
+	}
+	if r, _, b, _ := upright.At(10, 35).RGBA(); b < r {
+		t.Errorf("expected blue at the bottom, got r=%d b=%d", r, b)
+	}
+}
+
+func TestRecompressUploadKeepsSmallerOriginal(t *testing.T) {
+	b := newTestBot(t)
+	b.recompress = &recompressSettings{Quality: 100}
+	original := encodeJPEG(t, fixtureImage(300, 200), 10)
+
+	r, originalSize, err := b.recompressUpload(bytes.NewReader(original), "tiny.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got bytes.Buffer
+	got.ReadFrom(r)
+	if originalSize != 0 || !bytes.Equal(got.Bytes(), original) {
+		t.Errorf("expected the original to be kept, got %d bytes (original size %d)", got.Len(), originalSize)
+	}
+}
+
+func TestRecompressedDocumentIsStored(t *testing.T) {
+	original := encodeJPEG(t, fixtureImage(1200, 900), 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(original)
+	}))
+	defer srv.Close()
+
+	b := newDownloadTestBot(t, 5*time.Second)
+	api := &mockTelegramAPI{fileURL: srv.URL}
+	b.client = api
+	b.recompress = &recompressSettings{Quality: 75, MaxDimension: 600}
+
+	b.handleMessage(&tgbotapi.Message{
+		From:     &tgbotapi.User{ID: 42},
+		Chat:     &tgbotapi.Chat{ID: 42},
+		Document: &tgbotapi.Document{FileID: "d1", FileName: "photo.jpg", MimeType: "image/jpeg", FileSize: len(original)},
+	})
+
+	texts := api.texts()
+	if len(texts) != 1 || !strings.Contains(texts[0], "🗜 'photo.jpg' recompressed: "+formatSize(int64(len(original)))+" → ") {
+		t.Fatalf("unexpected reply %q", texts)
+	}
+	m, err := readMeta(b.storagePath, "photo.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.OriginalSize != int64(len(original)) || m.Size >= m.OriginalSize || m.FileID != "" {
+		t.Errorf("unexpected metadata %+v", m)
+	}
+	if info := formatFileInfo("photo.jpg", m); !strings.Contains(info, "Recompressed from: "+formatSize(m.OriginalSize)) {
+		t.Errorf("expected /info to show the original size, got %q", info)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// replyVerbosity selects how much REPLY_VERBOSITY puts in save replies.
+type replyVerbosity int
+
+const (
+	replyNormal  replyVerbosity = iota // name and size, plus the folder when not the storage root
+	replyTerse                         // name only
+	replyVerbose                       // name, size, folder and checksum
+)
+
+// parseReplyVerbosity parses REPLY_VERBOSITY; empty means normal.
+func parseReplyVerbosity(s string) (replyVerbosity, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "normal":
+		return replyNormal, nil
+	case "terse":
+		return replyTerse, nil
+	case "verbose":
+		return replyVerbose, nil
+	}
+	return replyNormal, fmt.Errorf("invalid REPLY_VERBOSITY %q (want terse, normal or verbose)", s)
+}
+
+// savedFile is one stored file as shown in a save reply.
+type savedFile struct {
+	Name   string // as shown to the user
+	Folder string // storage-relative folder, "." for the storage root
+	Size   int64
+}
+
+// savedMessage formats the reply for files stored from one message.
+func savedMessage(files []savedFile, verbosity replyVerbosity) string {
+	names := make([]string, len(files))
+	for i, f := range files {
+		switch verbosity {
+		case replyTerse:
+			names[i] = f.Name
+		default:
+			names[i] = fmt.Sprintf("'%s' (%s)", f.Name, formatSize(f.Size))
+		}
+	}
+
+	if verbosity == replyTerse {
+		return "✅ " + strings.Join(names, ", ")
+	}
+
+	msg := "✅ Saved " + strings.Join(names, " and ")
+	if folder := files[0].Folder; folder != "." || verbosity == replyVerbose {
+		if folder == "." {
+			folder = ""
+		}
+		msg += fmt.Sprintf("\n📁 Folder: /%s", folder)
+	}
+	return msg
+}
+
+// replySaved confirms files stored for userID in chatID, with their checksums
+// when REPLY_CHECKSUM or verbose replies are enabled. Users in quiet mode get
+// a summary per burst instead.
+func (b *Bot) replySaved(chatID, userID int64, saved ...FileMeta) {
+	if b.isQuiet(userID) {
+		b.quiet.Add(userID, saved, func(text string) { b.sendTextMessage(chatID, text) })
+		return
+	}
+
+	files := make([]savedFile, len(saved))
+	for i, m := range saved {
+		files[i] = savedFile{Name: b.displayName(userID, m.Path), Folder: path.Dir(m.Path), Size: m.Size}
+	}
+
+	msg := savedMessage(files, b.replyVerbosity)
+	if len(saved) == 1 && saved[0].Appended > 0 {
+		msg = fmt.Sprintf("📎 Appended %s to '%s', now %s", formatSize(saved[0].Appended), files[0].Name, formatSize(saved[0].Size))
+	}
+	for i, m := range saved {
+		if m.OriginalSize > 0 {
+			msg += fmt.Sprintf("\n🗜 '%s' recompressed: %s → %s (-%d%%)", files[i].Name, formatSize(m.OriginalSize), formatSize(m.Size), 100-m.Size*100/m.OriginalSize)
+		}
+	}
+	if b.replyChecksum || b.replyVerbosity == replyVerbose {
+		for i, m := range saved {
+			switch {
+			case m.SHA256 == "":
+			case len(saved) == 1:
+				msg += fmt.Sprintf("\nSHA-256: %s", m.SHA256)
+			default:
+				msg += fmt.Sprintf("\nSHA-256 of '%s': %s", files[i].Name, m.SHA256)
+			}
+		}
+	}
+	sent, err := b.send(tgbotapi.NewMessage(chatID, msg))
+	if err != nil {
+		log.Printf("Failed to send message: %v", err)
+		return
+	}
+
+	// Remember the confirmation so reactions to it can act on the files
+	paths := make([]string, len(saved))
+	for i, m := range saved {
+		paths[i] = m.Path
+	}
+	b.savedReplies.Track(chatID, sent.MessageID, userID, paths)
+}
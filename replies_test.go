@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseReplyVerbosity(t *testing.T) {
+	cases := map[string]replyVerbosity{"": replyNormal, "normal": replyNormal, "Terse": replyTerse, "verbose": replyVerbose}
+	for in, want := range cases {
+		if got, err := parseReplyVerbosity(in); err != nil || got != want {
+			t.Errorf("parseReplyVerbosity(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := parseReplyVerbosity("loud"); err == nil {
+		t.Error("expected an invalid verbosity to be rejected")
+	}
+}
+
+func TestSavedMessage(t *testing.T) {
+	root := []savedFile{{Name: "report.pdf", Folder: ".", Size: 1536}}
+	perUser := []savedFile{{Name: "report.pdf", Folder: "42", Size: 3 * 1024 * 1024}}
+	voice := []savedFile{{Name: "voice.ogg", Folder: ".", Size: 900}, {Name: "voice.mp3", Folder: ".", Size: 2048}}
+
+	cases := []struct {
+		files     []savedFile
+		verbosity replyVerbosity
+		want      string
+	}{
+		{root, replyTerse, "✅ report.pdf"},
+		{perUser, replyTerse, "✅ report.pdf"},
+		{root, replyNormal, "✅ Saved 'report.pdf' (1.5 KB)"},
+		{perUser, replyNormal, "✅ Saved 'report.pdf' (3.0 MB)\n📁 Folder: /42"},
+		{root, replyVerbose, "✅ Saved 'report.pdf' (1.5 KB)\n📁 Folder: /"},
+		{voice, replyNormal, "✅ Saved 'voice.ogg' (900 B) and 'voice.mp3' (2.0 KB)"},
+		{voice, replyTerse, "✅ voice.ogg, voice.mp3"},
+	}
+	for _, c := range cases {
+		if got := savedMessage(c.files, c.verbosity); got != c.want {
+			t.Errorf("savedMessage(%+v, %v) = %q; want %q", c.files, c.verbosity, got, c.want)
+		}
+	}
+}
+
+func TestReplySavedChecksums(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+	b.perUserDirs = true
+	saved := FileMeta{Path: "42/notes.txt", Size: 10, SHA256: "abc"}
+
+	b.replySaved(42, 42, saved)
+	b.replyVerbosity = replyVerbose
+	b.replySaved(42, 42, saved, FileMeta{Path: "42/notes.md", Size: 12, SHA256: "def"})
+
+	texts := api.texts()
+	if len(texts) != 2 || texts[0] != "✅ Saved 'notes.txt' (10 B)\n📁 Folder: /42" {
+		t.Fatalf("unexpected replies %q", texts)
+	}
+	if !strings.HasSuffix(texts[1], "\nSHA-256 of 'notes.txt': abc\nSHA-256 of 'notes.md': def") {
+		t.Errorf("expected verbose replies to list every checksum, got %q", texts[1])
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// errTooLargeToSend reports a stored file above the bot upload limit.
+var errTooLargeToSend = errors.New("file too large to send")
+
+// resendConfig returns the message sending file to chatID the way files of
+// mediaType are shown; anything else is sent as a document.
+func resendConfig(chatID int64, mediaType string, file tgbotapi.RequestFileData) tgbotapi.Chattable {
+	switch mediaType {
+	case "photos":
+		return tgbotapi.NewPhoto(chatID, file)
+	case "videos":
+		return tgbotapi.NewVideo(chatID, file)
+	case "animations":
+		return tgbotapi.NewAnimation(chatID, file)
+	case "audio":
+		return tgbotapi.NewAudio(chatID, file)
+	case "voice":
+		return tgbotapi.NewVoice(chatID, file)
+	case "video_notes":
+		return tgbotapi.NewVideoNote(chatID, 0, file)
+	case "stickers":
+		return tgbotapi.NewSticker(chatID, file)
+	}
+	return tgbotapi.NewDocument(chatID, file)
+}
+
+// isFileIDError reports whether Telegram rejected a file_id, e.g. because it
+// expired or belongs to another bot.
+func isFileIDError(err error) bool {
+	var tgErr *tgbotapi.Error
+	return errors.As(err, &tgErr) && tgErr.Code == 400
+}
+
+// resendFile sends the stored file rel to chatID, by its cached file_id when
+// there is one and by uploading it from storage otherwise or when Telegram no
+// longer accepts the file_id. It reports whether the file was uploaded.
+func (b *Bot) resendFile(chatID int64, rel string, owner int64) (bool, error) {
+	src := b.localPath(rel)
+	info, err := os.Stat(src)
+	if err != nil || !info.Mode().IsRegular() {
+		return false, errSourceMissing
+	}
+	m := b.fileMeta(rel, src, info)
+
+	if m.FileID != "" {
+		_, err := b.send(resendConfig(chatID, m.MediaType, tgbotapi.FileID(m.FileID)))
+		if err == nil || !isFileIDError(err) {
+			return false, err
+		}
+		log.Printf("Cached file_id of %s was rejected (%v), uploading it instead", rel, err)
+	}
+
+	size := info.Size()
+	if m.Compressed {
+		size = m.Size
+	}
+	if size > MaxFileSize {
+		return true, fmt.Errorf("%w: %s", errTooLargeToSend, formatSize(size))
+	}
+	f, err := b.openFile(rel)
+	if err != nil {
+		return true, err
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return true, err
+	}
+
+	sent, err := b.send(resendConfig(chatID, m.MediaType, tgbotapi.FileBytes{Name: path.Base(uncompressedName(rel, m)), Bytes: data}))
+	if err != nil {
+		return true, err
+	}
+
+	// Cache the new file_id for the next resend
+	if media := messageMedia(&sent); media != nil {
+		m.FileID = media.FileID
+		if m.MediaType == "" {
+			m.MediaType = media.MediaType
+		}
+		if m.Owner == 0 {
+			m.Owner = owner
+		}
+		if err := writeMeta(b.storagePath, m); err != nil {
+			log.Printf("Failed to cache file_id of %s: %v", rel, err)
+		}
+	}
+	return true, nil
+}
+
+// handleResendCommand sends one of the user's stored files back to them.
+func (b *Bot) handleResendCommand(chatID, userID int64, args string) {
+	if !b.requireLocalStorage(chatID) {
+		return
+	}
+
+	parts := splitArgs(args)
+	if len(parts) != 1 {
+		b.sendTextMessage(chatID, "Usage: /resend <file>\nQuote names containing spaces, e.g. /resend \"my file.pdf\"")
+		return
+	}
+	name, err := cleanRelPath(parts[0])
+	if err != nil {
+		b.sendTextMessage(chatID, "❌ Invalid file name")
+		return
+	}
+
+	rel := b.userFile(userID, name)
+	uploaded, err := b.resendFile(chatID, rel, userID)
+	switch {
+	case errors.Is(err, errSourceMissing):
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ File '%s' not found", name))
+	case errors.Is(err, errTooLargeToSend):
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ '%s' is too large to send. Maximum size is %d MB", name, MaxFileSize/(1024*1024)))
+	case err != nil:
+		log.Printf("Error resending %s: %v", rel, err)
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ Failed to send '%s'.", name))
+	default:
+		log.Printf("User %d resent %s (uploaded: %v)", userID, rel, uploaded)
+	}
+}
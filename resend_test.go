@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestResendByCachedFileID(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+	writeUploadedFile(t, b, "cat.jpg", 42, "photos", "p1")
+
+	b.handleResendCommand(42, 42, " cat.jpg")
+
+	if len(api.sent) != 1 {
+		t.Fatalf("expected one message, got %+v", api.sent)
+	}
+	photo, ok := api.sent[0].(tgbotapi.PhotoConfig)
+	if !ok || photo.File != tgbotapi.FileID("p1") || photo.ChatID != 42 {
+		t.Errorf("expected the photo by file_id, got %+v", api.sent[0])
+	}
+}
+
+func TestResendUploadsWhenFileIDRejected(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{sendErr: func(call int, c tgbotapi.Chattable) error {
+		if call == 1 {
+			return &tgbotapi.Error{Code: 400, Message: "Bad Request: wrong file identifier/HTTP URL specified"}
+		}
+		return nil
+	}}
+	b.client = api
+	writeUploadedFile(t, b, "report.pdf", 42, "documents", "expired")
+
+	b.handleResendCommand(42, 42, " report.pdf")
+
+	if len(api.sent) != 1 {
+		t.Fatalf("expected one upload after the rejected file_id, got %+v", api.sent)
+	}
+	doc, ok := api.sent[0].(tgbotapi.DocumentConfig)
+	if !ok {
+		t.Fatalf("expected a document, got %+v", api.sent[0])
+	}
+	file, ok := doc.File.(tgbotapi.FileBytes)
+	if !ok || file.Name != "report.pdf" || string(file.Bytes) != "content" {
+		t.Errorf("expected the stored file to be uploaded, got %+v", doc.File)
+	}
+}
+
+func TestResendUploadsFilesWithoutFileID(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+	writeTestFile(t, b, "notes.txt", "hello")
+
+	b.handleResendCommand(42, 42, " notes.txt")
+	b.handleResendCommand(42, 42, " missing.txt")
+
+	if len(api.sent) != 2 {
+		t.Fatalf("expected an upload and a reply, got %+v", api.sent)
+	}
+	if doc, ok := api.sent[0].(tgbotapi.DocumentConfig); !ok || string(doc.File.(tgbotapi.FileBytes).Bytes) != "hello" {
+		t.Errorf("expected the file to be uploaded as a document, got %+v", api.sent[0])
+	}
+	if texts := api.texts(); len(texts) != 1 || !strings.Contains(texts[0], "'missing.txt' not found") {
+		t.Errorf("unexpected replies %q", texts)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// DefaultRetentionInterval is how often the janitor looks for expired files.
+const DefaultRetentionInterval = time.Hour
+
+// parseRetentionDays parses RETENTION_DAYS; empty or 0 keeps files forever.
+func parseRetentionDays(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	days, err := strconv.Atoi(s)
+	if err != nil || days < 0 {
+		return 0, fmt.Errorf("invalid RETENTION_DAYS %q: expected a number of days", s)
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
+
+// runJanitor deletes expired files (past RETENTION_DAYS or scheduled by
+// /expire), records the daily storage snapshot and checks DISK_WARN_PERCENT
+// every interval until ctx is done.
+func (b *Bot) runJanitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		b.logSweep(b.sweepExpired(time.Now()))
+		if err := b.recordStorageSnapshot(time.Now()); err != nil {
+			log.Printf("Failed to record storage snapshot: %v", err)
+		}
+		b.checkDiskSpace()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (b *Bot) logSweep(removed int, freed int64, err error) {
+	if err != nil {
+		log.Printf("Retention sweep failed after removing %d files: %v", removed, err)
+		return
+	}
+	if removed > 0 {
+		log.Printf("Retention sweep removed %d expired files (%s freed)", removed, formatSize(freed))
+	}
+}
+
+// sweepExpired deletes stored files last modified more than the retention
+// period before now, unless pinned, and those /expire scheduled for deletion
+// by now, along with their metadata. It returns how many files were removed
+// and their total size.
+func (b *Bot) sweepExpired(now time.Time) (int, int64, error) {
+	cutoff := now.Add(-b.retention)
+
+	removed, freed := 0, int64(0)
+	for _, root := range b.dataRoots() {
+		err := walkStoredFiles(root, func(rel, path string, info os.FileInfo) error {
+			m, metaErr := readMeta(b.storagePath, rel)
+			var detail string
+			switch {
+			case metaErr == nil && m.ExpiresAt != nil && !m.ExpiresAt.After(now):
+				detail = "scheduled for " + m.ExpiresAt.Format(time.RFC3339)
+			case b.retention > 0 && info.ModTime().Before(cutoff) && !(metaErr == nil && m.Pinned):
+				detail = "modified " + info.ModTime().Format(time.RFC3339)
+			default:
+				return nil
+			}
+			if err := os.Remove(path); err != nil {
+				log.Printf("Failed to remove expired file %s: %v", rel, err)
+				return nil
+			}
+
+			b.forgetFile(rel)
+			b.audit(AuditEntry{Action: "expire", Path: rel, Size: info.Size(), Detail: detail})
+			removed++
+			freed += info.Size()
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return removed, freed, fmt.Errorf("failed to walk storage directory: %w", err)
+		}
+	}
+	return removed, freed, nil
+}
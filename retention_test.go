@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSweepExpiredRemovesOnlyOldFiles(t *testing.T) {
+	b := newTestBot(t)
+	b.retention = 7 * 24 * time.Hour
+	now := time.Now()
+
+	ages := map[string]time.Duration{
+		"old.txt":        10 * 24 * time.Hour,
+		"sub/older.pdf":  30 * 24 * time.Hour,
+		"fresh.txt":      24 * time.Hour,
+		"sub/recent.pdf": 6 * 24 * time.Hour,
+	}
+	for rel, age := range ages {
+		writeTestFile(t, b, rel, "content")
+		writeMeta(b.storagePath, FileMeta{Path: rel, Owner: 42})
+		mtime := now.Add(-age)
+		if err := os.Chtimes(b.localPath(rel), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, freed, err := b.sweepExpired(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 || freed != int64(2*len("content")) {
+		t.Errorf("expected 2 files (%d bytes) removed, got %d (%d bytes)", 2*len("content"), removed, freed)
+	}
+
+	for rel, age := range ages {
+		_, statErr := os.Stat(b.localPath(rel))
+		_, metaErr := readMeta(b.storagePath, rel)
+		expired := age > b.retention
+		if expired && (!os.IsNotExist(statErr) || metaErr == nil) {
+			t.Errorf("expected %s and its metadata to be removed", rel)
+		}
+		if !expired && (statErr != nil || metaErr != nil) {
+			t.Errorf("expected %s to be kept", rel)
+		}
+	}
+}
+
+func TestParseRetentionDays(t *testing.T) {
+	if d, err := parseRetentionDays(""); err != nil || d != 0 {
+		t.Errorf("expected empty to disable retention, got %v (%v)", d, err)
+	}
+	if d, err := parseRetentionDays("3"); err != nil || d != 72*time.Hour {
+		t.Errorf("expected 3 days, got %v (%v)", d, err)
+	}
+	for _, bad := range []string{"-1", "two", "1.5"} {
+		if _, err := parseRetentionDays(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// pendingFileName is the hidden file inside the storage root that persists
+	// uploads awaiting admin review.
+	pendingFileName = ".pending.json"
+	// pendingDirName is the hidden directory holding their content, one folder
+	// per user.
+	pendingDirName = ".pending"
+)
+
+// PendingUpload is an upload from an untrusted user held back for review.
+type PendingUpload struct {
+	Owner      int64     `json:"owner"`          // the sender
+	Chat       int64     `json:"chat,omitempty"` // where it was sent, see chat
+	MediaType  string    `json:"media_type"`
+	Name       string    `json:"name"` // stored under this name once approved
+	MIME       string    `json:"mime,omitempty"`
+	FileID     string    `json:"file_id,omitempty"`
+	Path       string    `json:"path"` // relative to the storage root, inside .pending/
+	Size       int64     `json:"size"`
+	Protected  bool      `json:"protected,omitempty"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// chat returns the chat the upload was sent in. Uploads held before it was
+// recorded came from the owner's private chat.
+func (p PendingUpload) chat() int64 {
+	if p.Chat != 0 {
+		return p.Chat
+	}
+	return p.Owner
+}
+
+// PendingStore is a JSON-file store of pending uploads keyed by review token,
+// safe for concurrent use.
+type PendingStore struct {
+	path string
+
+	mu      sync.Mutex
+	uploads map[string]PendingUpload
+}
+
+// OpenPendingStore loads the pending uploads at path. A missing file yields an
+// empty store.
+func OpenPendingStore(path string) (*PendingStore, error) {
+	s := &PendingStore{path: path, uploads: make(map[string]PendingUpload)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pending uploads: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &s.uploads); err != nil {
+		return nil, fmt.Errorf("failed to parse pending uploads: %w", err)
+	}
+	return s, nil
+}
+
+// Add records a pending upload under token and persists the store.
+func (s *PendingStore) Add(token string, p PendingUpload) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.uploads[token] = p
+	if err := s.save(); err != nil {
+		delete(s.uploads, token)
+		return err
+	}
+	return nil
+}
+
+// Take removes and returns the upload under token, so that only one review
+// acts on it. ok is false if there is none.
+func (s *PendingStore) Take(token string) (p PendingUpload, ok bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok = s.uploads[token]
+	if !ok {
+		return p, false, nil
+	}
+	delete(s.uploads, token)
+	if err := s.save(); err != nil {
+		s.uploads[token] = p
+		return p, false, err
+	}
+	return p, true, nil
+}
+
+// Len returns the number of pending uploads.
+func (s *PendingStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.uploads)
+}
+
+// save writes the store atomically. Callers must hold s.mu.
+func (s *PendingStore) save() error {
+	data, err := json.MarshalIndent(s.uploads, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode pending uploads: %w", err)
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// isUserTrusted reports whether uploads of userID are stored directly. Without
+// TRUSTED_USERS everyone is; admins always are.
+func (b *Bot) isUserTrusted(userID int64) bool {
+	if len(b.trustedUsers) == 0 || b.pending == nil {
+		return true
+	}
+	return b.trustedUsers[userID] || b.isUserAdmin(userID)
+}
+
+// quarantineMedia downloads the file of a media message owner sent in chatID
+// into the pending area and records it for review under a new token.
+func (b *Bot) quarantineMedia(media mediaFile, chatID, owner int64) (string, PendingUpload, error) {
+	fileURL, err := b.client.GetFileDirectURL(media.FileID)
+	if err != nil {
+		return "", PendingUpload{}, classifyError(fmt.Errorf("failed to get file info: %w", err))
+	}
+	body, err := b.download(fileURL)
+	if err != nil {
+		return "", PendingUpload{}, newBotError(CategoryNetwork, err)
+	}
+	defer body.Close()
+
+	token := newToken()
+	name := b.organizedName(media.MediaType, media.fileName(time.Now()), time.Now())
+	p := PendingUpload{
+		Owner:      owner,
+		Chat:       chatID,
+		MediaType:  media.MediaType,
+		Name:       name,
+		MIME:       media.MIME,
+		FileID:     media.FileID,
+		Protected:  media.Protected,
+		Path:       path.Join(pendingDirName, fmt.Sprint(owner), token+"_"+path.Base(name)),
+		ReceivedAt: time.Now(),
+	}
+	if p.Size, err = b.writePending(p.Path, body); err != nil {
+		return "", PendingUpload{}, classifyError(err)
+	}
+	if p.Size < b.minFileSize {
+		b.removePending(p)
+		return "", PendingUpload{}, &tooSmallError{Size: p.Size}
+	}
+	if err := b.pending.Add(token, p); err != nil {
+		b.removePending(p)
+		return "", PendingUpload{}, err
+	}
+	log.Printf("Quarantined %s from user %d as %s", name, owner, p.Path)
+	return token, p, nil
+}
+
+// writePending writes r to rel inside the storage root, encrypting it when
+// enabled, and returns the plaintext size.
+func (b *Bot) writePending(rel string, r io.Reader) (int64, error) {
+	dest := filepath.Join(b.storagePath, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create pending directory: %w", err)
+	}
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create pending file: %w", err)
+	}
+
+	plain := &countingReader{r: r}
+	var src io.Reader = plain
+	if b.cipher != nil {
+		encrypted := b.cipher.encryptReader(plain)
+		defer encrypted.Close()
+		src = encrypted
+	}
+	_, err = io.Copy(f, src)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dest)
+		return 0, fmt.Errorf("failed to write pending file: %w", err)
+	}
+	return plain.n, nil
+}
+
+// removePending deletes the content of a pending upload.
+func (b *Bot) removePending(p PendingUpload) {
+	if err := os.Remove(filepath.Join(b.storagePath, filepath.FromSlash(p.Path))); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to remove pending file %s: %v", p.Path, err)
+	}
+}
+
+// notifyReviewers asks every admin to approve or reject a pending upload.
+func (b *Bot) notifyReviewers(token string, p PendingUpload) {
+	admins := b.adminIDs()
+	if len(admins) == 0 {
+		log.Printf("Warning: no admin can review %s from user %d", p.Name, p.Owner)
+		return
+	}
+
+	text := fmt.Sprintf("🕵️ Upload awaiting review\n\n👤 User: %d", p.Owner)
+	if p.chat() != p.Owner {
+		text += fmt.Sprintf("\n💬 Chat: %d", p.chat())
+	}
+	text += fmt.Sprintf("\n📄 %s (%s, %s)", p.Name, p.MediaType, formatSize(p.Size))
+	markup := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Approve", "review:approve:"+token),
+		tgbotapi.NewInlineKeyboardButtonData("Reject", "review:reject:"+token),
+	))
+	for _, adminID := range admins {
+		msg := tgbotapi.NewMessage(adminID, text)
+		msg.ReplyMarkup = markup
+		if _, err := b.send(msg); err != nil {
+			log.Printf("Failed to ask admin %d to review %s: %v", adminID, p.Name, err)
+		}
+	}
+}
+
+// handleUntrustedMedia quarantines a file an untrusted user sent in chatID and
+// asks the admins to review it.
+func (b *Bot) handleUntrustedMedia(media mediaFile, chatID, userID int64) {
+	token, p, err := b.quarantineMedia(media, chatID, userID)
+	var tooSmall *tooSmallError
+	if errors.As(err, &tooSmall) {
+		log.Printf("Discarded %s from user %d: %v", media.Label, userID, err)
+		b.sendTextMessage(chatID, tooSmallMessage(media.Label, tooSmall, b.minFileSize))
+		return
+	}
+	if err != nil {
+		log.Printf("Error quarantining %s (%s): %v", media.Label, errorCategory(err), err)
+		b.sendTextMessage(chatID, b.failureReply(userID, err, fmt.Sprintf("❌ Failed to receive the %s.", media.Label)))
+		return
+	}
+
+	b.recentUploads.Mark(userID, media.FileUniqueID)
+	b.audit(AuditEntry{User: userID, Action: "quarantine", Path: p.Path, Size: p.Size})
+	b.notifyReviewers(token, p)
+	b.sendTextMessage(chatID, fmt.Sprintf("🕵️ Received '%s'. It will be stored once an admin approves it.", p.Name))
+}
+
+// handleReviewCallback approves or rejects a pending upload and returns the
+// text replacing the review message.
+func (b *Bot) handleReviewCallback(adminID int64, action, token string) string {
+	if !b.isUserAdmin(adminID) || b.pending == nil {
+		return "🚫 Access denied. Admin privileges required."
+	}
+
+	p, ok, err := b.pending.Take(token)
+	if err != nil {
+		log.Printf("Error taking pending upload %s: %v", token, err)
+		return "❌ Failed to update the pending uploads."
+	}
+	if !ok {
+		return "⚠️ This upload has already been reviewed."
+	}
+
+	if action != "approve" {
+		b.removePending(p)
+		log.Printf("Admin %d rejected %s from user %d", adminID, p.Name, p.Owner)
+		b.audit(AuditEntry{User: adminID, Action: "reject", Path: p.Path, Size: p.Size, Detail: fmt.Sprintf("upload by %d", p.Owner)})
+		b.sendTextMessage(p.chat(), fmt.Sprintf("❌ Your upload '%s' was not approved and has been deleted.", p.Name))
+		return fmt.Sprintf("❌ Rejected '%s' from user %d.", p.Name, p.Owner)
+	}
+
+	saved, err := b.approvePending(p)
+	if err != nil {
+		log.Printf("Error storing approved %s: %v", p.Path, err)
+		if err := b.pending.Add(token, p); err != nil {
+			log.Printf("Failed to keep %s pending: %v", p.Path, err)
+		}
+		return b.failureReply(p.Owner, err, fmt.Sprintf("❌ Failed to store '%s'. It is still pending.", p.Name))
+	}
+
+	log.Printf("Admin %d approved %s from user %d", adminID, saved.Path, p.Owner)
+	b.audit(AuditEntry{User: adminID, Action: "approve", Path: saved.Path, Size: saved.Size, Detail: fmt.Sprintf("upload by %d", p.Owner)})
+	b.replySaved(p.chat(), p.Owner, saved)
+	return fmt.Sprintf("✅ Approved '%s' from user %d.", b.displayName(p.Owner, saved.Path), p.Owner)
+}
+
+// approvePending moves a pending upload into the owner's storage.
+func (b *Bot) approvePending(p PendingUpload) (FileMeta, error) {
+	f, err := b.openPath(filepath.Join(b.storagePath, filepath.FromSlash(p.Path)))
+	if err != nil {
+		return FileMeta{}, err
+	}
+	saved, err := b.saveFileChecked(f, p.MediaType, p.Name, p.MIME, p.chat(), p.Owner, func(m *FileMeta) error {
+		m.FileID, m.MediaType, m.Protected = p.FileID, p.MediaType, p.Protected
+		return nil
+	})
+	f.Close()
+	if err != nil {
+		return FileMeta{}, err
+	}
+
+	b.removePending(p)
+	return saved, nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// newReviewTestBot returns a bot trusting user 1, with admin 9 reviewing the
+// uploads of everyone else, downloading from a server returning "payload".
+func newReviewTestBot(t *testing.T) (*Bot, *mockTelegramAPI) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("payload"))
+	}))
+	t.Cleanup(srv.Close)
+
+	b := newDownloadTestBot(t, 5*time.Second)
+	api := &mockTelegramAPI{fileURL: srv.URL}
+	b.client = api
+	b.adminUsers[9] = true
+	b.trustedUsers = map[int64]bool{1: true}
+	pending, err := OpenPendingStore(filepath.Join(b.storagePath, pendingFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.pending = pending
+	return b, api
+}
+
+// reviewCallback returns the callback data of the review button labelled text.
+func reviewCallback(t *testing.T, api *mockTelegramAPI, text string) string {
+	t.Helper()
+	for _, c := range api.sent {
+		msg, ok := c.(tgbotapi.MessageConfig)
+		if !ok || msg.ChatID != 9 {
+			continue
+		}
+		for _, button := range msg.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup).InlineKeyboard[0] {
+			if button.Text == text {
+				return *button.CallbackData
+			}
+		}
+	}
+	t.Fatalf("no %s button sent to the admin", text)
+	return ""
+}
+
+func pressButton(b *Bot, from int64, data string) {
+	b.handleCallbackQuery(&tgbotapi.CallbackQuery{
+		ID:      "cb",
+		From:    &tgbotapi.User{ID: from},
+		Message: &tgbotapi.Message{MessageID: 5, Chat: &tgbotapi.Chat{ID: from}},
+		Data:    data,
+	})
+}
+
+// lastEdit returns the text of the last edited message.
+func lastEdit(api *mockTelegramAPI) string {
+	for i := len(api.sent) - 1; i >= 0; i-- {
+		if edit, ok := api.sent[i].(tgbotapi.EditMessageTextConfig); ok {
+			return edit.Text
+		}
+	}
+	return ""
+}
+
+func TestReviewApproveStoresUpload(t *testing.T) {
+	b, api := newReviewTestBot(t)
+
+	b.handleMedia(*messageMedia(&tgbotapi.Message{Document: &tgbotapi.Document{FileID: "d1", FileName: "report.pdf", FileSize: 7}}), 42, 42)
+
+	if texts := api.texts(); len(texts) != 2 || !strings.Contains(texts[0], "Upload awaiting review") ||
+		!strings.Contains(texts[0], "User: 42") || texts[1] != "🕵️ Received 'report.pdf'. It will be stored once an admin approves it." {
+		t.Fatalf("unexpected messages %q", texts)
+	}
+	if _, err := os.Stat(b.localPath("report.pdf")); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing in storage before review, got %v", err)
+	}
+
+	// Pending uploads survive a restart
+	reopened, err := OpenPendingStore(filepath.Join(b.storagePath, pendingFileName))
+	if err != nil || reopened.Len() != 1 {
+		t.Fatalf("expected one persisted pending upload, got %d (%v)", reopened.Len(), err)
+	}
+	b.pending = reopened
+
+	approve := reviewCallback(t, api, "Approve")
+	pressButton(b, 42, approve) // not an admin
+	if got := lastEdit(api); !strings.Contains(got, "Admin privileges required") || b.pending.Len() != 1 {
+		t.Fatalf("expected a non-admin to be refused, got %q", got)
+	}
+
+	pressButton(b, 9, approve)
+	if got := lastEdit(api); got != "✅ Approved 'report.pdf' from user 42." {
+		t.Errorf("unexpected review result %q", got)
+	}
+	data, err := os.ReadFile(b.localPath("report.pdf"))
+	if err != nil || string(data) != "payload" {
+		t.Fatalf("expected the approved file in storage, got %q (%v)", data, err)
+	}
+	meta, err := readMeta(b.storagePath, "report.pdf")
+	if err != nil || meta.Owner != 42 || meta.FileID != "d1" {
+		t.Errorf("unexpected metadata %+v (%v)", meta, err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(b.storagePath, pendingDirName, "42")); len(entries) != 0 {
+		t.Errorf("expected the pending copy to be removed, got %v", entries)
+	}
+	if texts := api.texts(); texts[len(texts)-1] != "✅ Saved 'report.pdf' (7 B)" {
+		t.Errorf("expected the uploader to be told, got %q", texts)
+	}
+
+	pressButton(b, 9, approve)
+	if got := lastEdit(api); got != "⚠️ This upload has already been reviewed." {
+		t.Errorf("unexpected second review result %q", got)
+	}
+}
+
+func TestReviewRejectDeletesUpload(t *testing.T) {
+	b, api := newReviewTestBot(t)
+
+	b.handleMedia(*messageMedia(&tgbotapi.Message{Photo: []tgbotapi.PhotoSize{{FileID: "p1", FileSize: 7}}}), 42, 42)
+	pressButton(b, 9, reviewCallback(t, api, "Reject"))
+
+	if got := lastEdit(api); !strings.HasPrefix(got, "❌ Rejected 'photo_") {
+		t.Errorf("unexpected review result %q", got)
+	}
+	if b.pending.Len() != 0 {
+		t.Errorf("expected no pending uploads, got %d", b.pending.Len())
+	}
+	if entries, _ := os.ReadDir(filepath.Join(b.storagePath, pendingDirName, "42")); len(entries) != 0 {
+		t.Errorf("expected the pending copy to be deleted, got %v", entries)
+	}
+	if files, _ := listStoredFilesRecursive(b.storagePath, b.dataRoots()); len(files) != 0 {
+		t.Errorf("expected nothing stored, got %+v", files)
+	}
+	if texts := api.texts(); !strings.Contains(texts[len(texts)-1], "was not approved and has been deleted") {
+		t.Errorf("expected the uploader to be told, got %q", texts)
+	}
+}
+
+func TestTrustedUploadsSkipReview(t *testing.T) {
+	b, api := newReviewTestBot(t)
+
+	b.handleMedia(*messageMedia(&tgbotapi.Message{Document: &tgbotapi.Document{FileID: "d1", FileName: "a.txt", FileSize: 7}}), 1, 1)
+	b.handleMedia(*messageMedia(&tgbotapi.Message{Document: &tgbotapi.Document{FileID: "d2", FileName: "b.txt", FileSize: 7}}), 9, 9)
+
+	if b.pending.Len() != 0 {
+		t.Errorf("expected no pending uploads, got %d", b.pending.Len())
+	}
+	if texts := api.texts(); len(texts) != 2 || texts[0] != "✅ Saved 'a.txt' (7 B)" || texts[1] != "✅ Saved 'b.txt' (7 B)" {
+		t.Errorf("unexpected replies %q", texts)
+	}
+}
+
+func TestReviewTrustsTheSenderInGroups(t *testing.T) {
+	b, api := newReviewTestBot(t)
+
+	// A trusted member's upload is stored directly, whatever the group
+	b.handleMedia(*messageMedia(&tgbotapi.Message{Document: &tgbotapi.Document{FileID: "d1", FileName: "a.txt", FileSize: 7}}), -100, 1)
+	if b.pending.Len() != 0 || !exists(b.localPath("a.txt")) {
+		t.Fatalf("expected the trusted member's upload to be stored, %d pending", b.pending.Len())
+	}
+
+	b.handleMedia(*messageMedia(&tgbotapi.Message{Document: &tgbotapi.Document{FileID: "d2", FileName: "b.txt", FileSize: 7}}), -100, 42)
+	if texts := api.texts(); !strings.Contains(texts[1], "User: 42") || !strings.Contains(texts[1], "Chat: -100") {
+		t.Fatalf("expected the review request to name sender and group, got %q", texts)
+	}
+	pressButton(b, 9, reviewCallback(t, api, "Approve"))
+	meta, err := readMeta(b.storagePath, "b.txt")
+	if err != nil || meta.Owner != 42 {
+		t.Errorf("expected the approved file to belong to the sender, got %+v (%v)", meta, err)
+	}
+	var confirmed bool
+	for _, c := range api.sent {
+		if msg, ok := c.(tgbotapi.MessageConfig); ok && msg.Text == "✅ Saved 'b.txt' (7 B)" {
+			confirmed = msg.ChatID == -100
+		}
+	}
+	if !confirmed {
+		t.Error("expected the confirmation in the group")
+	}
+}
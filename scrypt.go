@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+)
+
+// scryptKey derives a keyLen-byte key from password and salt with scrypt
+// (RFC 7914). N is the CPU/memory cost, a power of two; memory use is about
+// 128*N*r bytes. golang.org/x/crypto/scrypt is not a dependency of this
+// module, so the algorithm is implemented here on top of crypto/pbkdf2.
+func scryptKey(password string, salt []byte, N, r, p, keyLen int) ([]byte, error) {
+	if N <= 1 || N&(N-1) != 0 {
+		return nil, errors.New("scrypt: N must be a power of two greater than 1")
+	}
+	if r <= 0 || p <= 0 || uint64(r)*uint64(p) >= 1<<30 || r > (1<<31-1)/128/p || N > (1<<31-1)/128/r {
+		return nil, errors.New("scrypt: parameters are too large")
+	}
+
+	b, err := pbkdf2.Key(sha256.New, password, salt, 1, p*128*r)
+	if err != nil {
+		return nil, err
+	}
+	xy := make([]uint32, 64*r)
+	v := make([]uint32, 32*N*r)
+	for i := 0; i < p; i++ {
+		scryptROMix(b[i*128*r:], r, N, v, xy)
+	}
+	return pbkdf2.Key(sha256.New, password, b, 1, keyLen)
+}
+
+// scryptROMix mixes the 128*r bytes of b in place.
+func scryptROMix(b []byte, r, N int, v, xy []uint32) {
+	var tmp [16]uint32
+	R := 32 * r
+	x, y := xy[:R], xy[R:]
+
+	for i := range x {
+		x[i] = binary.LittleEndian.Uint32(b[4*i:])
+	}
+	for i := 0; i < N; i += 2 {
+		copy(v[i*R:], x)
+		scryptBlockMix(&tmp, x, y, r)
+		copy(v[(i+1)*R:], y)
+		scryptBlockMix(&tmp, y, x, r)
+	}
+	for i := 0; i < N; i += 2 {
+		j := int(scryptInteger(x, r) & uint64(N-1))
+		for k := range x {
+			x[k] ^= v[j*R+k]
+		}
+		scryptBlockMix(&tmp, x, y, r)
+
+		j = int(scryptInteger(y, r) & uint64(N-1))
+		for k := range y {
+			y[k] ^= v[j*R+k]
+		}
+		scryptBlockMix(&tmp, y, x, r)
+	}
+	for i, w := range x {
+		binary.LittleEndian.PutUint32(b[4*i:], w)
+	}
+}
+
+// scryptBlockMix is BlockMix with Salsa20/8: even output blocks go to the
+// first half of out, odd ones to the second.
+func scryptBlockMix(tmp *[16]uint32, in, out []uint32, r int) {
+	copy(tmp[:], in[(2*r-1)*16:])
+	for i := 0; i < 2*r; i += 2 {
+		salsa208XOR(tmp, in[i*16:], out[i*8:])
+		salsa208XOR(tmp, in[i*16+16:], out[i*8+r*16:])
+	}
+}
+
+// scryptInteger is Integerify: the first 64 bits of the last block.
+func scryptInteger(b []uint32, r int) uint64 {
+	j := (2*r - 1) * 16
+	return uint64(b[j]) | uint64(b[j+1])<<32
+}
+
+// salsaRounds are the quarter-rounds of one Salsa20 double round: four on the
+// columns, then four on the rows.
+var salsaRounds = [8][4]int{
+	{0, 4, 8, 12}, {5, 9, 13, 1}, {10, 14, 2, 6}, {15, 3, 7, 11},
+	{0, 1, 2, 3}, {5, 6, 7, 4}, {10, 11, 8, 9}, {15, 12, 13, 14},
+}
+
+// salsa208XOR sets tmp and out[:16] to Salsa20/8 of tmp XOR in.
+func salsa208XOR(tmp *[16]uint32, in, out []uint32) {
+	var w [16]uint32
+	for i := range w {
+		w[i] = tmp[i] ^ in[i]
+	}
+	x := w
+	for range 4 {
+		for _, q := range salsaRounds {
+			a, b, c, d := q[0], q[1], q[2], q[3]
+			x[b] ^= bits.RotateLeft32(x[a]+x[d], 7)
+			x[c] ^= bits.RotateLeft32(x[b]+x[a], 9)
+			x[d] ^= bits.RotateLeft32(x[c]+x[b], 13)
+			x[a] ^= bits.RotateLeft32(x[d]+x[c], 18)
+		}
+	}
+	for i := range x {
+		tmp[i] = x[i] + w[i]
+		out[i] = tmp[i]
+	}
+}
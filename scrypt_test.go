@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// RFC 7914 section 12 test vectors
+func TestScryptKeyRFCVectors(t *testing.T) {
+	vectors := []struct {
+		password, salt string
+		N, r, p        int
+		want           string
+	}{
+		{"", "", 16, 1, 1, "77d6576238657b203b19ca42c18a0497f16b4844e3074ae8dfdffa3fede21442fcd0069ded0948f8326a753a0fc81f17e8d3e0fb2e0d3628cf35e20c38d18906"},
+		{"password", "NaCl", 1024, 8, 16, "fdbabe1c9d3472007856e7190d01e9fe7c6ad7cbc8237830e77376634b3731622eaf30d92e22a3886ff109279d9830dac727afb94a83ee6d8360cbdfa2cc0640"},
+	}
+	for _, v := range vectors {
+		key, err := scryptKey(v.password, []byte(v.salt), v.N, v.r, v.p, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(key); got != v.want {
+			t.Errorf("scrypt(%q, %q, %d, %d, %d) = %s, want %s", v.password, v.salt, v.N, v.r, v.p, got, v.want)
+		}
+	}
+}
+
+func TestScryptKeyRejectsBadParameters(t *testing.T) {
+	for _, p := range [][3]int{{0, 8, 1}, {1000, 8, 1}, {1024, 0, 1}, {1024, 8, 0}} {
+		if _, err := scryptKey("pw", nil, p[0], p[1], p[2], 32); err == nil {
+			t.Errorf("expected N=%d r=%d p=%d to be rejected", p[0], p[1], p[2])
+		}
+	}
+}
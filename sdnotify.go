@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// sdNotifier sends systemd service notifications (sd_notify protocol) to the
+// socket named by NOTIFY_SOCKET. A nil notifier is a no-op.
+type sdNotifier struct {
+	socket string
+}
+
+// newSDNotifier returns a notifier when running under systemd with Type=notify,
+// or nil otherwise.
+func newSDNotifier() *sdNotifier {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	return &sdNotifier{socket: socket}
+}
+
+// Notify sends a state such as "READY=1" or "WATCHDOG=1".
+func (n *sdNotifier) Notify(state string) error {
+	if n == nil {
+		return nil
+	}
+
+	addr := n.socket
+	if addr[0] == '@' {
+		// Abstract namespace socket
+		addr = "\x00" + addr[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("failed to connect to notify socket: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	return nil
+}
+
+// watchdogInterval returns the watchdog timeout requested via WATCHDOG_USEC,
+// honouring WATCHDOG_PID when set. ok is false when the watchdog is disabled.
+func watchdogInterval() (time.Duration, bool) {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, false
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, false
+	}
+	return time.Duration(usec) * time.Microsecond, true
+}
+
+// runWatchdog pings the systemd watchdog at half the timeout until ctx is done.
+func (n *sdNotifier) runWatchdog(ctx context.Context, timeout time.Duration) {
+	if n == nil {
+		return
+	}
+
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := n.Notify("WATCHDOG=1"); err != nil {
+				log.Printf("Watchdog ping failed: %v", err)
+			}
+		}
+	}
+}
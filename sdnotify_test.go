@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func listenNotifySocket(t *testing.T) (*net.UnixConn, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unix datagram sockets unavailable: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, path
+}
+
+func readNotification(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 256)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("expected a notification: %v", err)
+	}
+	return string(buf[:n])
+}
+
+func TestSDNotifierWritesToSocket(t *testing.T) {
+	conn, path := listenNotifySocket(t)
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	n := newSDNotifier()
+	if err := n.Notify("READY=1"); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if got := readNotification(t, conn); got != "READY=1" {
+		t.Errorf("expected READY=1, got %q", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		n.runWatchdog(ctx, 40*time.Millisecond)
+		close(done)
+	}()
+
+	if got := readNotification(t, conn); got != "WATCHDOG=1" {
+		t.Errorf("expected WATCHDOG=1, got %q", got)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the watchdog to stop with its context")
+	}
+}
+
+func TestSDNotifierNoopOutsideSystemd(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	t.Setenv("WATCHDOG_USEC", "")
+
+	n := newSDNotifier()
+	if n != nil {
+		t.Fatal("expected no notifier without NOTIFY_SOCKET")
+	}
+	if err := n.Notify("READY=1"); err != nil {
+		t.Errorf("expected a nil notifier to be a no-op, got %v", err)
+	}
+	if _, ok := watchdogInterval(); ok {
+		t.Error("expected the watchdog to be disabled")
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	t.Setenv("WATCHDOG_PID", "")
+	if d, ok := watchdogInterval(); !ok || d != 30*time.Second {
+		t.Errorf("expected 30s, got %v (%v)", d, ok)
+	}
+
+	t.Setenv("WATCHDOG_PID", "1")
+	if _, ok := watchdogInterval(); ok {
+		t.Error("expected a watchdog meant for another process to be ignored")
+	}
+}
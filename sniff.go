@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// sniffLen is how much content http.DetectContentType considers.
+const sniffLen = 512
+
+// sniffedExtensions lists the content types whose extension is corrected by
+// CORRECT_EXTENSIONS, with the extensions accepted for each (the first is used
+// when renaming). Zip covers the many zip-based formats, which keep their own.
+var sniffedExtensions = map[string][]string{
+	"image/png":          {".png"},
+	"image/jpeg":         {".jpg", ".jpeg", ".jpe", ".jfif"},
+	"image/gif":          {".gif"},
+	"image/webp":         {".webp"},
+	"application/pdf":    {".pdf"},
+	"application/x-gzip": {".gz", ".tgz", ".tgs"},
+	"application/zip": {".zip", ".docx", ".xlsx", ".pptx", ".odt", ".ods", ".odp",
+		".epub", ".jar", ".apk", ".ipa", ".xpi", ".kmz", ".cbz", ".3mf", ".whl", ".nupkg", ".vsix"},
+}
+
+// sniffExtension reads the start of r and returns a reader yielding the full
+// content again, along with the sniffed content type and the extension name
+// should have ("" when it is fine as is or the type is not well known).
+func sniffExtension(r io.Reader, name string) (io.Reader, string, string) {
+	br := bufio.NewReaderSize(r, sniffLen)
+	head, _ := br.Peek(sniffLen)
+
+	contentType := http.DetectContentType(head)
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+
+	accepted, ok := sniffedExtensions[contentType]
+	if !ok || containsType(accepted, strings.ToLower(path.Ext(name))) {
+		return br, contentType, ""
+	}
+	return br, contentType, accepted[0]
+}
+
+// withExtension replaces the extension of name (or appends one if it has none).
+func withExtension(name, ext string) string {
+	old := path.Ext(name)
+	if strings.Contains(old, " ") {
+		// "v1.2 notes": the dot is part of the name, not an extension
+		old = ""
+	}
+	return strings.TrimSuffix(name, old) + ext
+}
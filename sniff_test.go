@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+var (
+	pngFixture = append([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), bytes.Repeat([]byte{0}, 600)...)
+	pdfFixture = []byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n1 0 obj\n<< /Type /Catalog >>\nendobj\n")
+	zipFixture = append([]byte("PK\x03\x04\x14\x00\x00\x00\x08\x00"), bytes.Repeat([]byte("z"), 100)...)
+)
+
+func TestSniffExtension(t *testing.T) {
+	cases := []struct {
+		name    string
+		content []byte
+		want    string
+	}{
+		{"image.bin", pngFixture, ".png"},
+		{"image", pngFixture, ".png"},
+		{"image.PNG", pngFixture, ""},
+		{"scan.dat", pdfFixture, ".pdf"},
+		{"archive.bin", zipFixture, ".zip"},
+		{"report.docx", zipFixture, ""}, // zip-based formats keep their extension
+		{"notes.csv", []byte("a,b,c\n1,2,3\n"), ""},
+		{"blob.dat", []byte{0x00, 0x01, 0x02, 0xfe}, ""},
+	}
+	for _, tc := range cases {
+		r, _, ext := sniffExtension(bytes.NewReader(tc.content), tc.name)
+		if ext != tc.want {
+			t.Errorf("%s: expected %q, got %q", tc.name, tc.want, ext)
+		}
+		if got, _ := io.ReadAll(r); !bytes.Equal(got, tc.content) {
+			t.Errorf("%s: sniffing must not consume content", tc.name)
+		}
+	}
+}
+
+func TestWithExtension(t *testing.T) {
+	cases := map[string]string{"image.bin": "image.png", "image": "image.png", "v1.2 notes": "v1.2 notes.png"}
+	for in, want := range cases {
+		if got := withExtension(in, ".png"); got != want {
+			t.Errorf("withExtension(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSaveFileCorrectsExtension(t *testing.T) {
+	b := newTestBot(t)
+	b.correctExtensions = true
+
+	saved, err := b.saveFile(bytes.NewReader(pngFixture), "documents", "picture.bin", "application/octet-stream", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Path != "picture.png" || saved.OriginalName != "picture.bin" || saved.MIME != "image/png" {
+		t.Errorf("unexpected metadata %+v", saved)
+	}
+
+	// Unknown content is stored as declared
+	saved, err = b.saveFile(strings.NewReader("just text"), "documents", "readme.bin", "application/octet-stream", 42)
+	if err != nil || saved.Path != "readme.bin" || saved.OriginalName != "" {
+		t.Errorf("expected ambiguous content to keep its name, got %+v (%v)", saved, err)
+	}
+}
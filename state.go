@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// stateFileName is the hidden file inside the storage root that persists bot-wide state.
+const stateFileName = ".state.json"
+
+// BotState is bot-wide state that survives restarts.
+type BotState struct {
+	Paused bool `json:"paused"`
+	// Admin changes made with /admin promote and demote, applied on top of ADMIN_USERS
+	PromotedAdmins []int64 `json:"promoted_admins,omitempty"`
+	DemotedAdmins  []int64 `json:"demoted_admins,omitempty"`
+	// Unredeemed invites by token, and the users who joined with one
+	Invites      map[string]Invite `json:"invites,omitempty"`
+	InvitedUsers []int64           `json:"invited_users,omitempty"`
+}
+
+// loadState reads the state file at path. A missing file yields the zero state.
+func loadState(path string) (BotState, error) {
+	var s BotState
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return s, fmt.Errorf("failed to read state: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("failed to parse state: %w", err)
+	}
+	return s, nil
+}
+
+// saveState writes the state file at path atomically.
+func saveState(path string, s BotState) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+	return writeFileAtomic(path, data)
+}
+
+// writeFileAtomic replaces path with data via a temporary file, so readers never
+// observe a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// setPaused updates and persists whether uploads are accepted.
+func (b *Bot) setPaused(paused bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := b.state
+	state.Paused = paused
+	if err := saveState(b.statePath(), state); err != nil {
+		return err
+	}
+	b.state = state
+	return nil
+}
+
+// isPaused reports whether uploads are currently rejected.
+func (b *Bot) isPaused() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state.Paused
+}
+
+func (b *Bot) statePath() string {
+	return filepath.Join(b.storagePath, stateFileName)
+}
+
+func (b *Bot) handleAdminPause(chatID int64, paused bool) {
+	if b.isPaused() == paused {
+		if paused {
+			b.sendTextMessage(chatID, "ℹ️ Uploads are already paused")
+		} else {
+			b.sendTextMessage(chatID, "ℹ️ Uploads are not paused")
+		}
+		return
+	}
+
+	if err := b.setPaused(paused); err != nil {
+		log.Printf("Error saving paused state: %v", err)
+		b.sendTextMessage(chatID, "❌ Failed to save the paused state.")
+		return
+	}
+
+	if paused {
+		b.sendTextMessage(chatID, "⏸ Uploads paused. Files sent now will be rejected until /admin resume.")
+		log.Printf("Admin %d paused uploads", chatID)
+	} else {
+		b.sendTextMessage(chatID, "▶️ Uploads resumed.")
+		log.Printf("Admin %d resumed uploads", chatID)
+	}
+}
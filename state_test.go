@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestPauseRejectsUploadsUntilResume(t *testing.T) {
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("report-bytes"))
+	}))
+	defer files.Close()
+
+	b := newDownloadTestBot(t, time.Second)
+	api := &mockTelegramAPI{fileURL: files.URL}
+	b.client = api
+	b.adminUsers[1] = true
+
+	upload := &tgbotapi.Message{
+		From:     &tgbotapi.User{ID: 42},
+		Chat:     &tgbotapi.Chat{ID: 42},
+		Document: &tgbotapi.Document{FileID: "file", FileName: "report.pdf"},
+	}
+
+	b.handleMessage(&tgbotapi.Message{From: &tgbotapi.User{ID: 1}, Chat: &tgbotapi.Chat{ID: 1}, Text: "/admin pause"})
+	b.handleMessage(upload)
+
+	texts := api.texts()
+	if !strings.Contains(texts[len(texts)-1], "temporarily unavailable") {
+		t.Fatalf("expected upload to be rejected while paused, got %q", texts[len(texts)-1])
+	}
+
+	// The paused state survives a restart
+	state, err := loadState(b.statePath())
+	if err != nil || !state.Paused {
+		t.Fatalf("expected paused state to be persisted, got %+v (%v)", state, err)
+	}
+
+	// Commands still work while paused
+	b.handleMessage(&tgbotapi.Message{From: &tgbotapi.User{ID: 42}, Chat: &tgbotapi.Chat{ID: 42}, Text: "/list"})
+	texts = api.texts()
+	if strings.Contains(texts[len(texts)-1], "temporarily unavailable") {
+		t.Error("expected commands to keep working while paused")
+	}
+
+	b.handleMessage(&tgbotapi.Message{From: &tgbotapi.User{ID: 1}, Chat: &tgbotapi.Chat{ID: 1}, Text: "/admin resume"})
+	if b.isPaused() {
+		t.Fatal("expected uploads to be resumed")
+	}
+	if state, _ := loadState(b.statePath()); state.Paused {
+		t.Error("expected resumed state to be persisted")
+	}
+
+	b.handleMessage(upload)
+	if _, err := os.Stat(b.localPath("report.pdf")); err != nil {
+		t.Errorf("expected the upload to be stored after resume: %v", err)
+	}
+}
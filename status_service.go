@@ -1,14 +1,20 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
+	"os"
 	"sync"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
+// statusFileName is the hidden file inside the storage root that persists the
+// Synology task statuses last seen.
+const statusFileName = ".synology.json"
+
 // BotSender abstracts the Telegram bot API Send method for testability.
 type BotSender interface {
 	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
@@ -26,6 +32,12 @@ type StatusService struct {
 	botAPI       BotSender
 	tickInterval time.Duration
 	stopCh       chan struct{}
+
+	// statePath persists previousStatuses across restarts when set
+	statePath string
+	// baselined is set once previousStatuses reflects a real task list, so
+	// tasks first seen already finished count as completed
+	baselined bool
 }
 
 // NewStatusService creates a new status service.
@@ -40,6 +52,34 @@ func NewStatusService(synology SynologyClient, adminUsers map[int64]bool, botAPI
 	}
 }
 
+// PersistStatuses loads the task statuses last seen from path and saves them
+// there after every check, so a restart does not re-notify completed tasks.
+func (s *StatusService) PersistStatuses(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.statePath = path
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read task statuses: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.previousStatuses); err != nil {
+		return fmt.Errorf("failed to parse task statuses: %w", err)
+	}
+	s.baselined = true
+	return nil
+}
+
+// SetAdmins replaces the set of users notified about status changes.
+func (s *StatusService) SetAdmins(adminUsers map[int64]bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.adminUsers = adminUsers
+}
+
 // Start begins the status monitoring loop.
 // The ticker always runs at the configured interval and never stops.
 func (s *StatusService) Start() {
@@ -79,16 +119,54 @@ func (s *StatusService) checkStatus() {
 	s.tasks = tasks
 	s.lastChecked = time.Now()
 
+	completed := make(map[string]bool)
+	for _, task := range completedTasks(s.previousStatuses, s.baselined, tasks) {
+		completed[task.ID] = true
+		s.notifyCompleted(task)
+	}
+
+	statuses := make(map[string]string, len(tasks))
 	for _, task := range tasks {
-		if prevStatus, exists := s.previousStatuses[task.ID]; exists {
-			if prevStatus != task.Status {
-				s.notifyStatusChange(task, prevStatus)
-			}
+		if prevStatus, exists := s.previousStatuses[task.ID]; exists && prevStatus != task.Status && !completed[task.ID] {
+			s.notifyStatusChange(task, prevStatus)
+		}
+		statuses[task.ID] = task.Status
+	}
+	s.previousStatuses = statuses
+	s.baselined = true
+
+	if s.statePath != "" {
+		if err := s.saveStatuses(); err != nil {
+			log.Printf("Failed to save task statuses: %v", err)
 		}
-		s.previousStatuses[task.ID] = task.Status
 	}
 }
 
+// completedTasks returns the tasks that finished since the statuses in previous
+// were recorded. Unknown tasks already finished count only once a baseline exists.
+func completedTasks(previous map[string]string, baselined bool, tasks []Task) []Task {
+	var completed []Task
+	for _, task := range tasks {
+		if task.Status != "finished" {
+			continue
+		}
+		prevStatus, exists := previous[task.ID]
+		if (exists && prevStatus != "finished") || (!exists && baselined) {
+			completed = append(completed, task)
+		}
+	}
+	return completed
+}
+
+// saveStatuses persists previousStatuses. Must be called with s.mu held.
+func (s *StatusService) saveStatuses() error {
+	data, err := json.MarshalIndent(s.previousStatuses, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode task statuses: %w", err)
+	}
+	return writeFileAtomic(s.statePath, data)
+}
+
 // GetStatus returns the current cached status information.
 func (s *StatusService) GetStatus() ([]Task, time.Time) {
 	s.mu.RLock()
@@ -140,21 +218,30 @@ func (s *StatusService) FormatStatusMessage() string {
 	return result
 }
 
+// notifyCompleted tells admin users that a task finished downloading.
+// Must be called with s.mu held.
+func (s *StatusService) notifyCompleted(task Task) {
+	log.Printf("Task completed: %s", task.Title)
+	s.notifyAdmins(fmt.Sprintf("✅ Download complete:\n\n📦 %s\n💾 %s", task.Title, formatSize(task.Size)))
+}
+
+// notifyAdmins sends message to every admin user. Must be called with s.mu held.
+func (s *StatusService) notifyAdmins(message string) {
+	if s.botAPI == nil {
+		return
+	}
+	for userID := range s.adminUsers {
+		if _, err := s.botAPI.Send(tgbotapi.NewMessage(userID, message)); err != nil {
+			log.Printf("Failed to send notification to user %d: %v", userID, err)
+		}
+	}
+}
+
 // notifyStatusChange sends a notification to admin users when a task status changes.
 // Must be called with s.mu held.
 func (s *StatusService) notifyStatusChange(task Task, previousStatus string) {
 	log.Printf("Task status changed: %s (was %s, now %s)", task.Title, previousStatus, task.Status)
 
-	if s.botAPI != nil {
-		message := fmt.Sprintf("🔔 Status Change Alert:\n\nTask: %s\nPrevious Status: %s\nNew Status: %s\n\nLast updated: %s",
-			task.Title, previousStatus, task.Status, time.Now().Format("2006-01-02 15:04:05"))
-
-		for userID := range s.adminUsers {
-			msg := tgbotapi.NewMessage(userID, message)
-			_, err := s.botAPI.Send(msg)
-			if err != nil {
-				log.Printf("Failed to send status change notification to user %d: %v", userID, err)
-			}
-		}
-	}
+	s.notifyAdmins(fmt.Sprintf("🔔 Status Change Alert:\n\nTask: %s\nPrevious Status: %s\nNew Status: %s\n\nLast updated: %s",
+		task.Title, previousStatus, task.Status, time.Now().Format("2006-01-02 15:04:05")))
 }
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"errors"
+	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -180,6 +182,107 @@ func TestStatusChangeNotification(t *testing.T) {
 	}
 }
 
+func TestCompletedTasks(t *testing.T) {
+	previous := map[string]string{"1": "downloading", "2": "finished", "3": "paused"}
+	tasks := []Task{
+		{ID: "1", Status: "finished"},
+		{ID: "2", Status: "finished"},
+		{ID: "3", Status: "downloading"},
+		{ID: "4", Status: "finished"},
+	}
+
+	got := completedTasks(previous, true, tasks)
+	if len(got) != 2 || got[0].ID != "1" || got[1].ID != "4" {
+		t.Errorf("expected tasks 1 and 4 to complete, got %+v", got)
+	}
+
+	// Without a baseline, tasks already finished at startup are not news
+	got = completedTasks(map[string]string{}, false, tasks)
+	if len(got) != 0 {
+		t.Errorf("expected no completions before a baseline, got %+v", got)
+	}
+}
+
+func TestCompletionNotification(t *testing.T) {
+	client := &mockSynologyClient{
+		tasks: []Task{{ID: "1", Title: "File A", Status: "downloading", Size: 2048}},
+	}
+	sender := &mockBotSender{}
+	svc := newTestService(client, sender, time.Hour)
+	svc.checkStatus()
+
+	client.setTasks([]Task{{ID: "1", Title: "File A", Status: "finished", Size: 2048}})
+	svc.checkStatus()
+	svc.checkStatus()
+
+	msgs := sender.getMessages()
+	if len(msgs) != 1 {
+		t.Fatalf("expected 1 notification message, got %d", len(msgs))
+	}
+	text := msgs[0].(tgbotapi.MessageConfig).Text
+	if !containsString(text, "Download complete") || !containsString(text, "File A") || !containsString(text, "2.0 KB") {
+		t.Errorf("unexpected completion message %q", text)
+	}
+}
+
+func TestCompletionSkippedWhenUnreachable(t *testing.T) {
+	client := &mockSynologyClient{
+		tasks: []Task{{ID: "1", Title: "File A", Status: "downloading"}},
+	}
+	sender := &mockBotSender{}
+	svc := newTestService(client, sender, time.Hour)
+	svc.checkStatus()
+
+	client.mu.Lock()
+	client.err = errors.New("connection refused")
+	client.mu.Unlock()
+	svc.checkStatus()
+
+	client.mu.Lock()
+	client.err = nil
+	client.tasks = []Task{{ID: "1", Title: "File A", Status: "finished"}}
+	client.mu.Unlock()
+	svc.checkStatus()
+
+	if msgs := sender.getMessages(); len(msgs) != 1 {
+		t.Fatalf("expected the completion to be reported once Synology is back, got %d messages", len(msgs))
+	}
+}
+
+func TestPersistedStatusesSurviveRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), statusFileName)
+	client := &mockSynologyClient{
+		tasks: []Task{{ID: "1", Title: "File A", Status: "downloading"}},
+	}
+	sender := &mockBotSender{}
+	svc := newTestService(client, sender, time.Hour)
+	if err := svc.PersistStatuses(path); err != nil {
+		t.Fatal(err)
+	}
+	svc.checkStatus()
+
+	// Finished while the bot was down: reported once after the restart
+	client.setTasks([]Task{{ID: "1", Title: "File A", Status: "finished"}, {ID: "2", Title: "File B", Status: "finished"}})
+	svc = newTestService(client, sender, time.Hour)
+	if err := svc.PersistStatuses(path); err != nil {
+		t.Fatal(err)
+	}
+	svc.checkStatus()
+	if msgs := sender.getMessages(); len(msgs) != 2 {
+		t.Fatalf("expected 2 completion messages after the restart, got %d", len(msgs))
+	}
+
+	// Restarting again must not re-notify
+	svc = newTestService(client, sender, time.Hour)
+	if err := svc.PersistStatuses(path); err != nil {
+		t.Fatal(err)
+	}
+	svc.checkStatus()
+	if msgs := sender.getMessages(); len(msgs) != 2 {
+		t.Errorf("expected no new messages after a second restart, got %d", len(msgs))
+	}
+}
+
 func TestConcurrentAccess(t *testing.T) {
 	client := &mockSynologyClient{
 		tasks: []Task{{ID: "1", Title: "File A", Status: "downloading"}},
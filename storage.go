@@ -0,0 +1,255 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// Errors reported by storage backends that map to specific user-facing replies.
+var (
+	ErrStorageUnauthorized = errors.New("storage rejected credentials")
+	ErrStorageForbidden    = errors.New("storage denied access")
+	ErrStorageFull         = errors.New("storage is full")
+)
+
+// Storage is the backend holding stored file contents.
+// Names are slash-separated paths relative to the storage root.
+type Storage interface {
+	// Save writes the content of r as name and returns the number of bytes written.
+	// It never overwrites: an existing name yields an error wrapping os.ErrExist,
+	// normally before anything is read from r.
+	Save(name string, r io.Reader) (int64, error)
+	// List returns the files directly inside dir ("" for the root), newest first.
+	// A missing dir yields an empty listing.
+	List(dir string) ([]storedFile, error)
+	// Delete removes the file stored as name.
+	Delete(name string) error
+}
+
+// LocalStorage implements Storage on the local filesystem. Files of specific
+// media types or chats may live in separate roots (e.g. videos on another
+// disk); all roots share one namespace, so a name exists in at most one of them.
+type LocalStorage struct {
+	root      string
+	typeRoots map[string]string // media type -> root
+	chatRoots map[int64]string  // chat ID -> root, taking precedence over typeRoots
+	// writing holds the names being saved, so concurrent saves of one name
+	// cannot both end up in place
+	writing keyedMutex
+}
+
+func NewLocalStorage(root string) *LocalStorage {
+	return &LocalStorage{root: root}
+}
+
+// NewRoutedLocalStorage returns a LocalStorage that stores the files of the
+// given chats and media types in their own roots and everything else in root.
+func NewRoutedLocalStorage(root string, typeRoots map[string]string, chatRoots map[int64]string) *LocalStorage {
+	return &LocalStorage{root: root, typeRoots: typeRoots, chatRoots: chatRoots}
+}
+
+// rootFor returns the root receiving new files of mediaType from chatID.
+func (s *LocalStorage) rootFor(chatID int64, mediaType string) string {
+	if root, ok := s.chatRoots[chatID]; ok {
+		return root
+	}
+	if root, ok := s.typeRoots[mediaType]; ok {
+		return root
+	}
+	return s.root
+}
+
+// Roots returns every root, the default first.
+func (s *LocalStorage) Roots() []string {
+	roots := []string{s.root}
+	seen := map[string]bool{s.root: true}
+	add := func(root string) {
+		if !seen[root] {
+			roots = append(roots, root)
+			seen[root] = true
+		}
+	}
+	for _, mediaType := range mediaTypes {
+		if root, ok := s.typeRoots[mediaType]; ok {
+			add(root)
+		}
+	}
+	chats := make([]int64, 0, len(s.chatRoots))
+	for chatID := range s.chatRoots {
+		chats = append(chats, chatID)
+	}
+	slices.Sort(chats)
+	for _, chatID := range chats {
+		add(s.chatRoots[chatID])
+	}
+	return roots
+}
+
+// RootOf returns the root holding name, or the default root if it does not exist.
+func (s *LocalStorage) RootOf(name string) string {
+	for _, root := range s.Roots()[1:] {
+		if exists(filepath.Join(root, filepath.FromSlash(name))) {
+			return root
+		}
+	}
+	return s.root
+}
+
+// Locate returns the filesystem path of name in whichever root holds it, or
+// its path in the default root if it does not exist.
+func (s *LocalStorage) Locate(name string) string {
+	return filepath.Join(s.RootOf(name), filepath.FromSlash(name))
+}
+
+// Exists reports whether name is stored in any root.
+func (s *LocalStorage) Exists(name string) bool {
+	return exists(s.Locate(name))
+}
+
+func (s *LocalStorage) Save(name string, r io.Reader) (int64, error) {
+	return s.SaveAs("", name, r)
+}
+
+// SaveAs is Save routing the file to the root configured for mediaType.
+func (s *LocalStorage) SaveAs(mediaType, name string, r io.Reader) (int64, error) {
+	return s.SaveFor(0, mediaType, name, r)
+}
+
+// SaveFor is Save routing the file to the root configured for chatID, else to
+// the one for mediaType. The content is written to a hidden temporary file
+// beside the target and renamed into place once complete, so the name never
+// shows a partial file. A name another save is writing counts as existing.
+func (s *LocalStorage) SaveFor(chatID int64, mediaType, name string, r io.Reader) (int64, error) {
+	if !s.writing.TryLock(name) {
+		return 0, fmt.Errorf("failed to create local file: %w", os.ErrExist)
+	}
+	defer s.writing.Unlock(name)
+
+	filePath := filepath.Join(s.rootFor(chatID, mediaType), filepath.FromSlash(name))
+	if s.Exists(name) || exists(filePath) {
+		return 0, fmt.Errorf("failed to create local file: %w", os.ErrExist)
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".tmp-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create local file: %w", err)
+	}
+
+	size, err := io.Copy(tmp, r)
+	if err == nil {
+		err = tmp.Chmod(0644)
+	}
+	// Some filesystems only report a full disk when the file is closed
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filePath)
+	}
+	if err != nil {
+		// Never leave a truncated file behind
+		if rerr := os.Remove(tmp.Name()); rerr != nil && !errors.Is(rerr, os.ErrNotExist) {
+			log.Printf("Failed to remove partial file %s: %v", tmp.Name(), rerr)
+		}
+		return size, fmt.Errorf("failed to save file content: %w", err)
+	}
+	return size, nil
+}
+
+// keyedMutex is a set of locks by name. The zero value is ready to use.
+type keyedMutex struct {
+	mu     sync.Mutex
+	locked map[string]bool
+}
+
+// TryLock locks key unless it is locked already, and reports whether it did.
+func (k *keyedMutex) TryLock(key string) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.locked[key] {
+		return false
+	}
+	if k.locked == nil {
+		k.locked = make(map[string]bool)
+	}
+	k.locked[key] = true
+	return true
+}
+
+func (k *keyedMutex) Unlock(key string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.locked, key)
+}
+
+// checkWritable creates and removes a probe file in dir, so a read-only mount
+// or wrong permissions are reported at startup rather than on every upload.
+func checkWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".write-probe-*")
+	if err == nil {
+		_, err = f.Write([]byte("ok"))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if rerr := os.Remove(f.Name()); err == nil {
+			err = rerr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("storage directory %s is not writable: %w", dir, err)
+	}
+	return nil
+}
+
+// removePartial deletes whatever a failed save left behind as name. Backends
+// that clean up themselves report it missing, which is fine. LocalStorage only
+// ever wrote a temporary file, and name may be a concurrent save's by now.
+func (b *Bot) removePartial(name string) {
+	if _, ok := b.storage.(*LocalStorage); ok {
+		return
+	}
+	if err := b.storage.Delete(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Failed to remove partial file %s: %v", name, err)
+	}
+}
+
+func (s *LocalStorage) List(dir string) ([]storedFile, error) {
+	var all []storedFile
+	for _, root := range s.Roots() {
+		files, err := listStoredFiles(filepath.Join(root, filepath.FromSlash(dir)))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, files...)
+	}
+
+	sortNewestFirst(all)
+	return all, nil
+}
+
+func (s *LocalStorage) Delete(name string) error {
+	return os.Remove(s.Locate(name))
+}
+
+// routedSaver is implemented by backends that can route files by chat and
+// media type.
+type routedSaver interface {
+	SaveFor(chatID int64, mediaType, name string, r io.Reader) (int64, error)
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentSavesOfOneName(t *testing.T) {
+	b := newTestBot(t)
+
+	const writers = 8
+	saved := make([]FileMeta, writers)
+	errs := make([]error, writers)
+	var wg sync.WaitGroup
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			content := bytes.Repeat([]byte(fmt.Sprintf("writer %d\n", i)), 20000)
+			saved[i], errs[i] = b.saveFile(bytes.NewReader(content), "documents", "report.txt", "text/plain", 42)
+		}()
+	}
+	wg.Wait()
+
+	names := make(map[string]bool)
+	for i, m := range saved {
+		if errs[i] != nil {
+			t.Fatalf("writer %d: %v", i, errs[i])
+		}
+		if names[m.Path] {
+			t.Errorf("writer %d: %s stored twice", i, m.Path)
+		}
+		names[m.Path] = true
+
+		data, err := os.ReadFile(b.localPath(m.Path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != m.SHA256 || !bytes.HasPrefix(data, []byte(fmt.Sprintf("writer %d\n", i))) {
+			t.Errorf("writer %d: %s does not hold its content", i, m.Path)
+		}
+	}
+
+	entries, err := os.ReadDir(b.storagePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Name() != metaDirName && !names[e.Name()] {
+			t.Errorf("unexpected leftover %s", e.Name())
+		}
+	}
+}
+
+// gatedReader yields its content only once released.
+type gatedReader struct {
+	once    sync.Once
+	started chan struct{}
+	release chan struct{}
+	r       io.Reader
+}
+
+func (g *gatedReader) Read(p []byte) (int, error) {
+	g.once.Do(func() {
+		close(g.started)
+		<-g.release
+	})
+	return g.r.Read(p)
+}
+
+func TestLocalStorageSaveIsAtomic(t *testing.T) {
+	s := NewLocalStorage(t.TempDir())
+	gate := &gatedReader{started: make(chan struct{}), release: make(chan struct{}), r: bytes.NewReader([]byte("complete"))}
+
+	done := make(chan error)
+	go func() {
+		_, err := s.Save("a.txt", gate)
+		done <- err
+	}()
+	<-gate.started
+
+	// Mid-write the name is neither visible nor free
+	if s.Exists("a.txt") {
+		t.Error("expected the file to appear only once complete")
+	}
+	if files, err := s.List(""); err != nil || len(files) != 0 {
+		t.Errorf("expected the temporary file to be hidden, got %v (%v)", files, err)
+	}
+	read := false
+	if _, err := s.Save("a.txt", readerFunc(func([]byte) (int, error) { read = true; return 0, io.EOF })); !errors.Is(err, os.ErrExist) || read {
+		t.Errorf("expected a name being written to count as existing before reading, got %v (read %v)", err, read)
+	}
+
+	close(gate.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(s.Locate("a.txt")); err != nil || string(data) != "complete" {
+		t.Errorf("unexpected content %q (%v)", data, err)
+	}
+	if info, err := os.Stat(s.Locate("a.txt")); err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("unexpected mode %v (%v)", info.Mode(), err)
+	}
+}
+
+type readerFunc func([]byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) { return f(p) }
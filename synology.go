@@ -2,9 +2,13 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -13,6 +17,26 @@ type SynologyClient interface {
 	FetchTasks() ([]Task, error)
 }
 
+// SynologyTaskManager lists and changes DownloadStation tasks. Each action acts
+// on a batch of task IDs and reports the outcome per task.
+type SynologyTaskManager interface {
+	SynologyClient
+	PauseTasks(ids []string) ([]TaskResult, error)
+	ResumeTasks(ids []string) ([]TaskResult, error)
+	DeleteTasks(ids []string) ([]TaskResult, error)
+}
+
+// TaskResult is the outcome of a task action for one task. Error is the
+// DownloadStation error code, 0 on success.
+type TaskResult struct {
+	ID    string `json:"id"`
+	Error int    `json:"error"`
+}
+
+// synologyDeviceName is the name the bot's trusted device is registered under
+// on accounts with 2-step verification.
+const synologyDeviceName = "tg-fsyn"
+
 // synologyHTTPClient implements SynologyClient using the Synology DownloadStation HTTP API.
 type synologyHTTPClient struct {
 	client   *http.Client
@@ -20,25 +44,42 @@ type synologyHTTPClient struct {
 	port     string
 	username string
 	password string
+
+	// On accounts with 2-step verification the first login uses otp and
+	// registers the bot as a trusted device; later logins send deviceID
+	// instead, as the code expires within a minute.
+	mu       sync.Mutex
+	otp      string
+	deviceID string
+}
+
+// synologySession is a logged in session. Token is the SynoToken DSM
+// returns for enable_syno_token, sent along with every later request.
+type synologySession struct {
+	ID    string
+	Token string
 }
 
-func NewSynologyHTTPClient(host, port, username, password string) *synologyHTTPClient {
+// NewSynologyHTTPClient returns a client for the DSM at host:port. otp is a
+// current 2-step verification code, empty for accounts without one.
+func NewSynologyHTTPClient(host, port, username, password, otp string) *synologyHTTPClient {
 	return &synologyHTTPClient{
 		client:   &http.Client{Timeout: 30 * time.Second},
 		host:     host,
 		port:     port,
 		username: username,
 		password: password,
+		otp:      otp,
 	}
 }
 
 func (c *synologyHTTPClient) FetchTasks() ([]Task, error) {
-	sessionID, err := c.login()
+	session, err := c.login()
 	if err != nil {
 		return nil, fmt.Errorf("login failed: %w", err)
 	}
 
-	tasks, err := c.getDownloadTasks(sessionID)
+	tasks, err := c.getDownloadTasks(session)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get tasks: %w", err)
 	}
@@ -46,38 +87,95 @@ func (c *synologyHTTPClient) FetchTasks() ([]Task, error) {
 	return tasks, nil
 }
 
-func (c *synologyHTTPClient) login() (string, error) {
-	url := fmt.Sprintf("http://%s:%s/webapi/auth.cgi?api=SYNO.API.Auth&method=login&version=7&account=%s&passwd=%s&format=json", c.host, c.port, c.username, c.password)
+func (c *synologyHTTPClient) login() (synologySession, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	resp, err := c.client.Get(url)
+	params := url.Values{
+		"api":               {synologyAuthAPI},
+		"method":            {"login"},
+		"version":           {"7"},
+		"account":           {c.username},
+		"passwd":            {c.password},
+		"enable_syno_token": {"yes"},
+		"format":            {"json"},
+	}
+	switch {
+	case c.deviceID != "":
+		params.Set("device_id", c.deviceID)
+		params.Set("device_name", synologyDeviceName)
+	case c.otp != "":
+		params.Set("otp_code", c.otp)
+		params.Set("enable_device_token", "yes")
+		params.Set("device_name", synologyDeviceName)
+	}
+
+	resp, err := c.client.Get(fmt.Sprintf("http://%s:%s/webapi/auth.cgi?%s", c.host, c.port, params.Encode()))
 	if err != nil {
-		return "", fmt.Errorf("login request failed: %w", err)
+		return synologySession{}, fmt.Errorf("login request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read login response: %w", err)
+		return synologySession{}, fmt.Errorf("failed to read login response: %w", err)
 	}
 
-	var result map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return "", fmt.Errorf("failed to parse login response: %w", err)
+	var data struct {
+		SID       string `json:"sid"`
+		SynoToken string `json:"synotoken"`
+		DeviceID  string `json:"did"`
+	}
+	if err := decodeSynologyResponse(body, synologyAuthAPI, &data); err != nil {
+		return synologySession{}, c.explainLoginError(err)
+	}
+	if data.SID == "" {
+		return synologySession{}, fmt.Errorf("response has no session ID")
 	}
+	if data.DeviceID != "" {
+		c.deviceID = data.DeviceID
+		c.otp = ""
+	}
+	return synologySession{ID: data.SID, Token: data.SynoToken}, nil
+}
 
-	if result["success"] != true {
-		return "", fmt.Errorf("login failed: %s", string(body))
+// explainLoginError adds what to do about 2-step verification failures.
+func (c *synologyHTTPClient) explainLoginError(err error) error {
+	var dsmErr *SynologyError
+	if !errors.As(err, &dsmErr) {
+		return err
+	}
+	switch dsmErr.Code {
+	case 403:
+		if c.deviceID != "" {
+			// DSM forgot the trusted device; a new code is needed
+			c.deviceID = ""
+			return fmt.Errorf("%w: the bot is no longer a trusted device, restart it with a new SYNOLOGY_OTP", err)
+		}
+		return fmt.Errorf("%w: the account has 2-step verification, set SYNOLOGY_OTP to a current code", err)
+	case 404:
+		return fmt.Errorf("%w: SYNOLOGY_OTP was wrong or expired, restart with a current code", err)
 	}
+	return err
+}
 
-	data := result["data"].(map[string]interface{})
-	sessionID := data["sid"].(string)
-	return sessionID, nil
+// query returns the parameters common to requests made in session.
+func (s synologySession) query() url.Values {
+	params := url.Values{"_sid": {s.ID}}
+	if s.Token != "" {
+		params.Set("SynoToken", s.Token)
+	}
+	return params
 }
 
-func (c *synologyHTTPClient) getDownloadTasks(sessionID string) ([]Task, error) {
-	url := fmt.Sprintf("http://%s:%s/webapi/DownloadStation/task.cgi?api=SYNO.DownloadStation.Task&method=list&version=1&_sid=%s&additional=detail,file", c.host, c.port, sessionID)
+func (c *synologyHTTPClient) getDownloadTasks(session synologySession) ([]Task, error) {
+	params := session.query()
+	params.Set("api", synologyTaskAPI)
+	params.Set("method", "list")
+	params.Set("version", "1")
+	params.Set("additional", "detail,file")
 
-	resp, err := c.client.Get(url)
+	resp, err := c.client.Get(fmt.Sprintf("http://%s:%s/webapi/DownloadStation/task.cgi?%s", c.host, c.port, params.Encode()))
 	if err != nil {
 		return nil, fmt.Errorf("task list request failed: %w", err)
 	}
@@ -88,30 +186,148 @@ func (c *synologyHTTPClient) getDownloadTasks(sessionID string) ([]Task, error)
 		return nil, fmt.Errorf("failed to read task list response: %w", err)
 	}
 
-	var rawResponse map[string]interface{}
-	if err := json.Unmarshal(body, &rawResponse); err != nil {
-		return nil, fmt.Errorf("failed to parse raw task list response: %w", err)
-	}
-
-	var tasks []Task
-
-	if data, ok := rawResponse["data"]; ok {
-		if dataMap, ok := data.(map[string]interface{}); ok {
-			if tasksArray, ok := dataMap["tasks"]; ok {
-				if tasksSlice, ok := tasksArray.([]interface{}); ok {
-					for _, taskData := range tasksSlice {
-						if taskMap, ok := taskData.(map[string]interface{}); ok {
-							taskJSON, _ := json.Marshal(taskMap)
-							var task Task
-							if err := json.Unmarshal(taskJSON, &task); err == nil {
-								tasks = append(tasks, task)
-							}
-						}
-					}
-				}
-			}
-		}
+	var data struct {
+		Tasks []Task `json:"tasks"`
+	}
+	if err := decodeSynologyResponse(body, synologyTaskAPI, &data); err != nil {
+		return nil, err
 	}
+	return data.Tasks, nil
+}
 
-	return tasks, nil
+func (c *synologyHTTPClient) PauseTasks(ids []string) ([]TaskResult, error) {
+	return c.taskAction("pause", ids, nil)
+}
+
+func (c *synologyHTTPClient) ResumeTasks(ids []string) ([]TaskResult, error) {
+	return c.taskAction("resume", ids, nil)
+}
+
+// DeleteTasks removes tasks, keeping any files already downloaded.
+func (c *synologyHTTPClient) DeleteTasks(ids []string) ([]TaskResult, error) {
+	return c.taskAction("delete", ids, url.Values{"force_complete": {"false"}})
+}
+
+// taskAction calls a SYNO.DownloadStation.Task method on a batch of task IDs.
+func (c *synologyHTTPClient) taskAction(method string, ids []string, extra url.Values) ([]TaskResult, error) {
+	session, err := c.login()
+	if err != nil {
+		return nil, fmt.Errorf("login failed: %w", err)
+	}
+
+	params := session.query()
+	params.Set("api", synologyTaskAPI)
+	params.Set("method", method)
+	params.Set("version", "1")
+	params.Set("id", strings.Join(ids, ","))
+	for key, values := range extra {
+		params[key] = values
+	}
+
+	resp, err := c.client.Get(fmt.Sprintf("http://%s:%s/webapi/DownloadStation/task.cgi?%s", c.host, c.port, params.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("task %s request failed: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read task %s response: %w", method, err)
+	}
+
+	var results []TaskResult
+	if err := decodeSynologyResponse(body, synologyTaskAPI, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// synologyResponse is the envelope of every DSM Web API response.
+type synologyResponse struct {
+	Success bool            `json:"success"`
+	Data    json.RawMessage `json:"data"`
+	Error   struct {
+		Code int `json:"code"`
+	} `json:"error"`
+}
+
+// DSM Web API names, which select the meaning of API-specific error codes.
+const (
+	synologyAuthAPI = "SYNO.API.Auth"
+	synologyTaskAPI = "SYNO.DownloadStation.Task"
+)
+
+// SynologyError is a DSM API failure ("success": false).
+type SynologyError struct {
+	API  string
+	Code int
+}
+
+// synologyCommonErrors are the error codes shared by all DSM APIs.
+var synologyCommonErrors = map[int]string{
+	100: "unknown error",
+	101: "invalid parameter",
+	102: "the requested API does not exist",
+	103: "the requested method does not exist",
+	104: "the requested version does not support the functionality",
+	105: "the logged in session does not have permission",
+	106: "session timeout",
+	107: "session interrupted by duplicate login",
+}
+
+// synologyAPIErrors are the API-specific error codes (400 and up), which
+// overlap between APIs.
+var synologyAPIErrors = map[string]map[int]string{
+	synologyAuthAPI: {
+		400: "no such account or incorrect password",
+		401: "account disabled",
+		402: "permission denied",
+		403: "2-step verification code required",
+		404: "failed to authenticate 2-step verification code",
+	},
+	synologyTaskAPI: {
+		400: "file upload failed",
+		401: "max number of tasks reached",
+		402: "destination denied",
+		403: "destination does not exist",
+		404: "invalid task id",
+		405: "invalid task action",
+		406: "no default destination",
+		407: "set destination failed",
+		408: "file does not exist",
+	},
+}
+
+// errorMessage describes a DSM error code returned by api.
+func errorMessage(api string, code int) string {
+	if msg, ok := synologyCommonErrors[code]; ok {
+		return msg
+	}
+	if msg, ok := synologyAPIErrors[api][code]; ok {
+		return msg
+	}
+	return "unknown error code"
+}
+
+func (e *SynologyError) Error() string {
+	return fmt.Sprintf("%s error %d: %s", e.API, e.Code, errorMessage(e.API, e.Code))
+}
+
+// decodeSynologyResponse parses a response of api into data, returning a
+// *SynologyError when the API reports a failure.
+func decodeSynologyResponse(body []byte, api string, data interface{}) error {
+	var resp synologyResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	if !resp.Success {
+		return &SynologyError{API: api, Code: resp.Error.Code}
+	}
+	if len(resp.Data) == 0 {
+		return fmt.Errorf("response has no data")
+	}
+	if err := json.Unmarshal(resp.Data, data); err != nil {
+		return fmt.Errorf("failed to parse response data: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// newFakeSynology serves the given task list response and a successful login.
+func newFakeSynology(t *testing.T, taskList string) *synologyHTTPClient {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/webapi/auth.cgi":
+			w.Write([]byte(`{"success":true,"data":{"sid":"session"}}`))
+		case "/webapi/DownloadStation/task.cgi":
+			if r.URL.Query().Get("_sid") != "session" {
+				w.Write([]byte(`{"success":false,"error":{"code":106}}`))
+				return
+			}
+			w.Write([]byte(taskList))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	u, _ := url.Parse(srv.URL)
+	host, port, _ := net.SplitHostPort(u.Host)
+	return NewSynologyHTTPClient(host, port, "user", "secret", "")
+}
+
+func TestFetchTasksParsesTypedResponse(t *testing.T) {
+	c := newFakeSynology(t, `{"success":true,"data":{"offset":0,"total":2,"tasks":[
+		{"id":"dbid_1","title":"ubuntu.iso","status":"downloading","size":4000000000,"type":"bt","username":"admin",
+		 "additional":{"detail":{"started_time":1700000000},"file":[{"filename":"ubuntu.iso","size":4000000000}]}},
+		{"id":"dbid_2","title":"notes.zip","status":"finished","size":1024,"type":"http","username":"admin"}]}}`)
+
+	tasks, err := c.FetchTasks()
+	if err != nil {
+		t.Fatalf("FetchTasks failed: %v", err)
+	}
+	if len(tasks) != 2 || tasks[0].ID != "dbid_1" || tasks[0].Size != 4000000000 || tasks[1].Status != "finished" {
+		t.Fatalf("unexpected tasks %+v", tasks)
+	}
+	if tasks[0].Additional.Detail.StartedTime != 1700000000 || tasks[0].Additional.File[0].Name != "ubuntu.iso" {
+		t.Errorf("expected additional fields to be parsed, got %+v", tasks[0].Additional)
+	}
+}
+
+func TestFetchTasksReportsDSMErrorCode(t *testing.T) {
+	c := newFakeSynology(t, `{"success":false,"error":{"code":105}}`)
+
+	_, err := c.FetchTasks()
+	var dsmErr *SynologyError
+	if !errors.As(err, &dsmErr) || dsmErr.Code != 105 {
+		t.Fatalf("expected DSM error 105, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "does not have permission") {
+		t.Errorf("expected the error to describe the code, got %q", err)
+	}
+}
+
+func TestDecodeSynologyResponseMalformed(t *testing.T) {
+	var data struct {
+		Tasks []Task `json:"tasks"`
+	}
+	for name, body := range map[string]string{
+		"not json":       `<html>502 Bad Gateway</html>`,
+		"no data":        `{"success":true}`,
+		"wrong type":     `{"success":true,"data":{"tasks":"none"}}`,
+		"wrong envelope": `{"success":"yes"}`,
+	} {
+		if err := decodeSynologyResponse([]byte(body), synologyTaskAPI, &data); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestSynologyErrorMessage(t *testing.T) {
+	cases := []struct {
+		api  string
+		code int
+		want string
+	}{
+		{synologyAuthAPI, 400, "no such account or incorrect password"},
+		{synologyTaskAPI, 400, "file upload failed"},
+		{synologyTaskAPI, 106, "session timeout"},
+		{synologyAuthAPI, 403, "2-step verification code required"},
+		{synologyTaskAPI, 999, "unknown error code"},
+	}
+	for _, tc := range cases {
+		if got := errorMessage(tc.api, tc.code); got != tc.want {
+			t.Errorf("errorMessage(%s, %d) = %q, want %q", tc.api, tc.code, got, tc.want)
+		}
+	}
+
+	err := &SynologyError{API: synologyAuthAPI, Code: 400}
+	if err.Error() != "SYNO.API.Auth error 400: no such account or incorrect password" {
+		t.Errorf("unexpected error text %q", err.Error())
+	}
+}
+
+func TestTaskActionsSendMethodAndIDs(t *testing.T) {
+	var got url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/webapi/auth.cgi":
+			w.Write([]byte(`{"success":true,"data":{"sid":"session"}}`))
+		case "/webapi/DownloadStation/task.cgi":
+			got = r.URL.Query()
+			w.Write([]byte(`{"success":true,"data":[{"id":"dbid_1","error":0},{"id":"dbid_2","error":404}]}`))
+		}
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	host, port, _ := net.SplitHostPort(u.Host)
+	c := NewSynologyHTTPClient(host, port, "user", "secret", "")
+
+	actions := map[string]func([]string) ([]TaskResult, error){
+		"pause":  c.PauseTasks,
+		"resume": c.ResumeTasks,
+		"delete": c.DeleteTasks,
+	}
+	for method, action := range actions {
+		results, err := action([]string{"dbid_1", "dbid_2"})
+		if err != nil {
+			t.Fatalf("%s failed: %v", method, err)
+		}
+		if got.Get("method") != method || got.Get("id") != "dbid_1,dbid_2" || got.Get("api") != synologyTaskAPI || got.Get("_sid") != "session" {
+			t.Errorf("%s: unexpected query %v", method, got)
+		}
+		if len(results) != 2 || results[0].Error != 0 || results[1].Error != 404 {
+			t.Errorf("%s: unexpected results %+v", method, results)
+		}
+	}
+	if c.DeleteTasks([]string{"dbid_1"}); got.Get("force_complete") != "false" {
+		t.Errorf("expected delete to keep downloaded files, got %v", got)
+	}
+}
+
+// newFakeSynologyAuth serves logins through auth and records the queries of
+// the login and task list requests.
+func newFakeSynologyAuth(t *testing.T, otp string, auth func(q url.Values) string) (*synologyHTTPClient, *[]url.Values, *url.Values) {
+	t.Helper()
+	var logins []url.Values
+	var list url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/webapi/auth.cgi":
+			logins = append(logins, r.URL.Query())
+			w.Write([]byte(auth(r.URL.Query())))
+		case "/webapi/DownloadStation/task.cgi":
+			list = r.URL.Query()
+			w.Write([]byte(`{"success":true,"data":{"tasks":[]}}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	u, _ := url.Parse(srv.URL)
+	host, port, _ := net.SplitHostPort(u.Host)
+	return NewSynologyHTTPClient(host, port, "user", "p&ss word", otp), &logins, &list
+}
+
+func TestLoginSendsOTPThenTrustedDevice(t *testing.T) {
+	c, logins, list := newFakeSynologyAuth(t, "123456", func(q url.Values) string {
+		if q.Get("otp_code") == "123456" || q.Get("device_id") == "dev1" {
+			return `{"success":true,"data":{"sid":"session","synotoken":"tok","did":"dev1"}}`
+		}
+		return `{"success":false,"error":{"code":403}}`
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.FetchTasks(); err != nil {
+			t.Fatalf("FetchTasks %d failed: %v", i, err)
+		}
+	}
+
+	first, second := (*logins)[0], (*logins)[1]
+	if first.Get("otp_code") != "123456" || first.Get("enable_device_token") != "yes" || first.Get("passwd") != "p&ss word" {
+		t.Errorf("expected the first login to send the code, got %v", first)
+	}
+	if first.Get("enable_syno_token") != "yes" {
+		t.Errorf("expected a SynoToken to be requested, got %v", first)
+	}
+	if second.Get("otp_code") != "" || second.Get("device_id") != "dev1" {
+		t.Errorf("expected the second login to use the trusted device, got %v", second)
+	}
+	if list.Get("SynoToken") != "tok" || list.Get("_sid") != "session" {
+		t.Errorf("expected the task list to carry the session token, got %v", *list)
+	}
+}
+
+func TestLoginWithout2FACodeSendsNoOTP(t *testing.T) {
+	c, logins, list := newFakeSynologyAuth(t, "", func(url.Values) string {
+		return `{"success":true,"data":{"sid":"session"}}`
+	})
+
+	if _, err := c.FetchTasks(); err != nil {
+		t.Fatalf("FetchTasks failed: %v", err)
+	}
+	if q := (*logins)[0]; q.Has("otp_code") || q.Has("device_id") {
+		t.Errorf("expected a plain login, got %v", q)
+	}
+	if list.Has("SynoToken") {
+		t.Errorf("expected no SynoToken without one from DSM, got %v", *list)
+	}
+}
+
+func TestLoginReports2FARequired(t *testing.T) {
+	c, _, _ := newFakeSynologyAuth(t, "", func(url.Values) string {
+		return `{"success":false,"error":{"code":403}}`
+	})
+
+	_, err := c.FetchTasks()
+	var dsmErr *SynologyError
+	if !errors.As(err, &dsmErr) || dsmErr.Code != 403 {
+		t.Fatalf("expected DSM error 403, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "set SYNOLOGY_OTP") {
+		t.Errorf("expected the error to say how to log in, got %q", err)
+	}
+
+	c, _, _ = newFakeSynologyAuth(t, "000000", func(url.Values) string {
+		return `{"success":false,"error":{"code":404}}`
+	})
+	if _, err := c.FetchTasks(); err == nil || !strings.Contains(err.Error(), "wrong or expired") {
+		t.Errorf("expected a rejected code to be reported, got %v", err)
+	}
+}
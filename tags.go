@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Limits on the tags of one file.
+const (
+	MaxTagLength = 32
+	MaxFileTags  = 20
+)
+
+var errTooManyTags = fmt.Errorf("a file can have at most %d tags", MaxFileTags)
+
+// normalizeTag returns tag lowercased and without a leading '#', or an error
+// unless it is made of letters, digits, '_' and '-'.
+func normalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+	if tag == "" || len([]rune(tag)) > MaxTagLength {
+		return "", fmt.Errorf("invalid tag %q: use up to %d letters, digits, _ or -", tag, MaxTagLength)
+	}
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-' {
+			return "", fmt.Errorf("invalid tag %q: use up to %d letters, digits, _ or -", tag, MaxTagLength)
+		}
+	}
+	return tag, nil
+}
+
+// normalizeTags normalizes every tag and returns them sorted without duplicates.
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		t, err := normalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, t)
+	}
+	slices.Sort(normalized)
+	return slices.Compact(normalized), nil
+}
+
+// setTags adds and removes (normalized) tags of the stored file rel and
+// returns its tags afterwards. The sidecar and the index are updated.
+func (b *Bot) setTags(rel string, owner int64, add, remove []string) ([]string, error) {
+	src := b.localPath(rel)
+	info, err := os.Stat(src)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil, errSourceMissing
+	}
+
+	m := b.fileMeta(rel, src, info)
+	if m.Owner == 0 {
+		m.Owner = owner
+	}
+	tags := slices.DeleteFunc(append(slices.Clone(m.Tags), add...), func(tag string) bool {
+		return slices.Contains(remove, tag)
+	})
+	slices.Sort(tags)
+	tags = slices.Compact(tags)
+	if len(tags) > MaxFileTags {
+		return nil, errTooManyTags
+	}
+
+	if len(add) > 0 || len(remove) > 0 {
+		m.Tags = tags
+		if err := writeMeta(b.storagePath, m); err != nil {
+			return nil, err
+		}
+		if b.index != nil {
+			if err := b.index.Put(m); err != nil {
+				log.Printf("Failed to index %s: %v", m.Path, err)
+			}
+		}
+	}
+	return tags, nil
+}
+
+// handleTagCommand adds tags to (or with a leading '-' removes them from) one
+// of the user's files, or lists its tags.
+func (b *Bot) handleTagCommand(chatID, userID int64, args string) {
+	if !b.requireLocalStorage(chatID) {
+		return
+	}
+
+	parts := splitArgs(args)
+	if len(parts) == 0 {
+		b.sendTextMessage(chatID, "Usage: /tag <file> <tag1> <tag2>...\nPrefix a tag with - to remove it; /tag <file> alone lists its tags. Find tagged files with /search tag:<tag>")
+		return
+	}
+	name, err := cleanRelPath(parts[0])
+	if err != nil {
+		b.sendTextMessage(chatID, "❌ Invalid file name")
+		return
+	}
+
+	var add, remove []string
+	for _, arg := range parts[1:] {
+		if tag, ok := strings.CutPrefix(arg, "-"); ok {
+			remove = append(remove, tag)
+		} else {
+			add = append(add, arg)
+		}
+	}
+	if add, err = normalizeTags(add); err == nil {
+		remove, err = normalizeTags(remove)
+	}
+	if err != nil {
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ %v", err))
+		return
+	}
+
+	rel := b.userFile(userID, name)
+	tags, err := b.setTags(rel, userID, add, remove)
+	switch {
+	case errors.Is(err, errSourceMissing):
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ File '%s' not found", name))
+	case errors.Is(err, errTooManyTags):
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ %v", err))
+	case err != nil:
+		log.Printf("Error tagging %s: %v", rel, err)
+		b.sendTextMessage(chatID, "❌ Failed to update the file.")
+	case len(tags) == 0:
+		b.sendTextMessage(chatID, fmt.Sprintf("🏷 '%s' has no tags", name))
+	default:
+		if len(add) > 0 || len(remove) > 0 {
+			log.Printf("User %d tagged %s: %s", userID, rel, strings.Join(tags, ", "))
+		}
+		b.sendTextMessage(chatID, fmt.Sprintf("🏷 '%s': %s", name, formatTags(tags)))
+	}
+}
+
+// formatTags renders tags as hashtags.
+func formatTags(tags []string) string {
+	return "#" + strings.Join(tags, " #")
+}
+
+// findTagged returns up to limit of the user's files tagged with tag, newest
+// first, from the index if there is one and from the sidecars otherwise.
+func (b *Bot) findTagged(userID int64, tag string, limit int) ([]storedFile, error) {
+	tag, err := normalizeTag(tag)
+	if err != nil {
+		return nil, nil
+	}
+	dir := b.userDir(userID)
+
+	if b.index != nil {
+		metas, err := b.index.SearchTag(dir, tag, limit)
+		if err != nil {
+			return nil, err
+		}
+		files := make([]storedFile, 0, len(metas))
+		for _, m := range metas {
+			files = append(files, storedFile{Name: b.listedName(userID, m.Path), Size: m.Size, ModTime: m.ReceivedAt})
+		}
+		return files, nil
+	}
+
+	var files []storedFile
+	for _, root := range b.dataRoots() {
+		err := walkStoredFiles(filepath.Join(root, filepath.FromSlash(dir)), func(rel, _ string, info os.FileInfo) error {
+			rel = path.Join(dir, rel)
+			if m, err := readMeta(b.storagePath, rel); err == nil && slices.Contains(m.Tags, tag) {
+				files = append(files, storedFile{Name: b.listedName(userID, rel), Size: info.Size(), ModTime: m.ReceivedAt})
+			}
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	sortNewestFirst(files)
+	if len(files) > limit {
+		files = files[:limit]
+	}
+	return files, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	got, err := normalizeTags([]string{"Work", "#work", "2024", "tax-return", "WORK", "налоги"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"2024", "tax-return", "work", "налоги"}; !slices.Equal(got, want) {
+		t.Errorf("normalizeTags = %v, want %v", got, want)
+	}
+	for _, bad := range []string{"", "#", "a b", "a/b", "x.y", strings.Repeat("a", MaxTagLength+1)} {
+		if _, err := normalizeTags([]string{bad}); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestTagCommand(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+	writeTestFile(t, b, "invoice.pdf", "content")
+	last := func() string {
+		texts := api.texts()
+		return texts[len(texts)-1]
+	}
+
+	b.handleTagCommand(42, 42, " invoice.pdf Work #TAX work")
+	if reply := last(); reply != "🏷 'invoice.pdf': #tax #work" {
+		t.Fatalf("unexpected reply %q", reply)
+	}
+	m, err := readMeta(b.storagePath, "invoice.pdf")
+	if err != nil || !slices.Equal(m.Tags, []string{"tax", "work"}) || m.Owner != 42 {
+		t.Fatalf("expected the tags in the sidecar, got %+v (%v)", m, err)
+	}
+	if info := formatFileInfo("invoice.pdf", m); !strings.Contains(info, "\nTags: #tax #work") {
+		t.Errorf("expected the tags in /info, got %q", info)
+	}
+
+	b.handleTagCommand(42, 42, " invoice.pdf -Work 2024")
+	if reply := last(); reply != "🏷 'invoice.pdf': #2024 #tax" {
+		t.Errorf("unexpected reply to removing a tag %q", reply)
+	}
+	b.handleTagCommand(42, 42, " invoice.pdf")
+	if reply := last(); reply != "🏷 'invoice.pdf': #2024 #tax" {
+		t.Errorf("unexpected listing %q", reply)
+	}
+	b.handleTagCommand(42, 42, " invoice.pdf -2024 -tax")
+	if reply := last(); reply != "🏷 'invoice.pdf' has no tags" {
+		t.Errorf("unexpected reply to removing every tag %q", reply)
+	}
+
+	b.handleTagCommand(42, 42, " invoice.pdf a/b")
+	if reply := last(); !strings.HasPrefix(reply, "❌ invalid tag") {
+		t.Errorf("unexpected reply for a bad tag %q", reply)
+	}
+	b.handleTagCommand(42, 42, " missing.pdf work")
+	if reply := last(); reply != "❌ File 'missing.pdf' not found" {
+		t.Errorf("unexpected reply for a missing file %q", reply)
+	}
+}
+
+func TestSearchByTag(t *testing.T) {
+	for _, indexed := range []bool{false, true} {
+		b := newTestBot(t)
+		if indexed {
+			b.index = newTestIndex(t)
+		}
+		b.client = &mockTelegramAPI{}
+		for _, rel := range []string{"a.pdf", "b.pdf", "work.txt"} {
+			writeTestFile(t, b, rel, "content")
+		}
+		b.handleTagCommand(42, 42, " a.pdf work")
+		b.handleTagCommand(42, 42, " b.pdf Work home")
+
+		files, err := b.findFiles(42, "TAG:#WORK", ListLimit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, f := range files {
+			names = append(names, f.Name)
+		}
+		slices.Sort(names)
+		if !slices.Equal(names, []string{"a.pdf", "b.pdf"}) {
+			t.Errorf("indexed=%v: expected the tagged files, got %v", indexed, names)
+		}
+
+		b.handleTagCommand(42, 42, " b.pdf -work")
+		if files, err := b.findFiles(42, "tag:work", ListLimit); err != nil || len(files) != 1 || files[0].Name != "a.pdf" {
+			t.Errorf("indexed=%v: expected the untagged file to be gone, got %+v (%v)", indexed, files, err)
+		}
+		if files, err := b.findFiles(42, "tag:none", ListLimit); err != nil || len(files) != 0 {
+			t.Errorf("indexed=%v: expected no match, got %+v (%v)", indexed, files, err)
+		}
+	}
+}
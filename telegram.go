@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// MaxSendRetries bounds how many times a request rejected by flood control is retried.
+	MaxSendRetries = 3
+	// MaxMessageLength is Telegram's limit on the text of a single message.
+	MaxMessageLength = 4096
+	// PublicAPIDownloadLimit is the largest file the public Bot API lets bots
+	// download; getFile fails for anything bigger.
+	PublicAPIDownloadLimit = 20 * 1024 * 1024
+	// DefaultSendConcurrency is how many Send/Request calls may be in flight
+	// at once unless SEND_CONCURRENCY says otherwise.
+	DefaultSendConcurrency = 8
+)
+
+// TelegramAPI is the subset of *tgbotapi.BotAPI used to talk to Telegram.
+type TelegramAPI interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+	GetFileDirectURL(fileID string) (string, error)
+}
+
+// localBotAPI is a *tgbotapi.BotAPI talking to a self-hosted Bot API server
+// (TELEGRAM_API_URL). Its files are downloaded from that server, which has no
+// 20 MB limit, instead of api.telegram.org.
+type localBotAPI struct {
+	*tgbotapi.BotAPI
+	baseURL string
+}
+
+func (a localBotAPI) GetFileDirectURL(fileID string) (string, error) {
+	file, err := a.GetFile(tgbotapi.FileConfig{FileID: fileID})
+	if err != nil {
+		return "", err
+	}
+	return a.baseURL + "/file/bot" + a.Token + "/" + file.FilePath, nil
+}
+
+// pollUpdates long-polls getUpdates until ctx is cancelled. Unlike
+// GetUpdatesChan it decodes message_reaction updates, which Telegram only
+// sends when listed in allowed_updates.
+func (b *Bot) pollUpdates(ctx context.Context) <-chan botUpdate {
+	updates := make(chan botUpdate, 100)
+	go func() {
+		defer close(updates)
+		config := tgbotapi.UpdateConfig{
+			Timeout:        60,
+			AllowedUpdates: []string{"message", "edited_message", "callback_query", "inline_query", "message_reaction"},
+		}
+		if b.storeChannelPosts {
+			config.AllowedUpdates = append(config.AllowedUpdates, "channel_post")
+		}
+		for ctx.Err() == nil {
+			resp, err := b.api.Request(config)
+			var batch []botUpdate
+			if err == nil {
+				batch, err = decodeUpdates(resp.Result)
+			}
+			if err != nil {
+				log.Printf("Failed to get updates, retrying in 3 seconds: %v", err)
+				select {
+				case <-ctx.Done():
+				case <-time.After(3 * time.Second):
+				}
+				continue
+			}
+
+			for _, update := range batch {
+				if update.UpdateID >= config.Offset {
+					config.Offset = update.UpdateID + 1
+				}
+				select {
+				case updates <- update:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return updates
+}
+
+// parseSendConcurrency parses SEND_CONCURRENCY; 0 removes the limit.
+func parseSendConcurrency(s string) (int, error) {
+	if s == "" {
+		return DefaultSendConcurrency, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid SEND_CONCURRENCY %q: expected a number of concurrent sends, 0 for no limit", s)
+	}
+	return n, nil
+}
+
+// retryClient wraps a TelegramAPI and retries calls that Telegram rejects with
+// a 429 "retry after" response, sleeping for the requested duration. At most
+// cap(slots) Send and Request calls run at once; a slot is only held for the
+// duration of one attempt, never while sleeping or across a handler's
+// messages, so nested sends cannot deadlock.
+type retryClient struct {
+	api   TelegramAPI
+	sleep func(time.Duration)
+	slots chan struct{} // nil for no limit
+}
+
+func newRetryClient(api TelegramAPI, concurrency int) *retryClient {
+	c := &retryClient{api: api, sleep: time.Sleep}
+	if concurrency > 0 {
+		c.slots = make(chan struct{}, concurrency)
+	}
+	return c
+}
+
+// acquire waits for a free send slot; the returned function releases it.
+func (c *retryClient) acquire() func() {
+	if c.slots == nil {
+		return func() {}
+	}
+	c.slots <- struct{}{}
+	return func() { <-c.slots }
+}
+
+func (c *retryClient) Send(msg tgbotapi.Chattable) (tgbotapi.Message, error) {
+	var result tgbotapi.Message
+	err := c.withRetry(func() (err error) {
+		defer c.acquire()()
+		result, err = c.api.Send(msg)
+		return err
+	})
+	return result, err
+}
+
+func (c *retryClient) Request(msg tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	var result *tgbotapi.APIResponse
+	err := c.withRetry(func() (err error) {
+		defer c.acquire()()
+		result, err = c.api.Request(msg)
+		return err
+	})
+	return result, err
+}
+
+func (c *retryClient) GetFileDirectURL(fileID string) (string, error) {
+	var result string
+	err := c.withRetry(func() (err error) {
+		result, err = c.api.GetFileDirectURL(fileID)
+		return err
+	})
+	return result, err
+}
+
+func (c *retryClient) withRetry(call func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := call()
+
+		wait, ok := retryAfter(err)
+		if !ok || attempt >= MaxSendRetries {
+			return err
+		}
+
+		log.Printf("Telegram flood control: retrying in %v (attempt %d/%d)", wait, attempt+1, MaxSendRetries)
+		c.sleep(wait)
+	}
+}
+
+// retryAfter reports the wait requested by a Telegram flood-control error.
+func retryAfter(err error) (time.Duration, bool) {
+	var tgErr *tgbotapi.Error
+	if !errors.As(err, &tgErr) || tgErr.RetryAfter <= 0 {
+		return 0, false
+	}
+	return time.Duration(tgErr.RetryAfter) * time.Second, true
+}
+
+// isBlockedError reports whether Telegram refused a message because the recipient
+// blocked the bot or never started a chat with it.
+func isBlockedError(err error) bool {
+	var tgErr *tgbotapi.Error
+	return errors.As(err, &tgErr) && tgErr.Code == 403
+}
+
+// send delivers a message through the flood-control aware client.
+func (b *Bot) send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	return b.client.Send(c)
+}
+
+// request performs an API call through the flood-control aware client.
+func (b *Bot) request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	return b.client.Request(c)
+}
+
+// sendLongMessage sends text split into as many messages as needed to stay under
+// MaxMessageLength, breaking between lines.
+func (b *Bot) sendLongMessage(chatID int64, text string) {
+	for _, part := range splitMessage(text, MaxMessageLength) {
+		b.sendTextMessage(chatID, part)
+	}
+}
+
+// splitMessage splits text into chunks of at most limit bytes, preferring line
+// boundaries. Lines longer than limit are cut at rune boundaries.
+func splitMessage(text string, limit int) []string {
+	var parts []string
+	var current strings.Builder
+
+	flush := func() {
+		if current.Len() > 0 {
+			parts = append(parts, strings.TrimRight(current.String(), "\n"))
+			current.Reset()
+		}
+	}
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if current.Len()+len(line) > limit {
+			flush()
+		}
+		for len(line) > limit {
+			cut := limit
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			parts = append(parts, line[:cut])
+			line = line[cut:]
+		}
+		current.WriteString(line)
+	}
+	flush()
+
+	return parts
+}
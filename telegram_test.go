@@ -0,0 +1,330 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// mockTelegramAPI implements TelegramAPI for testing. When sendErr is set it is
+// consulted before every call and may return an error to inject a failure.
+type mockTelegramAPI struct {
+	mu       sync.Mutex
+	sent     []tgbotapi.Chattable
+	requests []tgbotapi.Chattable
+	calls    int
+	sendErr  func(call int, c tgbotapi.Chattable) error
+	fileURL  string // base URL GetFileDirectURL resolves file IDs against
+	fileErr  error  // returned by GetFileDirectURL when set
+	fileIDs  []string
+}
+
+func (m *mockTelegramAPI) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls++
+	if m.sendErr != nil {
+		if err := m.sendErr(m.calls, c); err != nil {
+			return tgbotapi.Message{}, err
+		}
+	}
+	m.sent = append(m.sent, c)
+	return tgbotapi.Message{MessageID: len(m.sent)}, nil
+}
+
+func (m *mockTelegramAPI) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls++
+	if m.sendErr != nil {
+		if err := m.sendErr(m.calls, c); err != nil {
+			return nil, err
+		}
+	}
+	m.requests = append(m.requests, c)
+	return &tgbotapi.APIResponse{Ok: true}, nil
+}
+
+func (m *mockTelegramAPI) GetFileDirectURL(fileID string) (string, error) {
+	m.mu.Lock()
+	m.fileIDs = append(m.fileIDs, fileID)
+	m.mu.Unlock()
+	if m.fileErr != nil {
+		return "", m.fileErr
+	}
+	if m.fileURL == "" {
+		return "", errors.New("no file server configured")
+	}
+	return m.fileURL + "/" + fileID, nil
+}
+
+// texts returns the text of every sent message.
+func (m *mockTelegramAPI) texts() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var texts []string
+	for _, c := range m.sent {
+		if msg, ok := c.(tgbotapi.MessageConfig); ok {
+			texts = append(texts, msg.Text)
+		}
+	}
+	return texts
+}
+
+func floodError(retryAfter int) error {
+	return &tgbotapi.Error{
+		Code:               429,
+		Message:            "Too Many Requests: retry after 1",
+		ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: retryAfter},
+	}
+}
+
+func TestRetryClientRetriesAfterFloodControl(t *testing.T) {
+	api := &mockTelegramAPI{sendErr: func(call int, _ tgbotapi.Chattable) error {
+		if call <= 2 {
+			return floodError(3)
+		}
+		return nil
+	}}
+
+	var slept []time.Duration
+	client := newRetryClient(api, 0)
+	client.sleep = func(d time.Duration) { slept = append(slept, d) }
+
+	if _, err := client.Send(tgbotapi.NewMessage(1, "hello")); err != nil {
+		t.Fatalf("expected delivery after retries, got %v", err)
+	}
+
+	if texts := api.texts(); len(texts) != 1 || texts[0] != "hello" {
+		t.Errorf("expected message to be delivered once, got %v", texts)
+	}
+	if len(slept) != 2 || slept[0] != 3*time.Second {
+		t.Errorf("expected two 3s waits, got %v", slept)
+	}
+}
+
+func TestRetryClientGivesUpAfterMaxRetries(t *testing.T) {
+	api := &mockTelegramAPI{sendErr: func(int, tgbotapi.Chattable) error { return floodError(1) }}
+	client := newRetryClient(api, 0)
+	client.sleep = func(time.Duration) {}
+
+	if _, err := client.Request(tgbotapi.NewCallback("id", "")); err == nil {
+		t.Fatal("expected error after exhausting retries")
+	}
+	if api.calls != MaxSendRetries+1 {
+		t.Errorf("expected %d attempts, got %d", MaxSendRetries+1, api.calls)
+	}
+}
+
+func TestRetryClientDoesNotRetryOtherErrors(t *testing.T) {
+	api := &mockTelegramAPI{sendErr: func(int, tgbotapi.Chattable) error {
+		return &tgbotapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"}
+	}}
+	client := newRetryClient(api, 0)
+	client.sleep = func(time.Duration) { t.Error("unexpected retry") }
+
+	_, err := client.Send(tgbotapi.NewMessage(1, "hello"))
+	var tgErr *tgbotapi.Error
+	if !errors.As(err, &tgErr) || tgErr.Code != 403 {
+		t.Errorf("expected the 403 error to be returned, got %v", err)
+	}
+	if api.calls != 1 {
+		t.Errorf("expected a single attempt, got %d", api.calls)
+	}
+}
+
+// slowTelegramAPI records how many Send calls run at once. Each call takes
+// delay; failFirst calls fail with flood control first.
+type slowTelegramAPI struct {
+	delay     time.Duration
+	failFirst int32
+
+	calls    atomic.Int32
+	inFlight atomic.Int32
+	peak     atomic.Int32
+}
+
+func (a *slowTelegramAPI) Send(tgbotapi.Chattable) (tgbotapi.Message, error) {
+	n := a.inFlight.Add(1)
+	defer a.inFlight.Add(-1)
+	for {
+		peak := a.peak.Load()
+		if n <= peak || a.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(a.delay)
+	if a.calls.Add(1) <= a.failFirst {
+		return tgbotapi.Message{}, floodError(1)
+	}
+	return tgbotapi.Message{}, nil
+}
+
+func (a *slowTelegramAPI) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	_, err := a.Send(c)
+	return &tgbotapi.APIResponse{Ok: err == nil}, err
+}
+
+func (a *slowTelegramAPI) GetFileDirectURL(string) (string, error) {
+	return "", errors.New("not supported")
+}
+
+func TestRetryClientLimitsConcurrentSends(t *testing.T) {
+	api := &slowTelegramAPI{delay: 5 * time.Millisecond}
+	client := newRetryClient(api, 3)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.Send(tgbotapi.NewMessage(1, "hello")); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if peak := api.peak.Load(); peak > 3 {
+		t.Errorf("expected at most 3 concurrent sends, got %d", peak)
+	}
+	if calls := api.calls.Load(); calls != 20 {
+		t.Errorf("expected every message to be sent, got %d calls", calls)
+	}
+}
+
+func TestRetryClientReleasesSlotWhileWaiting(t *testing.T) {
+	api := &slowTelegramAPI{failFirst: 1}
+	client := newRetryClient(api, 1)
+
+	// The flood-controlled send waits until another send went through, which
+	// needs the only slot
+	other := make(chan error)
+	client.sleep = func(time.Duration) {
+		go func() {
+			_, err := client.Send(tgbotapi.NewMessage(1, "other"))
+			other <- err
+		}()
+		select {
+		case err := <-other:
+			if err != nil {
+				t.Error(err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("the other send was blocked while retrying")
+		}
+	}
+
+	if _, err := client.Send(tgbotapi.NewMessage(1, "hello")); err != nil {
+		t.Fatalf("expected delivery after the retry, got %v", err)
+	}
+	if calls := api.calls.Load(); calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestParseSendConcurrency(t *testing.T) {
+	for in, want := range map[string]int{"": DefaultSendConcurrency, "0": 0, "2": 2} {
+		if got, err := parseSendConcurrency(in); err != nil || got != want {
+			t.Errorf("parseSendConcurrency(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"-1", "many"} {
+		if _, err := parseSendConcurrency(in); err == nil {
+			t.Errorf("expected %q to be rejected", in)
+		}
+	}
+}
+
+func TestLocalBotAPIDownloadsFromItsServer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/botTOKEN/getMe":
+			fmt.Fprint(w, `{"ok":true,"result":{"id":1,"is_bot":true,"username":"test_bot"}}`)
+		case "/botTOKEN/getFile":
+			fmt.Fprint(w, `{"ok":true,"result":{"file_id":"big","file_path":"videos/file_1.mp4"}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	bot, err := tgbotapi.NewBotAPIWithAPIEndpoint("TOKEN", srv.URL+"/bot%s/%s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url, err := localBotAPI{BotAPI: bot, baseURL: srv.URL}.GetFileDirectURL("big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := srv.URL + "/file/botTOKEN/videos/file_1.mp4"; url != want {
+		t.Errorf("expected %s, got %s", want, url)
+	}
+}
+
+func TestSplitMessage(t *testing.T) {
+	text := strings.Repeat("line of text\n", 10)
+
+	parts := splitMessage(text, 30)
+	for _, p := range parts {
+		if len(p) > 30 {
+			t.Errorf("part exceeds limit: %q", p)
+		}
+		if strings.HasPrefix(p, "text") {
+			t.Errorf("expected splits at line boundaries, got %q", p)
+		}
+	}
+	if joined := strings.Join(parts, "\n"); joined != strings.TrimRight(text, "\n") {
+		t.Errorf("expected no content to be lost, got %q", joined)
+	}
+
+	long := strings.Repeat("я", 20)
+	for _, p := range splitMessage(long, 7) {
+		if !utf8.ValidString(p) || len(p) > 7 {
+			t.Errorf("expected long lines to be cut at rune boundaries, got %q", p)
+		}
+	}
+}
+
+func TestSendLongMessageStaysUnderLimit(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+
+	// Multi-byte lines, one of them longer than a whole message
+	var lines []string
+	for i := 0; i < 300; i++ {
+		lines = append(lines, fmt.Sprintf("%03d 📄 файл-%d.txt", i, i))
+	}
+	lines = append(lines, strings.Repeat("ü", MaxMessageLength))
+	text := strings.Join(lines, "\n")
+
+	b.sendLongMessage(42, text)
+
+	parts := api.texts()
+	if len(parts) < 3 {
+		t.Fatalf("expected the text to be split, got %d messages", len(parts))
+	}
+	for i, p := range parts {
+		if len(p) > MaxMessageLength || !utf8.ValidString(p) {
+			t.Errorf("message %d is %d bytes or cuts a rune", i, len(p))
+		}
+	}
+	if got := strings.Join(parts, ""); strings.ReplaceAll(got, "\n", "") != strings.ReplaceAll(text, "\n", "") {
+		t.Error("expected the messages to carry the whole text in order")
+	}
+	for i, p := range parts {
+		if !strings.HasPrefix(p, "ü") && !strings.Contains(p[:10], " 📄") {
+			t.Errorf("expected message %d to start at a line, got %q", i, p[:10])
+		}
+	}
+}
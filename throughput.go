@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// minTransferTime is the shortest duration throughput is computed over;
+// shorter (or unmeasurably fast) transfers are rounded up to it.
+const minTransferTime = time.Millisecond
+
+// throughputMBps returns the rate of size bytes transferred in d, in MB/s.
+func throughputMBps(size int64, d time.Duration) float64 {
+	if d < minTransferTime {
+		d = minTransferTime
+	}
+	return float64(size) / (1024 * 1024) / d.Seconds()
+}
+
+// throughputBuckets are the upper bounds, in MB/s, of the histogram buckets;
+// a last bucket holds everything faster.
+var throughputBuckets = []float64{0.5, 1, 2, 5, 10, 20, 50}
+
+// throughputHistogram counts downloads by throughput, for /admin status. A
+// nil value records nothing.
+type throughputHistogram struct {
+	counts [8]atomic.Int64 // len(throughputBuckets) + 1
+}
+
+func newThroughputHistogram() *throughputHistogram {
+	return &throughputHistogram{}
+}
+
+// Observe records one download at mbps MB/s.
+func (h *throughputHistogram) Observe(mbps float64) {
+	if h == nil {
+		return
+	}
+	i := 0
+	for i < len(throughputBuckets) && mbps > throughputBuckets[i] {
+		i++
+	}
+	h.counts[i].Add(1)
+}
+
+// String lists the non-empty buckets, e.g. "≤1: 3, 5–10: 1".
+func (h *throughputHistogram) String() string {
+	if h == nil {
+		return ""
+	}
+	var parts []string
+	for i := range h.counts {
+		n := h.counts[i].Load()
+		if n == 0 {
+			continue
+		}
+		var label string
+		switch {
+		case i == 0:
+			label = fmt.Sprintf("≤%g", throughputBuckets[0])
+		case i == len(throughputBuckets):
+			label = fmt.Sprintf(">%g", throughputBuckets[i-1])
+		default:
+			label = fmt.Sprintf("%g–%g", throughputBuckets[i-1], throughputBuckets[i])
+		}
+		parts = append(parts, fmt.Sprintf("%s: %d", label, n))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestThroughputMBps(t *testing.T) {
+	cases := []struct {
+		size int64
+		d    time.Duration
+		want float64
+	}{
+		{10 << 20, 2 * time.Second, 5},
+		{1 << 20, 500 * time.Millisecond, 2},
+		{0, time.Second, 0},
+		// Too fast to measure: computed over minTransferTime instead of dividing by zero
+		{1 << 20, 0, 1000},
+		{1 << 20, -time.Second, 1000},
+	}
+	for _, c := range cases {
+		got := throughputMBps(c.size, c.d)
+		if math.IsInf(got, 0) || math.IsNaN(got) || math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("throughputMBps(%d, %s) = %v, want %v", c.size, c.d, got, c.want)
+		}
+	}
+}
+
+func TestThroughputHistogram(t *testing.T) {
+	h := newThroughputHistogram()
+	for _, mbps := range []float64{0.1, 0.5, 3, 4.9, 1000} {
+		h.Observe(mbps)
+	}
+	if got := h.String(); got != "≤0.5: 2, 2–5: 2, >50: 1" {
+		t.Errorf("unexpected histogram %q", got)
+	}
+
+	var unset *throughputHistogram
+	unset.Observe(1)
+	if unset.String() != "" {
+		t.Error("expected a nil histogram to stay empty")
+	}
+}
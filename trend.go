@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// historyFileName is the hidden JSON-lines file inside the storage root that
+// holds one storage snapshot per day, for /admin trend.
+const historyFileName = ".history.jsonl"
+
+// snapshotDateLayout is the layout of storageSnapshot.Date.
+const snapshotDateLayout = "2006-01-02"
+
+// trendPeriods are the periods, in days, /admin trend reports growth over.
+var trendPeriods = []int{7, 30}
+
+// storageSnapshot is the storage total on one day.
+type storageSnapshot struct {
+	Date  string `json:"date"`
+	Files int    `json:"files"`
+	Bytes int64  `json:"bytes"`
+}
+
+// readStorageHistory reads the snapshots at path, oldest first. A missing
+// file is an empty history; unreadable lines are skipped.
+func readStorageHistory(path string) ([]storageSnapshot, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage history: %w", err)
+	}
+	defer f.Close()
+
+	var history []storageSnapshot
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var s storageSnapshot
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil || s.Date == "" {
+			continue
+		}
+		history = append(history, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read storage history: %w", err)
+	}
+	return history, nil
+}
+
+// recordStorageSnapshot appends the storage total of now's day to the
+// history, unless that day is recorded already. Only the janitor calls it, so
+// the walk over storage happens at most once a day.
+func (b *Bot) recordStorageSnapshot(now time.Time) error {
+	today := now.Format(snapshotDateLayout)
+	if b.lastSnapshot == today {
+		return nil
+	}
+	path := filepath.Join(b.storagePath, historyFileName)
+	if b.lastSnapshot == "" {
+		history, err := readStorageHistory(path)
+		if err != nil {
+			return err
+		}
+		if len(history) > 0 && history[len(history)-1].Date == today {
+			b.lastSnapshot = today
+			return nil
+		}
+	}
+
+	usage, err := diskUsage(b.dataRoots())
+	if err != nil {
+		return err
+	}
+	snapshot := storageSnapshot{Date: today}
+	for _, u := range usage {
+		snapshot.Files += u.Files
+		snapshot.Bytes += u.Size
+	}
+	line, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to encode storage snapshot: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open storage history: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write storage snapshot: %w", err)
+	}
+	b.lastSnapshot = today
+	return nil
+}
+
+// snapshotBefore returns the newest snapshot of history dated at least days
+// before latest and how many days lie between the two, or false when the
+// history does not reach back that far.
+func snapshotBefore(history []storageSnapshot, latest storageSnapshot, days int) (storageSnapshot, int, bool) {
+	end, err := time.Parse(snapshotDateLayout, latest.Date)
+	if err != nil {
+		return storageSnapshot{}, 0, false
+	}
+	cutoff := end.AddDate(0, 0, -days)
+	for i := len(history) - 1; i >= 0; i-- {
+		date, err := time.Parse(snapshotDateLayout, history[i].Date)
+		if err == nil && !date.After(cutoff) {
+			return history[i], int(end.Sub(date).Hours()/24 + 0.5), true
+		}
+	}
+	return storageSnapshot{}, 0, false
+}
+
+// signedSize formats a size change with its sign.
+func signedSize(delta int64) string {
+	if delta < 0 {
+		return "-" + formatSize(-delta)
+	}
+	return "+" + formatSize(delta)
+}
+
+// formatStorageTrend reports the growth of storage over each of trendPeriods,
+// measured from the newest snapshot in history.
+func formatStorageTrend(history []storageSnapshot) string {
+	if len(history) == 0 {
+		return "📈 Not enough data yet: storage is recorded once a day."
+	}
+	latest := history[len(history)-1]
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📈 Storage trend (%s: %s in %d files)\n", latest.Date, formatSize(latest.Bytes), latest.Files))
+	for _, days := range trendPeriods {
+		base, span, ok := snapshotBefore(history, latest, days)
+		if !ok {
+			sb.WriteString(fmt.Sprintf("\n%d days: not enough data yet", days))
+			continue
+		}
+		delta := latest.Bytes - base.Bytes
+		line := fmt.Sprintf("\n%d days: %s, %+d files", days, signedSize(delta), latest.Files-base.Files)
+		if base.Bytes > 0 {
+			line += fmt.Sprintf(" (%+.1f%%)", float64(delta)*100/float64(base.Bytes))
+		}
+		line += fmt.Sprintf(", about %s a day", signedSize(delta/int64(span)))
+		sb.WriteString(line)
+	}
+	return sb.String()
+}
+
+// handleAdminTrend shows how storage grew over the last week and month.
+func (b *Bot) handleAdminTrend(chatID int64) {
+	if !b.requireLocalStorage(chatID) {
+		return
+	}
+	history, err := readStorageHistory(filepath.Join(b.storagePath, historyFileName))
+	if err != nil {
+		log.Printf("Error reading storage history: %v", err)
+		b.sendTextMessage(chatID, "❌ Failed to read the storage history.")
+		return
+	}
+	b.sendTextMessage(chatID, formatStorageTrend(history))
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStorageTrendFromHistory(t *testing.T) {
+	b := newTestBot(t)
+	path := filepath.Join(b.storagePath, historyFileName)
+	history := `{"date":"2024-05-01","files":100,"bytes":1048576000}
+{"date":"2024-05-20","files":150,"bytes":2097152000}
+not json
+{"date":"2024-05-24","files":160,"bytes":2202009600}
+{"date":"2024-05-31","files":200,"bytes":2936012800}
+`
+	if err := os.WriteFile(path, []byte(history), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshots, err := readStorageHistory(path)
+	if err != nil || len(snapshots) != 4 {
+		t.Fatalf("expected 4 snapshots, got %+v (%v)", snapshots, err)
+	}
+	text := formatStorageTrend(snapshots)
+	for _, want := range []string{
+		"📈 Storage trend (2024-05-31: 2.7 GB in 200 files)",
+		"7 days: +700.0 MB, +40 files (+33.3%), about +100.0 MB a day",
+		"30 days: +1.8 GB, +100 files (+180.0%), about +60.0 MB a day",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected %q in:\n%s", want, text)
+		}
+	}
+}
+
+func TestStorageTrendNotEnoughData(t *testing.T) {
+	if text := formatStorageTrend(nil); !strings.Contains(text, "Not enough data yet") {
+		t.Errorf("unexpected text for no history: %q", text)
+	}
+
+	text := formatStorageTrend([]storageSnapshot{
+		{Date: "2024-05-28", Files: 10, Bytes: 1000},
+		{Date: "2024-05-31", Files: 5, Bytes: 400},
+	})
+	if !strings.Contains(text, "7 days: not enough data yet") || !strings.Contains(text, "30 days: not enough data yet") {
+		t.Errorf("expected both periods to lack data, got:\n%s", text)
+	}
+}
+
+func TestRecordStorageSnapshotOncePerDay(t *testing.T) {
+	b := newTestBot(t)
+	writeTestFile(t, b, "a.txt", "hello")
+	day := time.Date(2024, 5, 31, 9, 0, 0, 0, time.Local)
+
+	if err := b.recordStorageSnapshot(day); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, b, "b.txt", "world!")
+	if err := b.recordStorageSnapshot(day.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	// A restart must not record the same day again
+	b.lastSnapshot = ""
+	if err := b.recordStorageSnapshot(day.Add(2 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.recordStorageSnapshot(day.AddDate(0, 0, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := readStorageHistory(filepath.Join(b.storagePath, historyFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []storageSnapshot{{Date: "2024-05-31", Files: 1, Bytes: 5}, {Date: "2024-06-01", Files: 2, Bytes: 11}}
+	if len(history) != 2 || history[0] != want[0] || history[1] != want[1] {
+		t.Errorf("history = %+v, want %+v", history, want)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// errPrivateAddress reports a link resolving to a loopback, private or
+// otherwise internal address while ALLOW_PRIVATE_FETCH is off.
+var errPrivateAddress = errors.New("address is private")
+
+// fetchTooLarge is the error for a link whose content exceeds limit.
+func fetchTooLarge(limit int64) *BotError {
+	return &BotError{
+		Category: CategoryTooLarge,
+		Message:  fmt.Sprintf("❌ The file at that link is too large. Maximum size is %s", formatLimit(limit)),
+		Err:      fmt.Errorf("content exceeds the %d byte limit", limit),
+	}
+}
+
+// fetchError classifies a failed request for a link.
+func fetchError(err error) error {
+	if errors.Is(err, errPrivateAddress) {
+		be := newBotError(CategoryForbidden, err)
+		be.Message = "🚫 Links to local or private network addresses are not allowed."
+		return be
+	}
+	if classified := classifyError(err); classified != err {
+		return classified
+	}
+	return newBotError(CategoryNetwork, err)
+}
+
+// cgnatNet is the carrier-grade NAT range, not covered by net.IP.IsPrivate.
+var cgnatNet = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// isPrivateIP reports whether ip is not a public unicast address.
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || cgnatNet.Contains(ip)
+}
+
+// rejectPrivateDial is a net.Dialer Control refusing connections to private
+// addresses. It runs after name resolution, for every connection including
+// redirects, so a hostname cannot be pointed at an internal address.
+func rejectPrivateDial(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || isPrivateIP(ip) {
+		return fmt.Errorf("%w: %s", errPrivateAddress, host)
+	}
+	return nil
+}
+
+// newFetchClient builds the client fetching links sent by users. Unlike the
+// download client it never uses a proxy, as the check of the dialed address
+// would otherwise only see the proxy.
+func newFetchClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+	if !allowPrivate {
+		dialer.Control = rejectPrivateDial
+	}
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          10,
+	}
+	return &http.Client{Transport: transport}
+}
+
+// messageURL returns the link of a message consisting of a single http(s) URL.
+func messageURL(text string) (*url.URL, bool) {
+	text = strings.TrimSpace(text)
+	if strings.ContainsAny(text, " \t\n") {
+		return nil, false
+	}
+	u, err := url.Parse(text)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, false
+	}
+	return u, true
+}
+
+// isMessageURL reports whether text is a single http(s) URL.
+func isMessageURL(text string) bool {
+	_, ok := messageURL(text)
+	return ok
+}
+
+// fetchFileName derives the stored name from Content-Disposition, else from
+// the last element of the URL path, else a generated one.
+func fetchFileName(u *url.URL, header http.Header, now time.Time) string {
+	candidates := []string{u.Path}
+	if _, params, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil {
+		candidates = append([]string{params["filename"]}, candidates...)
+	}
+	for _, c := range candidates {
+		if base := path.Base(c); base != "." && base != "/" {
+			if name := sanitizeFileName(base); name != "" {
+				return name
+			}
+		}
+	}
+	return fmt.Sprintf("download_%d", now.Unix())
+}
+
+// fetchURL downloads u, sent in chatID, and stores it as a document of owner. A HEAD request
+// rejects content declared larger than the document limit up front; content
+// turning out larger while downloading is not kept. Failures are
+// returned as a *BotError where the cause is known.
+func (b *Bot) fetchURL(u *url.URL, chatID, owner int64) (FileMeta, error) {
+	limit, _ := b.sizeLimits.limit("documents")
+
+	if resp, err := b.fetchClient.Head(u.String()); err != nil {
+		if errors.Is(err, errPrivateAddress) {
+			return FileMeta{}, fetchError(err)
+		}
+		log.Printf("HEAD %s failed, trying GET: %v", u.Redacted(), err)
+	} else {
+		resp.Body.Close()
+		if resp.ContentLength > limit {
+			return FileMeta{}, fetchTooLarge(limit)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		cancel()
+		return FileMeta{}, err
+	}
+	resp, err := b.fetchClient.Do(req)
+	if err != nil {
+		cancel()
+		return FileMeta{}, fetchError(fmt.Errorf("failed to fetch link: %w", err))
+	}
+	body := newIdleTimeoutReader(resp.Body, b.httpTimeout, cancel)
+	defer body.Close()
+	if resp.StatusCode != http.StatusOK {
+		be := newBotError(CategoryNetwork, fmt.Errorf("failed to fetch link: unexpected status %s", resp.Status))
+		be.Message = fmt.Sprintf("❌ The link could not be downloaded (%s).", resp.Status)
+		return FileMeta{}, be
+	}
+	if resp.ContentLength > limit {
+		return FileMeta{}, fetchTooLarge(limit)
+	}
+
+	mimeType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	name := b.organizedName("documents", fetchFileName(resp.Request.URL, resp.Header, time.Now()), time.Now())
+	saved, err := b.saveFileChecked(io.LimitReader(body, limit+1), "documents", name, mimeType, chatID, owner, func(m *FileMeta) error {
+		if m.Size > limit {
+			return fetchTooLarge(limit)
+		}
+		return nil
+	})
+	if err != nil {
+		return saved, classifyError(err)
+	}
+	return saved, nil
+}
+
+// handleURLMessage stores the file behind a link sent as a message.
+func (b *Bot) handleURLMessage(chatID, userID int64, text string) {
+	u, ok := messageURL(text)
+	switch {
+	case !ok:
+		return
+	case b.isPaused():
+		b.sendTextMessage(chatID, "⏸ Uploads are temporarily unavailable due to maintenance. Please try again later.")
+		return
+	case !b.isMediaTypeAllowed(userID, "documents"):
+		b.sendTextMessage(chatID, fmt.Sprintf("🚫 You are not allowed to send documents. Permitted: %s", b.describeAllowedTypes(userID)))
+		return
+	case !b.isUserTrusted(userID):
+		b.sendTextMessage(chatID, "🚫 Only trusted users can store files from links. Please send the file itself.")
+		return
+	}
+
+	saved, err := b.fetchURL(u, chatID, userID)
+	if err != nil {
+		log.Printf("Error fetching %s for user %d (%s): %v", u.Redacted(), userID, errorCategory(err), err)
+		b.sendTextMessage(chatID, b.failureReply(userID, err, "❌ Failed to fetch the link."))
+		return
+	}
+	log.Printf("User %d stored %s from %s", userID, saved.Path, u.Redacted())
+	b.replySaved(chatID, userID, saved)
+}
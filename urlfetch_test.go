@@ -0,0 +1,146 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsPrivateIP(t *testing.T) {
+	for addr, private := range map[string]bool{
+		"127.0.0.1":       true,
+		"::1":             true,
+		"10.1.2.3":        true,
+		"172.16.0.1":      true,
+		"192.168.1.34":    true,
+		"169.254.169.254": true,
+		"100.64.0.1":      true,
+		"0.0.0.0":         true,
+		"fe80::1":         true,
+		"fd00::1":         true,
+		"::ffff:10.0.0.1": true,
+		"8.8.8.8":         false,
+		"2606:4700::1111": false,
+		"100.128.0.1":     false,
+	} {
+		if got := isPrivateIP(net.ParseIP(addr)); got != private {
+			t.Errorf("isPrivateIP(%s) = %v, want %v", addr, got, private)
+		}
+	}
+}
+
+func TestMessageURL(t *testing.T) {
+	for text, want := range map[string]bool{
+		"https://example.com/a.pdf":   true,
+		" http://example.com/ ":       true,
+		"ftp://example.com/a.pdf":     false,
+		"see https://example.com/x":   false,
+		"https://":                    false,
+		"example.com/a.pdf":           false,
+		"https://example.com/a b.pdf": false,
+	} {
+		if got := isMessageURL(text); got != want {
+			t.Errorf("isMessageURL(%q) = %v, want %v", text, got, want)
+		}
+	}
+}
+
+func TestFetchFileName(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	cases := []struct {
+		url         string
+		disposition string
+		want        string
+	}{
+		{"https://example.com/files/report.pdf?x=1", "", "report.pdf"},
+		{"https://example.com/files/my%20notes.txt", "", "my notes.txt"},
+		{"https://example.com/download?id=7", `attachment; filename="invoice 7.pdf"`, "invoice 7.pdf"},
+		{"https://example.com/download", `attachment; filename*=UTF-8''%D0%BE%D1%82%D1%87%D1%91%D1%82.pdf`, "отчёт.pdf"},
+		{"https://example.com/d", `attachment; filename="../../etc/passwd"`, "passwd"},
+		{"https://example.com/", "", "download_1700000000"},
+		{"https://example.com", "inline", "download_1700000000"},
+	}
+	for _, c := range cases {
+		u, _ := url.Parse(c.url)
+		header := http.Header{}
+		if c.disposition != "" {
+			header.Set("Content-Disposition", c.disposition)
+		}
+		if got := fetchFileName(u, header, now); got != c.want {
+			t.Errorf("fetchFileName(%s, %q) = %q, want %q", c.url, c.disposition, got, c.want)
+		}
+	}
+}
+
+func TestFetchRejectsPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal secret"))
+	}))
+	defer srv.Close()
+
+	b := newDownloadTestBot(t, 5*time.Second)
+	api := &mockTelegramAPI{}
+	b.client = api
+	b.fetchClient = newFetchClient(5*time.Second, false)
+
+	b.handleURLMessage(42, 42, srv.URL+"/secret.txt")
+	if texts := api.texts(); len(texts) != 1 || texts[0] != "🚫 Links to local or private network addresses are not allowed." {
+		t.Fatalf("unexpected replies %q", texts)
+	}
+	if _, err := os.Stat(b.localPath("secret.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing stored, got %v", err)
+	}
+
+	// ALLOW_PRIVATE_FETCH lifts the guard
+	b.fetchClient = newFetchClient(5*time.Second, true)
+	b.handleURLMessage(42, 42, srv.URL+"/secret.txt")
+	if texts := api.texts(); texts[len(texts)-1] != "✅ Saved 'secret.txt' (15 B)" {
+		t.Errorf("unexpected reply %q", texts[len(texts)-1])
+	}
+}
+
+func TestFetchEnforcesSizeLimit(t *testing.T) {
+	gets := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			gets++
+		}
+		switch r.URL.Path {
+		case "/declared.bin":
+			w.Header().Set("Content-Length", "2048")
+			if r.Method == http.MethodGet {
+				w.Write(make([]byte, 2048))
+			}
+		case "/streamed.bin":
+			// No Content-Length: only the download shows the size
+			w.(http.Flusher).Flush()
+			w.Write([]byte(strings.Repeat("x", 2048)))
+		}
+	}))
+	defer srv.Close()
+
+	b := newDownloadTestBot(t, 5*time.Second)
+	b.sizeLimits = sizeLimits{perType: map[string]int64{"documents": 1024}}
+	api := &mockTelegramAPI{}
+	b.client = api
+	b.fetchClient = newFetchClient(5*time.Second, true)
+
+	b.handleURLMessage(42, 42, srv.URL+"/declared.bin")
+	if gets != 0 {
+		t.Errorf("expected the HEAD request to stop the download, got %d GETs", gets)
+	}
+	b.handleURLMessage(42, 42, srv.URL+"/streamed.bin")
+
+	texts := api.texts()
+	if len(texts) != 2 || texts[0] != "❌ The file at that link is too large. Maximum size is 1.0 KB" || texts[1] != texts[0] {
+		t.Errorf("unexpected replies %q", texts)
+	}
+	if _, err := os.Stat(b.localPath("streamed.bin")); !os.IsNotExist(err) {
+		t.Errorf("expected the oversized download to be deleted, got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// UsagePageSize is how many directories one page of /admin usage lists.
+const UsagePageSize = 20
+
+// userUsage is the disk usage of one top-level storage directory, which is a
+// user's directory with PER_USER_DIRS. Dir is "" for files at the top level.
+type userUsage struct {
+	Dir   string
+	Files int
+	Size  int64
+}
+
+// diskUsage sums the files under each top-level directory of roots, largest
+// first. Directories of the same name in several roots are added up.
+func diskUsage(roots []string) ([]userUsage, error) {
+	byDir := make(map[string]*userUsage)
+	for _, root := range roots {
+		err := walkStoredFiles(root, func(rel, _ string, info os.FileInfo) error {
+			dir, _, found := strings.Cut(rel, "/")
+			if !found {
+				dir = ""
+			}
+			u := byDir[dir]
+			if u == nil {
+				u = &userUsage{Dir: dir}
+				byDir[dir] = u
+			}
+			u.Files++
+			u.Size += info.Size()
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to walk storage directory: %w", err)
+		}
+	}
+
+	usage := make([]userUsage, 0, len(byDir))
+	for _, u := range byDir {
+		usage = append(usage, *u)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Size != usage[j].Size {
+			return usage[i].Size > usage[j].Size
+		}
+		return usage[i].Dir < usage[j].Dir
+	})
+	return usage, nil
+}
+
+// label names a usage row: the user for numeric directories, else the folder.
+func (u userUsage) label() string {
+	switch _, err := strconv.ParseInt(u.Dir, 10, 64); {
+	case u.Dir == "":
+		return "📄 (top level)"
+	case err == nil:
+		return "👤 " + u.Dir
+	}
+	return "📁 " + u.Dir
+}
+
+// formatUsagePage renders page (1-based) of usage with the grand total.
+func formatUsagePage(usage []userUsage, page int) string {
+	var total userUsage
+	for _, u := range usage {
+		total.Files += u.Files
+		total.Size += u.Size
+	}
+	pages := max(1, (len(usage)+UsagePageSize-1)/UsagePageSize)
+	start := (page - 1) * UsagePageSize
+	end := min(start+UsagePageSize, len(usage))
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("💾 Disk usage by directory (page %d/%d):\n\n", page, pages))
+	for i, u := range usage[start:end] {
+		sb.WriteString(fmt.Sprintf("%d. %s — %s in %d files\n", start+i+1, u.label(), formatSize(u.Size), u.Files))
+	}
+	sb.WriteString(fmt.Sprintf("\nTotal: %s in %d files across %d directories", formatSize(total.Size), total.Files, len(usage)))
+	if page < pages {
+		sb.WriteString(fmt.Sprintf("\nNext page: /admin usage %d", page+1))
+	}
+	return sb.String()
+}
+
+// handleAdminUsage lists the storage used by every top-level directory.
+func (b *Bot) handleAdminUsage(chatID int64, args []string) {
+	page := 1
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
+			b.sendTextMessage(chatID, "Usage: /admin usage [page]")
+			return
+		}
+		page = n
+	}
+
+	if !b.requireLocalStorage(chatID) {
+		return
+	}
+
+	usage, err := diskUsage(b.dataRoots())
+	if err != nil {
+		log.Printf("Error computing disk usage: %v", err)
+		b.sendTextMessage(chatID, "Failed to compute disk usage.")
+		return
+	}
+	if len(usage) == 0 {
+		b.sendTextMessage(chatID, "📁 No files stored yet.")
+		return
+	}
+	if pages := (len(usage) + UsagePageSize - 1) / UsagePageSize; page > pages {
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ There are only %d pages", pages))
+		return
+	}
+
+	b.sendLongMessage(chatID, formatUsagePage(usage, page))
+}
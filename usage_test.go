@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDiskUsagePerUser(t *testing.T) {
+	b := newTestBot(t)
+	b.perUserDirs = true
+	writeTestFile(t, b, "42/report.pdf", strings.Repeat("a", 3000))
+	writeTestFile(t, b, "42/shared/7/photo.jpg", strings.Repeat("b", 1000))
+	writeTestFile(t, b, "7/notes.txt", strings.Repeat("c", 5000))
+	writeTestFile(t, b, "100/a.txt", "x")
+	writeTestFile(t, b, "100/b.txt", "y")
+	writeTestFile(t, b, "loose.txt", "zz")
+	writeTestFile(t, b, ".users.json", strings.Repeat("{}", 10000)) // hidden, not counted
+
+	usage, err := diskUsage(b.dataRoots())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []userUsage{
+		{Dir: "7", Files: 1, Size: 5000},
+		{Dir: "42", Files: 2, Size: 4000},
+		{Dir: "", Files: 1, Size: 2},
+		{Dir: "100", Files: 2, Size: 2},
+	}
+	if fmt.Sprint(usage) != fmt.Sprint(want) {
+		t.Errorf("diskUsage = %+v, want %+v", usage, want)
+	}
+
+	text := formatUsagePage(usage, 1)
+	if !strings.Contains(text, "1. 👤 7 — 4.9 KB in 1 files") || !strings.Contains(text, "Total: 8.8 KB in 6 files across 4 directories") {
+		t.Errorf("unexpected usage text:\n%s", text)
+	}
+}
+
+func TestAdminUsagePaginates(t *testing.T) {
+	b := newTestBot(t)
+	b.adminUsers[1] = true
+	api := &mockTelegramAPI{}
+	b.client = api
+	for i := 1; i <= UsagePageSize+5; i++ {
+		writeTestFile(t, b, fmt.Sprintf("%d/file.txt", i), strings.Repeat("x", i))
+	}
+
+	b.handleAdminUsage(1, nil)
+	b.handleAdminUsage(1, []string{"2"})
+	b.handleAdminUsage(1, []string{"3"})
+
+	texts := api.texts()
+	if len(texts) != 3 {
+		t.Fatalf("expected three replies, got %q", texts)
+	}
+	if !strings.Contains(texts[0], "page 1/2") || !strings.HasPrefix(strings.Split(texts[0], "\n")[2], "1. 👤 25 ") ||
+		!strings.Contains(texts[0], "Next page: /admin usage 2") {
+		t.Errorf("unexpected first page:\n%s", texts[0])
+	}
+	if !strings.Contains(texts[1], "21. 👤 5 ") || !strings.Contains(texts[1], "25. 👤 1 ") || strings.Contains(texts[1], "Next page") {
+		t.Errorf("unexpected second page:\n%s", texts[1])
+	}
+	if texts[2] != "❌ There are only 2 pages" {
+		t.Errorf("unexpected reply %q", texts[2])
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// MaxUsersImportSize caps the document /admin import reads.
+const MaxUsersImportSize = 1 << 20
+
+// usersExport is the access configuration exchanged by /admin export and
+// /admin import.
+type usersExport struct {
+	AllowedUsers []int64 `json:"allowed_users"`
+	AdminUsers   []int64 `json:"admin_users"`
+}
+
+// parseUsersExport decodes and validates an exported access configuration.
+// Unknown fields, trailing data and non-positive IDs are rejected.
+func parseUsersExport(data []byte) (usersExport, error) {
+	var export usersExport
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&export); err != nil {
+		return usersExport{}, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if dec.More() {
+		return usersExport{}, errors.New("invalid JSON: unexpected data after the document")
+	}
+	if export.AllowedUsers == nil && export.AdminUsers == nil {
+		return usersExport{}, errors.New(`expected "allowed_users" and/or "admin_users"`)
+	}
+	for _, ids := range [][]int64{export.AllowedUsers, export.AdminUsers} {
+		for _, id := range ids {
+			if id <= 0 {
+				return usersExport{}, fmt.Errorf("invalid user ID %d", id)
+			}
+		}
+	}
+	return export, nil
+}
+
+// importUsers merges export into the current users and returns how many
+// allowed users and admins were new. Admins are persisted like /admin promote.
+func (b *Bot) importUsers(export usersExport) (allowed, admins int, err error) {
+	for _, id := range export.AllowedUsers {
+		if b.addUser(id) {
+			allowed++
+		}
+	}
+	for _, id := range export.AdminUsers {
+		if b.isUserAdmin(id) {
+			continue
+		}
+		if err := b.setAdmin(id, true); err != nil {
+			return allowed, admins, err
+		}
+		admins++
+	}
+	return allowed, admins, nil
+}
+
+// handleAdminExport sends the allowed and admin user IDs as a JSON document.
+func (b *Bot) handleAdminExport(chatID int64) {
+	data, err := json.MarshalIndent(usersExport{
+		AllowedUsers: b.allowedUserIDs(),
+		AdminUsers:   b.adminIDs(),
+	}, "", "  ")
+	if err != nil {
+		log.Printf("Error encoding users: %v", err)
+		b.sendTextMessage(chatID, "❌ Failed to export the users.")
+		return
+	}
+
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("users_%s.json", time.Now().Format("20060102")),
+		Bytes: append(data, '\n'),
+	})
+	doc.Caption = "👥 Allowed and admin users. Reply to this file with /admin import on another instance to merge them in."
+	if _, err := b.send(doc); err != nil {
+		log.Printf("Failed to send users export: %v", err)
+		b.sendTextMessage(chatID, "❌ Failed to send the export.")
+	}
+}
+
+// handleAdminImport merges the users of the JSON document message replies to.
+func (b *Bot) handleAdminImport(message *tgbotapi.Message, chatID, adminID int64) {
+	var doc *tgbotapi.Document
+	if message.ReplyToMessage != nil {
+		doc = message.ReplyToMessage.Document
+	}
+	switch {
+	case doc == nil:
+		b.sendTextMessage(chatID, "Usage: reply to an exported users JSON document with /admin import")
+		return
+	case doc.FileSize > MaxUsersImportSize:
+		b.sendTextMessage(chatID, "❌ That document is too large to be a users export.")
+		return
+	}
+
+	data, err := b.fetchTelegramFile(doc.FileID, MaxUsersImportSize)
+	if err != nil {
+		log.Printf("Error downloading users import: %v", err)
+		b.sendTextMessage(chatID, b.failureReply(adminID, err, "❌ Failed to download the document."))
+		return
+	}
+	export, err := parseUsersExport(data)
+	if err != nil {
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ Not a valid users export: %v", err))
+		return
+	}
+
+	allowed, admins, err := b.importUsers(export)
+	if err != nil {
+		log.Printf("Error saving imported admins: %v", err)
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ Import stopped after adding %d users and %d admins: failed to save the admin change.", allowed, admins))
+		return
+	}
+	log.Printf("Admin %d imported %d allowed users and %d admins", adminID, allowed, admins)
+	b.sendTextMessage(chatID, fmt.Sprintf("✅ Imported %d new allowed users and %d new admins (%d and %d already present).",
+		allowed, admins, len(export.AllowedUsers)-allowed, len(export.AdminUsers)-admins))
+}
+
+// fetchTelegramFile downloads a file sent to the bot into memory, failing if
+// it is larger than limit.
+func (b *Bot) fetchTelegramFile(fileID string, limit int64) ([]byte, error) {
+	fileURL, err := b.client.GetFileDirectURL(fileID)
+	if err != nil {
+		return nil, classifyError(fmt.Errorf("failed to get file info: %w", err))
+	}
+	body, err := b.download(fileURL)
+	if err != nil {
+		return nil, newBotError(CategoryNetwork, err)
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return nil, newBotError(CategoryNetwork, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, newBotError(CategoryTooLarge, fmt.Errorf("file exceeds %d bytes", limit))
+	}
+	return data, nil
+}
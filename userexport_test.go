@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// importReply is an /admin import message replying to the document fileID.
+func importReply(fileID string) *tgbotapi.Message {
+	return &tgbotapi.Message{
+		Text:           "/admin import",
+		ReplyToMessage: &tgbotapi.Message{Document: &tgbotapi.Document{FileID: fileID, FileName: "users.json"}},
+	}
+}
+
+func TestAdminExportImportRoundTrip(t *testing.T) {
+	source := newTestBot(t)
+	sourceAPI := &mockTelegramAPI{}
+	source.client = sourceAPI
+	source.allowedUsers = map[int64]bool{1: true, 2: true}
+	source.adminUsers = map[int64]bool{1: true}
+
+	source.handleAdminExport(1)
+	if len(sourceAPI.sent) != 1 {
+		t.Fatalf("expected one document, got %d messages", len(sourceAPI.sent))
+	}
+	doc, ok := sourceAPI.sent[0].(tgbotapi.DocumentConfig)
+	if !ok {
+		t.Fatalf("expected a document, got %T", sourceAPI.sent[0])
+	}
+	exported := doc.File.(tgbotapi.FileBytes).Bytes
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(exported)
+	}))
+	defer srv.Close()
+
+	target := newDownloadTestBot(t, 5*time.Second)
+	api := &mockTelegramAPI{fileURL: srv.URL}
+	target.client = api
+	target.allowedUsers = map[int64]bool{2: true, 3: true}
+	target.adminUsers = map[int64]bool{3: true}
+
+	target.handleAdminImport(importReply("users"), 3, 3)
+
+	if got := target.allowedUserIDs(); !slices.Equal(got, []int64{1, 2, 3}) {
+		t.Errorf("allowed users after import: %v", got)
+	}
+	if got := target.adminIDs(); !slices.Equal(got, []int64{1, 3}) {
+		t.Errorf("admins after import: %v", got)
+	}
+	if texts := api.texts(); len(texts) != 1 || !strings.Contains(texts[0], "Imported 1 new allowed users and 1 new admins") {
+		t.Errorf("unexpected reply %q", texts)
+	}
+
+	// Importing again adds nothing
+	target.handleAdminImport(importReply("users"), 3, 3)
+	if texts := api.texts(); !strings.Contains(texts[1], "Imported 0 new allowed users and 0 new admins") {
+		t.Errorf("unexpected reply to a repeated import %q", texts[1])
+	}
+}
+
+func TestParseUsersExportRejectsMalformed(t *testing.T) {
+	for _, data := range []string{
+		`not json`,
+		`{}`,
+		`{"allowed_users": ["1"]}`,
+		`{"allowed_users": [1], "extra": true}`,
+		`{"admin_users": [-5]}`,
+		`{"allowed_users": [1]} {"allowed_users": [2]}`,
+	} {
+		if _, err := parseUsersExport([]byte(data)); err == nil {
+			t.Errorf("expected %s to be rejected", data)
+		}
+	}
+
+	export, err := parseUsersExport([]byte(`{"admin_users": [7]}`))
+	if err != nil || len(export.AllowedUsers) != 0 || !slices.Equal(export.AdminUsers, []int64{7}) {
+		t.Errorf("unexpected result %+v, %v", export, err)
+	}
+}
+
+func TestAdminImportRequiresReply(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+
+	b.handleAdminImport(&tgbotapi.Message{Text: "/admin import"}, 1, 1)
+	if texts := api.texts(); len(texts) != 1 || !strings.HasPrefix(texts[0], "Usage:") {
+		t.Errorf("unexpected reply %q", texts)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// userDBName is the hidden file inside the storage root that persists per-user settings.
+const userDBName = ".users.json"
+
+// Media types users can be restricted to with /admin allow.
+var mediaTypes = []string{"documents", "photos", "videos", "animations", "audio", "voice", "video_notes", "stickers", "locations", "contacts", "polls"}
+
+// UserSettings holds the persisted per-user configuration.
+type UserSettings struct {
+	// AllowedTypes restricts which media types the user may store. Empty allows everything.
+	AllowedTypes []string `json:"allowed_types,omitempty"`
+	// Quiet replaces save replies with a summary per burst (/quiet).
+	Quiet bool `json:"quiet,omitempty"`
+	// Collision is what happens to a file arriving under a taken name
+	// (/setcollision); empty for the bot's default.
+	Collision string `json:"collision,omitempty"`
+}
+
+// UserDB is a small JSON-file store of per-user settings, safe for concurrent use.
+type UserDB struct {
+	path string
+
+	mu    sync.RWMutex
+	users map[int64]UserSettings
+}
+
+// OpenUserDB loads the user DB at path. A missing file yields an empty DB.
+func OpenUserDB(path string) (*UserDB, error) {
+	db := &UserDB{path: path, users: make(map[int64]UserSettings)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return db, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user DB: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &db.users); err != nil {
+		return nil, fmt.Errorf("failed to parse user DB: %w", err)
+	}
+	return db, nil
+}
+
+// Get returns the settings of a user (zero value if none are stored).
+func (db *UserDB) Get(userID int64) UserSettings {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.users[userID]
+}
+
+// Update applies fn to a user's settings and persists the DB. The change
+// only takes effect once it is saved.
+func (db *UserDB) Update(userID int64, fn func(*UserSettings)) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	settings := db.users[userID]
+	settings.AllowedTypes = slices.Clone(settings.AllowedTypes)
+	fn(&settings)
+
+	users := maps.Clone(db.users)
+	users[userID] = settings
+	if err := db.save(users); err != nil {
+		return err
+	}
+	db.users = users
+	return nil
+}
+
+// save writes users to the DB file atomically via a temporary file. Callers
+// must hold db.mu.
+func (db *UserDB) save(users map[int64]UserSettings) error {
+	data, err := json.MarshalIndent(users, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode user DB: %w", err)
+	}
+
+	return writeFileAtomic(db.path, data)
+}
+
+// parseMediaTypes parses a comma-separated list of media types. "all" clears the restriction.
+func parseMediaTypes(s string) ([]string, error) {
+	if strings.EqualFold(strings.TrimSpace(s), "all") {
+		return nil, nil
+	}
+
+	seen := make(map[string]bool)
+	var types []string
+	for _, t := range strings.Split(s, ",") {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || seen[t] {
+			continue
+		}
+		if !containsType(mediaTypes, t) {
+			return nil, fmt.Errorf("unknown media type %q", t)
+		}
+		seen[t] = true
+		types = append(types, t)
+	}
+	if len(types) == 0 {
+		return nil, fmt.Errorf("no media types given")
+	}
+
+	sort.Strings(types)
+	return types, nil
+}
+
+func containsType(types []string, t string) bool {
+	for _, candidate := range types {
+		if candidate == t {
+			return true
+		}
+	}
+	return false
+}
+
+// isMediaTypeAllowed reports whether a user may store the given media type.
+func (b *Bot) isMediaTypeAllowed(userID int64, mediaType string) bool {
+	allowed := b.users.Get(userID).AllowedTypes
+	return len(allowed) == 0 || containsType(allowed, mediaType)
+}
+
+// describeAllowedTypes returns a human-readable summary of a user's media permissions.
+func (b *Bot) describeAllowedTypes(userID int64) string {
+	allowed := b.users.Get(userID).AllowedTypes
+	if len(allowed) == 0 {
+		return "all media types"
+	}
+	return strings.Join(allowed, ", ")
+}
+
+// handleAdminAllow shows or sets the media types a user may store.
+func (b *Bot) handleAdminAllow(chatID int64, args []string) {
+	if len(args) == 0 {
+		b.sendTextMessage(chatID, fmt.Sprintf("Usage: /admin allow <user_id> [types|all]\nTypes: %s", strings.Join(mediaTypes, ", ")))
+		return
+	}
+
+	userID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		b.sendTextMessage(chatID, "❌ Invalid user ID format")
+		return
+	}
+
+	if len(args) == 1 {
+		b.sendTextMessage(chatID, fmt.Sprintf("📋 User %d may send: %s", userID, b.describeAllowedTypes(userID)))
+		return
+	}
+
+	types, err := parseMediaTypes(strings.Join(args[1:], ","))
+	if err != nil {
+		b.sendTextMessage(chatID, fmt.Sprintf("❌ %v. Valid types: %s", err, strings.Join(mediaTypes, ", ")))
+		return
+	}
+
+	if err := b.users.Update(userID, func(s *UserSettings) { s.AllowedTypes = types }); err != nil {
+		log.Printf("Error saving settings for user %d: %v", userID, err)
+		b.sendTextMessage(chatID, "❌ Failed to save user settings.")
+		return
+	}
+
+	b.sendTextMessage(chatID, fmt.Sprintf("✅ User %d may now send: %s", userID, b.describeAllowedTypes(userID)))
+	log.Printf("Admin %d set allowed media types of user %d to %s", chatID, userID, b.describeAllowedTypes(userID))
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestUserDBPersistsSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), userDBName)
+	db, err := OpenUserDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Update(42, func(s *UserSettings) { s.AllowedTypes = []string{"photos"} }); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	reopened, err := OpenUserDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reopened.Get(42).AllowedTypes; !reflect.DeepEqual(got, []string{"photos"}) {
+		t.Errorf("expected settings to survive a reload, got %v", got)
+	}
+	if got := reopened.Get(7).AllowedTypes; got != nil {
+		t.Errorf("expected no settings for an unknown user, got %v", got)
+	}
+}
+
+func TestUserDBKeepsSettingsWhenSaveFails(t *testing.T) {
+	dir := t.TempDir()
+	db, err := OpenUserDB(filepath.Join(dir, userDBName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Update(42, func(s *UserSettings) { s.AllowedTypes = []string{"photos"} }); err != nil {
+		t.Fatal(err)
+	}
+
+	// A file where the DB's folder should be makes every save fail
+	blocker := filepath.Join(dir, "blocker")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	db.path = filepath.Join(blocker, userDBName)
+	err = db.Update(42, func(s *UserSettings) {
+		s.AllowedTypes[0] = "videos"
+		s.Collision = CollisionReject
+	})
+	if err == nil {
+		t.Fatal("expected the update to fail")
+	}
+	if got := db.Get(42); !reflect.DeepEqual(got.AllowedTypes, []string{"photos"}) || got.Collision != "" {
+		t.Errorf("expected the failed update not to take effect, got %+v", got)
+	}
+}
+
+func TestParseMediaTypes(t *testing.T) {
+	got, err := parseMediaTypes("Photos, documents,photos")
+	if err != nil || !reflect.DeepEqual(got, []string{"documents", "photos"}) {
+		t.Errorf("parseMediaTypes = %v, %v", got, err)
+	}
+	if got, err := parseMediaTypes("all"); err != nil || got != nil {
+		t.Errorf("expected 'all' to clear the restriction, got %v, %v", got, err)
+	}
+	if _, err := parseMediaTypes("photos,executables"); err == nil {
+		t.Error("expected unknown types to be rejected")
+	}
+}
+
+func TestHandleMessageEnforcesAllowedTypes(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+	b.handleAdminAllow(1, []string{"42", "documents"})
+
+	b.handleMessage(&tgbotapi.Message{
+		From:  &tgbotapi.User{ID: 42},
+		Chat:  &tgbotapi.Chat{ID: 42},
+		Photo: []tgbotapi.PhotoSize{{FileID: "photo"}},
+	})
+
+	texts := api.texts()
+	last := texts[len(texts)-1]
+	if !strings.Contains(last, "not allowed to send photos") || !strings.Contains(last, "documents") {
+		t.Errorf("expected rejection naming the permitted types, got %q", last)
+	}
+
+	if !b.isMediaTypeAllowed(42, "documents") || b.isMediaTypeAllowed(42, "videos") {
+		t.Error("expected only documents to be allowed")
+	}
+	if !b.isMediaTypeAllowed(7, "videos") {
+		t.Error("expected users without restrictions to send anything")
+	}
+}
+
+func TestAdminAllowListsPermissions(t *testing.T) {
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+
+	b.handleAdminAllow(1, []string{"42"})
+	b.handleAdminAllow(1, []string{"42", "photos,videos"})
+	b.handleAdminAllow(1, []string{"42"})
+	b.handleAdminAllow(1, []string{"42", "all"})
+	b.handleAdminAllow(1, []string{"42"})
+
+	texts := api.texts()
+	want := []string{
+		"📋 User 42 may send: all media types",
+		"✅ User 42 may now send: photos, videos",
+		"📋 User 42 may send: photos, videos",
+		"✅ User 42 may now send: all media types",
+		"📋 User 42 may send: all media types",
+	}
+	if !reflect.DeepEqual(texts, want) {
+		t.Errorf("unexpected replies:\n%q\nwant:\n%q", texts, want)
+	}
+}
+
+func TestHandleMessageAllowedChats(t *testing.T) {
+	b := newTestBot(t)
+	b.allowedChats = map[int64]bool{-100: true}
+	api := &mockTelegramAPI{}
+	b.client = api
+
+	send := func(chatID, userID int64) {
+		b.handleMessage(&tgbotapi.Message{From: &tgbotapi.User{ID: userID}, Chat: &tgbotapi.Chat{ID: chatID}, Text: "/id"})
+	}
+	send(-100, 42) // the allowed group
+	send(-200, 42) // another group
+	send(42, 42)   // a private chat is a chat too
+
+	texts := api.texts()
+	if len(texts) != 3 || !strings.Contains(texts[0], "42") ||
+		texts[1] != "🚫 This bot is not enabled for this chat." || texts[2] != texts[1] {
+		t.Errorf("unexpected replies %q", texts)
+	}
+}
+
+func TestAllowedChatsComposeWithAllowedUsers(t *testing.T) {
+	b := newTestBot(t)
+	b.allowedChats = map[int64]bool{-100: true}
+	b.allowedUsers = map[int64]bool{42: true}
+	api := &mockTelegramAPI{}
+	b.client = api
+
+	b.handleMessage(&tgbotapi.Message{From: &tgbotapi.User{ID: 7}, Chat: &tgbotapi.Chat{ID: -100}, Text: "/id"})
+
+	if texts := api.texts(); len(texts) != 1 || !strings.Contains(texts[0], "Access Denied") {
+		t.Errorf("expected users outside ALLOWED_USERS to be refused in an allowed chat, got %q", texts)
+	}
+	if !newTestBot(t).isChatAllowed(-200) {
+		t.Error("expected every chat to be allowed without ALLOWED_CHATS")
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Build information, injected at build time:
+//
+//	go build -ldflags "-X main.Version=0.3.2 -X main.Commit=$(git rev-parse --short HEAD) -X main.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	Version   = "unknown"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+// formatVersion describes a build along with the running Go runtime and platform.
+func formatVersion(version, commit, buildDate string) string {
+	return fmt.Sprintf("tg-fsyn %s (commit %s, built %s, %s %s/%s)",
+		version, commit, buildDate, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
+
+// versionString describes the running binary.
+func versionString() string {
+	return formatVersion(Version, Commit, BuildDate)
+}
+
+func (b *Bot) handleVersionCommand(chatID int64) {
+	b.sendTextMessage(chatID, "ℹ️ "+versionString())
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestVersionCommandFormatsBuildInfo(t *testing.T) {
+	defer func(v, c, d string) { Version, Commit, BuildDate = v, c, d }(Version, Commit, BuildDate)
+	Version, Commit, BuildDate = "1.2.3", "abc1234", "2026-01-02T03:04:05Z"
+
+	b := newTestBot(t)
+	api := &mockTelegramAPI{}
+	b.client = api
+	b.handleVersionCommand(42)
+
+	texts := api.texts()
+	if len(texts) != 1 {
+		t.Fatalf("expected one reply, got %q", texts)
+	}
+	for _, want := range []string{"1.2.3", "abc1234", "2026-01-02T03:04:05Z", runtime.Version(), runtime.GOOS + "/" + runtime.GOARCH} {
+		if !strings.Contains(texts[0], want) {
+			t.Errorf("expected %q in %q", want, texts[0])
+		}
+	}
+}
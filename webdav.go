@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WebDAVStorage implements Storage on a WebDAV server such as Nextcloud.
+type WebDAVStorage struct {
+	client   *http.Client
+	baseURL  string
+	username string
+	password string
+}
+
+func NewWebDAVStorage(baseURL, username, password string) *WebDAVStorage {
+	return &WebDAVStorage{
+		client:   &http.Client{Timeout: 10 * time.Minute},
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		username: username,
+		password: password,
+	}
+}
+
+func (s *WebDAVStorage) Save(name string, r io.Reader) (int64, error) {
+	if err := s.ensureCollections(path.Dir(name)); err != nil {
+		return 0, err
+	}
+
+	// Check first so a taken name is reported before the body is consumed;
+	// If-None-Match still guards against a concurrent upload.
+	exists, err := s.exists(name)
+	if err != nil {
+		return 0, err
+	}
+	if exists {
+		return 0, fmt.Errorf("webdav upload %s: %w", name, os.ErrExist)
+	}
+
+	counter := &countingReader{r: r}
+	resp, err := s.do("PUT", s.url(name), counter, map[string]string{"If-None-Match": "*"})
+	if err != nil {
+		return counter.n, fmt.Errorf("webdav upload failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		return counter.n, nil
+	case http.StatusPreconditionFailed:
+		return counter.n, fmt.Errorf("webdav upload %s: %w", name, os.ErrExist)
+	default:
+		return counter.n, statusError("upload", resp)
+	}
+}
+
+func (s *WebDAVStorage) List(dir string) ([]storedFile, error) {
+	body := strings.NewReader(`<?xml version="1.0"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:getcontentlength/><d:getlastmodified/><d:resourcetype/></d:prop></d:propfind>`)
+
+	target := s.baseURL + "/"
+	if dir != "" {
+		target = s.url(dir) + "/"
+	}
+
+	resp, err := s.do("PROPFIND", target, body, map[string]string{
+		"Depth":        "1",
+		"Content-Type": "application/xml",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("webdav listing failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, statusError("listing", resp)
+	}
+
+	var ms davMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("failed to parse webdav listing: %w", err)
+	}
+
+	var files []storedFile
+	for _, r := range ms.Responses {
+		prop := r.prop()
+		if prop.ResourceType.Collection != nil || strings.HasSuffix(r.Href, "/") {
+			continue
+		}
+
+		href, err := url.PathUnescape(r.Href)
+		if err != nil {
+			href = r.Href
+		}
+		name := path.Base(strings.TrimRight(href, "/"))
+		if isHiddenName(name) {
+			continue
+		}
+
+		modTime, _ := http.ParseTime(prop.LastModified)
+		files = append(files, storedFile{
+			Name:    name,
+			Size:    prop.ContentLength,
+			ModTime: modTime,
+		})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime.After(files[j].ModTime)
+	})
+
+	return files, nil
+}
+
+func (s *WebDAVStorage) Delete(name string) error {
+	resp, err := s.do("DELETE", s.url(name), nil, nil)
+	if err != nil {
+		return fmt.Errorf("webdav delete failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusAccepted:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("webdav delete %s: %w", name, os.ErrNotExist)
+	default:
+		return statusError("delete", resp)
+	}
+}
+
+// exists reports whether a file is stored as name.
+func (s *WebDAVStorage) exists(name string) (bool, error) {
+	resp, err := s.do("HEAD", s.url(name), nil, nil)
+	if err != nil {
+		return false, fmt.Errorf("webdav lookup failed: %w", err)
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, statusError("lookup", resp)
+	}
+}
+
+// ensureCollections creates every collection along dir, ignoring ones that already exist.
+func (s *WebDAVStorage) ensureCollections(dir string) error {
+	if dir == "." || dir == "/" || dir == "" {
+		return nil
+	}
+
+	current := ""
+	for _, segment := range strings.Split(dir, "/") {
+		if segment == "" {
+			continue
+		}
+		current = path.Join(current, segment)
+
+		resp, err := s.do("MKCOL", s.url(current)+"/", nil, nil)
+		if err != nil {
+			return fmt.Errorf("webdav mkcol failed: %w", err)
+		}
+		resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusCreated, http.StatusMethodNotAllowed:
+			// Created, or already exists
+		default:
+			return statusError("mkcol", resp)
+		}
+	}
+	return nil
+}
+
+func (s *WebDAVStorage) do(method, target string, body io.Reader, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if s.username != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return s.client.Do(req)
+}
+
+// url builds the absolute URL for a slash-separated name, escaping each segment.
+func (s *WebDAVStorage) url(name string) string {
+	segments := strings.Split(strings.Trim(name, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return s.baseURL + "/" + strings.Join(segments, "/")
+}
+
+// statusError converts an unexpected WebDAV response into an error, mapping
+// well-known statuses to the storage sentinel errors.
+func statusError(op string, resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return fmt.Errorf("webdav %s: %w", op, ErrStorageUnauthorized)
+	case http.StatusForbidden:
+		return fmt.Errorf("webdav %s: %w", op, ErrStorageForbidden)
+	case http.StatusInsufficientStorage:
+		return fmt.Errorf("webdav %s: %w", op, ErrStorageFull)
+	default:
+		return fmt.Errorf("webdav %s: unexpected status %s", op, resp.Status)
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+type davMultistatus struct {
+	Responses []davResponse `xml:"response"`
+}
+
+type davResponse struct {
+	Href      string `xml:"href"`
+	Propstats []struct {
+		Status string  `xml:"status"`
+		Prop   davProp `xml:"prop"`
+	} `xml:"propstat"`
+}
+
+type davProp struct {
+	ContentLength int64  `xml:"getcontentlength"`
+	LastModified  string `xml:"getlastmodified"`
+	ResourceType  struct {
+		Collection *struct{} `xml:"collection"`
+	} `xml:"resourcetype"`
+}
+
+// prop returns the properties reported with a 200 status.
+func (r davResponse) prop() davProp {
+	for _, ps := range r.Propstats {
+		if strings.Contains(ps.Status, " 200 ") {
+			return ps.Prop
+		}
+	}
+	return davProp{}
+}
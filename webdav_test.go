@@ -0,0 +1,213 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeWebDAV is a minimal in-memory WebDAV server supporting HEAD, PUT, MKCOL, PROPFIND and DELETE.
+type fakeWebDAV struct {
+	mu          sync.Mutex
+	files       map[string][]byte
+	collections map[string]bool
+	methods     []string
+	status      int // when non-zero, every request fails with this status
+}
+
+func newFakeWebDAV(t *testing.T) (*fakeWebDAV, *httptest.Server) {
+	t.Helper()
+	dav := &fakeWebDAV{files: make(map[string][]byte), collections: map[string]bool{"/dav": true}}
+	srv := httptest.NewServer(dav)
+	t.Cleanup(srv.Close)
+	return dav, srv
+}
+
+func (d *fakeWebDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.methods = append(d.methods, r.Method+" "+r.URL.Path)
+
+	if user, pass, ok := r.BasicAuth(); !ok || user != "bot" || pass != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if d.status != 0 {
+		w.WriteHeader(d.status)
+		return
+	}
+
+	p := strings.TrimSuffix(r.URL.Path, "/")
+	parent := p[:strings.LastIndex(p, "/")]
+
+	switch r.Method {
+	case "MKCOL":
+		if d.collections[p] {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if !d.collections[parent] {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		d.collections[p] = true
+		w.WriteHeader(http.StatusCreated)
+	case "HEAD":
+		if _, ok := d.files[p]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	case "PUT":
+		if !d.collections[parent] {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		if _, ok := d.files[p]; ok && r.Header.Get("If-None-Match") == "*" {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		d.files[p] = data
+		w.WriteHeader(http.StatusCreated)
+	case "DELETE":
+		if _, ok := d.files[p]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(d.files, p)
+		w.WriteHeader(http.StatusNoContent)
+	case "PROPFIND":
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprintf(w, `<?xml version="1.0"?><d:multistatus xmlns:d="DAV:">`)
+		fmt.Fprintf(w, `<d:response><d:href>%s/</d:href><d:propstat><d:prop><d:resourcetype><d:collection/></d:resourcetype></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, p)
+		modified := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		for name, data := range d.files {
+			if name[:strings.LastIndex(name, "/")] != p {
+				continue
+			}
+			modified = modified.Add(time.Hour)
+			fmt.Fprintf(w, `<d:response><d:href>%s</d:href><d:propstat><d:prop><d:getcontentlength>%d</d:getcontentlength><d:getlastmodified>%s</d:getlastmodified><d:resourcetype/></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat><d:propstat><d:prop><d:quota-used-bytes/></d:prop><d:status>HTTP/1.1 404 Not Found</d:status></d:propstat></d:response>`,
+				strings.ReplaceAll(name, " ", "%20"), len(data), modified.Format(http.TimeFormat))
+		}
+		for name := range d.collections {
+			if name != p && strings.HasPrefix(name, p+"/") {
+				fmt.Fprintf(w, `<d:response><d:href>%s/</d:href><d:propstat><d:prop><d:resourcetype><d:collection/></d:resourcetype></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, name)
+			}
+		}
+		fmt.Fprint(w, `</d:multistatus>`)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestWebDAVSaveCreatesCollections(t *testing.T) {
+	dav, srv := newFakeWebDAV(t)
+	storage := NewWebDAVStorage(srv.URL+"/dav/", "bot", "secret")
+
+	n, err := storage.Save("photos/2024/my photo.jpg", strings.NewReader("image-bytes"))
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if n != int64(len("image-bytes")) {
+		t.Errorf("expected %d bytes written, got %d", len("image-bytes"), n)
+	}
+
+	if got := string(dav.files["/dav/photos/2024/my photo.jpg"]); got != "image-bytes" {
+		t.Errorf("unexpected stored content %q", got)
+	}
+	if !dav.collections["/dav/photos"] || !dav.collections["/dav/photos/2024"] {
+		t.Errorf("expected parent collections to be created, got %v", dav.collections)
+	}
+
+	// Saving again into the existing collections must tolerate 405 from MKCOL
+	if _, err := storage.Save("photos/2024/other.jpg", strings.NewReader("x")); err != nil {
+		t.Fatalf("second Save failed: %v", err)
+	}
+}
+
+func TestWebDAVListAndDelete(t *testing.T) {
+	dav, srv := newFakeWebDAV(t)
+	storage := NewWebDAVStorage(srv.URL+"/dav", "bot", "secret")
+
+	storage.Save("a.txt", strings.NewReader("aaa"))
+	storage.Save("sub/nested.txt", strings.NewReader("nested"))
+	dav.files["/dav/.hidden"] = []byte("internal")
+
+	files, err := storage.List("")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(files) != 1 || files[0].Name != "a.txt" || files[0].Size != 3 {
+		t.Fatalf("expected only a.txt (3 bytes), got %+v", files)
+	}
+	if files[0].ModTime.IsZero() {
+		t.Error("expected modification time to be parsed")
+	}
+
+	if err := storage.Delete("a.txt"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, ok := dav.files["/dav/a.txt"]; ok {
+		t.Error("expected a.txt to be deleted")
+	}
+
+	if err := storage.Delete("a.txt"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist deleting a missing file, got %v", err)
+	}
+}
+
+func TestWebDAVErrorMapping(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		user   string
+		want   error
+	}{
+		{"unauthorized", 0, "wrong", ErrStorageUnauthorized},
+		{"forbidden", http.StatusForbidden, "bot", ErrStorageForbidden},
+		{"insufficient storage", http.StatusInsufficientStorage, "bot", ErrStorageFull},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dav, srv := newFakeWebDAV(t)
+			dav.status = tt.status
+			storage := NewWebDAVStorage(srv.URL+"/dav", tt.user, "secret")
+
+			_, err := storage.Save("file.bin", strings.NewReader("data"))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+			if msg := errorReply(err, "generic"); msg == "generic" {
+				t.Errorf("expected a specific user-facing message for %v", tt.want)
+			}
+		})
+	}
+}
+
+func TestWebDAVSaveDoesNotOverwrite(t *testing.T) {
+	dav, srv := newFakeWebDAV(t)
+	storage := NewWebDAVStorage(srv.URL+"/dav", "bot", "secret")
+
+	storage.Save("a.txt", strings.NewReader("original"))
+
+	body := &countingReader{r: strings.NewReader("replacement")}
+	if _, err := storage.Save("a.txt", body); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("expected os.ErrExist, got %v", err)
+	}
+	if body.n != 0 {
+		t.Errorf("expected the body to be left unread, %d bytes consumed", body.n)
+	}
+	if got := string(dav.files["/dav/a.txt"]); got != "original" {
+		t.Errorf("expected original content to be kept, got %q", got)
+	}
+}